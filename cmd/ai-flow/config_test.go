@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/config"
+)
+
+func TestConfigJSONMasksSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	cfgYAML := `
+server:
+  admin_token: admin-tok
+linear:
+  api_key: lin-api-key
+  team_key: ENG
+  webhook_secret: wh-secret
+subprocess:
+  skip_command_check: true
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    prompt: Plan it carefully.
+    next_state: In Progress
+`
+	if err := os.WriteFile(path, []byte(cfgYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := configJSON(cfg.Redacted())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"admin-tok", "lin-api-key", "wh-secret", "Plan it carefully."} {
+		if strings.Contains(string(body), secret) {
+			t.Errorf("config JSON contains %q:\n%s", secret, body)
+		}
+	}
+	var doc struct {
+		Linear map[string]any `json:"linear"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Linear["api_key"] != "[redacted]" || doc.Linear["team_key"] != "ENG" {
+		t.Errorf("linear section = %v, want api_key masked and team_key shown", doc.Linear)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/jsonapi"
+	"github.com/mauza/ai-flow/internal/store"
+)
+
+// runExportColumns is the CSV header of GET /runs/export, in the order of
+// runExportRecord's fields.
+var runExportColumns = []string{
+	"id", "issue_id", "stage_name", "status", "exit_code", "output",
+	"pr_url", "branch_name", "error", "started_at", "ended_at", "audit",
+}
+
+// runExportRecord renders a run as a CSV row. Missing exit codes and end
+// times are empty; times are RFC 3339 in UTC.
+func runExportRecord(r store.RunRecord) []string {
+	exitCode, endedAt := "", ""
+	if r.ExitCode != nil {
+		exitCode = strconv.Itoa(*r.ExitCode)
+	}
+	if r.EndedAt != nil {
+		endedAt = r.EndedAt.UTC().Format(time.RFC3339)
+	}
+	return []string{
+		strconv.FormatInt(r.ID, 10), r.IssueID, r.StageName, r.Status, exitCode, r.Output,
+		r.PRURL, r.BranchName, r.Error, r.StartedAt.UTC().Format(time.RFC3339), endedAt, string(r.Audit),
+	}
+}
+
+// parseExportTime accepts an RFC 3339 timestamp or a YYYY-MM-DD date (UTC
+// midnight). An empty value is the zero time.
+func parseExportTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// handleRunExport serves GET /runs/export: runs started in [since, until) as
+// CSV (format=csv, the default) or JSON lines (format=jsonl), streamed from
+// the store as they're written.
+func handleRunExport(db *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		since, err := parseExportTime(q.Get("since"))
+		if err != nil {
+			jsonapi.Error(w, http.StatusBadRequest, "since must be an RFC 3339 time or YYYY-MM-DD date")
+			return
+		}
+		until, err := parseExportTime(q.Get("until"))
+		if err != nil {
+			jsonapi.Error(w, http.StatusBadRequest, "until must be an RFC 3339 time or YYYY-MM-DD date")
+			return
+		}
+
+		var write func(store.RunRecord) error
+		var flush func() error
+		switch format := q.Get("format"); format {
+		case "", "csv":
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", `attachment; filename="runs.csv"`)
+			cw := csv.NewWriter(w)
+			if err := cw.Write(runExportColumns); err != nil {
+				return
+			}
+			write = func(run store.RunRecord) error { return cw.Write(runExportRecord(run)) }
+			flush = func() error { cw.Flush(); return cw.Error() }
+		case "jsonl":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Content-Disposition", `attachment; filename="runs.jsonl"`)
+			enc := json.NewEncoder(w)
+			write = func(run store.RunRecord) error { return enc.Encode(run) }
+			flush = func() error { return nil }
+		default:
+			jsonapi.Error(w, http.StatusBadRequest, fmt.Sprintf("format must be csv or jsonl, got %q", format))
+			return
+		}
+
+		// The status line is already sent once rows stream, so a failure can
+		// only cut the export short
+		n := 0
+		err = db.ExportRuns(since, until, func(run store.RunRecord) error {
+			n++
+			return write(run)
+		})
+		if err == nil {
+			err = flush()
+		}
+		if err != nil {
+			slog.Error("exporting runs", "error", err, "rows", n)
+			return
+		}
+		slog.Debug("exported runs", "rows", n, "since", since, "until", until)
+	}
+}
@@ -2,23 +2,30 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"expvar"
 	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
 	"github.com/mauza/ai-flow/internal/config"
 	"github.com/mauza/ai-flow/internal/dashboard"
 	"github.com/mauza/ai-flow/internal/git"
+	"github.com/mauza/ai-flow/internal/github"
+	"github.com/mauza/ai-flow/internal/jsonapi"
 	"github.com/mauza/ai-flow/internal/linear"
 	"github.com/mauza/ai-flow/internal/orchestrator"
 	"github.com/mauza/ai-flow/internal/poller"
 	"github.com/mauza/ai-flow/internal/store"
 	"github.com/mauza/ai-flow/internal/subprocess"
+	"github.com/mauza/ai-flow/internal/telemetry"
+	"gopkg.in/yaml.v3"
 )
 
 func main() {
@@ -38,12 +45,19 @@ func main() {
 		os.Exit(1)
 	}
 	slog.Info("config loaded",
-		"port", cfg.Server.Port,
-		"team", cfg.Linear.TeamKey,
+		"addr", cfg.Server.ListenAddr(),
+		"teams", cfg.TeamKeys(),
 		"mode", cfg.Linear.Mode,
-		"stages", len(cfg.Pipeline),
+		"stages", stageCount(cfg),
 	)
 
+	// Tracing (no-op unless telemetry.otlp_endpoint is set)
+	shutdownTracing, err := telemetry.Setup(context.Background(), cfg.Telemetry.OTLPEndpoint)
+	if err != nil {
+		slog.Error("setting up tracing", "error", err)
+		os.Exit(1)
+	}
+
 	// Init store
 	db, err := store.New(*dbPath)
 	if err != nil {
@@ -63,44 +77,105 @@ func main() {
 
 	// Init Linear client and load workflow states
 	client := linear.NewClient(cfg.Linear.APIKey)
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	if err := client.LoadWorkflowStates(ctx, cfg.Linear.TeamKey); err != nil {
-		cancel()
-		slog.Error("loading workflow states from Linear", "error", err)
+	if err := client.SetHTTPOptions(linear.HTTPOptions{
+		ProxyURL:    cfg.Linear.ProxyURL,
+		TLSInsecure: cfg.Linear.TLSInsecure,
+		Timeout:     cfg.Linear.ParsedHTTPTimeout,
+	}); err != nil {
+		slog.Error("configuring Linear HTTP client", "error", err)
 		os.Exit(1)
 	}
+	client.SetRetryPolicy(cfg.Linear.MaxRetries, cfg.Linear.ParsedRetryMaxDelay)
+	client.SetExtraIssueFields(cfg.Linear.ExtraIssueFields)
+	client.SetExtraHeaders(cfg.Linear.ExtraHeaders)
+	client.SetVerifyTransition(cfg.Linear.VerifyTransition)
+	client.SetMaxLabels(cfg.Linear.MaxLabels)
+	if cfg.Linear.TLSInsecure {
+		slog.Warn("TLS certificate verification disabled for Linear API")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	for _, team := range cfg.TeamKeys() {
+		if err := client.LoadWorkflowStates(ctx, team); err != nil {
+			cancel()
+			slog.Error("loading workflow states from Linear", "error", err, "team", team)
+			os.Exit(1)
+		}
+	}
 	cancel()
 
-	// Validate that all pipeline states exist in Linear
-	for _, stage := range cfg.Pipeline {
-		if _, ok := client.ResolveStateID(stage.LinearState); !ok {
-			slog.Error("pipeline state not found in Linear",
-				"stage", stage.Name,
-				"linearState", stage.LinearState,
-			)
-			os.Exit(1)
+	// Adopt the registered webhook's signing secret instead of a copied one
+	if cfg.Linear.FetchWebhookSecret {
+		adminClient := client
+		if cfg.Linear.AdminAPIKey != "" {
+			adminClient = linear.NewClient(cfg.Linear.AdminAPIKey)
+			if err := adminClient.SetHTTPOptions(linear.HTTPOptions{
+				ProxyURL:    cfg.Linear.ProxyURL,
+				TLSInsecure: cfg.Linear.TLSInsecure,
+				Timeout:     cfg.Linear.ParsedHTTPTimeout,
+			}); err != nil {
+				slog.Error("configuring Linear HTTP client", "error", err)
+				os.Exit(1)
+			}
+			adminClient.SetRetryPolicy(cfg.Linear.MaxRetries, cfg.Linear.ParsedRetryMaxDelay)
+			adminClient.SetExtraHeaders(cfg.Linear.ExtraHeaders)
 		}
-		if _, ok := client.ResolveStateID(stage.NextState); !ok {
-			slog.Error("next state not found in Linear",
-				"stage", stage.Name,
-				"nextState", stage.NextState,
-			)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := adoptWebhookSecret(ctx, adminClient, cfg)
+		cancel()
+		if err != nil {
+			slog.Error("fetching webhook secret from Linear", "error", err)
 			os.Exit(1)
 		}
-		if stage.FailureState != "" {
-			if _, ok := client.ResolveStateID(stage.FailureState); !ok {
-				slog.Error("failure state not found in Linear",
+	}
+
+	// Validate that all pipeline states exist in their team's workflow
+	for _, team := range cfg.TeamKeys() {
+		for _, stage := range cfg.StagesFor(team) {
+			if !stage.IsEnabled() {
+				continue
+			}
+			if _, ok := client.ResolveStateID(team, stage.LinearState); !ok {
+				slog.Error("pipeline state not found in Linear",
+					"team", team,
+					"stage", stage.Name,
+					"linearState", stage.LinearState,
+				)
+				os.Exit(1)
+			}
+			if _, ok := client.ResolveStateID(team, stage.NextState); !ok {
+				slog.Error("next state not found in Linear",
+					"team", team,
 					"stage", stage.Name,
-					"failureState", stage.FailureState,
+					"nextState", stage.NextState,
 				)
 				os.Exit(1)
 			}
+			if stage.FailureState != "" {
+				if _, ok := client.ResolveStateID(team, stage.FailureState); !ok {
+					slog.Error("failure state not found in Linear",
+						"team", team,
+						"stage", stage.Name,
+						"failureState", stage.FailureState,
+					)
+					os.Exit(1)
+				}
+			}
+			if stage.RequeueState != "" {
+				if _, ok := client.ResolveStateID(team, stage.RequeueState); !ok {
+					slog.Error("requeue state not found in Linear",
+						"team", team,
+						"stage", stage.Name,
+						"requeueState", stage.RequeueState,
+					)
+					os.Exit(1)
+				}
+			}
 		}
 	}
 
 	// Validate project pipeline next_state values
 	for _, stage := range cfg.ProjectPipeline {
-		if _, ok := client.ResolveStateID(stage.NextState); !ok {
+		if _, ok := client.ResolveStateID(cfg.Linear.TeamKey, stage.NextState); !ok {
 			slog.Error("project pipeline next_state not found in Linear",
 				"stage", stage.Name,
 				"nextState", stage.NextState,
@@ -113,29 +188,89 @@ func main() {
 	var gitMgr *git.Manager
 	gitMgr, err = git.NewManager()
 	if err != nil {
-		slog.Warn("git manager not available, PR creation disabled", "error", err)
+		slog.Warn("git manager not available, stages that need git will fail", "error", err)
 		gitMgr = nil
 	} else {
-		slog.Info("git manager initialized")
+		gitMgr.Retries = *cfg.Git.Retries
+		gitMgr.RetryBackoff = cfg.Git.ParsedRetryBackoff
+		gitMgr.GHTimeout = cfg.Git.ParsedGHTimeout
+		gitMgr.MirrorRoot = cfg.Workspace.MirrorRoot
+		gitMgr.SigningKey = cfg.Git.SigningKey
+		gitMgr.SigningFormat = cfg.Git.SigningFormat
+		gitMgr.SetMaxConcurrent(cfg.Git.MaxConcurrent)
+		for _, p := range cfg.Projects {
+			if p.AuthorName != "" || p.AuthorEmail != "" {
+				gitMgr.SetIdentity(p.GithubRepo, p.AuthorName, p.AuthorEmail)
+			}
+			if p.ParsedGHTimeout > 0 {
+				gitMgr.SetGHTimeout(p.GithubRepo, p.ParsedGHTimeout)
+			}
+			if p.SigningKey != "" {
+				gitMgr.SetSigningKey(p.GithubRepo, p.SigningKey, p.SigningFormat)
+			}
+		}
+		slog.Info("git manager initialized", "retries", gitMgr.Retries)
 	}
 
 	// Init runner, session registry, and orchestrators
 	runner := subprocess.NewRunner(cfg.Subprocess.MaxConcurrent)
 	registry := dashboard.NewRegistry()
 	runner.SetTracker(registry)
+	if cfg.Subprocess.Nice != 0 || cfg.Subprocess.MaxMemoryMB > 0 {
+		if !subprocess.LimitsSupported {
+			slog.Warn("subprocess.nice and subprocess.max_memory_mb are only supported on Linux, ignoring them")
+		}
+		runner.SetLimits(subprocess.Limits{
+			Nice:           cfg.Subprocess.Nice,
+			MaxMemoryBytes: uint64(cfg.Subprocess.MaxMemoryMB) << 20,
+		})
+	}
 	orch := orchestrator.New(cfg, client, db, runner, gitMgr)
+	orch.CleanPartialWorkspaces()
 	var projectOrch *orchestrator.ProjectOrchestrator
 	if len(cfg.ProjectPipeline) > 0 {
 		projectOrch = orchestrator.NewProjectOrchestrator(cfg, client, db, runner)
 		slog.Info("project orchestrator initialized", "stages", len(cfg.ProjectPipeline))
 	}
 
+	var issuePoller *poller.Poller
+	if cfg.Linear.Mode == "poll" {
+		issuePoller = poller.New(cfg, client, orch)
+	}
+
 	// Set up HTTP server
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		fmt.Fprintf(w, `{"status":"ok","mode":%q}`, cfg.Linear.Mode)
 	})
+	var lastPoll func() time.Time
+	if issuePoller != nil {
+		lastPoll = issuePoller.LastPoll
+	}
+	mux.HandleFunc("GET /ready", handleReady(cfg.Linear.ParsedPollInterval, lastPoll))
+
+	// Runtime counters (expvar)
+	mux.Handle("GET /debug/vars", expvar.Handler())
+
+	// Effective config, for debugging (admin only)
+	if cfg.Server.AdminToken != "" {
+		mux.Handle("GET /config", requireAdmin(cfg.Server.AdminToken, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := configJSON(cfg.Redacted())
+			if err != nil {
+				slog.Error("encoding config", "error", err)
+				jsonapi.Error(w, http.StatusInternalServerError, "internal error")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(body)
+		})))
+		mux.Handle("GET /runs/export", requireAdmin(cfg.Server.AdminToken, handleRunExport(db)))
+	}
+
+	// Reverse lookup from a PR back to the runs that produced it
+	mux.HandleFunc("GET /runs", handleRunsByPR(db))
+	mux.HandleFunc("GET /runs/{id}", handleGetRun(db))
 
 	// Dashboard UI
 	dash := dashboard.New(registry, db, dashboard.WebDist)
@@ -144,21 +279,33 @@ func main() {
 
 	if cfg.Linear.Mode == "webhook" {
 		mux.HandleFunc("POST /webhook", linear.NewWebhookHandler(
-			cfg.Linear.WebhookSecret,
-			func(payload linear.WebhookPayload) {
-				switch payload.Type {
-				case "Issue":
-					orch.HandleWebhook(context.Background(), payload)
-				case "Comment":
-					orch.HandleCommentWebhook(context.Background(), payload)
-				}
+			cfg.Linear.WebhookSecrets,
+			cfg.Linear.ParsedMaxTimestampDrift,
+			func(payload linear.WebhookPayload) error {
+				return orch.Dispatch(context.Background(), payload)
+			},
+		))
+
+		// Handle deliveries the previous process accepted but never started
+		if n, err := orch.ResumePending(context.Background()); err != nil {
+			slog.Warn("resuming pending webhook deliveries", "error", err)
+		} else if n > 0 {
+			slog.Info("resuming pending webhook deliveries", "count", n)
+		}
+	}
+
+	if cfg.GitHub.WebhookSecret != "" {
+		mux.HandleFunc("POST /github-webhook", github.NewWebhookHandler(
+			cfg.GitHub.WebhookSecret,
+			func(event github.PullRequestEvent) {
+				orch.HandlePullRequestClosed(context.Background(), event)
 			},
 		))
 	}
 
 	server := &http.Server{
-		Addr:        fmt.Sprintf(":%d", cfg.Server.Port),
-		Handler:     mux,
+		Addr:        cfg.Server.ListenAddr(),
+		Handler:     logRequests(mux),
 		ReadTimeout: 10 * time.Second,
 		// WriteTimeout is 0 so SSE connections can stream indefinitely.
 		// Individual handlers are responsible for their own timeouts.
@@ -169,10 +316,33 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	// Keep local git mirrors fresh so clones only fetch recent objects
+	if gitMgr != nil && gitMgr.MirrorRoot != "" {
+		go gitMgr.RunMirrorRefresher(ctx, cfg.Workspace.ParsedMirrorRefresh)
+	}
+
+	// Pick up workflow states renamed or added in Linear
+	if d := cfg.Linear.ParsedStateRefreshInterval; d > 0 {
+		go client.RunStateRefresher(ctx, d)
+	}
+
+	// Act on wait_for_approval stages left unanswered past approval_timeout
+	if orch.HasApprovalTimeouts() {
+		go orch.RunApprovalSweeper(ctx)
+	}
+
+	// Mirror PR state onto issues as pr-open/pr-merged/pr-closed labels
+	if cfg.TracksAnyPRState() {
+		if gitMgr != nil {
+			go orch.RunPRStateReconciler(ctx, cfg.GitHub.ParsedPRStateInterval)
+		} else {
+			slog.Warn("track_pr_state is set but git is unavailable, not tracking PR state")
+		}
+	}
+
 	// Start poller in poll mode
-	if cfg.Linear.Mode == "poll" {
-		p := poller.New(cfg, client, orch)
-		go p.Run(ctx)
+	if issuePoller != nil {
+		go issuePoller.Run(ctx)
 	}
 
 	// Start project poller if project pipeline is configured (always polls, regardless of mode)
@@ -198,6 +368,46 @@ func main() {
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		slog.Error("shutdown error", "error", err)
 	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("flushing traces", "error", err)
+	}
 
 	slog.Info("shutdown complete")
 }
+
+// adoptWebhookSecret looks up the webhook registered for cfg's team and puts
+// its signing secret first in cfg.Linear.WebhookSecrets.
+func adoptWebhookSecret(ctx context.Context, client *linear.Client, cfg *config.Config) error {
+	hook, err := client.FindWebhook(ctx, cfg.Linear.TeamKey, cfg.Linear.WebhookURL)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(cfg.Linear.WebhookSecrets, hook.Secret) {
+		cfg.Linear.WebhookSecrets = append([]string{hook.Secret}, cfg.Linear.WebhookSecrets...)
+	}
+	slog.Info("using signing secret of registered webhook", "url", hook.URL, "webhookID", hook.ID)
+	return nil
+}
+
+// configJSON renders a config as JSON using its YAML field names, so the
+// output mirrors the config file (plus applied defaults).
+func configJSON(cfg *config.Config) ([]byte, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// stageCount returns how many pipeline stages the served teams have in total.
+func stageCount(cfg *config.Config) int {
+	n := 0
+	for _, team := range cfg.TeamKeys() {
+		n += len(cfg.StagesFor(team))
+	}
+	return n
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// captureLog sends slog's default logger to a buffer for the rest of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func TestLogRequestsRecordsStatus(t *testing.T) {
+	buf := captureLog(t)
+	h := logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/webhook", nil))
+
+	var entry struct {
+		Level  string `json:"level"`
+		Msg    string `json:"msg"`
+		Method string `json:"method"`
+		Path   string `json:"path"`
+		Status int    `json:"status"`
+		Bytes  int    `json:"bytes"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("parsing log %q: %v", buf, err)
+	}
+	if entry.Msg != "http request" || entry.Method != "POST" || entry.Path != "/webhook" {
+		t.Errorf("log entry = %+v", entry)
+	}
+	if entry.Status != http.StatusTeapot || entry.Bytes != len("short and stout") {
+		t.Errorf("logged status %d and %d bytes, want 418 and %d", entry.Status, entry.Bytes, len("short and stout"))
+	}
+	if entry.Level != "INFO" {
+		t.Errorf("non-2xx logged at %s, want INFO", entry.Level)
+	}
+}
+
+func TestLogRequestsKeepsHealthAtDebug(t *testing.T) {
+	buf := captureLog(t)
+	h := logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	var entry struct {
+		Level  string `json:"level"`
+		Status int    `json:"status"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("parsing log %q: %v", buf, err)
+	}
+	if entry.Level != "DEBUG" || entry.Status != http.StatusServiceUnavailable {
+		t.Errorf("health probe logged as %+v, want DEBUG with status 503", entry)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// handleReady serves /ready. Readiness fails when the poll loop has gone
+// three intervals without a successful query (e.g. wedged on a hung
+// request); lastPoll is nil in webhook mode, where there is no poll loop.
+func handleReady(interval time.Duration, lastPoll func() time.Time) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if lastPoll != nil {
+			if age := time.Since(lastPoll()); age > 3*interval {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprintf(w, `{"status":"poller stalled","last_poll_age":%q}`, age.Round(time.Second).String())
+				return
+			}
+		}
+		fmt.Fprint(w, `{"status":"ready"}`)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadiness(t *testing.T) {
+	const interval = 30 * time.Second
+	for _, tc := range []struct {
+		name     string
+		lastPoll func() time.Time
+		status   int
+		want     string
+	}{
+		{"webhook mode", nil, http.StatusOK, "ready"},
+		{"fresh poll", func() time.Time { return time.Now().Add(-interval) }, http.StatusOK, "ready"},
+		{"stale poll", func() time.Time { return time.Now().Add(-3*interval - time.Second) }, http.StatusServiceUnavailable, "poller stalled"},
+	} {
+		rec := httptest.NewRecorder()
+		handleReady(interval, tc.lastPoll)(rec, httptest.NewRequest(http.MethodGet, "/ready", nil))
+		if rec.Code != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.name, rec.Code, tc.status)
+		}
+		var body map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s: body %q is not JSON: %v", tc.name, rec.Body, err)
+			continue
+		}
+		if body["status"] != tc.want {
+			t.Errorf("%s: status %q, want %q", tc.name, body["status"], tc.want)
+		}
+		if tc.status != http.StatusOK && body["last_poll_age"] != "1m31s" {
+			t.Errorf("%s: last_poll_age %q, want 1m31s", tc.name, body["last_poll_age"])
+		}
+	}
+}
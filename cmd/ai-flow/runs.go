@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/mauza/ai-flow/internal/jsonapi"
+	"github.com/mauza/ai-flow/internal/store"
+)
+
+// handleRunsByPR serves GET /runs?pr=<url>: the runs that produced a PR, for
+// a reverse lookup from GitHub back to the issue.
+func handleRunsByPR(db *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		prURL := r.URL.Query().Get("pr")
+		if prURL == "" {
+			jsonapi.Error(w, http.StatusBadRequest, "missing pr query parameter")
+			return
+		}
+		runs, err := db.GetRunsByPRURL(prURL)
+		if err != nil {
+			slog.Error("querying runs by pr url", "pr_url", prURL, "error", err)
+			jsonapi.Error(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if runs == nil {
+			runs = []store.RunRecord{}
+		}
+		jsonapi.Write(w, runs)
+	}
+}
+
+// handleGetRun serves GET /runs/{id}.
+func handleGetRun(db *store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			jsonapi.Error(w, http.StatusBadRequest, "invalid id")
+			return
+		}
+		run, err := db.GetRun(id)
+		if err != nil {
+			slog.Error("getting run", "id", id, "error", err)
+			jsonapi.Error(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		if run == nil {
+			jsonapi.Error(w, http.StatusNotFound, "run not found")
+			return
+		}
+		jsonapi.Write(w, run)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/store"
+)
+
+func newTestStore(t *testing.T) *store.Store {
+	t.Helper()
+	db, err := store.New(filepath.Join(t.TempDir(), "ai-flow.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// serveAPI sends a GET for path through the API routes that need no token,
+// and the admin-only ones behind requireAdmin with token "admin".
+func serveAPI(t *testing.T, db *store.Store, path string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /runs", handleRunsByPR(db))
+	mux.HandleFunc("GET /runs/{id}", handleGetRun(db))
+	mux.Handle("GET /runs/export", requireAdmin("admin", handleRunExport(db)))
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIErrorsAreJSON(t *testing.T) {
+	db := newTestStore(t)
+	admin := http.Header{"Authorization": {"Bearer admin"}}
+	for _, tc := range []struct {
+		path   string
+		header http.Header
+		status int
+		error  string
+	}{
+		{"/runs/42", nil, http.StatusNotFound, "run not found"},
+		{"/runs/abc", nil, http.StatusBadRequest, "invalid id"},
+		{"/runs", nil, http.StatusBadRequest, "missing pr query parameter"},
+		{"/runs/export", nil, http.StatusUnauthorized, "unauthorized"},
+		{"/runs/export?since=yesterday", admin, http.StatusBadRequest, "since must be an RFC 3339 time or YYYY-MM-DD date"},
+		{"/runs/export?format=xml", admin, http.StatusBadRequest, `format must be csv or jsonl, got "xml"`},
+	} {
+		rec := serveAPI(t, db, tc.path, tc.header)
+		if rec.Code != tc.status {
+			t.Errorf("GET %s: status %d, want %d", tc.path, rec.Code, tc.status)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("GET %s: Content-Type %q, want application/json", tc.path, ct)
+		}
+		var body map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Errorf("GET %s: body %q is not JSON: %v", tc.path, rec.Body, err)
+			continue
+		}
+		if body["error"] != tc.error {
+			t.Errorf("GET %s: error %q, want %q", tc.path, body["error"], tc.error)
+		}
+	}
+}
+
+func TestGetRun(t *testing.T) {
+	db := newTestStore(t)
+	id, _, err := db.StartRun("issue-1", "plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := serveAPI(t, db, "/runs/"+strconv.FormatInt(id, 10), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	var run store.RunRecord
+	if err := json.Unmarshal(rec.Body.Bytes(), &run); err != nil {
+		t.Fatal(err)
+	}
+	if run.ID != id || run.StageName != "plan" {
+		t.Errorf("run = %+v", run)
+	}
+}
+
+func TestRunsByPR(t *testing.T) {
+	db := newTestStore(t)
+	const pr = "https://github.com/acme/app/pull/7"
+	id, _, err := db.StartRun("issue-1", "implement")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CompleteRun(id, 0, "done", pr, "eng-1-fix"); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serveAPI(t, db, "/runs?pr="+url.QueryEscape(pr), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	var runs []store.RunRecord
+	if err := json.Unmarshal(rec.Body.Bytes(), &runs); err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].ID != id || runs[0].IssueID != "issue-1" {
+		t.Errorf("runs = %+v, want the implement run", runs)
+	}
+}
+
+func TestRunExportCSV(t *testing.T) {
+	db := newTestStore(t)
+	planned, _, err := db.StartRun("issue-1", "plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CompleteRun(planned, 0, "line one,\n\"quoted\"", "", "eng-1-fix"); err != nil {
+		t.Fatal(err)
+	}
+	failed, _, err := db.StartRun("issue-2", "implement")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.FailRun(failed, 2, "boom"); err != nil {
+		t.Fatal(err)
+	}
+
+	admin := http.Header{"Authorization": {"Bearer admin"}}
+	rec := serveAPI(t, db, "/runs/export?format=csv&since=2020-01-01", admin)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want the header and 2 rows: %q", len(records), records)
+	}
+	if want := "id,issue_id,stage_name,status,exit_code,output,pr_url,branch_name,error,started_at,ended_at,audit"; strings.Join(records[0], ",") != want {
+		t.Errorf("header = %q, want %q", records[0], want)
+	}
+
+	row := func(record []string) map[string]string {
+		m := make(map[string]string, len(record))
+		for i, v := range record {
+			m[records[0][i]] = v
+		}
+		return m
+	}
+	first, second := row(records[1]), row(records[2])
+	if first["id"] != strconv.FormatInt(planned, 10) || first["stage_name"] != "plan" || first["status"] != "completed" ||
+		first["exit_code"] != "0" || first["output"] != "line one,\n\"quoted\"" || first["branch_name"] != "eng-1-fix" {
+		t.Errorf("first row = %v", first)
+	}
+	if second["id"] != strconv.FormatInt(failed, 10) || second["issue_id"] != "issue-2" || second["status"] != "failed" ||
+		second["exit_code"] != "2" || second["error"] != "boom" {
+		t.Errorf("second row = %v", second)
+	}
+	for _, r := range []map[string]string{first, second} {
+		if _, err := time.Parse(time.RFC3339, r["started_at"]); err != nil {
+			t.Errorf("started_at %q: %v", r["started_at"], err)
+		}
+		if _, err := time.Parse(time.RFC3339, r["ended_at"]); err != nil {
+			t.Errorf("ended_at %q: %v", r["ended_at"], err)
+		}
+	}
+
+	// Runs before since are left out
+	rec = serveAPI(t, db, "/runs/export?since="+time.Now().Add(time.Hour).UTC().Format(time.RFC3339), admin)
+	if records, err := csv.NewReader(rec.Body).ReadAll(); err != nil || len(records) != 1 {
+		t.Errorf("export after since = %q, %v; want only the header", records, err)
+	}
+}
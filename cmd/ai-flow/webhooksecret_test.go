@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/config"
+	"github.com/mauza/ai-flow/internal/linear"
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+func TestAdoptWebhookSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	cfgYAML := `
+linear:
+  api_key: lin-api-key
+  team_key: ENG
+  fetch_webhook_secret: true
+  webhook_url: https://ai-flow.example.com/webhook
+subprocess:
+  skip_command_check: true
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    prompt: Plan it.
+    next_state: In Progress
+`
+	if err := os.WriteFile(path, []byte(cfgYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := testutil.NewLinear(t, "Todo", "In Progress")
+	fake.Handle = func(req linear.GraphQLRequest) (any, bool) {
+		if !strings.Contains(req.Query, "webhooks(") {
+			return nil, false
+		}
+		return map[string]any{"webhooks": map[string]any{"nodes": []any{
+			map[string]any{"id": "wh-other", "url": "https://other.example.com/hook", "enabled": true, "secret": "other-secret", "team": map[string]any{"key": "ENG"}},
+			map[string]any{"id": "wh-1", "url": "https://ai-flow.example.com/webhook", "enabled": true, "secret": "registered-secret", "team": map[string]any{"key": "ENG"}},
+		}}}, true
+	}
+
+	if err := adoptWebhookSecret(context.Background(), fake.Client(), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"registered-secret"}; !slices.Equal(cfg.Linear.WebhookSecrets, want) {
+		t.Errorf("webhook secrets = %q, want %q", cfg.Linear.WebhookSecrets, want)
+	}
+
+}
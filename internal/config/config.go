@@ -1,56 +1,557 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"net"
+	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/mauza/ai-flow/internal/linear"
 )
 
 type Config struct {
-	Server          ServerConfig          `yaml:"server"`
-	Linear          LinearConfig          `yaml:"linear"`
-	Pipeline        []StageConfig         `yaml:"pipeline"`
-	ProjectPipeline []ProjectStageConfig  `yaml:"project_pipeline"`
-	Subprocess      SubprocessConfig      `yaml:"subprocess"`
-	Workspace       WorkspaceConfig       `yaml:"workspace"`
+	Server          ServerConfig         `yaml:"server"`
+	Linear          LinearConfig         `yaml:"linear"`
+	Pipeline        PipelineConfig       `yaml:"pipeline"`
+	ProjectPipeline []ProjectStageConfig `yaml:"project_pipeline"`
+	Subprocess      SubprocessConfig     `yaml:"subprocess"`
+	Workspace       WorkspaceConfig      `yaml:"workspace"`
+	Git             GitConfig            `yaml:"git"`
+	GitHub          GitHubConfig         `yaml:"github"`
+	Notify          NotifyConfig         `yaml:"notify"`
+	Telemetry       TelemetryConfig      `yaml:"telemetry"`
+
+	// Projects maps a Linear project name (or team key) to the repo its issues
+	// work on, so issue descriptions don't need repo frontmatter.
+	Projects map[string]ProjectRepoConfig `yaml:"projects"`
+
+	// dir is the directory prompt file paths are relative to: the config
+	// file's for a Load-ed config, the working directory otherwise.
+	dir string
+}
+
+// ProjectRepoConfig is the GitHub repo used for issues of a Linear project or team.
+type ProjectRepoConfig struct {
+	GithubRepo    string `yaml:"github_repo"`
+	DefaultBranch string `yaml:"default_branch"`
+
+	// BaseBranchByLabel sends issues with a given label to a different base
+	// branch (e.g. hotfix → release). The first matching entry wins.
+	BaseBranchByLabel []LabelBranch `yaml:"base_branch_by_label"`
+
+	// AuthorName and AuthorEmail replace ai-flow's commit identity in clones
+	// of this repo. Either may be set alone.
+	AuthorName  string `yaml:"author_name"`
+	AuthorEmail string `yaml:"author_email"`
+
+	// GHTimeout overrides git.gh_timeout for gh calls against this repo.
+	GHTimeout       string        `yaml:"gh_timeout"`
+	ParsedGHTimeout time.Duration `yaml:"-"`
+
+	// TrackPRState overrides github.track_pr_state for PRs on this repo.
+	TrackPRState *bool `yaml:"track_pr_state"`
+
+	// OnPRMergedState overrides github.on_pr_merged_state for PRs on this repo.
+	OnPRMergedState string `yaml:"on_pr_merged_state"`
+
+	// NormalizeCommits overrides git.normalize_commits for pushes to this repo.
+	NormalizeCommits *bool `yaml:"normalize_commits"`
+
+	// CommitIncludeDescription overrides git.commit_include_description for
+	// commits to this repo.
+	CommitIncludeDescription *bool `yaml:"commit_include_description"`
+
+	// SigningKey and SigningFormat replace git.signing_key and
+	// git.signing_format for commits in clones of this repo.
+	SigningKey    string `yaml:"signing_key"`
+	SigningFormat string `yaml:"signing_format"`
+}
+
+// LabelBranch maps an issue label to the base branch its PRs target.
+type LabelBranch struct {
+	Label  string `yaml:"label"`
+	Branch string `yaml:"branch"`
+}
+
+// BaseBranchFor returns the base branch for an issue with the given labels:
+// the branch of the first base_branch_by_label entry whose label the issue
+// has, or DefaultBranch ("" if unset, meaning the repo's own default).
+func (p ProjectRepoConfig) BaseBranchFor(labels []string) string {
+	for _, lb := range p.BaseBranchByLabel {
+		for _, l := range labels {
+			if strings.EqualFold(l, lb.Label) {
+				return lb.Branch
+			}
+		}
+	}
+	return p.DefaultBranch
+}
+
+// projectForRepo returns the projects entry whose github_repo is repo.
+// Projects sharing a repo agree on its repo-wide settings, so any will do.
+func (c *Config) projectForRepo(repo string) (ProjectRepoConfig, bool) {
+	for _, p := range c.Projects {
+		if p.GithubRepo == repo {
+			return p, true
+		}
+	}
+	return ProjectRepoConfig{}, false
+}
+
+// TrackPRState reports whether PR state labels are kept for PRs on repo:
+// its project's track_pr_state, or github.track_pr_state.
+func (c *Config) TrackPRState(repo string) bool {
+	if p, ok := c.projectForRepo(repo); ok && p.TrackPRState != nil {
+		return *p.TrackPRState
+	}
+	return c.GitHub.TrackPRState
+}
+
+// OnPRMergedState returns the state an issue moves to when its PR on repo is
+// merged: its project's on_pr_merged_state, or github.on_pr_merged_state.
+func (c *Config) OnPRMergedState(repo string) string {
+	if p, ok := c.projectForRepo(repo); ok && p.OnPRMergedState != "" {
+		return p.OnPRMergedState
+	}
+	return c.GitHub.OnPRMergedState
+}
+
+// NormalizeCommits reports whether commits pushed to repo are rewritten to
+// ai-flow's identity: its project's normalize_commits, or
+// git.normalize_commits.
+func (c *Config) NormalizeCommits(repo string) bool {
+	if p, ok := c.projectForRepo(repo); ok && p.NormalizeCommits != nil {
+		return *p.NormalizeCommits
+	}
+	return c.Git.NormalizeCommits
+}
+
+// CommitIncludeDescription reports whether commits ai-flow makes on repo
+// carry the issue's description: its project's commit_include_description,
+// or git.commit_include_description.
+func (c *Config) CommitIncludeDescription(repo string) bool {
+	if p, ok := c.projectForRepo(repo); ok && p.CommitIncludeDescription != nil {
+		return *p.CommitIncludeDescription
+	}
+	return c.Git.CommitIncludeDescription
+}
+
+// TracksAnyPRState reports whether any repo has PR state tracking on.
+func (c *Config) TracksAnyPRState() bool {
+	if c.GitHub.TrackPRState {
+		return true
+	}
+	for _, p := range c.Projects {
+		if p.TrackPRState != nil && *p.TrackPRState {
+			return true
+		}
+	}
+	return false
+}
+
+// NotifyConfig configures outbound notifications sent outside Linear.
+type NotifyConfig struct {
+	// EscalationURL receives a JSON POST when a stage failure matches the
+	// stage's escalate_on conditions.
+	EscalationURL string `yaml:"escalation_url"`
+}
+
+// TelemetryConfig enables OpenTelemetry tracing.
+type TelemetryConfig struct {
+	// OTLPEndpoint is the OTLP/HTTP collector URL spans are exported to
+	// (e.g. "http://localhost:4318"). Empty disables tracing.
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+}
+
+// GitConfig controls how git network operations (clone, fetch, push) behave.
+type GitConfig struct {
+	Retries            *int          `yaml:"retries"` // nil → default; 0 disables retries
+	RetryBackoff       string        `yaml:"retry_backoff"`
+	ParsedRetryBackoff time.Duration `yaml:"-"`
+	MaxConcurrent      int           `yaml:"max_concurrent"` // 0 = unlimited
+
+	// GHTimeout bounds each gh CLI call (PR create, view, comment, merge).
+	GHTimeout       string        `yaml:"gh_timeout"`
+	ParsedGHTimeout time.Duration `yaml:"-"`
+
+	// NormalizeCommits rewrites the commits a run is about to push so they
+	// carry ai-flow's identity and a Generated-by: ai-flow trailer, including
+	// any the subprocess made itself.
+	NormalizeCommits bool `yaml:"normalize_commits"`
+
+	// CommitIncludeDescription adds the issue's description to the body of
+	// the commits ai-flow makes.
+	CommitIncludeDescription bool `yaml:"commit_include_description"`
+
+	// SigningKey signs every commit made in clones, for repos whose branch
+	// protection requires signed commits. SigningFormat is "openpgp"
+	// (default; a GPG key ID) or "ssh" (a public key path or "key::" literal).
+	SigningKey    string `yaml:"signing_key"`
+	SigningFormat string `yaml:"signing_format"`
+}
+
+// GitHubConfig controls how ai-flow follows the PRs its runs open.
+type GitHubConfig struct {
+	// TrackPRState polls the state of every open PR a run created every
+	// PRStateInterval (default 5m) and mirrors it onto the Linear issue as a
+	// pr-open, pr-merged, or pr-closed label.
+	TrackPRState          bool          `yaml:"track_pr_state"`
+	PRStateInterval       string        `yaml:"pr_state_interval"`
+	ParsedPRStateInterval time.Duration `yaml:"-"`
+
+	// WebhookSecret enables POST /github-webhook, which verifies deliveries
+	// with it. When a run's PR is merged, its issue moves to OnPRMergedState.
+	WebhookSecret   string `yaml:"webhook_secret"`
+	OnPRMergedState string `yaml:"on_pr_merged_state"`
 }
 
 type WorkspaceConfig struct {
 	Root string `yaml:"root"`
+
+	// UseWorktrees keeps one primary clone per repo under Root and adds a
+	// git worktree per branch instead of a full clone.
+	UseWorktrees bool `yaml:"use_worktrees"`
+
+	// MirrorRoot holds local bare mirrors of each repo that clones reference,
+	// refreshed every MirrorRefresh (default 10m).
+	MirrorRoot          string        `yaml:"mirror_root"`
+	MirrorRefresh       string        `yaml:"mirror_refresh"`
+	ParsedMirrorRefresh time.Duration `yaml:"-"`
+
+	// DownloadAttachments saves an issue's Linear attachments into the
+	// workspace before a git stage runs, up to AttachmentsMaxMB in total
+	// (default 50).
+	DownloadAttachments bool `yaml:"download_attachments"`
+	AttachmentsMaxMB    int  `yaml:"attachments_max_mb"`
+
+	// CancelStates are Linear states that end an issue's pipeline: moving an
+	// issue into one stops its in-flight runs and removes its workspaces.
+	CancelStates []string `yaml:"cancel_states"`
 }
 
 type ServerConfig struct {
-	Port int `yaml:"port"`
+	Host string `yaml:"host"` // empty = all interfaces
+	Port int    `yaml:"port"`
+
+	// RedactIssueContent keeps issue titles, descriptions, and subprocess
+	// output out of logs; only identifiers are logged.
+	RedactIssueContent bool `yaml:"redact_issue_content"`
+
+	// AdminToken enables admin endpoints (e.g. GET /config), which require it
+	// as a bearer token. Empty leaves them unregistered.
+	AdminToken string `yaml:"admin_token"`
+}
+
+// ListenAddr returns the host:port address for the HTTP server, bracketing
+// IPv6 literals as needed.
+func (s ServerConfig) ListenAddr() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
 }
 
 type LinearConfig struct {
 	APIKey             string        `yaml:"api_key"`
 	WebhookSecret      string        `yaml:"webhook_secret"`
+	WebhookSecrets     []string      `yaml:"webhook_secrets"` // extra accepted secrets during rotation; webhook_secret is prepended
 	TeamKey            string        `yaml:"team_key"`
 	Mode               string        `yaml:"mode"`
 	PollInterval       string        `yaml:"poll_interval"`
 	ParsedPollInterval time.Duration `yaml:"-"`
+	PollConcurrency    int           `yaml:"poll_concurrency"` // issues processed at once in poll mode
+
+	// HeartbeatInterval enables a single progress comment that is edited with
+	// the latest subprocess output while a stage runs.
+	HeartbeatInterval       string        `yaml:"heartbeat_interval"`
+	ParsedHeartbeatInterval time.Duration `yaml:"-"`
+
+	// PostStartComment posts a status comment when a stage starts, with the
+	// stage's typical duration from past runs.
+	PostStartComment bool `yaml:"post_start_comment"`
+
+	// RerunMinInterval ignores comment-triggered re-runs that arrive less
+	// than this long after the stage's previous run for the issue.
+	RerunMinInterval       string        `yaml:"rerun_min_interval"`
+	ParsedRerunMinInterval time.Duration `yaml:"-"`
+
+	// CommentMode is "per_stage" (default: each stage posts its own comment)
+	// or "consolidated" (one comment per issue, with a section per stage).
+	CommentMode string `yaml:"comment_mode"`
+
+	// StateRefreshInterval reloads the team's workflow states this often, to
+	// pick up states renamed or added in Linear while ai-flow runs.
+	StateRefreshInterval       string        `yaml:"state_refresh_interval"`
+	ParsedStateRefreshInterval time.Duration `yaml:"-"`
+
+	// StatusLabels are issue labels showing a run's phase on Linear boards.
+	StatusLabels StatusLabelsConfig `yaml:"status_labels"`
+
+	// FetchWebhookSecret reads the signing secret of the team's registered
+	// webhook from the Linear API at startup, using AdminAPIKey (default
+	// APIKey). WebhookURL picks the webhook when the team has several.
+	FetchWebhookSecret bool   `yaml:"fetch_webhook_secret"`
+	AdminAPIKey        string `yaml:"admin_api_key"`
+	WebhookURL         string `yaml:"webhook_url"`
+
+	// VerifyTransition re-fetches an issue after each state change and logs
+	// a warning if it didn't land in the target state.
+	VerifyTransition bool `yaml:"verify_transition"`
+
+	// MaxLabels caps how many labels are read per issue (default 50); issues
+	// with more keep the first MaxLabels by name.
+	MaxLabels int `yaml:"max_labels"`
+
+	// MaxTimestampDrift is how old a webhook delivery may be before it is
+	// rejected as a possible replay (default 60s).
+	MaxTimestampDrift       string        `yaml:"max_timestamp_drift"`
+	ParsedMaxTimestampDrift time.Duration `yaml:"-"`
+
+	// WebhookDebounce holds an issue's state-change webhook this long before
+	// handling it; later changes to the same issue in that window are folded
+	// into it. Empty handles each delivery immediately.
+	WebhookDebounce       string        `yaml:"webhook_debounce"`
+	ParsedWebhookDebounce time.Duration `yaml:"-"`
+
+	// HTTP client settings for the Linear API. Without ProxyURL the standard
+	// HTTP(S)_PROXY environment variables apply.
+	ProxyURL          string        `yaml:"proxy_url"`
+	TLSInsecure       bool          `yaml:"tls_insecure"`
+	HTTPTimeout       string        `yaml:"http_timeout"`
+	ParsedHTTPTimeout time.Duration `yaml:"-"`
+
+	// ExtraHeaders are sent with every Linear API request, e.g. a token a
+	// gateway in front of Linear requires. They can't replace Content-Type
+	// or Authorization.
+	ExtraHeaders map[string]string `yaml:"extra_headers"`
+
+	// MaxRetries is the total attempts per Linear API request (default 3);
+	// RetryMaxDelay caps the jittered exponential backoff between them.
+	MaxRetries          int           `yaml:"max_retries"`
+	RetryMaxDelay       string        `yaml:"retry_max_delay"`
+	ParsedRetryMaxDelay time.Duration `yaml:"-"`
+
+	// AssigneeFilter, if set, limits processing to issues assigned to this
+	// user (matched by Linear user ID or email). Unassigned issues are skipped.
+	AssigneeFilter string `yaml:"assignee_filter"`
+
+	// SkipBotIssues ignores issues created by one of BotUsers (Linear user
+	// IDs or emails), so issues a stage files can't feed the pipeline.
+	SkipBotIssues bool     `yaml:"skip_bot_issues"`
+	BotUsers      []string `yaml:"bot_users"`
+
+	// RetryInstructions is appended to failure comments to tell users how to
+	// re-run a stage (e.g. "Comment /retry to re-run").
+	RetryInstructions string `yaml:"retry_instructions"`
+
+	// MentionAssigneeOnFailure starts failure comments with an @-mention of
+	// the issue's assignee, so they get notified.
+	MentionAssigneeOnFailure bool `yaml:"mention_assignee_on_failure"`
+
+	// ExtraIssueFields are additional Issue fields, as dotted paths (e.g.
+	// "estimate", "cycle.name"), fetched with every issue and passed to
+	// commands under "extra" in the stdin JSON.
+	ExtraIssueFields []string `yaml:"extra_issue_fields"`
+}
+
+// DefaultMaxLabels is linear.max_labels when it isn't set.
+const DefaultMaxLabels = 50
+
+// MaxLabelsLimit is the largest linear.max_labels: one page of an issue's
+// labels, as Linear returns at most 250 nodes per page.
+const MaxLabelsLimit = 250
+
+// StatusLabelsConfig names the labels linear.status_labels applies: Queued
+// from when a run is recorded until its command starts, then Running until
+// the run ends. Either may be empty to skip that phase.
+type StatusLabelsConfig struct {
+	Queued  string `yaml:"queued"`
+	Running string `yaml:"running"`
+}
+
+// allowedExtraIssueFields lists the fields linear.extra_issue_fields may
+// request: scalars on the issue and names on related objects, nothing that
+// pulls in large or sensitive data.
+var allowedExtraIssueFields = []string{
+	"archivedAt",
+	"assignee.displayName",
+	"assignee.name",
+	"branchName",
+	"canceledAt",
+	"completedAt",
+	"createdAt",
+	"creator.displayName",
+	"creator.name",
+	"customerTicketCount",
+	"cycle.endsAt",
+	"cycle.name",
+	"cycle.number",
+	"cycle.startsAt",
+	"dueDate",
+	"estimate",
+	"number",
+	"parent.identifier",
+	"parent.title",
+	"priorityLabel",
+	"projectMilestone.name",
+	"projectMilestone.targetDate",
+	"slaBreachesAt",
+	"snoozedUntilAt",
+	"startedAt",
+	"triagedAt",
+	"updatedAt",
+}
+
+// PipelineConfig holds the pipeline stages plus settings that apply to every
+// stage. In YAML it may be written either as a plain list of stages or as a
+// mapping with a "stages" key alongside the settings.
+type PipelineConfig struct {
+	Stages               []StageConfig `yaml:"stages"`
+	HandlerTimeout       string        `yaml:"handler_timeout"`
+	ParsedHandlerTimeout time.Duration `yaml:"-"`
+
+	// MaxRuntimePerIssue caps the total time runs may spend on one issue,
+	// across all stages and retries. Empty means no cap.
+	MaxRuntimePerIssue       string        `yaml:"max_runtime_per_issue"`
+	ParsedMaxRuntimePerIssue time.Duration `yaml:"-"`
+
+	// MaxCycles caps how many times an issue may loop back to a stage it
+	// already ran. 0 means no cap.
+	MaxCycles int `yaml:"max_cycles"`
+
+	// OnMissingRepo decides what happens when a git stage matches an issue
+	// with no repo to work in (no project or team in the projects map and no
+	// github_repo in the description): "fail" (default) runs the stage's
+	// failure path, "skip" leaves the issue where it is with a comment.
+	OnMissingRepo string `yaml:"on_missing_repo"`
+
+	// OnCompleteCommand is the on_complete_command for stages that don't set
+	// their own.
+	OnCompleteCommand string   `yaml:"on_complete_command"`
+	OnCompleteArgs    []string `yaml:"on_complete_args"`
+
+	// Teams maps a Linear team key to that team's own stages. Teams not
+	// listed here use Stages.
+	Teams map[string][]StageConfig `yaml:"teams"`
+}
+
+// UnmarshalYAML accepts both the flat list form and the mapping form.
+func (p *PipelineConfig) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.SequenceNode {
+		return value.Decode(&p.Stages)
+	}
+	type plain PipelineConfig
+	return value.Decode((*plain)(p))
 }
 
 type StageConfig struct {
-	Name        string   `yaml:"name"`
-	LinearState string   `yaml:"linear_state"`
-	Command     string   `yaml:"command"`
-	Args        []string `yaml:"args"`
-	PromptFile  string   `yaml:"prompt_file"`
-	Prompt      string   `yaml:"-"` // resolved from PromptFile at load time
-	NextState   string   `yaml:"next_state"`
-	Timeout     int      `yaml:"timeout"`
-	Labels          []string `yaml:"labels"`
-	CreatesPR       bool     `yaml:"creates_pr"`
-	UsesBranch      bool     `yaml:"uses_branch"`
-	FailureState    string   `yaml:"failure_state"`
-	WaitForApproval bool     `yaml:"wait_for_approval"`
+	Name             string   `yaml:"name"`
+	LinearState      string   `yaml:"linear_state"`
+	Command          string   `yaml:"command"`
+	Args             []string `yaml:"args"`
+	PromptFile       string   `yaml:"prompt_file"` // path relative to the config file, or an http(s) URL
+	Prompt           string   `yaml:"prompt"`      // inline prompt; otherwise resolved from PromptFile at load time
+	NextState        string   `yaml:"next_state"`
+	Timeout          int      `yaml:"timeout"`
+	Labels           []string `yaml:"labels"`
+	LabelMatch       string   `yaml:"label_match"` // "any" (default) or "all" of labels must be present
+	CreatesPR        bool     `yaml:"creates_pr"`
+	UsesBranch       bool     `yaml:"uses_branch"`
+	FailureState     string   `yaml:"failure_state"`
+	WaitForApproval  bool     `yaml:"wait_for_approval"`
+	AllowEmptyPrompt bool     `yaml:"allow_empty_prompt"` // command needs no instructions (e.g. a plain script)
+	MergesPR         bool     `yaml:"merges_pr"`          // merge the issue's PR after a successful run (requires uses_branch)
+	OnConflict       string   `yaml:"on_conflict"`        // merges_pr only: "fail" (default) or "requeue"
+	RequeueState     string   `yaml:"requeue_state"`      // state to move the issue to when on_conflict is "requeue"
+	FailureCooldown  string   `yaml:"failure_cooldown"`   // refuse to re-run the stage this long after a failure (e.g. "30m")
+	BranchFrom       string   `yaml:"branch_from"`        // creates_pr only: "base" (default) or "previous" to stack on the issue's last branch
+	OnMissingBranch  string   `yaml:"on_missing_branch"`  // uses_branch only: "fail" (default) or "recreate" when the branch was deleted on the remote
+	PRReviewers      []string `yaml:"pr_reviewers"`       // requested on PRs the stage opens (users or org/team)
+	PRAssignees      []string `yaml:"pr_assignees"`       // assigned to PRs the stage opens
+	PRLabels         []string `yaml:"pr_labels"`          // added to PRs the stage opens; labels missing on the repo are skipped
+	ReviewCommand    string   `yaml:"review_command"`     // git stages: second pass that must exit 0 before changes are committed
+	ReviewArgs       []string `yaml:"review_args"`
+	ReviewPromptFile string   `yaml:"review_prompt_file"`
+	ReviewPrompt     string   `yaml:"-"`              // resolved from ReviewPromptFile at load time
+	Model            string   `yaml:"model"`          // AIFLOW_MODEL for this stage (default subprocess.model)
+	Provider         string   `yaml:"provider"`       // AIFLOW_PROVIDER for this stage (default subprocess.provider)
+	EscalateOn       []string `yaml:"escalate_on"`    // notify.escalation_url gets a page for: "failure", "timeout", "repeated"
+	EscalateAfter    int      `yaml:"escalate_after"` // consecutive failures that count as "repeated" (default 3)
+
+	// PriorityOverrides swaps command/args/timeout for issues of a given
+	// Linear priority ("urgent", "high", "medium", "low", "none").
+	PriorityOverrides map[string]PriorityOverride `yaml:"priority_overrides"`
+
+	// IncludeStderrOnSuccess appends stderr to the success comment and stored
+	// output, for tools that report progress or summaries there.
+	IncludeStderrOnSuccess bool `yaml:"include_stderr_on_success"`
+
+	// CreateBranchIfMissing lets a uses_branch stage start the issue's branch
+	// from the base branch when no earlier stage created one. The branch is
+	// pushed without a PR.
+	CreateBranchIfMissing bool `yaml:"create_branch_if_missing"`
+
+	// PreviewOnly runs a git stage in a throwaway clone and posts the diff as
+	// a comment instead of committing, pushing, or opening a PR.
+	PreviewOnly bool `yaml:"preview_only"`
+
+	// CloneDepth is how many commits of history git stages clone and fetch:
+	// nil → default of 1, 0 clones full history (e.g. for changelogs built
+	// from git log).
+	CloneDepth *int `yaml:"clone_depth"`
+
+	// RerunOnDescription re-runs the stage when the issue's description is
+	// edited while it sits in this stage's state (webhook mode only).
+	RerunOnDescription bool `yaml:"rerun_on_description"`
+
+	// FailureCommentTemplate replaces the default failure comment. It is a
+	// text/template with .Stage, .Error, and .IssueURL.
+	FailureCommentTemplate string `yaml:"failure_comment_template"`
+
+	// ApprovalTimeout bounds how long a wait_for_approval stage may sit
+	// without a response before ApprovalTimeoutAction is taken: "fail"
+	// (default), "auto_approve" to move on to next_state, or "escalate".
+	ApprovalTimeout       string `yaml:"approval_timeout"`
+	ApprovalTimeoutAction string `yaml:"approval_timeout_action"`
+
+	// CommentTarget picks which issue gets the stage's output comment:
+	// "self" (default, the triggering issue), "parent", or "children".
+	CommentTarget string `yaml:"comment_target"`
+
+	// Enabled, when set to false, keeps the stage in the config but out of
+	// the pipeline: no issue is matched to it and startup doesn't check its
+	// states or command. Defaults to true.
+	Enabled *bool `yaml:"enabled"`
+
+	// PromptArg controls how the prompt reaches the command: "positional"
+	// (default, the final arg), a flag name such as "--prompt" to pass it as
+	// that flag's value, or "none" to leave it to AIFLOW_PROMPT or stdin.
+	PromptArg string `yaml:"prompt_arg"`
+
+	// OnCompleteCommand runs in the background once the stage has finished
+	// and the issue has been moved and commented on, with the outcome in
+	// AIFLOW_RESULT. Defaults to pipeline.on_complete_command.
+	OnCompleteCommand string   `yaml:"on_complete_command"`
+	OnCompleteArgs    []string `yaml:"on_complete_args"`
+
+	ParsedFailureCooldown time.Duration `yaml:"-"`
+	ParsedApprovalTimeout time.Duration `yaml:"-"`
+}
+
+// PriorityOverride replaces a stage's command settings for matching issues.
+// Unset fields keep the stage's values.
+type PriorityOverride struct {
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+	Timeout int      `yaml:"timeout"`
 }
 
 type ProjectStageConfig struct {
@@ -58,8 +559,8 @@ type ProjectStageConfig struct {
 	Label      string   `yaml:"label"`
 	Command    string   `yaml:"command"`
 	Args       []string `yaml:"args"`
-	PromptFile string   `yaml:"prompt_file"`
-	Prompt     string   `yaml:"-"` // resolved from PromptFile at load time
+	PromptFile string   `yaml:"prompt_file"` // path relative to the config file, or an http(s) URL
+	Prompt     string   `yaml:"prompt"`      // inline prompt; otherwise resolved from PromptFile at load time
 	NextState  string   `yaml:"next_state"`
 	Timeout    int      `yaml:"timeout"`
 }
@@ -75,49 +576,320 @@ func (psc *ProjectStageConfig) ParsedTimeout() time.Duration {
 type SubprocessConfig struct {
 	ContextMode   string `yaml:"context_mode"`
 	MaxConcurrent int    `yaml:"max_concurrent"`
+
+	// SkipCommandCheck disables the startup check that every stage command is
+	// on PATH, for setups where commands only exist inside another runtime.
+	SkipCommandCheck bool `yaml:"skip_command_check"`
+
+	// Model and Provider are the defaults for stages that don't set their
+	// own, passed to commands as AIFLOW_MODEL and AIFLOW_PROVIDER.
+	Model    string `yaml:"model"`
+	Provider string `yaml:"provider"`
+
+	// SkipUnchanged reuses the last successful run's output instead of
+	// running the command again when its inputs are identical and it finished
+	// within SkipUnchangedWindow (default 1h).
+	SkipUnchanged             bool          `yaml:"skip_unchanged"`
+	SkipUnchangedWindow       string        `yaml:"skip_unchanged_window"`
+	ParsedSkipUnchangedWindow time.Duration `yaml:"-"`
+
+	// AuditInputs records on each run which context its command was given:
+	// the prompt's hash and the names of env vars and stdin fields.
+	AuditInputs bool `yaml:"audit_inputs"`
+
+	// Nice and MaxMemoryMB deprioritize and cap every command, so heavy runs
+	// can't starve the host. Linux only; ignored elsewhere.
+	Nice        int `yaml:"nice"`
+	MaxMemoryMB int `yaml:"max_memory_mb"`
 }
 
 // Load reads and parses a YAML config file, expanding environment variables.
 // Prompt file paths are resolved relative to the config file's directory.
+// A config with several problems returns them all, joined by errors.Join.
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("reading config file: %w", err)
 	}
 
-	expanded := os.ExpandEnv(string(data))
+	expanded := expandEnv(string(data))
 
 	var cfg Config
 	if err := yaml.Unmarshal([]byte(expanded), &cfg); err != nil {
 		return nil, fmt.Errorf("parsing config: %w", err)
 	}
 
-	configDir := filepath.Dir(path)
-	if err := cfg.validate(configDir); err != nil {
+	cfg.dir = filepath.Dir(path)
+	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("validating config: %w", err)
 	}
 
 	return &cfg, nil
 }
 
-func (c *Config) validate(configDir string) error {
-	// Defaults
+// expandEnv replaces $VAR and ${VAR} in the config text with the host's
+// environment at load time, and $$ with a literal $ so that variables meant
+// for the command at run time (e.g. $$AIFLOW_PROMPT in a sh -c arg) survive.
+// Prompt files are read separately and never expanded.
+func expandEnv(s string) string {
+	return os.Expand(s, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		return os.Getenv(name)
+	})
+}
+
+// Validate applies defaults and checks the whole config, reporting every
+// problem found (joined with errors.Join) rather than only the first. A check
+// that depends on a value already reported as invalid is skipped.
+func (c *Config) Validate() error {
+	configDir := c.dir
+	errs := []error{
+		c.validateServer(),
+		c.validateSubprocess(),
+		c.validateGit(),
+		c.validateGitHub(),
+		c.validateProjects(),
+		c.validateLinear(),
+		c.validatePipeline(),
+		c.validateTelemetry(),
+		c.validateWorkspace(),
+	}
+
+	// Check each stage list on its own; a linear_state may repeat across teams
+	for _, list := range c.stageLists() {
+		errs = append(errs, c.validateStages(configDir, list.path, list.stages))
+	}
+	errs = append(errs, c.validateProjectPipeline(configDir))
+
+	if !c.Subprocess.SkipCommandCheck {
+		errs = append(errs, c.checkCommands())
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateServer() error {
+	var errs []error
 	if c.Server.Port == 0 {
 		c.Server.Port = 8080
 	}
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be between 1 and 65535, got %d", c.Server.Port))
+	}
+	// Accept "[::1]" as well as "::1"
+	c.Server.Host = strings.TrimSuffix(strings.TrimPrefix(c.Server.Host, "["), "]")
+	if c.Server.Host != "" {
+		invalidIPv6 := strings.Contains(c.Server.Host, ":") && net.ParseIP(c.Server.Host) == nil
+		if invalidIPv6 || strings.ContainsAny(c.Server.Host, " /[]") {
+			errs = append(errs, fmt.Errorf("server.host %q is not a valid IP address or hostname", c.Server.Host))
+		} else if _, _, err := net.SplitHostPort(c.Server.ListenAddr()); err != nil {
+			errs = append(errs, fmt.Errorf("server.host/port: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateSubprocess() error {
+	var errs []error
 	if c.Subprocess.ContextMode == "" {
 		c.Subprocess.ContextMode = "env"
 	}
+	switch c.Subprocess.ContextMode {
+	case "env", "stdin", "both", "stdin-ndjson":
+	default:
+		errs = append(errs, fmt.Errorf("subprocess.context_mode must be env, stdin, both, or stdin-ndjson; got %q", c.Subprocess.ContextMode))
+	}
 	if c.Subprocess.MaxConcurrent == 0 {
 		c.Subprocess.MaxConcurrent = 3
 	}
+	if c.Subprocess.SkipUnchanged {
+		if c.Subprocess.SkipUnchangedWindow == "" {
+			c.Subprocess.SkipUnchangedWindow = "1h"
+		}
+		d, err := time.ParseDuration(c.Subprocess.SkipUnchangedWindow)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("subprocess.skip_unchanged_window: %w", err))
+		case d <= 0:
+			errs = append(errs, fmt.Errorf("subprocess.skip_unchanged_window must be positive, got %s", d))
+		default:
+			c.Subprocess.ParsedSkipUnchangedWindow = d
+		}
+	}
+	if c.Subprocess.Nice < -20 || c.Subprocess.Nice > 19 {
+		errs = append(errs, fmt.Errorf("subprocess.nice must be between -20 and 19, got %d", c.Subprocess.Nice))
+	}
+	if c.Subprocess.MaxMemoryMB < 0 {
+		errs = append(errs, fmt.Errorf("subprocess.max_memory_mb must not be negative, got %d", c.Subprocess.MaxMemoryMB))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateGit() error {
+	var errs []error
+	if c.Git.Retries == nil {
+		retries := 2
+		c.Git.Retries = &retries
+	}
+	if *c.Git.Retries < 0 {
+		errs = append(errs, fmt.Errorf("git.retries must not be negative, got %d", *c.Git.Retries))
+	}
+	if c.Git.RetryBackoff == "" {
+		c.Git.RetryBackoff = "2s"
+	}
+	if retryBackoff, err := time.ParseDuration(c.Git.RetryBackoff); err != nil {
+		errs = append(errs, fmt.Errorf("git.retry_backoff: %w", err))
+	} else {
+		c.Git.ParsedRetryBackoff = retryBackoff
+	}
+	if c.Git.MaxConcurrent < 0 {
+		errs = append(errs, fmt.Errorf("git.max_concurrent must not be negative, got %d", c.Git.MaxConcurrent))
+	}
+	if c.Git.GHTimeout == "" {
+		c.Git.GHTimeout = "1m"
+	}
+	ghTimeout, err := time.ParseDuration(c.Git.GHTimeout)
+	switch {
+	case err != nil:
+		errs = append(errs, fmt.Errorf("git.gh_timeout: %w", err))
+	case ghTimeout <= 0:
+		errs = append(errs, fmt.Errorf("git.gh_timeout must be positive, got %s", ghTimeout))
+	default:
+		c.Git.ParsedGHTimeout = ghTimeout
+	}
+	switch c.Git.SigningFormat {
+	case "":
+		if c.Git.SigningKey != "" {
+			c.Git.SigningFormat = "openpgp"
+		}
+	case "openpgp", "ssh":
+		if c.Git.SigningKey == "" {
+			errs = append(errs, fmt.Errorf("git.signing_format requires git.signing_key"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("git.signing_format must be \"openpgp\" or \"ssh\", got %q", c.Git.SigningFormat))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateGitHub() error {
+	var errs []error
+	if c.GitHub.PRStateInterval == "" {
+		c.GitHub.PRStateInterval = "5m"
+	}
+	prStateInterval, err := time.ParseDuration(c.GitHub.PRStateInterval)
+	switch {
+	case err != nil:
+		errs = append(errs, fmt.Errorf("github.pr_state_interval: %w", err))
+	case prStateInterval <= 0:
+		errs = append(errs, fmt.Errorf("github.pr_state_interval must be positive, got %s", prStateInterval))
+	default:
+		c.GitHub.ParsedPRStateInterval = prStateInterval
+	}
+	if c.GitHub.OnPRMergedState != "" && c.GitHub.WebhookSecret == "" {
+		errs = append(errs, fmt.Errorf("github.on_pr_merged_state requires github.webhook_secret"))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateProjects() error {
+	var errs []error
+	identities := make(map[string]ProjectRepoConfig) // repo → project that set its identity
+	ghTimeouts := make(map[string]time.Duration)     // repo → gh_timeout set by a project
+	trackPRState := make(map[string]bool)            // repo → track_pr_state set by a project
+	mergedStates := make(map[string]string)          // repo → on_pr_merged_state set by a project
+	normalize := make(map[string]bool)               // repo → normalize_commits set by a project
+	descriptions := make(map[string]bool)            // repo → commit_include_description set by a project
+	signing := make(map[string]ProjectRepoConfig)    // repo → project that set its signing key
+	for _, name := range slices.Sorted(maps.Keys(c.Projects)) {
+		p := c.Projects[name]
+		if p.GithubRepo == "" {
+			errs = append(errs, fmt.Errorf("projects[%q].github_repo is required", name))
+			continue
+		}
+		for i, lb := range p.BaseBranchByLabel {
+			if lb.Label == "" || lb.Branch == "" {
+				errs = append(errs, fmt.Errorf("projects[%q].base_branch_by_label[%d] requires label and branch", name, i))
+			}
+		}
+		if p.GHTimeout != "" {
+			d, err := time.ParseDuration(p.GHTimeout)
+			switch {
+			case err != nil:
+				errs = append(errs, fmt.Errorf("projects[%q].gh_timeout: %w", name, err))
+			case d <= 0:
+				errs = append(errs, fmt.Errorf("projects[%q].gh_timeout must be positive, got %s", name, d))
+			default:
+				// gh calls are per repo, so projects sharing one must agree
+				if other, ok := ghTimeouts[p.GithubRepo]; ok && other != d {
+					errs = append(errs, fmt.Errorf("projects[%q]: gh_timeout conflicts with another project using %s", name, p.GithubRepo))
+				}
+				ghTimeouts[p.GithubRepo] = d
+				p.ParsedGHTimeout = d
+				c.Projects[name] = p
+			}
+		}
+		if p.TrackPRState != nil {
+			if other, ok := trackPRState[p.GithubRepo]; ok && other != *p.TrackPRState {
+				errs = append(errs, fmt.Errorf("projects[%q]: track_pr_state conflicts with another project using %s", name, p.GithubRepo))
+			}
+			trackPRState[p.GithubRepo] = *p.TrackPRState
+		}
+		if p.OnPRMergedState != "" {
+			if c.GitHub.WebhookSecret == "" {
+				errs = append(errs, fmt.Errorf("projects[%q].on_pr_merged_state requires github.webhook_secret", name))
+			}
+			if other, ok := mergedStates[p.GithubRepo]; ok && other != p.OnPRMergedState {
+				errs = append(errs, fmt.Errorf("projects[%q]: on_pr_merged_state conflicts with another project using %s", name, p.GithubRepo))
+			}
+			mergedStates[p.GithubRepo] = p.OnPRMergedState
+		}
+		if p.NormalizeCommits != nil {
+			if other, ok := normalize[p.GithubRepo]; ok && other != *p.NormalizeCommits {
+				errs = append(errs, fmt.Errorf("projects[%q]: normalize_commits conflicts with another project using %s", name, p.GithubRepo))
+			}
+			normalize[p.GithubRepo] = *p.NormalizeCommits
+		}
+		if p.CommitIncludeDescription != nil {
+			if other, ok := descriptions[p.GithubRepo]; ok && other != *p.CommitIncludeDescription {
+				errs = append(errs, fmt.Errorf("projects[%q]: commit_include_description conflicts with another project using %s", name, p.GithubRepo))
+			}
+			descriptions[p.GithubRepo] = *p.CommitIncludeDescription
+		}
+		switch {
+		case p.SigningFormat != "" && p.SigningFormat != "openpgp" && p.SigningFormat != "ssh":
+			errs = append(errs, fmt.Errorf("projects[%q].signing_format must be \"openpgp\" or \"ssh\", got %q", name, p.SigningFormat))
+		case p.SigningFormat != "" && p.SigningKey == "":
+			errs = append(errs, fmt.Errorf("projects[%q].signing_format requires signing_key", name))
+		case p.SigningKey != "":
+			// Signing is configured per clone, so projects sharing a repo must agree
+			if other, ok := signing[p.GithubRepo]; ok && (other.SigningKey != p.SigningKey || other.SigningFormat != p.SigningFormat) {
+				errs = append(errs, fmt.Errorf("projects[%q]: signing_key/signing_format conflict with another project using %s", name, p.GithubRepo))
+			}
+			signing[p.GithubRepo] = p
+		}
+		if p.AuthorName == "" && p.AuthorEmail == "" {
+			continue
+		}
+		// Clones are per repo, so every project on a repo must agree on its identity
+		if other, ok := identities[p.GithubRepo]; ok && (other.AuthorName != p.AuthorName || other.AuthorEmail != p.AuthorEmail) {
+			errs = append(errs, fmt.Errorf("projects[%q]: author_name/author_email conflict with another project using %s", name, p.GithubRepo))
+		}
+		identities[p.GithubRepo] = p
+	}
+	return errors.Join(errs...)
+}
 
+func (c *Config) validateLinear() error {
+	var errs []error
 	// Required fields
 	if c.Linear.APIKey == "" {
-		return fmt.Errorf("linear.api_key is required")
+		errs = append(errs, fmt.Errorf("linear.api_key is required"))
 	}
 	if c.Linear.TeamKey == "" {
-		return fmt.Errorf("linear.team_key is required")
+		errs = append(errs, fmt.Errorf("linear.team_key is required"))
 	}
 
 	// Default mode to webhook
@@ -126,136 +898,758 @@ func (c *Config) validate(configDir string) error {
 	}
 	switch c.Linear.Mode {
 	case "webhook":
-		if c.Linear.WebhookSecret == "" {
-			return fmt.Errorf("linear.webhook_secret is required when mode is \"webhook\"")
+		var secrets []string
+		for _, secret := range append([]string{c.Linear.WebhookSecret}, c.Linear.WebhookSecrets...) {
+			if secret != "" && !slices.Contains(secrets, secret) {
+				secrets = append(secrets, secret)
+			}
 		}
+		if len(secrets) == 0 && !c.Linear.FetchWebhookSecret {
+			errs = append(errs, fmt.Errorf("linear.webhook_secret (or webhook_secrets, or fetch_webhook_secret) is required when mode is \"webhook\""))
+		}
+		c.Linear.WebhookSecrets = secrets
 	case "poll":
+		if c.Linear.FetchWebhookSecret {
+			errs = append(errs, fmt.Errorf("linear.fetch_webhook_secret requires mode \"webhook\""))
+		}
 		if c.Linear.PollInterval == "" {
-			return fmt.Errorf("linear.poll_interval is required when mode is \"poll\"")
+			errs = append(errs, fmt.Errorf("linear.poll_interval is required when mode is \"poll\""))
+		} else {
+			d, err := time.ParseDuration(c.Linear.PollInterval)
+			switch {
+			case err != nil:
+				errs = append(errs, fmt.Errorf("linear.poll_interval: %w", err))
+			case d < 10*time.Second:
+				errs = append(errs, fmt.Errorf("linear.poll_interval must be at least 10s, got %s", d))
+			default:
+				c.Linear.ParsedPollInterval = d
+			}
 		}
-		d, err := time.ParseDuration(c.Linear.PollInterval)
-		if err != nil {
-			return fmt.Errorf("linear.poll_interval: %w", err)
+
+		if c.Linear.PollConcurrency == 0 {
+			c.Linear.PollConcurrency = 10
 		}
-		if d < 10*time.Second {
-			return fmt.Errorf("linear.poll_interval must be at least 10s, got %s", d)
+		if c.Linear.PollConcurrency < 0 {
+			errs = append(errs, fmt.Errorf("linear.poll_concurrency must be positive, got %d", c.Linear.PollConcurrency))
 		}
-		c.Linear.ParsedPollInterval = d
 
 		// Warn about wait_for_approval in poll mode
-		for _, stage := range c.Pipeline {
+		for _, stage := range c.allStages() {
 			if stage.WaitForApproval {
 				slog.Warn("wait_for_approval has limited functionality in poll mode (comment re-runs won't auto-trigger)",
 					"stage", stage.Name,
 				)
 			}
+			if stage.RerunOnDescription {
+				slog.Warn("rerun_on_description has no effect in poll mode",
+					"stage", stage.Name,
+				)
+			}
 		}
 	default:
-		return fmt.Errorf("linear.mode must be \"webhook\" or \"poll\", got %q", c.Linear.Mode)
+		errs = append(errs, fmt.Errorf("linear.mode must be \"webhook\" or \"poll\", got %q", c.Linear.Mode))
+	}
+
+	if c.Linear.HeartbeatInterval != "" {
+		d, err := time.ParseDuration(c.Linear.HeartbeatInterval)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("linear.heartbeat_interval: %w", err))
+		case d < 10*time.Second:
+			errs = append(errs, fmt.Errorf("linear.heartbeat_interval must be at least 10s, got %s", d))
+		default:
+			c.Linear.ParsedHeartbeatInterval = d
+		}
 	}
 
-	if len(c.Pipeline) == 0 {
-		return fmt.Errorf("at least one pipeline stage is required")
+	if c.Linear.SkipBotIssues && len(c.Linear.BotUsers) == 0 {
+		errs = append(errs, fmt.Errorf("linear.skip_bot_issues requires linear.bot_users"))
 	}
 
-	// Validate context_mode
-	switch c.Subprocess.ContextMode {
-	case "env", "stdin", "both":
+	if c.Linear.StateRefreshInterval != "" {
+		d, err := time.ParseDuration(c.Linear.StateRefreshInterval)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("linear.state_refresh_interval: %w", err))
+		case d < time.Minute:
+			errs = append(errs, fmt.Errorf("linear.state_refresh_interval must be at least 1m, got %s", d))
+		default:
+			c.Linear.ParsedStateRefreshInterval = d
+		}
+	}
+
+	if c.Linear.RerunMinInterval != "" {
+		d, err := time.ParseDuration(c.Linear.RerunMinInterval)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("linear.rerun_min_interval: %w", err))
+		case d <= 0:
+			errs = append(errs, fmt.Errorf("linear.rerun_min_interval must be positive, got %s", d))
+		default:
+			c.Linear.ParsedRerunMinInterval = d
+		}
+	}
+
+	switch c.Linear.CommentMode {
+	case "":
+		c.Linear.CommentMode = "per_stage"
+	case "per_stage", "consolidated":
+	default:
+		errs = append(errs, fmt.Errorf("linear.comment_mode must be \"per_stage\" or \"consolidated\", got %q", c.Linear.CommentMode))
+	}
+	if l := c.Linear.StatusLabels; l.Queued != "" && strings.EqualFold(l.Queued, l.Running) {
+		errs = append(errs, fmt.Errorf("linear.status_labels: queued and running must be different labels, got %q for both", l.Queued))
+	}
+
+	if c.Linear.HTTPTimeout == "" {
+		c.Linear.HTTPTimeout = "30s"
+	}
+	httpTimeout, err := time.ParseDuration(c.Linear.HTTPTimeout)
+	switch {
+	case err != nil:
+		errs = append(errs, fmt.Errorf("linear.http_timeout: %w", err))
+	case httpTimeout <= 0:
+		errs = append(errs, fmt.Errorf("linear.http_timeout must be positive, got %s", httpTimeout))
 	default:
-		return fmt.Errorf("subprocess.context_mode must be env, stdin, or both; got %q", c.Subprocess.ContextMode)
+		c.Linear.ParsedHTTPTimeout = httpTimeout
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Linear.ExtraHeaders)) {
+		value := c.Linear.ExtraHeaders[name]
+		switch {
+		case name == "" || strings.ContainsAny(name, " \t\r\n:"):
+			errs = append(errs, fmt.Errorf("linear.extra_headers: %q is not a valid header name", name))
+		case strings.ContainsAny(value, "\r\n"):
+			errs = append(errs, fmt.Errorf("linear.extra_headers[%q]: value must not contain line breaks", name))
+		case strings.EqualFold(name, "Content-Type") || strings.EqualFold(name, "Authorization"):
+			errs = append(errs, fmt.Errorf("linear.extra_headers: %s is set by ai-flow and can't be overridden", name))
+		}
+	}
+	if c.Linear.ProxyURL != "" {
+		if u, err := url.Parse(c.Linear.ProxyURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("linear.proxy_url %q must be a URL like http://proxy:3128", c.Linear.ProxyURL))
+		}
+	}
+
+	for _, field := range c.Linear.ExtraIssueFields {
+		if !slices.Contains(allowedExtraIssueFields, field) {
+			errs = append(errs, fmt.Errorf("linear.extra_issue_fields: %q is not supported (allowed: %s)", field, strings.Join(allowedExtraIssueFields, ", ")))
+		}
+	}
+	slices.Sort(c.Linear.ExtraIssueFields)
+	c.Linear.ExtraIssueFields = slices.Compact(c.Linear.ExtraIssueFields)
+
+	if c.Linear.MaxLabels == 0 {
+		c.Linear.MaxLabels = DefaultMaxLabels
+	}
+	if c.Linear.MaxLabels < 0 || c.Linear.MaxLabels > MaxLabelsLimit {
+		errs = append(errs, fmt.Errorf("linear.max_labels must be between 1 and %d, got %d", MaxLabelsLimit, c.Linear.MaxLabels))
+	}
+
+	if c.Linear.MaxRetries == 0 {
+		c.Linear.MaxRetries = 3
+	}
+	if c.Linear.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("linear.max_retries must be positive, got %d", c.Linear.MaxRetries))
+	}
+	if c.Linear.RetryMaxDelay == "" {
+		c.Linear.RetryMaxDelay = "10s"
+	}
+	retryMaxDelay, err := time.ParseDuration(c.Linear.RetryMaxDelay)
+	switch {
+	case err != nil:
+		errs = append(errs, fmt.Errorf("linear.retry_max_delay: %w", err))
+	case retryMaxDelay <= 0:
+		errs = append(errs, fmt.Errorf("linear.retry_max_delay must be positive, got %s", retryMaxDelay))
+	default:
+		c.Linear.ParsedRetryMaxDelay = retryMaxDelay
+	}
+
+	if c.Linear.MaxTimestampDrift == "" {
+		c.Linear.MaxTimestampDrift = "60s"
+	}
+	maxDrift, err := time.ParseDuration(c.Linear.MaxTimestampDrift)
+	switch {
+	case err != nil:
+		errs = append(errs, fmt.Errorf("linear.max_timestamp_drift: %w", err))
+	case maxDrift <= 0:
+		errs = append(errs, fmt.Errorf("linear.max_timestamp_drift must be positive, got %s", maxDrift))
+	default:
+		c.Linear.ParsedMaxTimestampDrift = maxDrift
+	}
+
+	if c.Linear.WebhookDebounce != "" {
+		d, err := time.ParseDuration(c.Linear.WebhookDebounce)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("linear.webhook_debounce: %w", err))
+		case d <= 0:
+			errs = append(errs, fmt.Errorf("linear.webhook_debounce must be positive, got %s", d))
+		default:
+			c.Linear.ParsedWebhookDebounce = d
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) validatePipeline() error {
+	var errs []error
+	for _, team := range slices.Sorted(maps.Keys(c.Pipeline.Teams)) {
+		if len(c.Pipeline.Teams[team]) == 0 {
+			errs = append(errs, fmt.Errorf("pipeline.teams.%s: at least one stage is required", team))
+		}
+	}
+	if len(c.StagesFor(c.Linear.TeamKey)) == 0 {
+		errs = append(errs, fmt.Errorf("at least one pipeline stage is required"))
+	}
+
+	if c.Pipeline.HandlerTimeout != "" {
+		d, err := time.ParseDuration(c.Pipeline.HandlerTimeout)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("pipeline.handler_timeout: %w", err))
+		case d <= 0:
+			errs = append(errs, fmt.Errorf("pipeline.handler_timeout must be positive, got %s", d))
+		default:
+			c.Pipeline.ParsedHandlerTimeout = d
+		}
+	}
+
+	if c.Pipeline.MaxRuntimePerIssue != "" {
+		d, err := time.ParseDuration(c.Pipeline.MaxRuntimePerIssue)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("pipeline.max_runtime_per_issue: %w", err))
+		case d <= 0:
+			errs = append(errs, fmt.Errorf("pipeline.max_runtime_per_issue must be positive, got %s", d))
+		default:
+			c.Pipeline.ParsedMaxRuntimePerIssue = d
+		}
+	}
+
+	if c.Pipeline.MaxCycles < 0 {
+		errs = append(errs, fmt.Errorf("pipeline.max_cycles must be non-negative, got %d", c.Pipeline.MaxCycles))
+	}
+
+	switch c.Pipeline.OnMissingRepo {
+	case "":
+		c.Pipeline.OnMissingRepo = "fail"
+	case "fail", "skip":
+	default:
+		errs = append(errs, fmt.Errorf("pipeline.on_missing_repo must be fail or skip, got %q", c.Pipeline.OnMissingRepo))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateTelemetry() error {
+	if c.Telemetry.OTLPEndpoint != "" {
+		if u, err := url.Parse(c.Telemetry.OTLPEndpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("telemetry.otlp_endpoint %q must be a URL like http://localhost:4318", c.Telemetry.OTLPEndpoint)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateWorkspace() error {
+	var errs []error
+	if c.Workspace.UseWorktrees && c.Workspace.Root == "" {
+		errs = append(errs, fmt.Errorf("workspace.use_worktrees requires workspace.root"))
+	}
+	if c.Workspace.AttachmentsMaxMB == 0 {
+		c.Workspace.AttachmentsMaxMB = 50
+	}
+	if c.Workspace.AttachmentsMaxMB < 0 {
+		errs = append(errs, fmt.Errorf("workspace.attachments_max_mb must be positive, got %d", c.Workspace.AttachmentsMaxMB))
 	}
 
 	// Create workspace root if configured
 	if c.Workspace.Root != "" {
 		if err := os.MkdirAll(c.Workspace.Root, 0755); err != nil {
-			return fmt.Errorf("creating workspace root %q: %w", c.Workspace.Root, err)
+			errs = append(errs, fmt.Errorf("creating workspace root %q: %w", c.Workspace.Root, err))
+		}
+	}
+
+	if c.Workspace.MirrorRoot != "" {
+		if err := os.MkdirAll(c.Workspace.MirrorRoot, 0755); err != nil {
+			errs = append(errs, fmt.Errorf("creating workspace mirror root %q: %w", c.Workspace.MirrorRoot, err))
+		}
+		if c.Workspace.MirrorRefresh == "" {
+			c.Workspace.MirrorRefresh = "10m"
 		}
+		d, err := time.ParseDuration(c.Workspace.MirrorRefresh)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("workspace.mirror_refresh: %w", err))
+		case d < time.Minute:
+			errs = append(errs, fmt.Errorf("workspace.mirror_refresh must be at least 1m, got %s", d))
+		default:
+			c.Workspace.ParsedMirrorRefresh = d
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateProjectPipeline checks the optional project_pipeline section.
+func (c *Config) validateProjectPipeline(configDir string) error {
+	var errs []error
+	for i := range c.ProjectPipeline {
+		errs = append(errs, c.validateProjectStage(configDir, i))
 	}
+	return errors.Join(errs...)
+}
 
-	// Check stages and no duplicate linear_states
-	seen := make(map[string]bool)
-	for i, stage := range c.Pipeline {
-		if stage.Name == "" {
-			return fmt.Errorf("pipeline[%d].name is required", i)
+func (c *Config) validateProjectStage(configDir string, i int) error {
+	var errs []error
+	stage := c.ProjectPipeline[i]
+	if stage.Name == "" {
+		errs = append(errs, fmt.Errorf("project_pipeline[%d].name is required", i))
+	}
+	if stage.Label == "" {
+		errs = append(errs, fmt.Errorf("project_pipeline[%d].label is required", i))
+	}
+	if stage.Command == "" {
+		errs = append(errs, fmt.Errorf("project_pipeline[%d].command is required", i))
+	}
+	switch {
+	case stage.PromptFile != "" && stage.Prompt != "":
+		errs = append(errs, fmt.Errorf("project_pipeline[%d] prompt and prompt_file are mutually exclusive", i))
+	case stage.PromptFile != "":
+		prompt, err := readPrompt(configDir, stage.PromptFile)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("project_pipeline[%d].prompt_file %q: %w", i, stage.PromptFile, err))
+		case strings.TrimSpace(prompt) == "":
+			errs = append(errs, fmt.Errorf("project_pipeline[%d] (stage %q): prompt_file %q is empty", i, stage.Name, stage.PromptFile))
+		default:
+			c.ProjectPipeline[i].Prompt = prompt
 		}
-		if stage.LinearState == "" {
-			return fmt.Errorf("pipeline[%d].linear_state is required", i)
+	case strings.TrimSpace(stage.Prompt) == "":
+		errs = append(errs, fmt.Errorf("project_pipeline[%d].prompt_file or prompt is required", i))
+	}
+
+	if stage.NextState == "" {
+		errs = append(errs, fmt.Errorf("project_pipeline[%d].next_state is required", i))
+	}
+	if stage.Timeout == 0 {
+		c.ProjectPipeline[i].Timeout = 3600
+	}
+	return errors.Join(errs...)
+}
+
+// validateStages checks one pipeline's stages, applies their defaults, and
+// rejects duplicate linear_states. path prefixes errors (e.g. "pipeline").
+func (c *Config) validateStages(configDir, path string, stages []StageConfig) error {
+	var errs []error
+	seen := make(map[string][]int) // linear_state → indexes of stages using it
+	for i, stage := range stages {
+		if err := c.validateStage(configDir, path, stages, i); err != nil {
+			errs = append(errs, err)
+			continue
 		}
-		if stage.Command == "" {
-			return fmt.Errorf("pipeline[%d].command is required", i)
+		if !stage.IsEnabled() {
+			continue
 		}
-		if stage.PromptFile == "" {
-			return fmt.Errorf("pipeline[%d].prompt_file is required", i)
+		state := strings.ToLower(linear.NormalizeStateName(stage.LinearState))
+		for _, j := range seen[state] {
+			if labelsOverlap(stages[j].Labels, stage.Labels) {
+				errs = append(errs, fmt.Errorf("duplicate linear_state %q in %s: stages %q and %q need disjoint, non-empty labels to share a state", stage.LinearState, path, stages[j].Name, stage.Name))
+				break
+			}
+		}
+		seen[state] = append(seen[state], i)
+	}
+	return errors.Join(errs...)
+}
+
+// validateStage checks stages[i] and applies its defaults.
+func (c *Config) validateStage(configDir, path string, stages []StageConfig, i int) error {
+	var errs []error
+	stage := stages[i]
+	if stage.Name == "" {
+		errs = append(errs, fmt.Errorf("%s[%d].name is required", path, i))
+	}
+	if stage.LinearState == "" {
+		errs = append(errs, fmt.Errorf("%s[%d].linear_state is required", path, i))
+	}
+	if stage.Command == "" {
+		errs = append(errs, fmt.Errorf("%s[%d].command is required", path, i))
+	}
+	if stage.OnCompleteCommand == "" {
+		stages[i].OnCompleteCommand, stages[i].OnCompleteArgs = c.Pipeline.OnCompleteCommand, c.Pipeline.OnCompleteArgs
+	}
+	switch {
+	case stage.PromptFile != "" && stage.Prompt != "":
+		errs = append(errs, fmt.Errorf("%s[%d] prompt and prompt_file are mutually exclusive", path, i))
+	case stage.PromptFile != "":
+		prompt, err := readPrompt(configDir, stage.PromptFile)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s[%d].prompt_file %q: %w", path, i, stage.PromptFile, err))
+		case strings.TrimSpace(prompt) == "" && !stage.AllowEmptyPrompt:
+			errs = append(errs, fmt.Errorf("%s[%d] (stage %q): prompt_file %q is empty (set allow_empty_prompt if intended)", path, i, stage.Name, stage.PromptFile))
+		default:
+			stages[i].Prompt = prompt
 		}
-		promptPath := stage.PromptFile
-		if !filepath.IsAbs(promptPath) {
-			promptPath = filepath.Join(configDir, promptPath)
+	case strings.TrimSpace(stage.Prompt) != "" || stage.AllowEmptyPrompt:
+	default:
+		errs = append(errs, fmt.Errorf("%s[%d].prompt_file or prompt is required", path, i))
+	}
+
+	if stage.ReviewCommand != "" || stage.ReviewPromptFile != "" {
+		if !stage.CreatesPR && !stage.UsesBranch {
+			errs = append(errs, fmt.Errorf("%s[%d] review_command requires creates_pr or uses_branch", path, i))
 		}
-		promptData, err := os.ReadFile(promptPath)
-		if err != nil {
-			return fmt.Errorf("pipeline[%d].prompt_file %q: %w", i, stage.PromptFile, err)
+		if stage.ReviewCommand == "" || stage.ReviewPromptFile == "" {
+			errs = append(errs, fmt.Errorf("%s[%d] review_command and review_prompt_file must be set together", path, i))
+		} else {
+			reviewPrompt, err := readPrompt(configDir, stage.ReviewPromptFile)
+			switch {
+			case err != nil:
+				errs = append(errs, fmt.Errorf("%s[%d].review_prompt_file %q: %w", path, i, stage.ReviewPromptFile, err))
+			case strings.TrimSpace(reviewPrompt) == "" && !stage.AllowEmptyPrompt:
+				errs = append(errs, fmt.Errorf("%s[%d] (stage %q): review_prompt_file %q is empty (set allow_empty_prompt if intended)", path, i, stage.Name, stage.ReviewPromptFile))
+			default:
+				stages[i].ReviewPrompt = reviewPrompt
+			}
 		}
-		c.Pipeline[i].Prompt = string(promptData)
+	}
 
-		if stage.NextState == "" {
-			return fmt.Errorf("pipeline[%d].next_state is required", i)
+	if stage.NextState == "" {
+		errs = append(errs, fmt.Errorf("%s[%d].next_state is required", path, i))
+	}
+	if stage.Timeout == 0 {
+		stages[i].Timeout = 3600
+	}
+	if stage.UsesBranch && stage.CreatesPR {
+		errs = append(errs, fmt.Errorf("%s[%d] has both uses_branch and creates_pr (mutually exclusive)", path, i))
+	}
+	if stage.MergesPR && !stage.UsesBranch {
+		errs = append(errs, fmt.Errorf("%s[%d] merges_pr requires uses_branch", path, i))
+	}
+	switch stage.OnConflict {
+	case "":
+		stages[i].OnConflict = "fail"
+	case "fail":
+	case "requeue":
+		if !stage.MergesPR {
+			errs = append(errs, fmt.Errorf("%s[%d] on_conflict requires merges_pr", path, i))
 		}
-		if stage.Timeout == 0 {
-			c.Pipeline[i].Timeout = 3600
+		if stage.RequeueState == "" {
+			errs = append(errs, fmt.Errorf("%s[%d] on_conflict \"requeue\" requires requeue_state", path, i))
 		}
-		if stage.UsesBranch && stage.CreatesPR {
-			return fmt.Errorf("pipeline[%d] has both uses_branch and creates_pr (mutually exclusive)", i)
+	default:
+		errs = append(errs, fmt.Errorf("%s[%d].on_conflict must be \"fail\" or \"requeue\", got %q", path, i, stage.OnConflict))
+	}
+	for _, priority := range slices.Sorted(maps.Keys(stage.PriorityOverrides)) {
+		switch priority {
+		case "urgent", "high", "medium", "low", "none":
+		default:
+			errs = append(errs, fmt.Errorf("%s[%d].priority_overrides: unknown priority %q (want urgent, high, medium, low, or none)", path, i, priority))
+		}
+	}
+	switch stage.LabelMatch {
+	case "":
+		stages[i].LabelMatch = "any"
+	case "any", "all":
+	default:
+		errs = append(errs, fmt.Errorf("%s[%d].label_match must be \"any\" or \"all\", got %q", path, i, stage.LabelMatch))
+	}
+	if stage.Model == "" {
+		stages[i].Model = c.Subprocess.Model
+	}
+	if stage.Provider == "" {
+		stages[i].Provider = c.Subprocess.Provider
+	}
+	if stage.PreviewOnly {
+		if !stage.CreatesPR && !stage.UsesBranch {
+			errs = append(errs, fmt.Errorf("%s[%d] preview_only requires creates_pr or uses_branch", path, i))
+		}
+		if stage.MergesPR || stage.ReviewCommand != "" {
+			errs = append(errs, fmt.Errorf("%s[%d] preview_only cannot be combined with merges_pr or review_command", path, i))
 		}
-		if stage.FailureState != "" && strings.EqualFold(stage.FailureState, stage.LinearState) {
-			return fmt.Errorf("pipeline[%d] failure_state cannot equal linear_state", i)
+	}
+	if stage.CloneDepth == nil {
+		depth := 1
+		stages[i].CloneDepth = &depth
+	} else {
+		if *stage.CloneDepth < 0 {
+			errs = append(errs, fmt.Errorf("%s[%d].clone_depth must not be negative, got %d", path, i, *stage.CloneDepth))
 		}
-		if seen[stage.LinearState] {
-			return fmt.Errorf("duplicate linear_state %q in pipeline", stage.LinearState)
+		if !stage.CreatesPR && !stage.UsesBranch {
+			errs = append(errs, fmt.Errorf("%s[%d] clone_depth requires creates_pr or uses_branch", path, i))
 		}
-		seen[stage.LinearState] = true
 	}
+	if stage.CreateBranchIfMissing && !stage.UsesBranch {
+		errs = append(errs, fmt.Errorf("%s[%d] create_branch_if_missing requires uses_branch", path, i))
+	}
+	switch stage.OnMissingBranch {
+	case "":
+		stages[i].OnMissingBranch = "fail"
+	case "fail":
+	case "recreate":
+		if !stage.UsesBranch {
+			errs = append(errs, fmt.Errorf("%s[%d] on_missing_branch \"recreate\" requires uses_branch", path, i))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%s[%d].on_missing_branch must be \"fail\" or \"recreate\", got %q", path, i, stage.OnMissingBranch))
+	}
+	if len(stage.PRReviewers)+len(stage.PRAssignees)+len(stage.PRLabels) > 0 && !stage.CreatesPR && !stage.UsesBranch {
+		errs = append(errs, fmt.Errorf("%s[%d] pr_reviewers, pr_assignees and pr_labels require creates_pr or uses_branch", path, i))
+	}
+	switch stage.BranchFrom {
+	case "":
+		stages[i].BranchFrom = "base"
+	case "base":
+	case "previous":
+		if !stage.CreatesPR {
+			errs = append(errs, fmt.Errorf("%s[%d] branch_from \"previous\" requires creates_pr", path, i))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%s[%d].branch_from must be \"base\" or \"previous\", got %q", path, i, stage.BranchFrom))
+	}
+	for _, cond := range stage.EscalateOn {
+		switch cond {
+		case "failure", "timeout", "repeated":
+		default:
+			errs = append(errs, fmt.Errorf("%s[%d].escalate_on entries must be \"failure\", \"timeout\", or \"repeated\", got %q", path, i, cond))
+		}
+	}
+	if len(stage.EscalateOn) > 0 && c.Notify.EscalationURL == "" {
+		errs = append(errs, fmt.Errorf("%s[%d] escalate_on requires notify.escalation_url", path, i))
+	}
+	if stage.EscalateAfter < 0 {
+		errs = append(errs, fmt.Errorf("%s[%d].escalate_after must not be negative, got %d", path, i, stage.EscalateAfter))
+	}
+	if stage.EscalateAfter == 0 {
+		stages[i].EscalateAfter = 3
+	}
+	if stage.FailureCooldown != "" {
+		d, err := time.ParseDuration(stage.FailureCooldown)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s[%d].failure_cooldown: %w", path, i, err))
+		case d < 0:
+			errs = append(errs, fmt.Errorf("%s[%d].failure_cooldown must not be negative, got %s", path, i, d))
+		default:
+			stages[i].ParsedFailureCooldown = d
+		}
+	}
+	if stage.ApprovalTimeout != "" {
+		if !stage.WaitForApproval {
+			errs = append(errs, fmt.Errorf("%s[%d] approval_timeout requires wait_for_approval", path, i))
+		}
+		d, err := time.ParseDuration(stage.ApprovalTimeout)
+		switch {
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s[%d].approval_timeout: %w", path, i, err))
+		case d <= 0:
+			errs = append(errs, fmt.Errorf("%s[%d].approval_timeout must be positive, got %s", path, i, d))
+		default:
+			stages[i].ParsedApprovalTimeout = d
+		}
+	}
+	switch stage.ApprovalTimeoutAction {
+	case "":
+		if stage.ApprovalTimeout != "" {
+			stages[i].ApprovalTimeoutAction = "fail"
+		}
+	case "fail", "auto_approve":
+		if stage.ApprovalTimeout == "" {
+			errs = append(errs, fmt.Errorf("%s[%d] approval_timeout_action requires approval_timeout", path, i))
+		}
+	case "escalate":
+		if stage.ApprovalTimeout == "" {
+			errs = append(errs, fmt.Errorf("%s[%d] approval_timeout_action requires approval_timeout", path, i))
+		}
+		if c.Notify.EscalationURL == "" {
+			errs = append(errs, fmt.Errorf("%s[%d] approval_timeout_action \"escalate\" requires notify.escalation_url", path, i))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%s[%d].approval_timeout_action must be \"fail\", \"auto_approve\", or \"escalate\", got %q", path, i, stage.ApprovalTimeoutAction))
+	}
+	switch stage.CommentTarget {
+	case "":
+		stages[i].CommentTarget = "self"
+	case "self", "parent", "children":
+	default:
+		errs = append(errs, fmt.Errorf("%s[%d].comment_target must be \"self\", \"parent\", or \"children\", got %q", path, i, stage.CommentTarget))
+	}
+	switch {
+	case stage.PromptArg == "":
+		stages[i].PromptArg = "positional"
+	case stage.PromptArg == "positional", stage.PromptArg == "none":
+	case strings.HasPrefix(stage.PromptArg, "-") && !strings.ContainsAny(stage.PromptArg, " \t="):
+	default:
+		errs = append(errs, fmt.Errorf("%s[%d].prompt_arg must be \"positional\", \"none\", or a flag like \"--prompt\", got %q", path, i, stage.PromptArg))
+	}
+	if stage.FailureState != "" && linear.SameState(stage.FailureState, stage.LinearState) {
+		errs = append(errs, fmt.Errorf("%s[%d] failure_state cannot equal linear_state", path, i))
+	}
+	return errors.Join(errs...)
+}
 
-	// Validate project pipeline stages (optional section)
-	for i, stage := range c.ProjectPipeline {
-		if stage.Name == "" {
-			return fmt.Errorf("project_pipeline[%d].name is required", i)
+// checkCommands verifies that every distinct command the pipelines can run
+// resolves via PATH, so a typo fails at startup rather than mid-run.
+func (c *Config) checkCommands() error {
+	checked := make(map[string]bool)
+	var errs []error
+	check := func(where, command string) {
+		if command == "" || checked[command] {
+			return
 		}
-		if stage.Label == "" {
-			return fmt.Errorf("project_pipeline[%d].label is required", i)
+		checked[command] = true
+		if _, err := exec.LookPath(command); err != nil {
+			errs = append(errs, fmt.Errorf("%s: command %q not found (set subprocess.skip_command_check to disable this check): %w", where, command, err))
 		}
-		if stage.Command == "" {
-			return fmt.Errorf("project_pipeline[%d].command is required", i)
+	}
+
+	for _, list := range c.stageLists() {
+		for i, stage := range list.stages {
+			if !stage.IsEnabled() {
+				continue
+			}
+			check(fmt.Sprintf("%s[%d].command", list.path, i), stage.Command)
+			check(fmt.Sprintf("%s[%d].review_command", list.path, i), stage.ReviewCommand)
+			check(fmt.Sprintf("%s[%d].on_complete_command", list.path, i), stage.OnCompleteCommand)
+			for priority, override := range stage.PriorityOverrides {
+				check(fmt.Sprintf("%s[%d].priority_overrides[%s].command", list.path, i, priority), override.Command)
+			}
 		}
-		if stage.PromptFile == "" {
-			return fmt.Errorf("project_pipeline[%d].prompt_file is required", i)
+	}
+	for i, stage := range c.ProjectPipeline {
+		check(fmt.Sprintf("project_pipeline[%d].command", i), stage.Command)
+	}
+	return errors.Join(errs...)
+}
+
+// RepoFor returns the configured repo for an issue, looking up the Linear
+// project name first and then the team key.
+func (c *Config) RepoFor(projectName, teamKey string) (ProjectRepoConfig, bool) {
+	if projectName != "" {
+		if p, ok := c.Projects[projectName]; ok {
+			return p, true
 		}
-		promptPath := stage.PromptFile
-		if !filepath.IsAbs(promptPath) {
-			promptPath = filepath.Join(configDir, promptPath)
+	}
+	if teamKey != "" {
+		if p, ok := c.Projects[teamKey]; ok {
+			return p, true
 		}
-		promptData, err := os.ReadFile(promptPath)
-		if err != nil {
-			return fmt.Errorf("project_pipeline[%d].prompt_file %q: %w", i, stage.PromptFile, err)
+	}
+	return ProjectRepoConfig{}, false
+}
+
+// StagesFor returns the pipeline stages for a Linear team: the team's entry in
+// pipeline.teams if it has one, otherwise the flat stage list.
+func (c *Config) StagesFor(teamKey string) []StageConfig {
+	if stages, ok := c.Pipeline.Teams[teamKey]; ok {
+		return stages
+	}
+	return c.Pipeline.Stages
+}
+
+// TeamKeys returns the Linear teams ai-flow serves: linear.team_key, then
+// the other teams in pipeline.teams in key order.
+func (c *Config) TeamKeys() []string {
+	keys := []string{c.Linear.TeamKey}
+	for _, team := range slices.Sorted(maps.Keys(c.Pipeline.Teams)) {
+		if team != c.Linear.TeamKey {
+			keys = append(keys, team)
 		}
-		c.ProjectPipeline[i].Prompt = string(promptData)
+	}
+	return keys
+}
 
-		if stage.NextState == "" {
-			return fmt.Errorf("project_pipeline[%d].next_state is required", i)
+// FindStage returns the team's enabled pipeline stage matching the given
+// Linear state name, or nil. When several stages share the state, the first whose labels
+// match issueLabels is returned, falling back to the first with the state.
+func (c *Config) FindStage(teamKey, linearStateName string, issueLabels []string) *StageConfig {
+	stages := c.StagesFor(teamKey)
+	var found *StageConfig
+	for i := range stages {
+		if !linear.SameState(stages[i].LinearState, linearStateName) {
+			continue
+		}
+		if !stages[i].IsEnabled() {
+			slog.Debug("skipping disabled stage", "stage", stages[i].Name, "state", linearStateName)
+			continue
+		}
+		if stages[i].MatchesLabels(issueLabels) {
+			return &stages[i]
 		}
-		if stage.Timeout == 0 {
-			c.ProjectPipeline[i].Timeout = 3600
+		if found == nil {
+			found = &stages[i]
 		}
 	}
+	return found
+}
 
-	return nil
+// IsEnabled reports whether the stage is part of the pipeline, i.e. enabled
+// isn't set to false.
+func (s *StageConfig) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
 }
 
-// FindStage returns the pipeline stage matching the given Linear state name, or nil.
-func (c *Config) FindStage(linearStateName string) *StageConfig {
-	for i := range c.Pipeline {
-		if strings.EqualFold(c.Pipeline[i].LinearState, linearStateName) {
-			return &c.Pipeline[i]
+// MatchesLabels reports whether an issue with issueLabels passes the stage's
+// label filter: any of its labels, or every one of them with label_match
+// "all". Comparison is case-insensitive.
+func (s *StageConfig) MatchesLabels(issueLabels []string) bool {
+	if len(s.Labels) == 0 {
+		return true
+	}
+	all := s.LabelMatch == "all"
+	labelSet := make(map[string]bool, len(issueLabels))
+	for _, l := range issueLabels {
+		labelSet[strings.ToLower(l)] = true
+	}
+	for _, req := range s.Labels {
+		has := labelSet[strings.ToLower(req)]
+		if has && !all {
+			return true
+		}
+		if !has && all {
+			return false
 		}
 	}
-	return nil
+	return all
+}
+
+// labelsOverlap reports whether two stages' label filters could both match
+// the same issue: either has no labels, or they share one.
+func labelsOverlap(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	for _, x := range a {
+		for _, y := range b {
+			if strings.EqualFold(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stageList is one pipeline's stages with the config path used in errors.
+type stageList struct {
+	path   string
+	stages []StageConfig
+}
+
+// stageLists returns the flat pipeline followed by each team's pipeline, in
+// team key order. The slices alias the config, so defaults applied through
+// them stick.
+func (c *Config) stageLists() []stageList {
+	lists := []stageList{{path: "pipeline", stages: c.Pipeline.Stages}}
+	for _, team := range slices.Sorted(maps.Keys(c.Pipeline.Teams)) {
+		lists = append(lists, stageList{path: "pipeline.teams." + team, stages: c.Pipeline.Teams[team]})
+	}
+	return lists
+}
+
+// allStages returns every stage across the flat and per-team pipelines.
+func (c *Config) allStages() []StageConfig {
+	var all []StageConfig
+	for _, list := range c.stageLists() {
+		all = append(all, list.stages...)
+	}
+	return all
 }
@@ -0,0 +1,416 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// baseYAML is the smallest config that validates, minus its pipeline.
+const baseYAML = `
+linear:
+  api_key: test-key
+  team_key: ENG
+  webhook_secret: secret
+subprocess:
+  skip_command_check: true
+`
+
+// minimalPipelineYAML is a one-stage pipeline for tests about other sections.
+const minimalPipelineYAML = `
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    prompt: Plan it.
+    next_state: In Progress
+`
+
+// loadYAML writes cfgYAML and files (relative to the config) to a temporary
+// directory and loads the config.
+func loadYAML(t *testing.T, cfgYAML string, files map[string]string) (*Config, error) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(cfgYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func TestEmptyPromptFileIsRejected(t *testing.T) {
+	stage := `
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    prompt_file: plan.md
+    next_state: In Progress
+`
+	_, err := loadYAML(t, baseYAML+stage, map[string]string{"plan.md": " \n\t\n"})
+	if err == nil {
+		t.Fatal("Load accepted an empty prompt file")
+	}
+	for _, want := range []string{`stage "plan"`, `prompt_file "plan.md" is empty`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+
+	cfg, err := loadYAML(t, baseYAML+stage+"    allow_empty_prompt: true\n", map[string]string{"plan.md": ""})
+	if err != nil {
+		t.Fatalf("Load with allow_empty_prompt: %v", err)
+	}
+	if got := cfg.Pipeline.Stages[0].Prompt; got != "" {
+		t.Errorf("prompt = %q, want empty", got)
+	}
+}
+
+func TestServerListenAddr(t *testing.T) {
+	for _, tc := range []struct {
+		server string
+		want   string
+	}{
+		{"", ":8080"},
+		{"server:\n  port: 9000\n", ":9000"},
+		{"server:\n  host: 127.0.0.1\n  port: 9000\n", "127.0.0.1:9000"},
+		{"server:\n  host: \"::1\"\n  port: 9000\n", "[::1]:9000"},
+		{"server:\n  host: \"[fe80::1]\"\n", "[fe80::1]:8080"},
+		{"server:\n  host: localhost\n", "localhost:8080"},
+	} {
+		cfg, err := loadYAML(t, baseYAML+tc.server+minimalPipelineYAML, nil)
+		if err != nil {
+			t.Errorf("Load(%q): %v", tc.server, err)
+			continue
+		}
+		if got := cfg.Server.ListenAddr(); got != tc.want {
+			t.Errorf("ListenAddr for %q = %q, want %q", tc.server, got, tc.want)
+		}
+	}
+
+	for _, host := range []string{"\"::1::2\"", "\"[::1]:80\"", "\"a b\""} {
+		if _, err := loadYAML(t, baseYAML+"server:\n  host: "+host+"\n"+minimalPipelineYAML, nil); err == nil {
+			t.Errorf("Load accepted server.host %s", host)
+		}
+	}
+}
+
+func TestMatchesLabels(t *testing.T) {
+	for _, tc := range []struct {
+		match  string
+		labels []string
+		issue  []string
+		want   bool
+	}{
+		{"any", nil, nil, true},
+		{"all", nil, []string{"bug"}, true},
+		{"any", []string{"bug", "urgent"}, []string{"URGENT"}, true},
+		{"any", []string{"bug", "urgent"}, []string{"feature"}, false},
+		{"any", []string{"bug", "urgent"}, nil, false},
+		{"all", []string{"bug", "urgent"}, []string{"Bug"}, false},
+		{"all", []string{"bug", "urgent"}, []string{"urgent", "BUG", "feature"}, true},
+		{"all", []string{"bug", "urgent"}, nil, false},
+	} {
+		stage := StageConfig{Labels: tc.labels, LabelMatch: tc.match}
+		if got := stage.MatchesLabels(tc.issue); got != tc.want {
+			t.Errorf("label_match %s of %q on issue labels %q = %v, want %v", tc.match, tc.labels, tc.issue, got, tc.want)
+		}
+	}
+}
+
+func TestLabelMatchDefaultsToAny(t *testing.T) {
+	cfg, err := loadYAML(t, baseYAML+minimalPipelineYAML+"    labels: [bug, urgent]\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stage := cfg.Pipeline.Stages[0]
+	if stage.LabelMatch != "any" {
+		t.Errorf("label_match = %q, want any", stage.LabelMatch)
+	}
+	if !stage.MatchesLabels([]string{"urgent"}) {
+		t.Error("default label_match required every label")
+	}
+
+	if _, err := loadYAML(t, baseYAML+minimalPipelineYAML+"    labels: [bug]\n    label_match: most\n", nil); err == nil {
+		t.Error("Load accepted label_match \"most\"")
+	}
+}
+
+func TestCommandCheck(t *testing.T) {
+	checked := strings.Replace(baseYAML, "skip_command_check: true", "skip_command_check: false", 1)
+	stage := func(command string) string {
+		return strings.Replace(minimalPipelineYAML, "command: sh", "command: "+command, 1)
+	}
+
+	if _, err := loadYAML(t, checked+stage("sh"), nil); err != nil {
+		t.Errorf("Load with command sh: %v", err)
+	}
+
+	_, err := loadYAML(t, checked+stage("claude-cdoe")+"    on_complete_command: notify-bogus\n", nil)
+	if err == nil {
+		t.Fatal("Load accepted a command that is not on PATH")
+	}
+	for _, want := range []string{
+		`pipeline[0].command: command "claude-cdoe" not found`,
+		`pipeline[0].on_complete_command: command "notify-bogus" not found`,
+		"skip_command_check",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+
+	if _, err := loadYAML(t, baseYAML+stage("claude-cdoe"), nil); err != nil {
+		t.Errorf("Load with skip_command_check: %v", err)
+	}
+}
+
+func TestWebhookSecretsDuringRotation(t *testing.T) {
+	linearSection := strings.Replace(baseYAML, "webhook_secret: secret", "webhook_secret: new-secret\n  webhook_secrets: [old-secret, new-secret]", 1)
+	cfg, err := loadYAML(t, linearSection+minimalPipelineYAML, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Linear.WebhookSecrets; !slices.Equal(got, []string{"new-secret", "old-secret"}) {
+		t.Errorf("webhook secrets = %q, want webhook_secret first, then the rest without duplicates", got)
+	}
+
+	onlyList := strings.Replace(baseYAML, "webhook_secret: secret", "webhook_secrets: [old-secret, new-secret]", 1)
+	cfg, err = loadYAML(t, onlyList+minimalPipelineYAML, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Linear.WebhookSecrets; !slices.Equal(got, []string{"old-secret", "new-secret"}) {
+		t.Errorf("webhook secrets = %q, want the list as given", got)
+	}
+}
+
+func TestExtraIssueFieldsAllowlist(t *testing.T) {
+	withFields := func(fields string) string {
+		return strings.Replace(baseYAML, "subprocess:", "  extra_issue_fields: "+fields+"\nsubprocess:", 1) + minimalPipelineYAML
+	}
+	cfg, err := loadYAML(t, withFields("[estimate, cycle.name, estimate]"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Linear.ExtraIssueFields; !slices.Equal(got, []string{"cycle.name", "estimate"}) {
+		t.Errorf("extra_issue_fields = %q, want sorted without duplicates", got)
+	}
+
+	_, err = loadYAML(t, withFields("[estimate, comments.body]"), nil)
+	if err == nil || !strings.Contains(err.Error(), `"comments.body" is not supported`) {
+		t.Errorf("err = %v, want comments.body rejected", err)
+	}
+}
+
+func TestPromptFileNotEnvExpanded(t *testing.T) {
+	t.Setenv("FOO", "expanded")
+	t.Setenv("AIFLOW_TEST_CMD", "sh")
+	stage := `
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: $AIFLOW_TEST_CMD
+    prompt_file: plan.md
+    next_state: In Progress
+`
+	const prompt = "Cost: $FOO and ${FOO}, $$ stays, $1 too.\n"
+	cfg, err := loadYAML(t, baseYAML+stage, map[string]string{"plan.md": prompt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := cfg.Pipeline.Stages[0]
+	if plan.Prompt != prompt {
+		t.Errorf("prompt = %q, want the file verbatim %q", plan.Prompt, prompt)
+	}
+	if plan.Command != "sh" {
+		t.Errorf("command = %q, want the config itself env-expanded", plan.Command)
+	}
+}
+
+// sharedStateYAML is two stages on Todo, with bugLabels and featureLabels
+// added to them as extra stage lines.
+func sharedStateYAML(bugLabels, featureLabels string) string {
+	return `
+pipeline:
+  - name: triage-bug
+    linear_state: Todo
+    command: sh
+    prompt: Triage the bug.
+    next_state: In Progress
+` + bugLabels + `
+  - name: triage-feature
+    linear_state: todo
+    command: sh
+    prompt: Triage the feature.
+    next_state: In Progress
+` + featureLabels
+}
+
+func TestDuplicateStateNeedsDisjointLabels(t *testing.T) {
+	if _, err := loadYAML(t, baseYAML+sharedStateYAML("    labels: [bug]", "    labels: [feature, Enhancement]\n"), nil); err != nil {
+		t.Errorf("disjoint labels rejected: %v", err)
+	}
+
+	for name, labels := range map[string][2]string{
+		"overlapping":      {"    labels: [bug, Feature]", "    labels: [feature]\n"},
+		"one without any":  {"    labels: [bug]", ""},
+		"both without any": {"", ""},
+	} {
+		_, err := loadYAML(t, baseYAML+sharedStateYAML(labels[0], labels[1]), nil)
+		if err == nil || !strings.Contains(err.Error(), `duplicate linear_state "todo"`) {
+			t.Errorf("%s labels: err = %v, want the duplicate state rejected", name, err)
+		}
+	}
+
+	// A disabled stage doesn't claim its state
+	disabled := sharedStateYAML("", "    enabled: false\n")
+	if _, err := loadYAML(t, baseYAML+disabled, nil); err != nil {
+		t.Errorf("duplicate state with a disabled stage rejected: %v", err)
+	}
+}
+
+func TestFindStageByLabel(t *testing.T) {
+	cfg, err := loadYAML(t, baseYAML+sharedStateYAML("    labels: [bug]", "    labels: [feature]\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		labels []string
+		want   string
+	}{
+		{[]string{"bug"}, "triage-bug"},
+		{[]string{"Feature"}, "triage-feature"},
+		{[]string{"docs", "feature"}, "triage-feature"},
+	}
+	for _, tt := range tests {
+		stage := cfg.FindStage("ENG", "Todo", tt.labels)
+		if stage == nil || stage.Name != tt.want {
+			t.Errorf("FindStage(Todo, %q) = %v, want %s", tt.labels, stage, tt.want)
+		}
+	}
+	if stage := cfg.FindStage("ENG", "Done", []string{"bug"}); stage != nil {
+		t.Errorf("FindStage(Done) = %s, want nil", stage.Name)
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	cfgYAML := `
+linear:
+  api_key: test-key
+  team_key: ENG
+  webhook_secret: secret
+  http_timeout: soon
+  max_labels: -1
+subprocess:
+  skip_command_check: true
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    prompt: Plan it.
+    next_state: In Progress
+    label_match: some
+    comment_target: sibling
+    branch_from: elsewhere
+`
+	var cfg Config
+	if err := yaml.Unmarshal([]byte(cfgYAML), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		"http_timeout",
+		"max_labels",
+		"label_match",
+		"comment_target",
+		"branch_from",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s:\n%v", want, err)
+		}
+	}
+}
+
+func TestDisabledStageNeverMatched(t *testing.T) {
+	// The disabled stage shares Todo with overlapping labels and names a
+	// command that doesn't exist; neither is an error while it is disabled.
+	cfgYAML := `
+linear:
+  api_key: test-key
+  team_key: ENG
+  webhook_secret: secret
+pipeline:
+  - name: old-triage
+    enabled: false
+    linear_state: Todo
+    command: no-such-command-ai-flow
+    prompt: Triage it.
+    next_state: In Progress
+  - name: triage
+    linear_state: Todo
+    labels: [bug]
+    command: sh
+    prompt: Triage it.
+    next_state: In Progress
+  - name: review
+    enabled: false
+    linear_state: In Review
+    command: sh
+    prompt: Review it.
+    next_state: Done
+`
+	cfg, err := loadYAML(t, cfgYAML, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stage := cfg.FindStage("ENG", "Todo", []string{"bug"}); stage == nil || stage.Name != "triage" {
+		t.Errorf("FindStage(Todo, bug) = %v, want triage", stage)
+	}
+	// Without the bug label, triage is only a fallback match; the disabled
+	// stage must not be picked instead.
+	if stage := cfg.FindStage("ENG", "Todo", nil); stage == nil || stage.Name != "triage" {
+		t.Errorf("FindStage(Todo) = %v, want triage", stage)
+	}
+	if stage := cfg.FindStage("ENG", "In Review", nil); stage != nil {
+		t.Errorf("FindStage(In Review) = %s, want nil for a disabled stage", stage.Name)
+	}
+
+	enable := strings.Replace(cfgYAML, "    enabled: false\n", "", 1)
+	if _, err := loadYAML(t, enable, nil); err == nil || !strings.Contains(err.Error(), "no-such-command-ai-flow") {
+		t.Errorf("enabling the stage: err = %v, want its missing command reported", err)
+	}
+}
+
+func TestExtraHeadersEnvExpanded(t *testing.T) {
+	t.Setenv("GATEWAY_TOKEN", "gw-secret")
+	cfg, err := loadYAML(t, strings.Replace(baseYAML, "  webhook_secret: secret\n", "  webhook_secret: secret\n  extra_headers:\n    X-Gateway-Token: $GATEWAY_TOKEN\n", 1)+minimalPipelineYAML, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Linear.ExtraHeaders["X-Gateway-Token"]; got != "gw-secret" {
+		t.Errorf("X-Gateway-Token = %q, want the expanded environment variable", got)
+	}
+}
+
+func TestFindStageEmojiPrefixedState(t *testing.T) {
+	cfg, err := loadYAML(t, baseYAML+strings.Replace(minimalPipelineYAML, "linear_state: Todo", "linear_state: Ready to Deploy", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stage := cfg.FindStage("ENG", "🚀 Ready  to Deploy", nil); stage == nil || stage.Name != "plan" {
+		t.Errorf("FindStage(emoji-prefixed state) = %v, want plan", stage)
+	}
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProjectGHTimeout(t *testing.T) {
+	cfg, err := loadYAML(t, baseYAML+minimalPipelineYAML+`
+git:
+  gh_timeout: 2m
+projects:
+  App:
+    github_repo: acme/app
+    gh_timeout: 5m
+  Docs:
+    github_repo: acme/docs
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Projects["App"].ParsedGHTimeout; got != 5*time.Minute {
+		t.Errorf("App gh_timeout = %s, want 5m", got)
+	}
+	if got := cfg.Projects["Docs"].ParsedGHTimeout; got != 0 {
+		t.Errorf("Docs gh_timeout = %s, want unset so git.gh_timeout applies", got)
+	}
+	if got := cfg.Git.ParsedGHTimeout; got != 2*time.Minute {
+		t.Errorf("git.gh_timeout = %s, want 2m", got)
+	}
+
+	_, err = loadYAML(t, baseYAML+minimalPipelineYAML+`
+projects:
+  App:
+    github_repo: acme/app
+    gh_timeout: 5m
+  Web:
+    github_repo: acme/app
+    gh_timeout: 1m
+`, nil)
+	if err == nil || !strings.Contains(err.Error(), "gh_timeout conflicts") {
+		t.Errorf("err = %v, want conflicting gh_timeout on one repo rejected", err)
+	}
+}
+
+func TestProjectTrackPRState(t *testing.T) {
+	cfg, err := loadYAML(t, baseYAML+minimalPipelineYAML+`
+projects:
+  App:
+    github_repo: acme/app
+    track_pr_state: true
+  Docs:
+    github_repo: acme/docs
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.TrackPRState("acme/app") || cfg.TrackPRState("acme/docs") || cfg.TrackPRState("acme/other") {
+		t.Error("want PR state tracked for acme/app only")
+	}
+	if !cfg.TracksAnyPRState() {
+		t.Error("TracksAnyPRState = false with a tracking project")
+	}
+
+	cfg.GitHub.TrackPRState = true
+	if !cfg.TrackPRState("acme/docs") || !cfg.TrackPRState("acme/other") {
+		t.Error("want github.track_pr_state to apply to repos that don't set it")
+	}
+}
+
+func TestProjectOnPRMergedState(t *testing.T) {
+	cfg, err := loadYAML(t, baseYAML+minimalPipelineYAML+`
+github:
+  webhook_secret: gh-secret
+  on_pr_merged_state: Done
+projects:
+  Docs:
+    github_repo: acme/docs
+    on_pr_merged_state: Published
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.OnPRMergedState("acme/docs"); got != "Published" {
+		t.Errorf("acme/docs on_pr_merged_state = %q, want Published", got)
+	}
+	if got := cfg.OnPRMergedState("acme/app"); got != "Done" {
+		t.Errorf("acme/app on_pr_merged_state = %q, want github.on_pr_merged_state Done", got)
+	}
+
+	_, err = loadYAML(t, baseYAML+minimalPipelineYAML+`
+projects:
+  Docs:
+    github_repo: acme/docs
+    on_pr_merged_state: Published
+`, nil)
+	if err == nil || !strings.Contains(err.Error(), "requires github.webhook_secret") {
+		t.Errorf("err = %v, want on_pr_merged_state without a GitHub webhook secret rejected", err)
+	}
+}
+
+func TestProjectNormalizeCommits(t *testing.T) {
+	cfg, err := loadYAML(t, baseYAML+minimalPipelineYAML+`
+git:
+  normalize_commits: true
+projects:
+  App:
+    github_repo: acme/app
+  Docs:
+    github_repo: acme/docs
+    normalize_commits: false
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.NormalizeCommits("acme/app") || cfg.NormalizeCommits("acme/docs") || !cfg.NormalizeCommits("acme/other") {
+		t.Error("want commits normalized everywhere but acme/docs")
+	}
+
+	_, err = loadYAML(t, baseYAML+minimalPipelineYAML+`
+projects:
+  App:
+    github_repo: acme/app
+    normalize_commits: true
+  AppDocs:
+    github_repo: acme/app
+    normalize_commits: false
+`, nil)
+	if err == nil || !strings.Contains(err.Error(), "normalize_commits conflicts") {
+		t.Errorf("err = %v, want a normalize_commits conflict", err)
+	}
+}
+
+func TestProjectCommitIncludeDescription(t *testing.T) {
+	cfg, err := loadYAML(t, baseYAML+minimalPipelineYAML+`
+projects:
+  App:
+    github_repo: acme/app
+    commit_include_description: true
+  Docs:
+    github_repo: acme/docs
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.CommitIncludeDescription("acme/app") || cfg.CommitIncludeDescription("acme/docs") {
+		t.Error("want the description in commits on acme/app only")
+	}
+	cfg.Git.CommitIncludeDescription = true
+	if !cfg.CommitIncludeDescription("acme/docs") {
+		t.Error("want git.commit_include_description to apply to repos that don't set it")
+	}
+
+	_, err = loadYAML(t, baseYAML+minimalPipelineYAML+`
+projects:
+  App:
+    github_repo: acme/app
+    commit_include_description: true
+  AppDocs:
+    github_repo: acme/app
+    commit_include_description: false
+`, nil)
+	if err == nil || !strings.Contains(err.Error(), "commit_include_description conflicts") {
+		t.Errorf("err = %v, want a commit_include_description conflict", err)
+	}
+}
+
+func TestProjectSigningKey(t *testing.T) {
+	cfg, err := loadYAML(t, baseYAML+minimalPipelineYAML+`
+git:
+  signing_key: ABCD1234
+projects:
+  App:
+    github_repo: acme/app
+    signing_key: /keys/app.pub
+    signing_format: ssh
+  Docs:
+    github_repo: acme/docs
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := cfg.Projects["App"]; p.SigningKey != "/keys/app.pub" || p.SigningFormat != "ssh" {
+		t.Errorf("App signing = %q %q", p.SigningKey, p.SigningFormat)
+	}
+	if cfg.Git.SigningKey != "ABCD1234" || cfg.Git.SigningFormat != "openpgp" {
+		t.Errorf("git signing = %q %q, want the global key as openpgp", cfg.Git.SigningKey, cfg.Git.SigningFormat)
+	}
+
+	for _, tc := range []struct {
+		name, projects, want string
+	}{
+		{"format without key", `
+  App:
+    github_repo: acme/app
+    signing_format: ssh
+`, `projects["App"].signing_format requires signing_key`},
+		{"unknown format", `
+  App:
+    github_repo: acme/app
+    signing_key: ABCD1234
+    signing_format: x509
+`, `projects["App"].signing_format must be "openpgp" or "ssh"`},
+		{"conflict", `
+  App:
+    github_repo: acme/app
+    signing_key: ABCD1234
+  AppDocs:
+    github_repo: acme/app
+    signing_key: EF567890
+`, "signing_key/signing_format conflict"},
+	} {
+		_, err := loadYAML(t, baseYAML+minimalPipelineYAML+"projects:"+tc.projects, nil)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: err = %v, want %q", tc.name, err, tc.want)
+		}
+	}
+}
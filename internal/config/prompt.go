@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// promptFetchTimeout bounds fetching a prompt_file given as a URL.
+const promptFetchTimeout = 15 * time.Second
+
+// maxPromptSize caps how much of a remote prompt is read.
+const maxPromptSize = 1 << 20
+
+var (
+	promptCacheMu sync.Mutex
+	promptCache   = make(map[string]string) // URL → fetched prompt
+)
+
+// isPromptURL reports whether a prompt_file refers to an http(s) URL.
+func isPromptURL(ref string) bool {
+	return strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://")
+}
+
+// readPrompt loads a prompt_file: an http(s) URL is fetched (once per
+// process), anything else is read as a path relative to configDir.
+func readPrompt(configDir, ref string) (string, error) {
+	if isPromptURL(ref) {
+		return fetchPrompt(ref)
+	}
+	path := ref
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(configDir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// fetchPrompt GETs a remote prompt, caching it so stages sharing a URL only
+// fetch it once.
+func fetchPrompt(url string) (string, error) {
+	promptCacheMu.Lock()
+	defer promptCacheMu.Unlock()
+	if prompt, ok := promptCache[url]; ok {
+		return prompt, nil
+	}
+
+	client := &http.Client{Timeout: promptFetchTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("fetching prompt: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching prompt: server returned %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPromptSize+1))
+	if err != nil {
+		return "", fmt.Errorf("reading prompt: %w", err)
+	}
+	if len(data) > maxPromptSize {
+		return "", fmt.Errorf("prompt exceeds %d bytes", maxPromptSize)
+	}
+
+	promptCache[url] = string(data)
+	return string(data), nil
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// promptStage is a one-stage pipeline whose prompt source is src, a
+// "prompt: ..." or "prompt_file: ..." line.
+func promptStage(src string) string {
+	return `
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    ` + src + `
+    next_state: In Progress
+`
+}
+
+func TestLocalPromptFile(t *testing.T) {
+	cfg, err := loadYAML(t, baseYAML+promptStage("prompt_file: plan.md"), map[string]string{"plan.md": "Plan from a file."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Pipeline.Stages[0].Prompt; got != "Plan from a file." {
+		t.Errorf("prompt = %q", got)
+	}
+
+	if _, err := loadYAML(t, baseYAML+promptStage("prompt_file: missing.md"), nil); err == nil {
+		t.Error("Load accepted a prompt_file that does not exist")
+	}
+}
+
+func TestInlinePrompt(t *testing.T) {
+	cfg, err := loadYAML(t, baseYAML+promptStage("prompt: |\n      Plan it\n      in two lines."), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Pipeline.Stages[0].Prompt; got != "Plan it\nin two lines.\n" {
+		t.Errorf("prompt = %q", got)
+	}
+
+	_, err = loadYAML(t, baseYAML+promptStage("prompt: Plan it.\n    prompt_file: plan.md"), map[string]string{"plan.md": "x"})
+	if err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("prompt with prompt_file: err = %v, want mutually exclusive", err)
+	}
+}
+
+func TestPromptFileURL(t *testing.T) {
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if r.URL.Path != "/prompts/plan.md" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("Plan from the server."))
+	}))
+	defer srv.Close()
+
+	url := srv.URL + "/prompts/plan.md"
+	for range 2 {
+		cfg, err := loadYAML(t, baseYAML+promptStage("prompt_file: "+url), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cfg.Pipeline.Stages[0].Prompt; got != "Plan from the server." {
+			t.Errorf("prompt = %q", got)
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("prompt fetched %d times, want once (cached)", got)
+	}
+}
+
+func TestPromptFileURLFetchFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusInternalServerError)
+	}))
+	url := srv.URL + "/plan.md"
+
+	_, err := loadYAML(t, baseYAML+promptStage("prompt_file: "+url), nil)
+	if err == nil {
+		t.Fatal("Load accepted a prompt URL that returned 500")
+	}
+	for _, want := range []string{"prompt_file", "500"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+
+	// An unreachable server fails the load too
+	srv.Close()
+	if _, err := loadYAML(t, baseYAML+promptStage("prompt_file: "+srv.URL+"/other.md"), nil); err == nil {
+		t.Error("Load accepted a prompt URL whose server is down")
+	}
+}
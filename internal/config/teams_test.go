@@ -0,0 +1,91 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// multiTeamYAML gives OPS and WEB pipelines of their own next to the default
+// stages. Both OPS stages pick up Todo, told apart by label.
+const multiTeamYAML = `
+pipeline:
+  stages:
+    - name: plan
+      linear_state: Todo
+      command: sh
+      prompt: Plan it.
+      next_state: In Progress
+  teams:
+    WEB:
+      - name: design
+        linear_state: Todo
+        command: sh
+        prompt: Design it.
+        next_state: In Review
+    OPS:
+      - name: triage
+        linear_state: Todo
+        labels: [routine]
+        command: sh
+        prompt: Triage it.
+        next_state: Backlog
+      - name: hotfix
+        linear_state: Todo
+        labels: [incident]
+        command: sh
+        prompt: Fix it now.
+        next_state: Done
+`
+
+func TestMultiTeamPipeline(t *testing.T) {
+	cfg, err := loadYAML(t, baseYAML+multiTeamYAML, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cfg.TeamKeys(), []string{"ENG", "OPS", "WEB"}; !slices.Equal(got, want) {
+		t.Errorf("TeamKeys() = %q, want %q", got, want)
+	}
+
+	tests := []struct {
+		team   string
+		labels []string
+		want   string
+	}{
+		{"ENG", nil, "plan"},
+		{"WEB", nil, "design"},
+		{"OPS", []string{"routine"}, "triage"},
+		{"OPS", nil, "triage"},
+		{"OPS", []string{"incident"}, "hotfix"},
+		{"DATA", nil, "plan"}, // teams without a pipeline use the default stages
+	}
+	for _, tt := range tests {
+		stage := cfg.FindStage(tt.team, "Todo", tt.labels)
+		if stage == nil || stage.Name != tt.want {
+			t.Errorf("FindStage(%s, Todo, %q) = %v, want %s", tt.team, tt.labels, stage, tt.want)
+		}
+	}
+	if stage := cfg.FindStage("OPS", "In Progress", nil); stage != nil {
+		t.Errorf("OPS found stage %s for a state only the default pipeline uses", stage.Name)
+	}
+}
+
+func TestTeamKeysListsDefaultTeamOnce(t *testing.T) {
+	cfg, err := loadYAML(t, baseYAML+strings.Replace(multiTeamYAML, "    WEB:", "    ENG:", 1), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cfg.TeamKeys(), []string{"ENG", "OPS"}; !slices.Equal(got, want) {
+		t.Errorf("TeamKeys() = %q, want %q", got, want)
+	}
+	if stage := cfg.FindStage("ENG", "Todo", nil); stage == nil || stage.Name != "design" {
+		t.Errorf("ENG stage = %v, want its pipeline.teams entry over the default stages", stage)
+	}
+}
+
+func TestTeamWithoutStagesIsRejected(t *testing.T) {
+	_, err := loadYAML(t, baseYAML+multiTeamYAML+"    DATA: []\n", nil)
+	if err == nil || !strings.Contains(err.Error(), "pipeline.teams.DATA") {
+		t.Errorf("err = %v, want pipeline.teams.DATA rejected", err)
+	}
+}
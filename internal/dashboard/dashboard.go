@@ -8,10 +8,10 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/mauza/ai-flow/internal/jsonapi"
 	"github.com/mauza/ai-flow/internal/store"
 )
 
-
 // Dashboard serves the web UI and API endpoints.
 type Dashboard struct {
 	registry *Registry
@@ -89,7 +89,7 @@ func (d *Dashboard) handleListSessions(w http.ResponseWriter, _ *http.Request) {
 			StartedAt:       s.StartedAt,
 		})
 	}
-	writeJSON(w, summaries)
+	jsonapi.Write(w, summaries)
 }
 
 func (d *Dashboard) handleGetSession(w http.ResponseWriter, r *http.Request) {
@@ -99,7 +99,7 @@ func (d *Dashboard) handleGetSession(w http.ResponseWriter, r *http.Request) {
 	}
 	s := d.registry.Get(runID)
 	if s == nil {
-		http.Error(w, "session not found", http.StatusNotFound)
+		jsonapi.Error(w, http.StatusNotFound, "session not found")
 		return
 	}
 	s.mu.Lock()
@@ -107,7 +107,7 @@ func (d *Dashboard) handleGetSession(w http.ResponseWriter, r *http.Request) {
 	copy(output, s.buf)
 	s.mu.Unlock()
 
-	writeJSON(w, SessionDetail{
+	jsonapi.Write(w, SessionDetail{
 		SessionSummary: SessionSummary{
 			RunID:           s.RunID,
 			IssueID:         s.IssueID,
@@ -127,7 +127,7 @@ func (d *Dashboard) handleKillSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if !d.registry.Kill(runID) {
-		http.Error(w, "session not found", http.StatusNotFound)
+		jsonapi.Error(w, http.StatusNotFound, "session not found")
 		return
 	}
 	slog.Info("session killed via dashboard", "runID", runID)
@@ -143,14 +143,14 @@ func (d *Dashboard) handleStreamSession(w http.ResponseWriter, r *http.Request)
 
 	snapshot, ch, session, found := d.registry.Subscribe(runID)
 	if !found {
-		http.Error(w, "session not found", http.StatusNotFound)
+		jsonapi.Error(w, http.StatusNotFound, "session not found")
 		return
 	}
 	defer d.registry.Unsubscribe(runID, ch)
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		jsonapi.Error(w, http.StatusInternalServerError, "streaming not supported")
 		return
 	}
 
@@ -204,21 +204,21 @@ func (d *Dashboard) handleListRuns(w http.ResponseWriter, _ *http.Request) {
 	runs, err := d.store.ListRecentRuns(50)
 	if err != nil {
 		slog.Error("listing recent runs", "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		jsonapi.Error(w, http.StatusInternalServerError, "internal error")
 		return
 	}
 	// Omit output from list to keep payload small
 	type runSummary struct {
-		ID         int64      `json:"id"`
-		IssueID    string     `json:"issue_id"`
-		StageName  string     `json:"stage_name"`
-		Status     string     `json:"status"`
-		ExitCode   *int       `json:"exit_code"`
-		PRURL      string     `json:"pr_url"`
-		BranchName string     `json:"branch_name"`
-		Error      string     `json:"error"`
-		StartedAt  any        `json:"started_at"`
-		EndedAt    any        `json:"ended_at"`
+		ID         int64  `json:"id"`
+		IssueID    string `json:"issue_id"`
+		StageName  string `json:"stage_name"`
+		Status     string `json:"status"`
+		ExitCode   *int   `json:"exit_code"`
+		PRURL      string `json:"pr_url"`
+		BranchName string `json:"branch_name"`
+		Error      string `json:"error"`
+		StartedAt  any    `json:"started_at"`
+		EndedAt    any    `json:"ended_at"`
 	}
 	summaries := make([]runSummary, 0, len(runs))
 	for _, r := range runs {
@@ -236,27 +236,27 @@ func (d *Dashboard) handleListRuns(w http.ResponseWriter, _ *http.Request) {
 		}
 		summaries = append(summaries, s)
 	}
-	writeJSON(w, summaries)
+	jsonapi.Write(w, summaries)
 }
 
 func (d *Dashboard) handleGetRun(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "invalid id", http.StatusBadRequest)
+		jsonapi.Error(w, http.StatusBadRequest, "invalid id")
 		return
 	}
 	run, err := d.store.GetRun(id)
 	if err != nil {
 		slog.Error("getting run", "id", id, "error", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		jsonapi.Error(w, http.StatusInternalServerError, "internal error")
 		return
 	}
 	if run == nil {
-		http.Error(w, "run not found", http.StatusNotFound)
+		jsonapi.Error(w, http.StatusNotFound, "run not found")
 		return
 	}
-	writeJSON(w, run)
+	jsonapi.Write(w, run)
 }
 
 // --- helpers ---
@@ -264,16 +264,8 @@ func (d *Dashboard) handleGetRun(w http.ResponseWriter, r *http.Request) {
 func parseRunID(w http.ResponseWriter, r *http.Request) (int64, bool) {
 	runID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
-		http.Error(w, "invalid id", http.StatusBadRequest)
+		jsonapi.Error(w, http.StatusBadRequest, "invalid id")
 		return 0, false
 	}
 	return runID, true
 }
-
-func writeJSON(w http.ResponseWriter, v any) {
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(v); err != nil {
-		slog.Error("encoding JSON response", "error", err)
-	}
-}
-
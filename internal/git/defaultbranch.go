@@ -0,0 +1,52 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// DefaultBranch returns the branch a repo's HEAD points at on GitHub, as
+// reported by `git ls-remote --symref`. Results are cached for the life of
+// the Manager.
+func (m *Manager) DefaultBranch(ctx context.Context, repo string) (string, error) {
+	if branch, ok := m.defaultBranches.Load(repo); ok {
+		return branch.(string), nil
+	}
+
+	var branch string
+	err := m.withRetry(ctx, "ls-remote", func() error {
+		cmd := exec.CommandContext(ctx, "git", "ls-remote", "--symref", remoteURL(repo), "HEAD")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("git ls-remote: %s: %w", strings.TrimSpace(string(out)), err)
+		}
+		var ok bool
+		branch, ok = parseSymrefHead(string(out))
+		if !ok {
+			return fmt.Errorf("git ls-remote: no HEAD symref for %s", repo)
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	m.defaultBranches.Store(repo, branch)
+	return branch, nil
+}
+
+// parseSymrefHead extracts the branch from `git ls-remote --symref <url> HEAD`
+// output, whose first line reads "ref: refs/heads/<branch>\tHEAD".
+func parseSymrefHead(output string) (string, bool) {
+	for line := range strings.Lines(output) {
+		ref, target, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if !ok || target != "HEAD" {
+			continue
+		}
+		if branch, ok := strings.CutPrefix(ref, "ref: refs/heads/"); ok && branch != "" {
+			return branch, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,49 @@
+package git
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+func TestParseSymrefHead(t *testing.T) {
+	tests := []struct {
+		name, output, want string
+		ok                 bool
+	}{
+		{"main", "ref: refs/heads/main\tHEAD\n4e1243bd22c66e76c2ba9eddc1f91394e57f9f83\tHEAD\n", "main", true},
+		{"master", "ref: refs/heads/master\tHEAD\n4e1243bd22c66e76c2ba9eddc1f91394e57f9f83\tHEAD\n", "master", true},
+		{"slashed name", "ref: refs/heads/release/v2\tHEAD\n4e1243bd22c66e76c2ba9eddc1f91394e57f9f83\tHEAD\n", "release/v2", true},
+		{"detached", "4e1243bd22c66e76c2ba9eddc1f91394e57f9f83\tHEAD\n", "", false},
+		{"empty repo", "", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := parseSymrefHead(tt.output)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: parseSymrefHead = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDefaultBranchFollowsRemoteHead(t *testing.T) {
+	repos := testutil.NewGit(t)
+	bare := repos.Remote(t, "acme/app")
+	testutil.RunGit(t, bare, "branch", "trunk", "main")
+	testutil.RunGit(t, bare, "symbolic-ref", "HEAD", "refs/heads/trunk")
+
+	m := &Manager{}
+	got, err := m.DefaultBranch(context.Background(), "acme/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "trunk" {
+		t.Errorf("default branch = %q, want trunk", got)
+	}
+
+	// Cached for the life of the manager
+	testutil.RunGit(t, bare, "symbolic-ref", "HEAD", "refs/heads/main")
+	if got, _ := m.DefaultBranch(context.Background(), "acme/app"); got != "trunk" {
+		t.Errorf("default branch after HEAD moved = %q, want the cached trunk", got)
+	}
+}
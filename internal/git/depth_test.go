@@ -0,0 +1,46 @@
+package git
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+func TestCloneDepth(t *testing.T) {
+	repos := testutil.NewGit(t)
+	bare := repos.Remote(t, "acme/app")
+	repos.Commit(t, bare, "main", "a.txt", "a\n")
+	repos.Commit(t, bare, "main", "b.txt", "b\n")
+
+	m := &Manager{AuthorName: "ai-flow", AuthorEmail: "ai-flow@noreply"}
+	ctx := context.Background()
+
+	shallow := filepath.Join(t.TempDir(), "shallow")
+	if err := m.Clone(ctx, "acme/app", "main", shallow, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.RunGit(t, shallow, "rev-list", "--count", "HEAD"); got != "1" {
+		t.Errorf("depth 1 clone has %s commits, want 1", got)
+	}
+
+	full := filepath.Join(t.TempDir(), "full")
+	if err := m.Clone(ctx, "acme/app", "main", full, 0); err != nil {
+		t.Fatal(err)
+	}
+	if isShallow(ctx, full) {
+		t.Error("depth 0 clone is shallow")
+	}
+	if got := testutil.RunGit(t, full, "rev-list", "--count", "HEAD"); got != "3" {
+		t.Errorf("depth 0 clone has %s commits, want 3", got)
+	}
+
+	// A workspace cloned shallow gets full history once the depth is 0
+	if err := m.Fetch(ctx, shallow, 0); err != nil {
+		t.Fatal(err)
+	}
+	if isShallow(ctx, shallow) {
+		t.Error("shallow workspace still shallow after a depth 0 fetch")
+	}
+}
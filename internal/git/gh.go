@@ -0,0 +1,87 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// maxGHOutput caps how much of a gh command's stdout and stderr is kept.
+const maxGHOutput = 1 << 20
+
+// cappedBuffer keeps the first limit bytes written to it and discards the
+// rest, so a runaway gh command can't grow memory without bound.
+type cappedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// gh runs a gh CLI command in dir under repo's gh timeout and returns its
+// stdout and stderr. A command still running when the timeout passes is
+// killed, and the error says so.
+func (m *Manager) gh(ctx context.Context, repo, dir string, args ...string) (stdout, stderr string, err error) {
+	timeout := m.ghTimeoutFor(repo)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, "gh", args...)
+	cmd.Dir = dir
+	outBuf := &cappedBuffer{limit: maxGHOutput}
+	errBuf := &cappedBuffer{limit: maxGHOutput}
+	cmd.Stdout = outBuf
+	cmd.Stderr = errBuf
+	// Don't wait on children of gh that keep the output pipes open
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	if err != nil && timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s: %w", timeout, ctx.Err())
+	}
+	return outBuf.String(), strings.TrimSpace(errBuf.String()), err
+}
+
+// ghTimeoutFor returns the gh timeout for repo: its SetGHTimeout override, or
+// GHTimeout.
+func (m *Manager) ghTimeoutFor(repo string) time.Duration {
+	if d, ok := m.ghTimeouts[repo]; ok {
+		return d
+	}
+	return m.GHTimeout
+}
+
+// RepoOfPR returns the owner/repo of a GitHub PR URL
+// (https://github.com/owner/repo/pull/123 → "owner/repo"), or "".
+func RepoOfPR(prURL string) string {
+	before, _, ok := strings.Cut(prURL, "/pull/")
+	if !ok {
+		return ""
+	}
+	parts := strings.Split(before, "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[len(parts)-2] + "/" + parts[len(parts)-1]
+}
+
+// ghMessage picks the text to report for a failed gh command: its stderr, or
+// its stdout if gh printed the error there.
+func ghMessage(stdout, stderr string) string {
+	if stderr != "" {
+		return stderr
+	}
+	return strings.TrimSpace(stdout)
+}
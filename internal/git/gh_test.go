@@ -0,0 +1,138 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+func TestRepoOfPR(t *testing.T) {
+	tests := map[string]string{
+		"https://github.com/acme/app/pull/12":        "acme/app",
+		"https://github.com/acme/app/pull/12/files":  "acme/app",
+		"https://github.example.com/acme/app/pull/3": "acme/app",
+		"https://github.com/acme/app":                "",
+		"":                                           "",
+	}
+	for url, want := range tests {
+		if got := RepoOfPR(url); got != want {
+			t.Errorf("RepoOfPR(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestGHTimeoutPerRepo(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "gh"), []byte("#!/bin/sh\nexec sleep 5\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	m := &Manager{GHTimeout: 300 * time.Millisecond}
+	m.SetGHTimeout("acme/app", 100*time.Millisecond)
+
+	_, err := m.PRState(context.Background(), "https://github.com/acme/app/pull/1")
+	if err == nil || !strings.Contains(err.Error(), "timed out after 100ms") {
+		t.Errorf("acme/app error = %v, want its own 100ms timeout", err)
+	}
+	_, err = m.PRState(context.Background(), "https://github.com/acme/other/pull/1")
+	if err == nil || !strings.Contains(err.Error(), "timed out after 300ms") {
+		t.Errorf("acme/other error = %v, want the global 300ms timeout", err)
+	}
+}
+
+// flakyGH puts a gh first on PATH whose first "pr create" fails with a
+// GitHub 502 and later ones print a PR URL. It returns the attempt count.
+func flakyGH(t *testing.T) (attempts func() int) {
+	t.Helper()
+	dir := t.TempDir()
+	count := filepath.Join(dir, "count")
+	script := `#!/bin/sh
+echo x >> "` + count + `"
+if [ "$(wc -l < "` + count + `")" -le 1 ]; then
+	echo "HTTP 502: Bad Gateway (https://api.github.com/graphql)" >&2
+	exit 1
+fi
+echo https://github.com/acme/app/pull/5
+`
+	if err := os.WriteFile(filepath.Join(dir, "gh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return func() int {
+		data, _ := os.ReadFile(count)
+		return strings.Count(string(data), "\n")
+	}
+}
+
+func TestCreatePRRetriesTransientFailure(t *testing.T) {
+	attempts := flakyGH(t)
+
+	m := &Manager{Retries: 2, RetryBackoff: time.Millisecond}
+	url, err := m.CreatePR(context.Background(), "acme/app", t.TempDir(), "ENG-1: Fix", "body", "main", "eng-1-fix", PROptions{})
+	if err != nil {
+		t.Fatalf("CreatePR: %v", err)
+	}
+	if url != "https://github.com/acme/app/pull/5" {
+		t.Errorf("PR URL = %q", url)
+	}
+	if got := attempts(); got != 2 {
+		t.Errorf("gh pr create attempted %d times, want 2", got)
+	}
+}
+
+func TestCreatePRFailsWithoutRetries(t *testing.T) {
+	attempts := flakyGH(t)
+
+	m := &Manager{}
+	_, err := m.CreatePR(context.Background(), "acme/app", t.TempDir(), "ENG-1: Fix", "body", "main", "eng-1-fix", PROptions{})
+	if err == nil || !strings.Contains(err.Error(), "Bad Gateway") {
+		t.Fatalf("CreatePR error = %v, want the 502", err)
+	}
+	if got := attempts(); got != 1 {
+		t.Errorf("gh pr create attempted %d times, want 1", got)
+	}
+}
+
+func TestCreatePRSkipsMissingLabels(t *testing.T) {
+	gh := testutil.NewGH(t)
+	gh.Respond(t, "label list", "bug\nAI Generated\n", "", 0)
+
+	m := &Manager{}
+	_, err := m.CreatePR(context.Background(), "acme/app", t.TempDir(), "ENG-1: Fix", "body", "main", "eng-1-fix", PROptions{
+		Reviewers: []string{"alice", "acme/backend"},
+		Assignees: []string{"@me"},
+		Labels:    []string{"ai generated", "no-such-label"},
+	})
+	if err != nil {
+		t.Fatalf("CreatePR: %v", err)
+	}
+	calls := gh.Calls("pr", "create")
+	if len(calls) != 1 {
+		t.Fatalf("gh pr create calls = %q", calls)
+	}
+	var reviewers, labels []string
+	for i, a := range calls[0] {
+		switch a {
+		case "--reviewer":
+			reviewers = append(reviewers, calls[0][i+1])
+		case "--label":
+			labels = append(labels, calls[0][i+1])
+		}
+	}
+	if !slices.Equal(reviewers, []string{"alice", "acme/backend"}) {
+		t.Errorf("reviewers = %q", reviewers)
+	}
+	if got := testutil.ArgValue(calls[0], "--assignee"); got != "@me" {
+		t.Errorf("assignee = %q, want @me", got)
+	}
+	if !slices.Equal(labels, []string{"ai generated"}) {
+		t.Errorf("labels = %q, want only the one the repo has", labels)
+	}
+}
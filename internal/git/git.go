@@ -3,11 +3,17 @@ package git
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Manager wraps git and gh CLI commands for repository operations.
@@ -15,6 +21,114 @@ type Manager struct {
 	// Git author identity for commits in temp clones.
 	AuthorName  string
 	AuthorEmail string
+
+	// identities overrides AuthorName/AuthorEmail per repo; see SetIdentity.
+	identities map[string]identity
+
+	// SigningKey, if set, signs every commit in clones with this key:
+	// user.signingkey for SigningFormat "openpgp" (a GPG key ID, the default)
+	// or "ssh" (a public key file or "key::" literal). signingKeys overrides
+	// it per repo; see SetSigningKey.
+	SigningKey    string
+	SigningFormat string
+	signingKeys   map[string]signingKey
+
+	// Retries is how many times a network operation (clone, fetch, push) is
+	// retried after a transient failure; RetryBackoff is the initial delay.
+	Retries      int
+	RetryBackoff time.Duration
+
+	// MirrorRoot, if set, holds local bare mirrors that clones borrow objects
+	// from via --reference, so only missing objects cross the network.
+	MirrorRoot  string
+	mirrorLocks sync.Map // repo → *sync.Mutex guarding mirror creation
+	repoLocks   sync.Map // primary clone dir → *sync.Mutex guarding worktree changes
+
+	defaultBranches sync.Map // repo → branch HEAD points at, from DefaultBranch
+
+	// GHTimeout bounds each gh CLI call; zero means no limit beyond the
+	// caller's context. ghTimeouts overrides it per repo; see SetGHTimeout.
+	GHTimeout  time.Duration
+	ghTimeouts map[string]time.Duration
+
+	// sem bounds concurrent network operations; nil means unlimited.
+	sem chan struct{}
+}
+
+// identity is a commit author; empty fields fall back to the manager's.
+type identity struct {
+	name, email string
+}
+
+// SetIdentity makes clones of repo commit as name/email instead of
+// AuthorName/AuthorEmail. An empty name or email keeps the default for that
+// field. Call it before the manager is used.
+func (m *Manager) SetIdentity(repo, name, email string) {
+	if m.identities == nil {
+		m.identities = make(map[string]identity)
+	}
+	m.identities[repo] = identity{name: name, email: email}
+}
+
+// signingKey is a commit signing key and its gpg.format.
+type signingKey struct {
+	key, format string
+}
+
+// SetSigningKey makes clones of repo sign commits with key, in format
+// ("openpgp" if empty), instead of SigningKey. Call it before the manager is
+// used.
+func (m *Manager) SetSigningKey(repo, key, format string) {
+	if m.signingKeys == nil {
+		m.signingKeys = make(map[string]signingKey)
+	}
+	m.signingKeys[repo] = signingKey{key: key, format: format}
+}
+
+// signingKeyFor returns the signing key and format for clones of repo, or an
+// empty key if their commits aren't signed.
+func (m *Manager) signingKeyFor(repo string) (key, format string) {
+	key, format = m.SigningKey, m.SigningFormat
+	if k, ok := m.signingKeys[repo]; ok {
+		key, format = k.key, k.format
+	}
+	if format == "" {
+		format = "openpgp"
+	}
+	return key, format
+}
+
+// SetGHTimeout makes gh calls against repo time out after d instead of
+// GHTimeout. Call it before the manager is used.
+func (m *Manager) SetGHTimeout(repo string, d time.Duration) {
+	if m.ghTimeouts == nil {
+		m.ghTimeouts = make(map[string]time.Duration)
+	}
+	m.ghTimeouts[repo] = d
+}
+
+// identityFor returns the commit name and email for clones of repo.
+func (m *Manager) identityFor(repo string) (name, email string) {
+	name, email = m.AuthorName, m.AuthorEmail
+	if id, ok := m.identities[repo]; ok {
+		if id.name != "" {
+			name = id.name
+		}
+		if id.email != "" {
+			email = id.email
+		}
+	}
+	return name, email
+}
+
+// SetMaxConcurrent limits how many clones, fetches, and pushes run at once,
+// independently of the subprocess limit. n <= 0 removes the limit.
+func (m *Manager) SetMaxConcurrent(n int) {
+	if n <= 0 {
+		m.sem = nil
+		return
+	}
+	m.sem = make(chan struct{}, n)
 }
 
 // NewManager creates a new git Manager after verifying that git and gh are available.
@@ -31,60 +145,116 @@ func NewManager() (*Manager, error) {
 		return nil, fmt.Errorf("required tools not found in PATH: %s", strings.Join(missing, ", "))
 	}
 	return &Manager{
-		AuthorName:  "ai-flow",
-		AuthorEmail: "ai-flow@noreply",
+		AuthorName:   "ai-flow",
+		AuthorEmail:  "ai-flow@noreply",
+		Retries:      2,
+		RetryBackoff: 2 * time.Second,
+		GHTimeout:    time.Minute,
 	}, nil
 }
 
-// Clone performs a shallow clone of the given repo into dir, then configures
-// the git identity so commits work even without global git config.
-func (m *Manager) Clone(ctx context.Context, repo, branch, dir string) error {
-	url := "git@github.com:" + repo + ".git"
-	cmd := exec.CommandContext(ctx, "git", "clone", "--depth", "1", "--branch", branch, url, dir)
-	out, err := cmd.CombinedOutput()
+// remoteURL returns the SSH clone URL for a GitHub "owner/repo".
+func remoteURL(repo string) string {
+	return "git@github.com:" + repo + ".git"
+}
+
+// Clone clones the given repo into dir, then configures the git identity so
+// commits work even without global git config. A depth above 0 makes a
+// shallow, single-branch clone of that many commits; 0 clones full history.
+// With a MirrorRoot configured, objects are taken from the local mirror when
+// possible.
+func (m *Manager) Clone(ctx context.Context, repo, branch, dir string, depth int) error {
+	args := []string{"clone", "--branch", branch}
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+	}
+	if m.MirrorRoot != "" {
+		if mirror, err := m.EnsureMirror(ctx, repo); err != nil {
+			slog.Warn("git mirror unavailable, cloning from remote", "repo", repo, "error", err)
+		} else {
+			// --dissociate copies the borrowed objects so the clone survives mirror pruning
+			args = append(args, "--reference", mirror, "--dissociate")
+		}
+	}
+	args = append(args, remoteURL(repo), dir)
+
+	err := m.withRetry(ctx, "clone", func() error {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("git clone: %s: %w", strings.TrimSpace(string(out)), err)
+		}
+		return nil
+	}, func() {
+		// git refuses to clone into a non-empty directory left by a failed attempt
+		os.RemoveAll(dir)
+	})
 	if err != nil {
-		return fmt.Errorf("git clone: %s: %w", strings.TrimSpace(string(out)), err)
+		return err
 	}
 
 	// Configure git identity in the clone so commits don't fail
-	if err := m.configureIdentity(ctx, dir); err != nil {
+	if err := m.configureIdentity(ctx, dir, repo); err != nil {
 		return fmt.Errorf("configuring git identity: %w", err)
 	}
 	return nil
 }
 
-// configureIdentity sets user.name and user.email in the clone's local config.
-func (m *Manager) configureIdentity(ctx context.Context, dir string) error {
-	nameCmd := exec.CommandContext(ctx, "git", "-C", dir, "config", "user.name", m.AuthorName)
+// configureIdentity sets user.name and user.email in the clone's local config
+// to the identity for repo, and turns on commit signing when SigningKey is set.
+func (m *Manager) configureIdentity(ctx context.Context, dir, repo string) error {
+	name, email := m.identityFor(repo)
+	nameCmd := exec.CommandContext(ctx, "git", "-C", dir, "config", "user.name", name)
 	if out, err := nameCmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("git config user.name: %s: %w", strings.TrimSpace(string(out)), err)
 	}
-	emailCmd := exec.CommandContext(ctx, "git", "-C", dir, "config", "user.email", m.AuthorEmail)
+	emailCmd := exec.CommandContext(ctx, "git", "-C", dir, "config", "user.email", email)
 	if out, err := emailCmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("git config user.email: %s: %w", strings.TrimSpace(string(out)), err)
 	}
+	key, format := m.signingKeyFor(repo)
+	if key == "" {
+		return nil
+	}
+	// commit.gpgsign also covers commits the subprocess makes itself
+	for _, kv := range [][2]string{
+		{"gpg.format", format},
+		{"user.signingkey", key},
+		{"commit.gpgsign", "true"},
+	} {
+		cmd := exec.CommandContext(ctx, "git", "-C", dir, "config", kv[0], kv[1])
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git config %s: %s: %w", kv[0], strings.TrimSpace(string(out)), err)
+		}
+	}
 	return nil
 }
 
-// Fetch fetches all refs from origin, unshallowing if necessary.
-func (m *Manager) Fetch(ctx context.Context, dir string) error {
-	// Unshallow if this was a shallow clone, so all refs are available
+// Fetch fetches from origin at the given depth, as for Clone: a shallow
+// workspace stays shallow, and with depth 0 a shallow one (e.g. cloned before
+// the depth was changed) is converted to full history.
+func (m *Manager) Fetch(ctx context.Context, dir string, depth int) error {
 	args := []string{"-C", dir, "fetch", "origin"}
-	if isShallow(dir) {
+	if depth > 0 {
+		args = []string{"-C", dir, "fetch", "--depth", strconv.Itoa(depth), "origin"}
+	} else if isShallow(ctx, dir) {
 		args = []string{"-C", dir, "fetch", "--unshallow", "origin"}
 	}
-	cmd := exec.CommandContext(ctx, "git", args...)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("git fetch: %s: %w", strings.TrimSpace(string(out)), err)
-	}
-	return nil
+	return m.withRetry(ctx, "fetch", func() error {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("git fetch: %s: %w", strings.TrimSpace(string(out)), err)
+		}
+		return nil
+	}, nil)
 }
 
-// isShallow returns true if the repo is a shallow clone.
-func isShallow(dir string) bool {
-	_, err := os.Stat(fmt.Sprintf("%s/.git/shallow", dir))
-	return err == nil
+// isShallow returns true if the repo is a shallow clone. Asking git rather
+// than looking for .git/shallow also covers worktrees, whose .git is a file.
+func isShallow(ctx context.Context, dir string) bool {
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "--is-shallow-repository").Output()
+	return err == nil && strings.TrimSpace(string(out)) == "true"
 }
 
 // ResetToRemote checks out the given branch and hard-resets it to match the remote,
@@ -116,6 +286,27 @@ func (m *Manager) ResetToRemote(ctx context.Context, dir, branch string) error {
 	return nil
 }
 
+// ResetHard resets dir to commit and removes untracked files, discarding any
+// changes made since.
+func (m *Manager) ResetHard(ctx context.Context, dir, commit string) error {
+	if out, err := exec.CommandContext(ctx, "git", "-C", dir, "reset", "--hard", commit).CombinedOutput(); err != nil {
+		return fmt.Errorf("git reset: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	if out, err := exec.CommandContext(ctx, "git", "-C", dir, "clean", "-fd").CombinedOutput(); err != nil {
+		return fmt.Errorf("git clean: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// HeadCommit returns the commit checked out in dir.
+func (m *Manager) HeadCommit(ctx context.Context, dir string) (string, error) {
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "HEAD").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
 // CreateBranch creates and checks out a new branch in the given directory.
 // If the branch already exists locally (e.g. from a previous stage that
 // never pushed), it checks out the existing branch instead.
@@ -131,14 +322,35 @@ func (m *Manager) CreateBranch(ctx context.Context, dir, name string) error {
 	return nil
 }
 
+// RecreateBranch points branch at origin/<base>, discarding any local commits
+// and changes on it, and checks it out. Used when a branch was deleted on the
+// remote and should start over from the base branch.
+func (m *Manager) RecreateBranch(ctx context.Context, dir, branch, base string) error {
+	cmd := exec.CommandContext(ctx, "git", "-C", dir, "checkout", "-f", "-B", branch, "origin/"+base)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git checkout -B: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	cleanCmd := exec.CommandContext(ctx, "git", "-C", dir, "clean", "-fd")
+	if out, err := cleanCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git clean: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
 // FetchAndCheckout fetches a remote branch and checks it out locally.
 // Handles the case where the local branch may or may not already exist.
 func (m *Manager) FetchAndCheckout(ctx context.Context, dir, branch string) error {
 	// Fetch with explicit refspec so origin/<branch> tracking ref is updated
 	refspec := "refs/heads/" + branch + ":refs/remotes/origin/" + branch
-	fetchCmd := exec.CommandContext(ctx, "git", "-C", dir, "fetch", "origin", refspec)
-	if out, err := fetchCmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("git fetch: %s: %w", strings.TrimSpace(string(out)), err)
+	err := m.withRetry(ctx, "fetch", func() error {
+		fetchCmd := exec.CommandContext(ctx, "git", "-C", dir, "fetch", "origin", refspec)
+		if out, err := fetchCmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git fetch: %s: %w", strings.TrimSpace(string(out)), err)
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return err
 	}
 
 	// Try creating a new local branch tracking the remote
@@ -168,6 +380,21 @@ func (m *Manager) BranchExistsOnRemote(ctx context.Context, dir, branch string)
 	return strings.TrimSpace(stdout.String()) != "", nil
 }
 
+// DiffFrom stages everything in dir, untracked files included, and returns
+// the patch from commit to the result. Commits made since commit are covered too.
+func (m *Manager) DiffFrom(ctx context.Context, dir, commit string) (string, error) {
+	if out, err := exec.CommandContext(ctx, "git", "-C", dir, "add", "-A").CombinedOutput(); err != nil {
+		return "", fmt.Errorf("git add: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	cmd := exec.CommandContext(ctx, "git", "-C", dir, "diff", "--cached", commit)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git diff: %w", err)
+	}
+	return stdout.String(), nil
+}
+
 // HasChanges returns true if the working tree has uncommitted changes.
 func (m *Manager) HasChanges(ctx context.Context, dir string) (bool, error) {
 	cmd := exec.CommandContext(ctx, "git", "-C", dir, "status", "--porcelain")
@@ -179,13 +406,52 @@ func (m *Manager) HasChanges(ctx context.Context, dir string) (bool, error) {
 	return strings.TrimSpace(stdout.String()) != "", nil
 }
 
-// HasUnpushedCommits returns true if the current branch has commits not present
-// on the given base branch (or its remote tracking ref). This detects changes
-// that a subprocess committed directly.
-func (m *Manager) HasUnpushedCommits(ctx context.Context, dir, baseBranch string) (bool, error) {
-	// Compare HEAD against the base branch's remote tracking ref
-	ref := "origin/" + baseBranch
-	cmd := exec.CommandContext(ctx, "git", "-C", dir, "rev-list", "--count", ref+"..HEAD")
+// Exclude adds pattern to the clone's info/exclude (shared with its
+// worktrees) unless it is already there, so matching files are never
+// committed.
+func (m *Manager) Exclude(ctx context.Context, dir, pattern string) error {
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "--git-path", "info/exclude").Output()
+	if err != nil {
+		return fmt.Errorf("git rev-parse: %w", err)
+	}
+	path := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == pattern {
+			return nil
+		}
+	}
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		pattern = "\n" + pattern
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", path, err)
+	}
+	if _, err := fmt.Fprintln(f, pattern); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// HasUnpushedCommits reports whether HEAD has commits the remote doesn't:
+// commits not on origin/<branch> when that branch has been fetched, otherwise
+// not on origin/<baseBranch>. This detects both commits ai-flow made and
+// commits a subprocess made directly.
+func (m *Manager) HasUnpushedCommits(ctx context.Context, dir, branch, baseBranch string) (bool, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", dir, "rev-list", "--count", upstreamRef(ctx, dir, branch, baseBranch)+"..HEAD")
 	var stdout bytes.Buffer
 	cmd.Stdout = &stdout
 	if err := cmd.Run(); err != nil {
@@ -194,14 +460,46 @@ func (m *Manager) HasUnpushedCommits(ctx context.Context, dir, baseBranch string
 	return strings.TrimSpace(stdout.String()) != "0", nil
 }
 
-// CommitAll stages all changes and commits with the given message.
-func (m *Manager) CommitAll(ctx context.Context, dir, message string) error {
+// upstreamRef returns the ref a branch's unpushed commits are counted from:
+// origin/<branch> if it has been fetched, otherwise origin/<baseBranch>.
+func upstreamRef(ctx context.Context, dir, branch, baseBranch string) string {
+	verify := exec.CommandContext(ctx, "git", "-C", dir, "rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+branch)
+	if err := verify.Run(); err == nil {
+		return "origin/" + branch
+	}
+	return "origin/" + baseBranch
+}
+
+// NormalizeCommits rewrites every unpushed commit (see HasUnpushedCommits) so
+// its author and committer are the identity configured in the clone (see
+// configureIdentity) and its message ends with a "Generated-by: ai-flow"
+// trailer. Commits already on the remote are left alone, so the result pushes
+// without force.
+func (m *Manager) NormalizeCommits(ctx context.Context, dir, branch, baseBranch string) error {
+	amend := "git commit --amend --no-edit --no-verify --allow-empty --reset-author --trailer 'Generated-by: ai-flow'"
+	cmd := exec.CommandContext(ctx, "git", "-C", dir, "rebase", "--exec", amend, upstreamRef(ctx, dir, branch, baseBranch))
+	cmd.Env = append(os.Environ(), "GIT_EDITOR=true")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		abort := exec.CommandContext(ctx, "git", "-C", dir, "rebase", "--abort")
+		_ = abort.Run()
+		return fmt.Errorf("git rebase: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// CommitAll stages all changes in dir, a clone of repo, and commits with the
+// given message, signed if repo has a signing key.
+func (m *Manager) CommitAll(ctx context.Context, repo, dir, message string) error {
 	addCmd := exec.CommandContext(ctx, "git", "-C", dir, "add", "-A")
 	if out, err := addCmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("git add: %s: %w", strings.TrimSpace(string(out)), err)
 	}
 
-	commitCmd := exec.CommandContext(ctx, "git", "-C", dir, "commit", "-m", message)
+	args := []string{"-C", dir, "commit", "-m", message}
+	if key, _ := m.signingKeyFor(repo); key != "" {
+		args = append(args, "-S")
+	}
+	commitCmd := exec.CommandContext(ctx, "git", args...)
 	if out, err := commitCmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("git commit: %s: %w", strings.TrimSpace(string(out)), err)
 	}
@@ -210,58 +508,130 @@ func (m *Manager) CommitAll(ctx context.Context, dir, message string) error {
 
 // Push pushes the branch to origin with upstream tracking.
 func (m *Manager) Push(ctx context.Context, dir, branch string) error {
-	cmd := exec.CommandContext(ctx, "git", "-C", dir, "push", "-u", "origin", branch)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("git push: %s: %w", strings.TrimSpace(string(out)), err)
-	}
-	return nil
+	return m.withRetry(ctx, "push", func() error {
+		cmd := exec.CommandContext(ctx, "git", "-C", dir, "push", "-u", "origin", branch)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("git push: %s: %w", strings.TrimSpace(string(out)), err)
+		}
+		return nil
+	}, nil)
+}
+
+// PROptions are optional settings for a pull request opened by CreatePR.
+type PROptions struct {
+	Reviewers []string // users or org/team slugs to request reviews from
+	Assignees []string // users to assign
+	Labels    []string // labels to add; ones the repo doesn't have are skipped
 }
 
 // CreatePR creates a GitHub pull request using the gh CLI and returns the PR URL.
-func (m *Manager) CreatePR(ctx context.Context, dir, title, body, base, head string) (string, error) {
-	cmd := exec.CommandContext(ctx, "gh", "pr", "create",
+// Transient failures (GitHub 5xx, dropped connections) are retried like git
+// network operations.
+func (m *Manager) CreatePR(ctx context.Context, repo, dir, title, body, base, head string, opts PROptions) (string, error) {
+	args := []string{"pr", "create",
 		"--title", title,
 		"--body", body,
 		"--base", base,
 		"--head", head,
-	)
-	cmd.Dir = dir
-	var stdout bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &bytes.Buffer{}
-	if err := cmd.Run(); err != nil {
-		stderr := cmd.Stderr.(*bytes.Buffer).String()
-		return "", fmt.Errorf("gh pr create: %s: %w", strings.TrimSpace(stderr), err)
 	}
-	return strings.TrimSpace(stdout.String()), nil
+	for _, reviewer := range opts.Reviewers {
+		args = append(args, "--reviewer", reviewer)
+	}
+	for _, assignee := range opts.Assignees {
+		args = append(args, "--assignee", assignee)
+	}
+	for _, label := range m.existingLabels(ctx, repo, dir, opts.Labels) {
+		args = append(args, "--label", label)
+	}
+
+	var prURL string
+	err := m.withRetry(ctx, "pr create", func() error {
+		stdout, stderr, err := m.gh(ctx, repo, dir, args...)
+		if err != nil {
+			return fmt.Errorf("gh pr create: %s: %w", ghMessage(stdout, stderr), err)
+		}
+		prURL = strings.TrimSpace(stdout)
+		return nil
+	}, nil)
+	if err != nil {
+		return "", err
+	}
+	return prURL, nil
 }
 
-// FindPR looks up an existing open PR for the given branch using the gh CLI.
-// Returns the PR URL if found, or empty string if no PR exists.
-func (m *Manager) FindPR(ctx context.Context, dir, branch string) (string, error) {
-	cmd := exec.CommandContext(ctx, "gh", "pr", "view", branch, "--json", "url", "--jq", ".url")
-	cmd.Dir = dir
-	var stdout bytes.Buffer
-	cmd.Stdout = &stdout
-	if err := cmd.Run(); err != nil {
+// existingLabels returns the labels that exist on the repo, logging a warning
+// for each that doesn't: gh pr create fails outright on an unknown label. If
+// the repo's labels can't be listed, all labels are returned unchecked.
+func (m *Manager) existingLabels(ctx context.Context, repo, dir string, labels []string) []string {
+	if len(labels) == 0 {
+		return nil
+	}
+	stdout, stderr, err := m.gh(ctx, repo, dir, "label", "list", "--limit", "1000", "--json", "name", "--jq", ".[].name")
+	if err != nil {
+		slog.Warn("listing repo labels, adding PR labels unchecked", "error", ghMessage(stdout, stderr))
+		return labels
+	}
+	have := make(map[string]bool)
+	for _, name := range strings.Split(stdout, "\n") {
+		have[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	var found []string
+	for _, label := range labels {
+		if have[strings.ToLower(label)] {
+			found = append(found, label)
+		} else {
+			slog.Warn("PR label doesn't exist on the repo, skipping it", "label", label)
+		}
+	}
+	return found
+}
+
+// FindPR looks up an existing open PR for the given branch of repo using the
+// gh CLI. Returns the PR URL if found, or empty string if no PR exists.
+func (m *Manager) FindPR(ctx context.Context, repo, dir, branch string) (string, error) {
+	stdout, _, err := m.gh(ctx, repo, dir, "pr", "view", branch, "--json", "url", "--jq", ".url")
+	if err != nil {
 		// gh pr view exits non-zero when no PR exists
 		return "", nil
 	}
-	return strings.TrimSpace(stdout.String()), nil
+	return strings.TrimSpace(stdout), nil
 }
 
 // CommentOnPR posts a comment on an existing PR using the gh CLI.
 func (m *Manager) CommentOnPR(ctx context.Context, dir, prURL, body string) error {
-	cmd := exec.CommandContext(ctx, "gh", "pr", "comment", prURL, "--body", body)
-	cmd.Dir = dir
-	out, err := cmd.CombinedOutput()
+	stdout, stderr, err := m.gh(ctx, RepoOfPR(prURL), dir, "pr", "comment", prURL, "--body", body)
 	if err != nil {
-		return fmt.Errorf("gh pr comment: %s: %w", strings.TrimSpace(string(out)), err)
+		return fmt.Errorf("gh pr comment: %s: %w", ghMessage(stdout, stderr), err)
 	}
 	return nil
 }
 
+// ErrMergeConflict is returned (wrapped) by MergePR when GitHub reports that
+// the PR cannot be merged cleanly into its base.
+var ErrMergeConflict = errors.New("merge conflict")
+
+// MergePR merges an open PR using the gh CLI.
+func (m *Manager) MergePR(ctx context.Context, dir, prURL string) error {
+	stdout, stderr, err := m.gh(ctx, RepoOfPR(prURL), dir, "pr", "merge", prURL, "--merge")
+	if err != nil {
+		msg := ghMessage(stdout, stderr)
+		if isMergeConflict(msg) {
+			return fmt.Errorf("gh pr merge: %s: %w", msg, ErrMergeConflict)
+		}
+		return fmt.Errorf("gh pr merge: %s: %w", msg, err)
+	}
+	return nil
+}
+
+// isMergeConflict reports whether gh's output indicates the PR conflicts with its base.
+func isMergeConflict(output string) bool {
+	lower := strings.ToLower(output)
+	return strings.Contains(lower, "merge conflict") ||
+		strings.Contains(lower, "cannot be cleanly created") ||
+		strings.Contains(lower, "is not mergeable")
+}
+
 // Cleanup removes the temporary directory.
 func (m *Manager) Cleanup(dir string) {
 	os.RemoveAll(dir)
@@ -281,3 +651,13 @@ func SanitizeBranchName(identifier, title string) string {
 	}
 	return sanitized
 }
+
+// StackedBranchName returns the branch name for a stage stacked on parent,
+// e.g. "eng-123-add-login" + "tests" → "eng-123-add-login-tests".
+func StackedBranchName(parent, stageName string) string {
+	suffix := strings.Trim(nonAlphanumeric.ReplaceAllString(strings.ToLower(stageName), "-"), "-")
+	if suffix == "" {
+		return parent
+	}
+	return parent + "-" + suffix
+}
@@ -0,0 +1,46 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// gitWrapper puts a git script first on PATH that logs its arguments, runs
+// prelude (shell, with the log at $GIT_LOG and git at $REAL_GIT), and then
+// execs the real git. It
+// returns a function listing the logged invocations whose leading arguments
+// match prefix.
+func gitWrapper(t *testing.T, prelude string) (calls func(prefix ...string) [][]string) {
+	t.Helper()
+	realGit, err := exec.LookPath("git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	log := filepath.Join(dir, "calls")
+	script := "#!/bin/sh\nGIT_LOG=" + log + "\nREAL_GIT=" + realGit + "\n" +
+		"{ for a in \"$@\"; do printf '%s\\037' \"$a\"; done; printf '\\036'; } >> \"$GIT_LOG\"\n" +
+		prelude + "\nexec \"$REAL_GIT\" \"$@\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "git"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return func(prefix ...string) [][]string {
+		data, _ := os.ReadFile(log)
+		var calls [][]string
+		for _, rec := range strings.Split(string(data), "\036") {
+			if rec == "" {
+				continue
+			}
+			args := strings.Split(strings.TrimSuffix(rec, "\037"), "\037")
+			if len(args) >= len(prefix) && slices.Equal(args[:len(prefix)], prefix) {
+				calls = append(calls, args)
+			}
+		}
+		return calls
+	}
+}
@@ -0,0 +1,40 @@
+package git
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+func TestCloneIdentityPerRepo(t *testing.T) {
+	repos := testutil.NewGit(t)
+	for _, repo := range []string{"acme/app", "acme/docs", "acme/web"} {
+		repos.Remote(t, repo)
+	}
+	ctx := context.Background()
+
+	m := &Manager{AuthorName: "ai-flow", AuthorEmail: "ai-flow@noreply"}
+	m.SetIdentity("acme/app", "App Bot", "app-bot@example.com")
+	m.SetIdentity("acme/docs", "", "docs-bot@example.com")
+
+	for _, tc := range []struct {
+		repo, name, email string
+	}{
+		{"acme/app", "App Bot", "app-bot@example.com"},
+		{"acme/docs", "ai-flow", "docs-bot@example.com"}, // name falls back to the global
+		{"acme/web", "ai-flow", "ai-flow@noreply"},
+	} {
+		dir := filepath.Join(t.TempDir(), "clone")
+		if err := m.Clone(ctx, tc.repo, "main", dir, 1); err != nil {
+			t.Fatalf("%s: %v", tc.repo, err)
+		}
+		if got := testutil.RunGit(t, dir, "config", "--local", "user.name"); got != tc.name {
+			t.Errorf("%s: user.name = %q, want %q", tc.repo, got, tc.name)
+		}
+		if got := testutil.RunGit(t, dir, "config", "--local", "user.email"); got != tc.email {
+			t.Errorf("%s: user.email = %q, want %q", tc.repo, got, tc.email)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package git
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+// overlappingClones counts clones that started while another was running,
+// using a lock directory the git wrapper holds for the length of each clone.
+func overlappingClones(t *testing.T) func() int {
+	t.Helper()
+	calls := gitWrapper(t, `if [ "$1" = clone ]; then
+	mkdir "$GIT_LOG.lock" 2>/dev/null || printf 'overlap\037\036' >> "$GIT_LOG"
+	sleep 0.3
+	"$REAL_GIT" "$@"; rc=$?
+	rmdir "$GIT_LOG.lock" 2>/dev/null
+	exit $rc
+fi`)
+	return func() int { return len(calls("overlap")) }
+}
+
+// cloneConcurrently clones acme/app n times at once.
+func cloneConcurrently(t *testing.T, m *Manager, n int) {
+	t.Helper()
+	root := t.TempDir()
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = m.Clone(context.Background(), "acme/app", "main", filepath.Join(root, strconv.Itoa(i)), 0)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMaxConcurrentSerializesClones(t *testing.T) {
+	repos := testutil.NewGit(t)
+	repos.Remote(t, "acme/app")
+	overlaps := overlappingClones(t)
+
+	m := &Manager{AuthorName: "ai-flow", AuthorEmail: "ai-flow@noreply"}
+	m.SetMaxConcurrent(1)
+	cloneConcurrently(t, m, 3)
+	if n := overlaps(); n != 0 {
+		t.Errorf("%d clones overlapped at max_concurrent 1", n)
+	}
+}
+
+func TestUnlimitedClonesRunConcurrently(t *testing.T) {
+	repos := testutil.NewGit(t)
+	repos.Remote(t, "acme/app")
+	overlaps := overlappingClones(t)
+
+	m := &Manager{AuthorName: "ai-flow", AuthorEmail: "ai-flow@noreply"}
+	cloneConcurrently(t, m, 3)
+	if n := overlaps(); n == 0 {
+		t.Error("no clones overlapped without a limit; the overlap check is not working")
+	}
+}
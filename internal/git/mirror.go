@@ -0,0 +1,89 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mirrorPath returns where the bare mirror of repo lives under MirrorRoot.
+func (m *Manager) mirrorPath(repo string) string {
+	return filepath.Join(m.MirrorRoot, repo+".git")
+}
+
+// EnsureMirror creates the local bare mirror of repo under MirrorRoot if it
+// doesn't exist yet and returns its path. Existing mirrors are brought up to
+// date by RunMirrorRefresher rather than here, to keep clones fast.
+func (m *Manager) EnsureMirror(ctx context.Context, repo string) (string, error) {
+	if m.MirrorRoot == "" {
+		return "", fmt.Errorf("no mirror root configured")
+	}
+	path := m.mirrorPath(repo)
+
+	// Serialize creation per repo so concurrent clones don't race to create it
+	lock, _ := m.mirrorLocks.LoadOrStore(repo, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	if _, err := os.Stat(filepath.Join(path, "HEAD")); err == nil {
+		return path, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("creating mirror parent: %w", err)
+	}
+
+	slog.Info("creating git mirror", "repo", repo, "path", path)
+	err := m.withRetry(ctx, "mirror clone", func() error {
+		cmd := exec.CommandContext(ctx, "git", "clone", "--mirror", remoteURL(repo), path)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("git clone --mirror: %s: %w", strings.TrimSpace(string(out)), err)
+		}
+		return nil
+	}, func() {
+		os.RemoveAll(path)
+	})
+	if err != nil {
+		os.RemoveAll(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// RefreshMirrors runs `git remote update --prune` in every mirror under MirrorRoot.
+func (m *Manager) RefreshMirrors(ctx context.Context) {
+	mirrors, _ := filepath.Glob(filepath.Join(m.MirrorRoot, "*", "*.git"))
+	for _, path := range mirrors {
+		err := m.withRetry(ctx, "mirror update", func() error {
+			cmd := exec.CommandContext(ctx, "git", "-C", path, "remote", "update", "--prune")
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				return fmt.Errorf("git remote update: %s: %w", strings.TrimSpace(string(out)), err)
+			}
+			return nil
+		}, nil)
+		if err != nil {
+			slog.Warn("refreshing git mirror", "path", path, "error", err)
+		}
+	}
+}
+
+// RunMirrorRefresher refreshes all mirrors every interval until ctx is cancelled.
+func (m *Manager) RunMirrorRefresher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.RefreshMirrors(ctx)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+func TestCloneReferencesMirror(t *testing.T) {
+	repos := testutil.NewGit(t)
+	bare := repos.Remote(t, "acme/app")
+	calls := gitWrapper(t, "")
+
+	m := &Manager{AuthorName: "ai-flow", AuthorEmail: "ai-flow@noreply", MirrorRoot: t.TempDir()}
+	mirror, err := m.EnsureMirror(context.Background(), "acme/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(m.MirrorRoot, "acme/app.git"); mirror != want {
+		t.Errorf("mirror path = %s, want %s", mirror, want)
+	}
+
+	dir := filepath.Join(t.TempDir(), "app")
+	if err := m.Clone(context.Background(), "acme/app", "main", dir, 0); err != nil {
+		t.Fatal(err)
+	}
+	clones := calls("clone", "--branch")
+	if len(clones) != 1 {
+		t.Fatalf("got %d clones, want 1: %q", len(clones), calls())
+	}
+	i := slices.Index(clones[0], "--reference")
+	if i < 0 || i+1 >= len(clones[0]) || clones[0][i+1] != mirror || !slices.Contains(clones[0], "--dissociate") {
+		t.Errorf("clone args = %q, want --reference %s --dissociate", clones[0], mirror)
+	}
+	if got := len(calls("clone", "--mirror")); got != 1 {
+		t.Errorf("mirror cloned %d times, want once", got)
+	}
+
+	// The dissociated clone stands alone and tracks the real remote
+	if _, err := os.Stat(filepath.Join(dir, ".git", "objects", "info", "alternates")); !os.IsNotExist(err) {
+		t.Errorf("clone still borrows objects from the mirror: %v", err)
+	}
+	if got, want := testutil.RunGit(t, dir, "rev-parse", "HEAD"), testutil.RunGit(t, bare, "rev-parse", "main"); got != want {
+		t.Errorf("clone HEAD = %s, want %s", got, want)
+	}
+}
+
+func TestCloneWithoutMirrorRoot(t *testing.T) {
+	repos := testutil.NewGit(t)
+	repos.Remote(t, "acme/app")
+	calls := gitWrapper(t, "")
+
+	m := &Manager{AuthorName: "ai-flow", AuthorEmail: "ai-flow@noreply"}
+	if err := m.Clone(context.Background(), "acme/app", "main", filepath.Join(t.TempDir(), "app"), 0); err != nil {
+		t.Fatal(err)
+	}
+	clones := calls("clone")
+	if len(clones) != 1 || slices.Contains(clones[0], "--reference") {
+		t.Errorf("clones = %q, want one without --reference", clones)
+	}
+}
@@ -0,0 +1,96 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// PRStatus summarizes whether a PR is ready to merge.
+type PRStatus struct {
+	ReviewDecision string   // APPROVED, CHANGES_REQUESTED, REVIEW_REQUIRED, or "" when no review is required
+	Mergeable      string   // MERGEABLE, CONFLICTING, or UNKNOWN (GitHub hasn't computed it yet)
+	FailingChecks  []string // names of checks or status contexts that failed
+}
+
+// Conflicting reports whether GitHub says the PR conflicts with its base.
+func (s *PRStatus) Conflicting() bool {
+	return s.Mergeable == "CONFLICTING"
+}
+
+// NotReadyReason explains why the PR should not be merged yet, or returns ""
+// if it is approved (or needs no review), not conflicting, and has no failing checks.
+func (s *PRStatus) NotReadyReason() string {
+	var reasons []string
+	switch s.ReviewDecision {
+	case "CHANGES_REQUESTED":
+		reasons = append(reasons, "changes requested")
+	case "REVIEW_REQUIRED":
+		reasons = append(reasons, "review required")
+	}
+	if s.Conflicting() {
+		reasons = append(reasons, "conflicts with base branch")
+	}
+	if len(s.FailingChecks) > 0 {
+		reasons = append(reasons, "failing checks: "+strings.Join(s.FailingChecks, ", "))
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// PRStatus fetches the review decision, mergeability, and failing checks of a PR.
+func (m *Manager) PRStatus(ctx context.Context, dir, prURL string) (*PRStatus, error) {
+	stdout, stderr, err := m.gh(ctx, RepoOfPR(prURL), dir, "pr", "view", prURL, "--json", "reviewDecision,mergeable,statusCheckRollup")
+	if err != nil {
+		return nil, fmt.Errorf("gh pr view: %s: %w", stderr, err)
+	}
+	return parsePRStatus([]byte(stdout))
+}
+
+// PRState returns a PR's state as gh reports it: OPEN, MERGED, or CLOSED.
+func (m *Manager) PRState(ctx context.Context, prURL string) (string, error) {
+	stdout, stderr, err := m.gh(ctx, RepoOfPR(prURL), "", "pr", "view", prURL, "--json", "state", "--jq", ".state")
+	if err != nil {
+		return "", fmt.Errorf("gh pr view: %s: %w", stderr, err)
+	}
+	return strings.TrimSpace(stdout), nil
+}
+
+// parsePRStatus decodes `gh pr view --json reviewDecision,mergeable,statusCheckRollup`.
+// The rollup mixes check runs (name/conclusion) and commit status contexts (context/state).
+func parsePRStatus(data []byte) (*PRStatus, error) {
+	var raw struct {
+		ReviewDecision    string `json:"reviewDecision"`
+		Mergeable         string `json:"mergeable"`
+		StatusCheckRollup []struct {
+			Name       string `json:"name"`
+			Conclusion string `json:"conclusion"`
+			Context    string `json:"context"`
+			State      string `json:"state"`
+		} `json:"statusCheckRollup"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing PR status: %w", err)
+	}
+
+	status := &PRStatus{
+		ReviewDecision: raw.ReviewDecision,
+		Mergeable:      raw.Mergeable,
+	}
+	for _, check := range raw.StatusCheckRollup {
+		name := check.Name
+		if name == "" {
+			name = check.Context
+		}
+		switch check.Conclusion {
+		case "FAILURE", "CANCELLED", "TIMED_OUT", "ACTION_REQUIRED", "STARTUP_FAILURE":
+			status.FailingChecks = append(status.FailingChecks, name)
+			continue
+		}
+		switch check.State {
+		case "FAILURE", "ERROR":
+			status.FailingChecks = append(status.FailingChecks, name)
+		}
+	}
+	return status, nil
+}
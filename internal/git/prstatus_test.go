@@ -0,0 +1,82 @@
+package git
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+func TestParsePRStatusApprovedAndMergeable(t *testing.T) {
+	status, err := parsePRStatus([]byte(`{
+  "mergeable": "MERGEABLE",
+  "reviewDecision": "APPROVED",
+  "statusCheckRollup": [
+    {"__typename": "CheckRun", "name": "build", "status": "COMPLETED", "conclusion": "SUCCESS"},
+    {"__typename": "CheckRun", "name": "lint", "status": "COMPLETED", "conclusion": "SKIPPED"},
+    {"__typename": "StatusContext", "context": "ci/deploy-preview", "state": "SUCCESS"}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.ReviewDecision != "APPROVED" || status.Mergeable != "MERGEABLE" || len(status.FailingChecks) != 0 {
+		t.Errorf("status = %+v", status)
+	}
+	if status.Conflicting() {
+		t.Error("mergeable PR reported as conflicting")
+	}
+	if reason := status.NotReadyReason(); reason != "" {
+		t.Errorf("NotReadyReason = %q, want ready", reason)
+	}
+}
+
+func TestParsePRStatusChangesRequested(t *testing.T) {
+	status, err := parsePRStatus([]byte(`{
+  "mergeable": "CONFLICTING",
+  "reviewDecision": "CHANGES_REQUESTED",
+  "statusCheckRollup": [
+    {"__typename": "CheckRun", "name": "build", "status": "COMPLETED", "conclusion": "FAILURE"},
+    {"__typename": "CheckRun", "name": "test", "status": "IN_PROGRESS", "conclusion": ""},
+    {"__typename": "StatusContext", "context": "ci/legacy", "state": "ERROR"}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(status.FailingChecks, []string{"build", "ci/legacy"}) {
+		t.Errorf("failing checks = %q, want build and ci/legacy", status.FailingChecks)
+	}
+	if !status.Conflicting() {
+		t.Error("CONFLICTING PR not reported as conflicting")
+	}
+	want := "changes requested; conflicts with base branch; failing checks: build, ci/legacy"
+	if reason := status.NotReadyReason(); reason != want {
+		t.Errorf("NotReadyReason = %q, want %q", reason, want)
+	}
+}
+
+func TestParsePRStatusRejectsBadJSON(t *testing.T) {
+	if _, err := parsePRStatus([]byte("no pull requests found")); err == nil {
+		t.Error("parsed non-JSON gh output")
+	}
+}
+
+func TestPRStatusAsksGHForReadiness(t *testing.T) {
+	gh := testutil.NewGH(t)
+	gh.Respond(t, "pr view", `{"mergeable":"MERGEABLE","reviewDecision":"REVIEW_REQUIRED","statusCheckRollup":[]}`, "", 0)
+
+	m := &Manager{}
+	status, err := m.PRStatus(context.Background(), t.TempDir(), testutil.DefaultPRURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason := status.NotReadyReason(); reason != "review required" {
+		t.Errorf("NotReadyReason = %q, want review required", reason)
+	}
+	calls := gh.Calls("pr", "view")
+	if len(calls) != 1 || calls[0][2] != testutil.DefaultPRURL || testutil.ArgValue(calls[0], "--json") != "reviewDecision,mergeable,statusCheckRollup" {
+		t.Errorf("gh calls = %q", calls)
+	}
+}
@@ -0,0 +1,125 @@
+package git
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/telemetry"
+)
+
+// permanentErrorMarkers identify git failures that retrying cannot fix.
+// They are checked before transientErrorMarkers because e.g. an auth failure
+// may also mention the remote hanging up.
+var permanentErrorMarkers = []string{
+	"authentication failed",
+	"permission denied",
+	"could not read username",
+	"repository not found",
+	"does not appear to be a git repository",
+	"conflict",
+	"non-fast-forward",
+	"[rejected]",
+	"couldn't find remote ref",
+	"already exists and is not an empty directory",
+}
+
+// transientErrorMarkers identify network-level git failures worth retrying.
+var transientErrorMarkers = []string{
+	"could not resolve host",
+	"temporary failure in name resolution",
+	"connection timed out",
+	"operation timed out",
+	"connection reset",
+	"connection refused",
+	"connection closed",
+	"network is unreachable",
+	"the remote end hung up unexpectedly",
+	"early eof",
+	"rpc failed",
+	"unexpected disconnect",
+	"tls handshake timeout",
+	"gnutls_handshake() failed",
+	"the tls connection was non-properly terminated",
+	"ssl_error_syscall",
+	"ssh: connect to host",
+	"http 500",
+	"http 502",
+	"http 503",
+	"http 504",
+	"error connecting to",
+	"bad gateway",
+	"service unavailable",
+	"gateway timeout",
+	"error: 500",
+	"error: 502",
+	"error: 503",
+	"error: 504",
+}
+
+// IsTransient reports whether a git error looks like a transient network
+// failure (DNS, timeouts, dropped connections) rather than a permanent one
+// such as an auth failure or a rejected/conflicting push.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, m := range permanentErrorMarkers {
+		if strings.Contains(msg, m) {
+			return false
+		}
+	}
+	for _, m := range transientErrorMarkers {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// withRetry runs op, retrying up to m.Retries more times with exponential
+// backoff while it fails with a transient error. before, if non-nil, runs
+// ahead of every retry (e.g. to clear a partial clone). Each attempt holds a
+// slot of the git concurrency limit; backoff waits don't.
+func (m *Manager) withRetry(ctx context.Context, name string, op func() error, before func()) (err error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "git "+name)
+	defer func() { telemetry.End(span, err) }()
+
+	for attempt := 0; attempt <= m.Retries; attempt++ {
+		if attempt > 0 {
+			delay := time.Duration(float64(m.RetryBackoff) * math.Pow(2, float64(attempt-1)))
+			slog.Warn("retrying git operation", "op", name, "attempt", attempt+1, "delay", delay, "error", err)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return err
+			}
+			if before != nil {
+				before()
+			}
+		}
+
+		err = m.limited(ctx, op)
+		if err == nil || !IsTransient(err) || ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// limited runs op while holding a slot of the git concurrency limit.
+func (m *Manager) limited(ctx context.Context, op func() error) error {
+	if m.sem == nil {
+		return op()
+	}
+	select {
+	case m.sem <- struct{}{}:
+		defer func() { <-m.sem }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return op()
+}
@@ -0,0 +1,75 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+func TestIsTransient(t *testing.T) {
+	for _, tc := range []struct {
+		msg  string
+		want bool
+	}{
+		{"fatal: unable to access 'https://github.com/acme/app/': gnutls_handshake() failed: The TLS connection was non-properly terminated.", true},
+		{"fatal: unable to access 'https://github.com/acme/app/': net/http: TLS handshake timeout", true},
+		{"fatal: unable to access 'https://github.com/acme/app/': OpenSSL SSL_connect: SSL_ERROR_SYSCALL in connection to github.com:443", true},
+		{"ssh: connect to host github.com port 22: Connection timed out", true},
+		{"fatal: unable to access 'https://github.com/acme/app/': server certificate verification failed. CAfile: none CRLfile: none (TLS)", false},
+		{"error: failed to push some refs: tls-support/ruleset [rejected]", false},
+		{"fatal: Authentication failed for 'https://github.com/acme/app/'", false},
+	} {
+		if got := IsTransient(errors.New(tc.msg)); got != tc.want {
+			t.Errorf("IsTransient(%q) = %v, want %v", tc.msg, got, tc.want)
+		}
+	}
+}
+
+// flakyGitClone puts a git wrapper first on PATH whose first clone fails with
+// a TLS handshake error; everything else goes to the real git.
+func flakyGitClone(t *testing.T) (attempts func() int) {
+	t.Helper()
+	calls := gitWrapper(t, `if [ "$1" = clone ] && [ "$(tr '\036' '\n' < "$GIT_LOG" | grep -c '^clone')" -le 1 ]; then
+	echo "fatal: unable to access 'https://github.com/acme/app/': gnutls_handshake() failed: Error in the pull function." >&2
+	exit 128
+fi`)
+	return func() int { return len(calls("clone")) }
+}
+
+func TestCloneRetriesTransientFailure(t *testing.T) {
+	repos := testutil.NewGit(t)
+	repos.Remote(t, "acme/app")
+	attempts := flakyGitClone(t)
+
+	m := &Manager{AuthorName: "ai-flow", AuthorEmail: "ai-flow@noreply", Retries: 2}
+	dir := filepath.Join(t.TempDir(), "app")
+	if err := m.Clone(context.Background(), "acme/app", "main", dir, 0); err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	if got := attempts(); got != 2 {
+		t.Errorf("clone attempted %d times, want 2", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "README.md")); err != nil {
+		t.Errorf("clone is missing README.md: %v", err)
+	}
+}
+
+func TestCloneDoesNotRetryWithoutRetries(t *testing.T) {
+	repos := testutil.NewGit(t)
+	repos.Remote(t, "acme/app")
+	attempts := flakyGitClone(t)
+
+	m := &Manager{AuthorName: "ai-flow", AuthorEmail: "ai-flow@noreply"}
+	err := m.Clone(context.Background(), "acme/app", "main", filepath.Join(t.TempDir(), "app"), 0)
+	if err == nil || !strings.Contains(err.Error(), "gnutls_handshake() failed") {
+		t.Fatalf("Clone error = %v, want the handshake failure", err)
+	}
+	if got := attempts(); got != 1 {
+		t.Errorf("clone attempted %d times, want 1", got)
+	}
+}
@@ -0,0 +1,91 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+// sshKey creates a throwaway SSH signing key and returns the path of its
+// public half and the public key itself.
+func sshKey(t *testing.T, name string) (path, public string) {
+	t.Helper()
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not installed")
+	}
+	priv := filepath.Join(t.TempDir(), name)
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", name, "-f", priv).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen: %s: %v", out, err)
+	}
+	pub, err := os.ReadFile(priv + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv + ".pub", strings.TrimSpace(string(pub))
+}
+
+func TestCommitSigningKeyPerRepo(t *testing.T) {
+	repos := testutil.NewGit(t)
+	for _, repo := range []string{"acme/app", "acme/docs"} {
+		repos.Remote(t, repo)
+	}
+	globalPath, globalKey := sshKey(t, "global")
+	docsPath, docsKey := sshKey(t, "docs")
+	// Signatures are reported with the principal of the key that made them
+	signers := filepath.Join(t.TempDir(), "allowed_signers")
+	if err := os.WriteFile(signers, []byte("global "+globalKey+"\ndocs "+docsKey+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	m := &Manager{AuthorName: "ai-flow", AuthorEmail: "ai-flow@noreply", SigningKey: globalPath, SigningFormat: "ssh"}
+	m.SetSigningKey("acme/docs", docsPath, "ssh")
+
+	for _, tc := range []struct {
+		repo, signer string
+	}{
+		{"acme/app", "global"}, // no override, so the global key signs
+		{"acme/docs", "docs"},
+	} {
+		dir := filepath.Join(t.TempDir(), "clone")
+		if err := m.Clone(ctx, tc.repo, "main", dir, 1); err != nil {
+			t.Fatalf("%s: %v", tc.repo, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "change.txt"), []byte("change\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.CommitAll(ctx, tc.repo, dir, "Change things"); err != nil {
+			t.Fatalf("%s: %v", tc.repo, err)
+		}
+		// Commits the command makes itself are signed too
+		testutil.RunGit(t, dir, "commit", "--allow-empty", "-m", "Command's own commit")
+
+		for _, rev := range []string{"HEAD~1", "HEAD"} {
+			got := testutil.RunGit(t, dir, "-c", "gpg.ssh.allowedSignersFile="+signers, "log", "-1", "--format=%G? %GS", rev)
+			if want := "G " + tc.signer; got != want {
+				t.Errorf("%s %s: signature %q, want %q", tc.repo, rev, got, want)
+			}
+		}
+	}
+
+	// Without a key, commits aren't signed
+	unsigned := &Manager{AuthorName: "ai-flow", AuthorEmail: "ai-flow@noreply"}
+	dir := filepath.Join(t.TempDir(), "clone")
+	if err := unsigned.Clone(ctx, "acme/app", "main", dir, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "change.txt"), []byte("change\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := unsigned.CommitAll(ctx, "acme/app", dir, "Change things"); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.RunGit(t, dir, "log", "-1", "--format=%G?"); got != "N" {
+		t.Errorf("unsigned commit signature status = %q, want N", got)
+	}
+}
@@ -0,0 +1,76 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// lockRepo serializes operations that modify a repository's shared worktree
+// metadata (adding/removing worktrees, fetching into the primary clone).
+func (m *Manager) lockRepo(dir string) func() {
+	lock, _ := m.repoLocks.LoadOrStore(dir, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	return lock.(*sync.Mutex).Unlock
+}
+
+// AddWorktree adds a worktree at path with a detached HEAD at origin/<base>,
+// cloning repo into primaryDir first if it isn't there yet. Callers then create
+// or check out the issue branch in it, exactly as they would in a fresh clone.
+// depth is the clone and fetch depth of the primary, as for Clone.
+func (m *Manager) AddWorktree(ctx context.Context, repo, primaryDir, path, base string, depth int) error {
+	unlock := m.lockRepo(primaryDir)
+	defer unlock()
+
+	if _, err := os.Stat(filepath.Join(primaryDir, ".git")); err != nil {
+		if err := m.Clone(ctx, repo, base, primaryDir, depth); err != nil {
+			return fmt.Errorf("cloning primary: %w", err)
+		}
+	}
+
+	// The primary is a single-branch clone, so fetch the base explicitly
+	refspec := fmt.Sprintf("+refs/heads/%s:refs/remotes/origin/%s", base, base)
+	args := []string{"-C", primaryDir, "fetch"}
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+	} else if isShallow(ctx, primaryDir) {
+		args = append(args, "--unshallow")
+	}
+	args = append(args, "origin", refspec)
+	err := m.withRetry(ctx, "fetch", func() error {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git fetch: %s: %w", strings.TrimSpace(string(out)), err)
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	// Drop metadata for worktrees whose directories were removed by hand
+	_ = exec.CommandContext(ctx, "git", "-C", primaryDir, "worktree", "prune").Run()
+
+	cmd := exec.CommandContext(ctx, "git", "-C", primaryDir, "worktree", "add", "--detach", path, "origin/"+base)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git worktree add: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// RemoveWorktree deletes the worktree at path and its metadata in the primary clone.
+func (m *Manager) RemoveWorktree(ctx context.Context, primaryDir, path string) error {
+	unlock := m.lockRepo(primaryDir)
+	defer unlock()
+
+	cmd := exec.CommandContext(ctx, "git", "-C", primaryDir, "worktree", "remove", "--force", path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git worktree remove: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
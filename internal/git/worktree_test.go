@@ -0,0 +1,67 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+func TestWorktreesAreIsolated(t *testing.T) {
+	repos := testutil.NewGit(t)
+	bare := repos.Remote(t, "acme/app")
+	ctx := context.Background()
+
+	m := &Manager{AuthorName: "ai-flow", AuthorEmail: "ai-flow@noreply"}
+	root := t.TempDir()
+	primary := filepath.Join(root, "primary")
+	one := filepath.Join(root, "one")
+	two := filepath.Join(root, "two")
+	for _, path := range []string{one, two} {
+		if err := m.AddWorktree(ctx, "acme/app", primary, path, "main", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	base := testutil.RunGit(t, bare, "rev-parse", "main")
+	for _, path := range []string{one, two} {
+		if got := testutil.RunGit(t, path, "rev-parse", "HEAD"); got != base {
+			t.Errorf("%s HEAD = %s, want origin/main %s", filepath.Base(path), got, base)
+		}
+	}
+
+	// Work in one worktree must not show up in the other
+	testutil.RunGit(t, one, "checkout", "--quiet", "-b", "ai/one")
+	if err := os.WriteFile(filepath.Join(one, "one.txt"), []byte("one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	testutil.RunGit(t, one, "add", "-A")
+	testutil.RunGit(t, one, "commit", "--quiet", "-m", "one")
+	if err := os.WriteFile(filepath.Join(two, "two.txt"), []byte("two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(two, "one.txt")); !os.IsNotExist(err) {
+		t.Errorf("one.txt leaked into the second worktree: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(one, "two.txt")); !os.IsNotExist(err) {
+		t.Errorf("two.txt leaked into the first worktree: %v", err)
+	}
+	if got := testutil.RunGit(t, two, "rev-parse", "HEAD"); got != base {
+		t.Errorf("second worktree HEAD moved to %s after committing in the first", got)
+	}
+	if got := testutil.RunGit(t, one, "status", "--porcelain"); got != "" {
+		t.Errorf("first worktree status = %q, want clean", got)
+	}
+
+	if err := m.RemoveWorktree(ctx, primary, one); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(one); !os.IsNotExist(err) {
+		t.Errorf("removed worktree still on disk: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(two, "two.txt")); err != nil {
+		t.Errorf("removing one worktree touched the other: %v", err)
+	}
+}
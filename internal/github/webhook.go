@@ -0,0 +1,94 @@
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const (
+	maxBodySize     = 25 << 20 // GitHub caps payloads at 25 MB
+	signatureHeader = "X-Hub-Signature-256"
+	eventHeader     = "X-GitHub-Event"
+)
+
+// PullRequestEvent is the part of a pull_request delivery ai-flow acts on.
+type PullRequestEvent struct {
+	Action string
+	URL    string // the PR's html_url, as recorded by runs
+	Merged bool
+}
+
+// DispatchFunc is the callback the webhook handler invokes for closed PRs.
+type DispatchFunc func(event PullRequestEvent)
+
+// NewWebhookHandler returns an http.HandlerFunc that verifies GitHub webhook
+// deliveries against secret and dispatches pull_request "closed" events
+// (merged or not). Other events are acknowledged and ignored.
+func NewWebhookHandler(secret string, dispatch DispatchFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+		if err != nil {
+			slog.Error("reading github webhook body", "error", err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		if !verifySignature(secret, body, r.Header.Get(signatureHeader)) {
+			slog.Warn("invalid github webhook signature")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		event := r.Header.Get(eventHeader)
+		if event != "pull_request" {
+			slog.Debug("ignoring github webhook", "event", event)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		var payload struct {
+			Action      string `json:"action"`
+			PullRequest struct {
+				HTMLURL string `json:"html_url"`
+				Merged  bool   `json:"merged"`
+			} `json:"pull_request"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			slog.Error("parsing github webhook payload", "error", err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		// Return 200 immediately
+		w.WriteHeader(http.StatusOK)
+
+		if payload.Action != "closed" {
+			slog.Debug("ignoring pull_request webhook", "action", payload.Action)
+			return
+		}
+		go dispatch(PullRequestEvent{
+			Action: payload.Action,
+			URL:    payload.PullRequest.HTMLURL,
+			Merged: payload.PullRequest.Merged,
+		})
+	}
+}
+
+// verifySignature reports whether signature ("sha256=<hex>") is the HMAC of
+// body under secret.
+func verifySignature(secret string, body []byte, signature string) bool {
+	sig, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok || secret == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(sig))
+}
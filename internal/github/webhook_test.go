@@ -0,0 +1,82 @@
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const closedPayload = `{"action":"closed","pull_request":{"html_url":"https://github.com/acme/app/pull/7","merged":true}}`
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(closedPayload)
+	tests := []struct {
+		name, secret, signature string
+		want                    bool
+	}{
+		{"valid", "s3cret", sign("s3cret", closedPayload), true},
+		{"wrong secret", "s3cret", sign("other", closedPayload), false},
+		{"other body", "s3cret", sign("s3cret", closedPayload+" "), false},
+		{"sha1 prefix", "s3cret", strings.Replace(sign("s3cret", closedPayload), "sha256=", "sha1=", 1), false},
+		{"missing", "s3cret", "", false},
+		{"no secret configured", "", sign("", closedPayload), false},
+	}
+	for _, tt := range tests {
+		if got := verifySignature(tt.secret, body, tt.signature); got != tt.want {
+			t.Errorf("%s: verifySignature = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookDispatchesClosedPR(t *testing.T) {
+	events := make(chan PullRequestEvent, 1)
+	handler := NewWebhookHandler("s3cret", func(e PullRequestEvent) { events <- e })
+	deliver := func(event, body, signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/github-webhook", strings.NewReader(body))
+		req.Header.Set(eventHeader, event)
+		req.Header.Set(signatureHeader, signature)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	if code := deliver("pull_request", closedPayload, sign("wrong", closedPayload)); code != http.StatusUnauthorized {
+		t.Errorf("badly signed delivery: status %d, want 401", code)
+	}
+	opened := strings.Replace(closedPayload, `"closed"`, `"opened"`, 1)
+	if code := deliver("pull_request", opened, sign("s3cret", opened)); code != http.StatusOK {
+		t.Errorf("opened delivery: status %d, want 200", code)
+	}
+	if code := deliver("push", closedPayload, sign("s3cret", closedPayload)); code != http.StatusOK {
+		t.Errorf("push delivery: status %d, want 200", code)
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("dispatched %+v for a delivery that isn't a closed PR", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if code := deliver("pull_request", closedPayload, sign("s3cret", closedPayload)); code != http.StatusOK {
+		t.Fatalf("closed delivery: status %d, want 200", code)
+	}
+	select {
+	case e := <-events:
+		want := PullRequestEvent{Action: "closed", URL: "https://github.com/acme/app/pull/7", Merged: true}
+		if e != want {
+			t.Errorf("event = %+v, want %+v", e, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("closed PR not dispatched")
+	}
+}
@@ -0,0 +1,27 @@
+// Package jsonapi writes the JSON responses of ai-flow's HTTP API, so every
+// endpoint answers in the same shape whether it succeeds or fails.
+package jsonapi
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// Write responds with v encoded as JSON and status 200.
+func Write(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("encoding JSON response", "error", err)
+	}
+}
+
+// Error responds with {"error": msg} so API clients get the same content type
+// on failure as on success.
+func Error(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]string{"error": msg}); err != nil {
+		slog.Error("encoding JSON error response", "error", err)
+	}
+}
@@ -0,0 +1,96 @@
+package linear
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+// uploadsHost serves files uploaded to Linear; fetching them needs the API key.
+const uploadsHost = "uploads.linear.app"
+
+// ErrAttachmentTooLarge is returned by DownloadAttachment when the file is
+// bigger than the caller allows.
+var ErrAttachmentTooLarge = errors.New("attachment too large")
+
+// ErrAttachmentNotFile is returned by DownloadAttachment for attachments that
+// are links to web pages (e.g. a linked PR) rather than files.
+var ErrAttachmentNotFile = errors.New("attachment is a web page, not a file")
+
+// GetIssueAttachments fetches the attachments on an issue.
+func (c *Client) GetIssueAttachments(ctx context.Context, issueID string) ([]Attachment, error) {
+	query := `query($id: String!) {
+		issue(id: $id) {
+			attachments(first: 100) {
+				nodes { id title url }
+			}
+		}
+	}`
+
+	var resp GraphQLResponse[struct {
+		Issue struct {
+			Attachments struct {
+				Nodes []Attachment `json:"nodes"`
+			} `json:"attachments"`
+		} `json:"issue"`
+	}]
+
+	err := c.do(ctx, GraphQLRequest{
+		Query:     query,
+		Variables: map[string]any{"id": issueID},
+	}, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("getting issue attachments: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return nil, fmt.Errorf("graphql errors: %s", resp.Errors[0].Message)
+	}
+
+	return resp.Data.Issue.Attachments.Nodes, nil
+}
+
+// DownloadAttachment copies the file at rawURL to w, failing with
+// ErrAttachmentTooLarge once it passes maxBytes, and returns the bytes
+// written. Files uploaded to Linear are fetched with the API key; any other
+// URL is fetched without it so the key never leaves Linear.
+func (c *Client) DownloadAttachment(ctx context.Context, rawURL string, w io.Writer, maxBytes int64) (int64, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return 0, fmt.Errorf("unsupported attachment URL %q", rawURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+	if u.Host == uploadsHost {
+		req.Header.Set("Authorization", c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("downloading attachment: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("downloading attachment: unexpected status %d", resp.StatusCode)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "text/html" {
+		return 0, ErrAttachmentNotFile
+	}
+	if resp.ContentLength > maxBytes {
+		return 0, ErrAttachmentTooLarge
+	}
+
+	n, err := io.Copy(w, io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return n, fmt.Errorf("downloading attachment: %w", err)
+	}
+	if n > maxBytes {
+		return n, ErrAttachmentTooLarge
+	}
+	return n, nil
+}
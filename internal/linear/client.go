@@ -8,9 +8,14 @@ import (
 	"io"
 	"log/slog"
 	"math"
+	"math/rand/v2"
 	"net/http"
+	"slices"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/mauza/ai-flow/internal/telemetry"
 )
 
 const apiURL = "https://api.linear.app/graphql"
@@ -18,41 +23,96 @@ const apiURL = "https://api.linear.app/graphql"
 // Client is a minimal GraphQL client for the Linear API.
 type Client struct {
 	apiKey     string
+	apiURL     string
 	httpClient *http.Client
 
-	mu           sync.RWMutex
-	stateCache   map[string]string // name → ID
-	reverseCache map[string]string // ID → name
-	labelCache   map[string]string // issue label name → ID
-	teamID       string            // cached team ID
+	mu    sync.RWMutex
+	teams map[string]*teamCache // team key → that team's states and labels
+
+	refreshMu sync.Mutex // serializes on-demand reloads of the caches
+
+	maxRetries    int                               // total attempts per request
+	retryMaxDelay time.Duration                     // cap on the backoff between attempts
+	jitter        func(time.Duration) time.Duration // picks a wait in [0, n); rand.N outside tests
+
+	extraFields []string // linear.extra_issue_fields, added to issue queries
+
+	maxLabels int // labels kept per issue, 0 for all; the rest are dropped
+
+	verifyTransition bool // re-read the state after UpdateIssueState
+
+	extraHeaders http.Header // linear.extra_headers, sent with every request
 }
 
-// NewClient creates a new Linear API client.
+// NewClient creates a new Linear API client. It honors proxy settings from
+// the environment; use SetHTTPOptions to configure a proxy, TLS, or timeout.
 func NewClient(apiKey string) *Client {
+	httpClient, _ := NewHTTPClient(HTTPOptions{}) // cannot fail without a proxy URL
 	return &Client{
-		apiKey:       apiKey,
-		httpClient:   &http.Client{},
-		stateCache:   make(map[string]string),
-		reverseCache: make(map[string]string),
-		labelCache:   make(map[string]string),
+		apiKey:     apiKey,
+		apiURL:     apiURL,
+		httpClient: httpClient,
+		teams:      make(map[string]*teamCache),
+
+		maxRetries:    defaultMaxRetries,
+		retryMaxDelay: defaultRetryMaxDelay,
+		jitter:        rand.N[time.Duration],
 	}
 }
 
+// labelPageSize is how many labels issue queries read per issue. The labels
+// connection multiplies the complexity of every issue page it's nested in, so
+// it is kept small; issues with more labels have the rest read by moreLabels.
+const labelPageSize = 10
+
+// moreLabelsPageSize is the page size of moreLabels, which reads one issue's
+// labels at a time: Linear's largest page.
+const moreLabelsPageSize = 250
+
 const (
-	maxRetries     = 3
-	baseRetryDelay = 500 * time.Millisecond
+	defaultMaxRetries    = 3
+	defaultRetryMaxDelay = 10 * time.Second
+	baseRetryDelay       = 500 * time.Millisecond
 )
 
-func (c *Client) do(ctx context.Context, req GraphQLRequest, result any) error {
+// SetRetryPolicy sets how many attempts each request gets (including the
+// first) and the cap on the backoff between them. Zero values keep the defaults.
+func (c *Client) SetRetryPolicy(maxRetries int, maxDelay time.Duration) {
+	if maxRetries > 0 {
+		c.maxRetries = maxRetries
+	}
+	if maxDelay > 0 {
+		c.retryMaxDelay = maxDelay
+	}
+}
+
+// retryDelay returns the wait before the given retry attempt (1-based):
+// exponential backoff capped at maxDelay, with full jitter (a wait drawn by
+// jitter from below the backoff) so concurrent callers don't retry in lockstep.
+func retryDelay(attempt int, maxDelay time.Duration, jitter func(time.Duration) time.Duration) time.Duration {
+	backoff := time.Duration(float64(baseRetryDelay) * math.Pow(2, float64(attempt-1)))
+	if backoff > maxDelay || backoff <= 0 {
+		backoff = maxDelay
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return jitter(backoff)
+}
+
+func (c *Client) do(ctx context.Context, req GraphQLRequest, result any) (err error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "linear graphql")
+	defer func() { telemetry.End(span, err) }()
+
 	body, err := json.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("marshaling request: %w", err)
 	}
 
 	var lastErr error
-	for attempt := range maxRetries {
+	for attempt := range c.maxRetries {
 		if attempt > 0 {
-			delay := time.Duration(float64(baseRetryDelay) * math.Pow(2, float64(attempt-1)))
+			delay := retryDelay(attempt, c.retryMaxDelay, c.jitter)
 			slog.Debug("retrying Linear API request", "attempt", attempt+1, "delay", delay)
 			select {
 			case <-time.After(delay):
@@ -72,14 +132,18 @@ func (c *Client) do(ctx context.Context, req GraphQLRequest, result any) error {
 		}
 		slog.Warn("Linear API request failed", "attempt", attempt+1, "error", lastErr)
 	}
-	return fmt.Errorf("after %d attempts: %w", maxRetries, lastErr)
+	return fmt.Errorf("after %d attempts: %w", c.maxRetries, lastErr)
 }
 
 func (c *Client) doOnce(ctx context.Context, body []byte, result any) error {
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewReader(body))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiURL, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
 	}
+	// Extra headers first, so they can never replace the required ones
+	for name, values := range c.extraHeaders {
+		httpReq.Header[name] = values
+	}
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Authorization", c.apiKey)
 
@@ -105,7 +169,20 @@ func (c *Client) doOnce(ctx context.Context, body []byte, result any) error {
 	return nil
 }
 
-// LoadWorkflowStates fetches the team's workflow states and populates the cache.
+// teamCache holds one team's workflow states and issue labels. State and
+// label IDs are specific to a team, so each configured team has its own.
+type teamCache struct {
+	id       string
+	states   map[string]string // normalized name (see stateKey) → ID
+	names    map[string]string // state ID → canonical name
+	labels   map[string]string // issue label name → ID
+	loadedAt time.Time         // when the caches were last loaded
+}
+
+// LoadWorkflowStates fetches the team's workflow states and issue labels and
+// replaces its caches with them. Teams are cached side by side, so calling it
+// for each team ai-flow serves lets lookups resolve any of them. Reloads log
+// only what changed.
 func (c *Client) LoadWorkflowStates(ctx context.Context, teamKey string) error {
 	query := `query($teamKey: String!) {
 		teams(filter: { key: { eq: $teamKey } }) {
@@ -161,59 +238,379 @@ func (c *Client) LoadWorkflowStates(ctx context.Context, teamKey string) error {
 
 	team := resp.Data.Teams.Nodes[0]
 
+	cache := &teamCache{
+		id:       team.ID,
+		states:   make(map[string]string, len(team.States.Nodes)),
+		names:    make(map[string]string, len(team.States.Nodes)),
+		labels:   make(map[string]string, len(team.Labels.Nodes)),
+		loadedAt: time.Now(),
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	c.teamID = team.ID
-
+	old, reload := c.teams[teamKey]
 	for _, s := range team.States.Nodes {
-		c.stateCache[s.Name] = s.ID
-		c.reverseCache[s.ID] = s.Name
-		slog.Info("loaded workflow state", "name", s.Name, "id", s.ID, "type", s.Type)
+		cache.states[stateKey(s.Name)] = s.ID
+		cache.names[s.ID] = s.Name
+		switch {
+		case !reload:
+			slog.Info("loaded workflow state", "team", teamKey, "name", s.Name, "id", s.ID, "type", s.Type)
+		case old.names[s.ID] == "":
+			slog.Info("workflow state added", "team", teamKey, "name", s.Name, "id", s.ID, "type", s.Type)
+		case old.names[s.ID] != s.Name:
+			slog.Info("workflow state renamed", "team", teamKey, "from", old.names[s.ID], "to", s.Name, "id", s.ID)
+		}
+	}
+	if reload {
+		for id, name := range old.names {
+			if _, ok := cache.names[id]; !ok {
+				slog.Warn("workflow state removed", "team", teamKey, "name", name, "id", id)
+			}
+		}
 	}
 
 	for _, l := range team.Labels.Nodes {
-		c.labelCache[l.Name] = l.ID
-		slog.Debug("loaded issue label", "name", l.Name, "id", l.ID)
+		cache.labels[l.Name] = l.ID
+		slog.Debug("loaded issue label", "team", teamKey, "name", l.Name, "id", l.ID)
 	}
 
+	c.teams[teamKey] = cache
 	return nil
 }
 
-// ResolveStateID returns the state ID for a given state name.
-func (c *Client) ResolveStateID(name string) (string, bool) {
+// stateMissRefreshGap is the least time between reloads triggered by
+// ResolveStateID misses, so an unknown name can't cause a reload per lookup.
+const stateMissRefreshGap = 10 * time.Second
+
+// RunStateRefresher reloads the workflow states every interval until ctx is
+// cancelled, so states renamed or added in Linear are picked up.
+func (c *Client) RunStateRefresher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.refreshStates(ctx)
+		}
+	}
+}
+
+// refreshStates reloads the caches of every team they were loaded for.
+func (c *Client) refreshStates(ctx context.Context) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	for _, teamKey := range c.loadedTeams(0) {
+		if err := c.LoadWorkflowStates(ctx, teamKey); err != nil {
+			slog.Warn("reloading workflow states", "error", err, "teamKey", teamKey)
+		}
+	}
+}
+
+// loadedTeams returns the keys of the cached teams last loaded more than
+// minAge ago.
+func (c *Client) loadedTeams(minAge time.Duration) []string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	id, ok := c.stateCache[name]
+	var keys []string
+	for key, cache := range c.teams {
+		if time.Since(cache.loadedAt) >= minAge {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// refreshOnMiss reloads the caches after a state name or ID failed to resolve,
+// skipping teams loaded within stateMissRefreshGap. A name miss reloads only
+// teamKey; an ID miss ("" teamKey) reloads every team. It reports whether a
+// reload was attempted.
+func (c *Client) refreshOnMiss(teamKey, state string) bool {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	stale := c.loadedTeams(stateMissRefreshGap)
+	if teamKey != "" {
+		if !slices.Contains(stale, teamKey) {
+			return false
+		}
+		stale = []string{teamKey}
+	}
+	if len(stale) == 0 {
+		return false
+	}
+	slog.Info("unknown workflow state, reloading states", "state", state, "teams", stale)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for _, key := range stale {
+		if err := c.LoadWorkflowStates(ctx, key); err != nil {
+			slog.Warn("reloading workflow states", "error", err, "teamKey", key)
+		}
+	}
+	return true
+}
+
+// ResolveStateID returns the ID of the team's state with the given name,
+// matched as SameState does. A name that isn't cached reloads the team's
+// states once and is looked up again, in case it was added or renamed in
+// Linear since.
+func (c *Client) ResolveStateID(teamKey, name string) (string, bool) {
+	if id, ok := c.cachedStateID(teamKey, name); ok {
+		return id, true
+	}
+	if !c.refreshOnMiss(teamKey, name) {
+		return "", false
+	}
+	return c.cachedStateID(teamKey, name)
+}
+
+// cachedStateID looks name up in the team's state cache.
+func (c *Client) cachedStateID(teamKey, name string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	cache, ok := c.teams[teamKey]
+	if !ok {
+		return "", false
+	}
+	id, ok := cache.states[stateKey(name)]
 	return id, ok
 }
 
-// ResolveStateName returns the state name for a given state ID.
+// ResolveStateName returns the canonical state name, as Linear spells it, for
+// a given state ID of any loaded team. Like ResolveStateID, an unknown ID
+// reloads the states once.
 func (c *Client) ResolveStateName(id string) (string, bool) {
+	if name, ok := c.cachedStateName(id); ok {
+		return name, true
+	}
+	if !c.refreshOnMiss("", id) {
+		return "", false
+	}
+	return c.cachedStateName(id)
+}
+
+// cachedStateName looks id up in every team's state cache.
+func (c *Client) cachedStateName(id string) (string, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	name, ok := c.reverseCache[id]
-	return name, ok
+	for _, cache := range c.teams {
+		if name, ok := cache.names[id]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// TeamKey returns the key of the loaded team with the given ID, as found in
+// webhook payloads.
+func (c *Client) TeamKey(teamID string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for key, cache := range c.teams {
+		if cache.id == teamID {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// SetExtraIssueFields adds fields, as dotted paths like "estimate" or
+// "cycle.name", to every issue query. Their values end up in IssueDetails.Extra.
+func (c *Client) SetExtraIssueFields(fields []string) {
+	c.extraFields = fields
+}
+
+// SetExtraHeaders adds headers to every API request. Content-Type and
+// Authorization are always set by the client and can't be replaced.
+func (c *Client) SetExtraHeaders(headers map[string]string) {
+	c.extraHeaders = make(http.Header, len(headers))
+	for name, value := range headers {
+		c.extraHeaders.Set(name, value)
+	}
+}
+
+// SetMaxLabels caps how many labels are kept per issue; 0 keeps them all.
+// Issues with more keep the first n by name, and a warning is logged.
+func (c *Client) SetMaxLabels(n int) {
+	c.maxLabels = max(n, 0)
+}
+
+// SetVerifyTransition makes UpdateIssueState re-fetch the issue after a
+// successful update and warn if it isn't in the requested state.
+func (c *Client) SetVerifyTransition(verify bool) {
+	c.verifyTransition = verify
+}
+
+// issueSelection is the set of issue fields every issue query fetches.
+func (c *Client) issueSelection() string {
+	return `id identifier title description url priority
+				state { id name }
+				team { id key }
+				` + c.labelSelection() + `
+				project { id name description }
+				assignee { id email url }
+				creator { id email }
+				` + c.extraSelection()
+}
+
+// labelSelection selects the first page of an issue's labels, in a stable
+// order, with the cursor decodeIssue needs to read the rest.
+func (c *Client) labelSelection() string {
+	return fmt.Sprintf("labels(first: %d, orderBy: createdAt) { nodes { id name } pageInfo { hasNextPage endCursor } }", labelPageSize)
+}
+
+// extraSelection renders the extra fields as a GraphQL selection set.
+func (c *Client) extraSelection() string {
+	return selectionFor(c.extraFields)
+}
+
+// selectionFor renders dotted field paths as GraphQL selections, e.g.
+// ["estimate", "cycle.name", "cycle.number"] → "estimate cycle { name number }".
+func selectionFor(paths []string) string {
+	var order []string
+	children := make(map[string][]string)
+	for _, path := range paths {
+		field, rest, nested := strings.Cut(path, ".")
+		if _, seen := children[field]; !seen {
+			order = append(order, field)
+			children[field] = nil
+		}
+		if nested {
+			children[field] = append(children[field], rest)
+		}
+	}
+	parts := make([]string, 0, len(order))
+	for _, field := range order {
+		if sub := children[field]; len(sub) > 0 {
+			parts = append(parts, field+" { "+selectionFor(sub)+" }")
+		} else {
+			parts = append(parts, field)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// labelPage is the pagination state of an issue's labels connection.
+type labelPage struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+// decodeIssue parses an issue object, reading any labels beyond the first
+// page and collecting any extra fields into Extra.
+func (c *Client) decodeIssue(ctx context.Context, raw json.RawMessage) (IssueDetails, error) {
+	var issue IssueDetails
+	if err := json.Unmarshal(raw, &issue); err != nil {
+		return issue, fmt.Errorf("unmarshaling issue: %w", err)
+	}
+	var page struct {
+		Labels struct {
+			PageInfo labelPage `json:"pageInfo"`
+		} `json:"labels"`
+	}
+	if err := json.Unmarshal(raw, &page); err != nil {
+		return issue, fmt.Errorf("unmarshaling issue labels: %w", err)
+	}
+	if page.Labels.PageInfo.HasNextPage {
+		if err := c.moreLabels(ctx, &issue, page.Labels.PageInfo.EndCursor); err != nil {
+			return issue, err
+		}
+	}
+	c.capLabels(&issue)
+	if len(c.extraFields) == 0 {
+		return issue, nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return issue, fmt.Errorf("unmarshaling issue fields: %w", err)
+	}
+	issue.Extra = make(map[string]any, len(c.extraFields))
+	for _, path := range c.extraFields {
+		issue.Extra[path] = lookupPath(fields, path)
+	}
+	return issue, nil
+}
+
+// moreLabels appends the issue's labels after cursor, page by page, so the
+// cap is applied to all of them.
+func (c *Client) moreLabels(ctx context.Context, issue *IssueDetails, cursor string) error {
+	query := fmt.Sprintf(`query($id: String!, $after: String!) {
+		issue(id: $id) {
+			labels(first: %d, after: $after, orderBy: createdAt) { nodes { id name } pageInfo { hasNextPage endCursor } }
+		}
+	}`, moreLabelsPageSize)
+
+	for {
+		var resp GraphQLResponse[struct {
+			Issue struct {
+				Labels struct {
+					Nodes    []IssueLabel `json:"nodes"`
+					PageInfo labelPage    `json:"pageInfo"`
+				} `json:"labels"`
+			} `json:"issue"`
+		}]
+		err := c.do(ctx, GraphQLRequest{
+			Query:     query,
+			Variables: map[string]any{"id": issue.ID, "after": cursor},
+		}, &resp)
+		if err != nil {
+			return fmt.Errorf("getting labels of %s: %w", issue.Identifier, err)
+		}
+		if len(resp.Errors) > 0 {
+			return fmt.Errorf("graphql errors: %s", resp.Errors[0].Message)
+		}
+		labels := resp.Data.Issue.Labels
+		issue.Labels.Nodes = append(issue.Labels.Nodes, labels.Nodes...)
+		if !labels.PageInfo.HasNextPage || len(labels.Nodes) == 0 {
+			return nil
+		}
+		cursor = labels.PageInfo.EndCursor
+	}
+}
+
+// capLabels drops labels beyond the cap. Labels are sorted by name first so
+// the same ones are kept whatever order Linear returned them in.
+func (c *Client) capLabels(issue *IssueDetails) {
+	labels := issue.Labels.Nodes
+	if c.maxLabels == 0 || len(labels) <= c.maxLabels {
+		return
+	}
+	slices.SortFunc(labels, func(a, b IssueLabel) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	issue.Labels.Nodes = labels[:c.maxLabels]
+	slog.Warn("issue has more labels than linear.max_labels, ignoring the rest",
+		"issue", issue.Identifier,
+		"maxLabels", c.maxLabels,
+	)
+}
+
+// lookupPath walks a dotted path through decoded JSON objects, returning nil
+// if any step is missing or null.
+func lookupPath(fields map[string]any, path string) any {
+	var v any = fields
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = obj[key]
+	}
+	return v
 }
 
 // GetIssue fetches full issue details by ID.
 func (c *Client) GetIssue(ctx context.Context, id string) (*IssueDetails, error) {
 	query := `query($id: String!) {
 		issue(id: $id) {
-			id
-			identifier
-			title
-			description
-			url
-			state { id name }
-			team { id key }
-			labels { nodes { id name } }
-			project { id name description }
+			` + c.issueSelection() + `
 		}
 	}`
 
 	var resp GraphQLResponse[struct {
-		Issue IssueDetails `json:"issue"`
+		Issue json.RawMessage `json:"issue"`
 	}]
 
 	err := c.do(ctx, GraphQLRequest{
@@ -227,60 +624,106 @@ func (c *Client) GetIssue(ctx context.Context, id string) (*IssueDetails, error)
 		return nil, fmt.Errorf("graphql errors: %s", resp.Errors[0].Message)
 	}
 
-	return &resp.Data.Issue, nil
+	issue, err := c.decodeIssue(ctx, resp.Data.Issue)
+	if err != nil {
+		return nil, err
+	}
+	return &issue, nil
 }
 
+// issuesPerState is the page size of GetIssuesByStates; pagination is not
+// implemented, so a state with more issues only returns this many.
+const issuesPerState = 50
+
 // GetIssuesByState fetches issues for a team filtered by workflow state name.
 // Returns full issue details so no second fetch is needed.
 func (c *Client) GetIssuesByState(ctx context.Context, teamKey, stateName string) ([]IssueDetails, error) {
-	query := `query($teamKey: String!, $stateName: String!) {
-		issues(
+	byState, err := c.GetIssuesByStates(ctx, teamKey, []string{stateName})
+	if err != nil {
+		return nil, err
+	}
+	return byState[stateName], nil
+}
+
+// statesPerQuery is how many states one GetIssuesByStates request asks for.
+// Each state's issue page (with its labels and every extra_issue_fields
+// field) costs up to about 2,000 points of Linear's query complexity, and a
+// query may cost at most 10,000.
+const statesPerQuery = 4
+
+// GetIssuesByStates fetches a team's issues in each of the given workflow
+// states, keyed by the state names as passed in. Each state is an aliased
+// issues field, so every state gets its own page, and up to statesPerQuery
+// states share a request.
+func (c *Client) GetIssuesByStates(ctx context.Context, teamKey string, stateNames []string) (map[string][]IssueDetails, error) {
+	byState := make(map[string][]IssueDetails, len(stateNames))
+	for batch := range slices.Chunk(stateNames, statesPerQuery) {
+		if err := c.getIssuesByStates(ctx, teamKey, batch, byState); err != nil {
+			return nil, err
+		}
+	}
+	return byState, nil
+}
+
+// getIssuesByStates fetches the issues of stateNames in one request, adding
+// them to byState.
+func (c *Client) getIssuesByStates(ctx context.Context, teamKey string, stateNames []string, byState map[string][]IssueDetails) error {
+	params := []string{"$teamKey: String!"}
+	vars := map[string]any{"teamKey": teamKey}
+	var fields strings.Builder
+	for i, name := range stateNames {
+		fmt.Fprintf(&fields, `
+		s%d: issues(
 			filter: {
 				team: { key: { eq: $teamKey } }
-				state: { name: { eq: $stateName } }
+				state: { name: { eq: $state%d } }
 			}
-			first: 50
+			first: %d
 		) {
 			nodes {
-				id
-				identifier
-				title
-				description
-				url
-				state { id name }
-				team { id key }
-				labels { nodes { id name } }
-				project { id name description }
+				%s
 			}
-		}
-	}`
+		}`, i, i, issuesPerState, c.issueSelection())
+		params = append(params, fmt.Sprintf("$state%d: String!", i))
+		vars[fmt.Sprintf("state%d", i)] = c.canonicalStateName(teamKey, name)
+	}
+	query := "query(" + strings.Join(params, ", ") + ") {" + fields.String() + "\n\t}"
 
-	var resp GraphQLResponse[struct {
-		Issues struct {
-			Nodes []IssueDetails `json:"nodes"`
-		} `json:"issues"`
+	var resp GraphQLResponse[map[string]struct {
+		Nodes []json.RawMessage `json:"nodes"`
 	}]
 
 	err := c.do(ctx, GraphQLRequest{
 		Query:     query,
-		Variables: map[string]any{"teamKey": teamKey, "stateName": stateName},
+		Variables: vars,
 	}, &resp)
 	if err != nil {
-		return nil, fmt.Errorf("getting issues by state: %w", err)
+		return fmt.Errorf("getting issues by state: %w", err)
 	}
 	if len(resp.Errors) > 0 {
-		return nil, fmt.Errorf("graphql errors: %s", resp.Errors[0].Message)
+		return fmt.Errorf("graphql errors: %s", resp.Errors[0].Message)
 	}
 
-	issues := resp.Data.Issues.Nodes
-	if len(issues) == 50 {
-		slog.Warn("GetIssuesByState returned exactly 50 issues, there may be more (pagination not implemented)",
-			"teamKey", teamKey,
-			"stateName", stateName,
-		)
+	for i, name := range stateNames {
+		nodes := resp.Data[fmt.Sprintf("s%d", i)].Nodes
+		issues := make([]IssueDetails, 0, len(nodes))
+		for _, raw := range nodes {
+			issue, err := c.decodeIssue(ctx, raw)
+			if err != nil {
+				return err
+			}
+			issues = append(issues, issue)
+		}
+		if len(issues) == issuesPerState {
+			slog.Warn("state has at least a page of issues, there may be more (pagination not implemented)",
+				"teamKey", teamKey,
+				"stateName", name,
+				"pageSize", issuesPerState,
+			)
+		}
+		byState[name] = issues
 	}
-
-	return issues, nil
+	return nil
 }
 
 // UpdateIssueState transitions an issue to a new workflow state.
@@ -311,9 +754,56 @@ func (c *Client) UpdateIssueState(ctx context.Context, issueID, stateID string)
 		return fmt.Errorf("issue update returned success=false")
 	}
 
+	if c.verifyTransition {
+		c.checkIssueState(ctx, issueID, stateID)
+	}
 	return nil
 }
 
+// checkIssueState logs a warning if the issue's current state isn't stateID,
+// e.g. because an automation moved it straight back. Lookup failures are only
+// logged too: the update itself succeeded.
+func (c *Client) checkIssueState(ctx context.Context, issueID, stateID string) {
+	query := `query($id: String!) {
+		issue(id: $id) {
+			identifier
+			state { id name }
+		}
+	}`
+
+	var resp GraphQLResponse[struct {
+		Issue struct {
+			Identifier string `json:"identifier"`
+			State      struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"state"`
+		} `json:"issue"`
+	}]
+
+	err := c.do(ctx, GraphQLRequest{
+		Query:     query,
+		Variables: map[string]any{"id": issueID},
+	}, &resp)
+	if err == nil && len(resp.Errors) > 0 {
+		err = fmt.Errorf("graphql errors: %s", resp.Errors[0].Message)
+	}
+	if err != nil {
+		slog.Warn("verifying issue state after update", "error", err, "issueID", issueID)
+		return
+	}
+
+	issue := resp.Data.Issue
+	if issue.State.ID != stateID {
+		want, _ := c.ResolveStateName(stateID)
+		slog.Warn("issue state update reported success but the issue is elsewhere",
+			"issue", issue.Identifier,
+			"want", want,
+			"got", issue.State.Name,
+		)
+	}
+}
+
 // GetIssueComments fetches all comments on an issue, ordered by creation time.
 func (c *Client) GetIssueComments(ctx context.Context, issueID string) ([]CommentNode, error) {
 	query := `query($id: String!) {
@@ -351,6 +841,43 @@ func (c *Client) GetIssueComments(ctx context.Context, issueID string) ([]Commen
 	return resp.Data.Issue.Comments.Nodes, nil
 }
 
+// GetIssueRelatives fetches an issue's parent and sub-issues.
+func (c *Client) GetIssueRelatives(ctx context.Context, issueID string) (*IssueRelatives, error) {
+	query := `query($id: String!) {
+		issue(id: $id) {
+			parent { id identifier }
+			children(first: 250) {
+				nodes { id identifier }
+			}
+		}
+	}`
+
+	var resp GraphQLResponse[struct {
+		Issue struct {
+			Parent   *IssueRef `json:"parent"`
+			Children struct {
+				Nodes []IssueRef `json:"nodes"`
+			} `json:"children"`
+		} `json:"issue"`
+	}]
+
+	err := c.do(ctx, GraphQLRequest{
+		Query:     query,
+		Variables: map[string]any{"id": issueID},
+	}, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("getting issue relatives: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return nil, fmt.Errorf("graphql errors: %s", resp.Errors[0].Message)
+	}
+
+	return &IssueRelatives{
+		Parent:   resp.Data.Issue.Parent,
+		Children: resp.Data.Issue.Children.Nodes,
+	}, nil
+}
+
 // UpdateIssueDescription updates the description of a Linear issue.
 func (c *Client) UpdateIssueDescription(ctx context.Context, issueID, description string) error {
 	query := `mutation($id: String!, $description: String!) {
@@ -384,15 +911,26 @@ func (c *Client) UpdateIssueDescription(ctx context.Context, issueID, descriptio
 
 // PostComment adds a comment to an issue.
 func (c *Client) PostComment(ctx context.Context, issueID, body string) error {
+	_, err := c.CreateComment(ctx, issueID, body)
+	return err
+}
+
+// CreateComment adds a comment to an issue and returns the new comment's ID,
+// so it can later be edited with UpdateComment.
+func (c *Client) CreateComment(ctx context.Context, issueID, body string) (string, error) {
 	query := `mutation($issueId: String!, $body: String!) {
 		commentCreate(input: { issueId: $issueId, body: $body }) {
 			success
+			comment { id }
 		}
 	}`
 
 	var resp GraphQLResponse[struct {
 		CommentCreate struct {
 			Success bool `json:"success"`
+			Comment struct {
+				ID string `json:"id"`
+			} `json:"comment"`
 		} `json:"commentCreate"`
 	}]
 
@@ -401,23 +939,89 @@ func (c *Client) PostComment(ctx context.Context, issueID, body string) error {
 		Variables: map[string]any{"issueId": issueID, "body": body},
 	}, &resp)
 	if err != nil {
-		return fmt.Errorf("creating comment: %w", err)
+		return "", fmt.Errorf("creating comment: %w", err)
 	}
 	if len(resp.Errors) > 0 {
-		return fmt.Errorf("graphql errors: %s", resp.Errors[0].Message)
+		return "", fmt.Errorf("graphql errors: %s", resp.Errors[0].Message)
 	}
 	if !resp.Data.CommentCreate.Success {
-		return fmt.Errorf("comment create returned success=false")
+		return "", fmt.Errorf("comment create returned success=false")
+	}
+
+	return resp.Data.CommentCreate.Comment.ID, nil
+}
+
+// UpdateComment replaces the body of an existing comment.
+func (c *Client) UpdateComment(ctx context.Context, commentID, body string) error {
+	query := `mutation($id: String!, $body: String!) {
+		commentUpdate(id: $id, input: { body: $body }) {
+			success
+		}
+	}`
+
+	var resp GraphQLResponse[struct {
+		CommentUpdate struct {
+			Success bool `json:"success"`
+		} `json:"commentUpdate"`
+	}]
+
+	err := c.do(ctx, GraphQLRequest{
+		Query:     query,
+		Variables: map[string]any{"id": commentID, "body": body},
+	}, &resp)
+	if err != nil {
+		return fmt.Errorf("updating comment: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return fmt.Errorf("graphql errors: %s", resp.Errors[0].Message)
+	}
+	if !resp.Data.CommentUpdate.Success {
+		return fmt.Errorf("comment update returned success=false")
 	}
 
 	return nil
 }
 
-// TeamID returns the cached team ID (populated after LoadWorkflowStates).
-func (c *Client) TeamID() string {
+// LinkPR attaches a GitHub PR to an issue, so Linear shows it in the issue's
+// sidebar with live PR status.
+func (c *Client) LinkPR(ctx context.Context, issueID, prURL string) error {
+	query := `mutation($issueId: String!, $url: String!) {
+		attachmentLinkGitHubPR(issueId: $issueId, url: $url) {
+			success
+		}
+	}`
+
+	var resp GraphQLResponse[struct {
+		AttachmentLinkGitHubPR struct {
+			Success bool `json:"success"`
+		} `json:"attachmentLinkGitHubPR"`
+	}]
+
+	err := c.do(ctx, GraphQLRequest{
+		Query:     query,
+		Variables: map[string]any{"issueId": issueID, "url": prURL},
+	}, &resp)
+	if err != nil {
+		return fmt.Errorf("linking PR: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return fmt.Errorf("graphql errors: %s", resp.Errors[0].Message)
+	}
+	if !resp.Data.AttachmentLinkGitHubPR.Success {
+		return fmt.Errorf("PR link returned success=false")
+	}
+
+	return nil
+}
+
+// TeamID returns the cached ID of the team (populated by LoadWorkflowStates).
+func (c *Client) TeamID(teamKey string) string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	return c.teamID
+	if cache, ok := c.teams[teamKey]; ok {
+		return cache.id
+	}
+	return ""
 }
 
 // ListProjectsWithLabel returns projects that have the given label name.
@@ -532,10 +1136,10 @@ func (c *Client) CreateIssue(ctx context.Context, input CreateIssueInput) (strin
 	}`
 
 	issueInput := map[string]any{
-		"teamId":    input.TeamID,
-		"title":     input.Title,
-		"stateId":   input.StateID,
-		"priority":  input.Priority,
+		"teamId":   input.TeamID,
+		"title":    input.Title,
+		"stateId":  input.StateID,
+		"priority": input.Priority,
 	}
 	if input.ProjectID != "" {
 		issueInput["projectId"] = input.ProjectID
@@ -605,18 +1209,66 @@ func (c *Client) RemoveProjectLabel(ctx context.Context, projectID, labelID stri
 	return nil
 }
 
-// ResolveIssueLabels converts label names to IDs using the cached label map.
-// Unknown labels are logged and skipped (best-effort).
-func (c *Client) ResolveIssueLabels(labelNames []string) []string {
+// AddLabels adds labels (by ID) to an issue, keeping its existing labels.
+func (c *Client) AddLabels(ctx context.Context, issueID string, labelIDs []string) error {
+	return c.updateIssueLabels(ctx, issueID, "addedLabelIds", labelIDs)
+}
+
+// RemoveLabels removes labels (by ID) from an issue.
+func (c *Client) RemoveLabels(ctx context.Context, issueID string, labelIDs []string) error {
+	return c.updateIssueLabels(ctx, issueID, "removedLabelIds", labelIDs)
+}
+
+// updateIssueLabels sets the given IssueUpdateInput label field.
+func (c *Client) updateIssueLabels(ctx context.Context, issueID, field string, labelIDs []string) error {
+	if len(labelIDs) == 0 {
+		return nil
+	}
+	query := `mutation($id: String!, $labelIds: [String!]!) {
+		issueUpdate(id: $id, input: { ` + field + `: $labelIds }) {
+			success
+		}
+	}`
+
+	var resp GraphQLResponse[struct {
+		IssueUpdate struct {
+			Success bool `json:"success"`
+		} `json:"issueUpdate"`
+	}]
+
+	err := c.do(ctx, GraphQLRequest{
+		Query:     query,
+		Variables: map[string]any{"id": issueID, "labelIds": labelIDs},
+	}, &resp)
+	if err != nil {
+		return fmt.Errorf("updating issue labels: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return fmt.Errorf("graphql errors: %s", resp.Errors[0].Message)
+	}
+	if !resp.Data.IssueUpdate.Success {
+		return fmt.Errorf("issue label update returned success=false")
+	}
+
+	return nil
+}
+
+// ResolveIssueLabels converts label names to IDs using the team's cached
+// label map. Unknown labels are logged and skipped (best-effort).
+func (c *Client) ResolveIssueLabels(teamKey string, labelNames []string) []string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	var labels map[string]string
+	if cache, ok := c.teams[teamKey]; ok {
+		labels = cache.labels
+	}
 	var ids []string
 	for _, name := range labelNames {
-		if id, ok := c.labelCache[name]; ok {
+		if id, ok := labels[name]; ok {
 			ids = append(ids, id)
 		} else {
-			slog.Warn("issue label not found in cache, skipping", "label", name)
+			slog.Warn("issue label not found in cache, skipping", "team", teamKey, "label", name)
 		}
 	}
 	return ids
@@ -0,0 +1,418 @@
+package linear_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/linear"
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+func TestCreateThenUpdateComment(t *testing.T) {
+	fake := testutil.NewLinear(t, "Todo")
+	issue := fake.AddIssue(linear.IssueDetails{Title: "Fix the thing"})
+	c := fake.Client()
+	ctx := context.Background()
+
+	id, err := c.CreateComment(ctx, issue.ID, "started")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == "" {
+		t.Fatal("CreateComment returned an empty comment ID")
+	}
+	if err := c.UpdateComment(ctx, id, "finished"); err != nil {
+		t.Fatal(err)
+	}
+
+	create := fake.Requests("commentCreate")
+	if len(create) != 1 {
+		t.Fatalf("got %d commentCreate requests, want 1", len(create))
+	}
+	if got := create[0].Variables; got["issueId"] != issue.ID || got["body"] != "started" {
+		t.Errorf("commentCreate variables = %v", got)
+	}
+	update := fake.Requests("commentUpdate")
+	if len(update) != 1 {
+		t.Fatalf("got %d commentUpdate requests, want 1", len(update))
+	}
+	if got := update[0].Variables; got["id"] != id || got["body"] != "finished" {
+		t.Errorf("commentUpdate variables = %v, want id %q and body %q", got, id, "finished")
+	}
+
+	comments := fake.Comments(issue.ID)
+	if len(comments) != 1 || comments[0].Body != "finished" || comments[0].Edits != 1 {
+		t.Errorf("comments = %+v, want one comment edited to %q", comments, "finished")
+	}
+}
+
+func TestLinkPR(t *testing.T) {
+	fake := testutil.NewLinear(t, "Todo")
+	issue := fake.AddIssue(linear.IssueDetails{Title: "Fix the thing"})
+	c := fake.Client()
+
+	if err := c.LinkPR(context.Background(), issue.ID, testutil.DefaultPRURL); err != nil {
+		t.Fatal(err)
+	}
+	reqs := fake.Requests("attachmentLinkGitHubPR")
+	if len(reqs) != 1 {
+		t.Fatalf("got %d attachmentLinkGitHubPR requests, want 1", len(reqs))
+	}
+	if got := reqs[0].Variables; len(got) != 2 || got["issueId"] != issue.ID || got["url"] != testutil.DefaultPRURL {
+		t.Errorf("attachmentLinkGitHubPR variables = %v, want issueId %q and url %q", got, issue.ID, testutil.DefaultPRURL)
+	}
+
+	fake.Handle = func(req linear.GraphQLRequest) (any, bool) {
+		if !strings.Contains(req.Query, "attachmentLinkGitHubPR") {
+			return nil, false
+		}
+		return map[string]any{"attachmentLinkGitHubPR": map[string]any{"success": false}}, true
+	}
+	if err := c.LinkPR(context.Background(), issue.ID, testutil.DefaultPRURL); err == nil {
+		t.Error("LinkPR succeeded although Linear returned success=false")
+	}
+}
+
+func TestExtraIssueFields(t *testing.T) {
+	fake := testutil.NewLinear(t, "Todo")
+	issue := fake.AddIssue(linear.IssueDetails{Title: "Fix the thing"})
+	fake.Handle = func(req linear.GraphQLRequest) (any, bool) {
+		if !strings.Contains(req.Query, "issue(id:") {
+			return nil, false
+		}
+		return map[string]any{"issue": map[string]any{
+			"id":       issue.ID,
+			"title":    issue.Title,
+			"estimate": 3,
+			"cycle":    map[string]any{"name": "Sprint 4", "number": 4},
+		}}, true
+	}
+	c := fake.Client()
+	c.SetExtraIssueFields([]string{"estimate", "cycle.name", "dueDate"})
+
+	got, err := c.GetIssue(context.Background(), issue.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := fake.Requests("issue(id:")[0].Query; !strings.Contains(q, "estimate cycle { name } dueDate") {
+		t.Errorf("query does not select the extra fields:\n%s", q)
+	}
+	want := map[string]any{"estimate": 3.0, "cycle.name": "Sprint 4", "dueDate": nil}
+	if len(got.Extra) != len(want) {
+		t.Errorf("Extra = %v, want %v", got.Extra, want)
+	}
+	for path, value := range want {
+		if v, ok := got.Extra[path]; !ok || v != value {
+			t.Errorf("Extra[%q] = %v (present %v), want %v", path, v, ok, value)
+		}
+	}
+	if got.Title != issue.Title {
+		t.Errorf("title = %q, want the regular fields decoded too", got.Title)
+	}
+}
+
+func TestVerifyTransitionWarnsOnMismatch(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	fake := testutil.NewLinear(t, "Todo", "Done")
+	issue := fake.AddIssue(linear.IssueDetails{Title: "Fix the thing"})
+	c := fake.Client()
+	ctx := context.Background()
+	if err := c.LoadWorkflowStates(ctx, testutil.TeamKey); err != nil {
+		t.Fatal(err)
+	}
+	c.SetVerifyTransition(true)
+	fake.MoveIssue(issue.ID, "Todo")
+	done := fake.StateID("Done")
+
+	// Linear reports success, but the issue stays in Todo.
+	fake.Handle = func(req linear.GraphQLRequest) (any, bool) {
+		if !strings.Contains(req.Query, "issueUpdate") {
+			return nil, false
+		}
+		return map[string]any{"issueUpdate": map[string]any{"success": true}}, true
+	}
+	if err := c.UpdateIssueState(ctx, issue.ID, done); err != nil {
+		t.Fatalf("UpdateIssueState: %v", err)
+	}
+	log := buf.String()
+	if !strings.Contains(log, "reported success but the issue is elsewhere") ||
+		!strings.Contains(log, "want=Done") || !strings.Contains(log, "got=Todo") {
+		t.Errorf("log = %q, want a warning that the issue is in Todo, not Done", log)
+	}
+
+	buf.Reset()
+	fake.Handle = nil
+	if err := c.UpdateIssueState(ctx, issue.ID, done); err != nil {
+		t.Fatalf("UpdateIssueState: %v", err)
+	}
+	if strings.Contains(buf.String(), "elsewhere") {
+		t.Errorf("log = %q, want no warning once the issue moved", buf.String())
+	}
+}
+
+func TestExtraHeadersSentWithEveryRequest(t *testing.T) {
+	fake := testutil.NewLinear(t, "Todo")
+	issue := fake.AddIssue(linear.IssueDetails{Title: "Fix the thing"})
+
+	var mu sync.Mutex
+	var seen []http.Header
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Header.Clone())
+		mu.Unlock()
+		target, _ := url.Parse(fake.URL)
+		httputil.NewSingleHostReverseProxy(target).ServeHTTP(w, r)
+	}))
+	defer proxy.Close()
+
+	c := fake.Client()
+	c.SetAPIURL(proxy.URL)
+	c.SetExtraHeaders(map[string]string{
+		"X-Gateway-Token": "gw-secret",
+		"x-team":          "platform",
+		"Authorization":   "Bearer not-the-api-key",
+		"Content-Type":    "text/plain",
+	})
+	ctx := context.Background()
+	if _, err := c.GetIssue(ctx, issue.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateComment(ctx, issue.ID, "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(seen) != 2 {
+		t.Fatalf("proxy saw %d requests, want 2", len(seen))
+	}
+	for i, h := range seen {
+		for name, want := range map[string]string{
+			"X-Gateway-Token": "gw-secret",
+			"X-Team":          "platform",
+			"Authorization":   "test-key",
+			"Content-Type":    "application/json",
+		} {
+			if got := h.Values(name); len(got) != 1 || got[0] != want {
+				t.Errorf("request %d: %s = %q, want %q", i, name, got, want)
+			}
+		}
+	}
+}
+
+func TestMaxLabelsKeepsSameLabelsWhateverTheOrder(t *testing.T) {
+	fake := testutil.NewLinear(t, "Todo")
+	names := []string{"delta", "alpha", "echo", "charlie", "bravo"}
+	withLabels := func(names []string) *linear.IssueDetails {
+		var issue linear.IssueDetails
+		for _, name := range names {
+			issue.Labels.Nodes = append(issue.Labels.Nodes, linear.IssueLabel{Name: name})
+		}
+		return fake.AddIssue(issue)
+	}
+	forward := withLabels(names)
+	reversed := withLabels([]string{"bravo", "charlie", "echo", "alpha", "delta"})
+	c := fake.Client()
+	c.SetMaxLabels(2)
+
+	for _, issue := range []*linear.IssueDetails{forward, reversed} {
+		details, err := c.GetIssue(context.Background(), issue.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := details.LabelNames(); !slices.Equal(got, []string{"alpha", "bravo"}) {
+			t.Errorf("labels of %s = %q, want the first two by name", issue.ID, got)
+		}
+	}
+}
+
+func TestLabelsBeyondFirstPageReadBeforeCapping(t *testing.T) {
+	fake := testutil.NewLinear(t, "Todo")
+	var issue linear.IssueDetails
+	for i := range 14 {
+		issue.Labels.Nodes = append(issue.Labels.Nodes, linear.IssueLabel{Name: fmt.Sprintf("label-%02d", 13-i)})
+	}
+	added := fake.AddIssue(issue)
+	all := fake.Issue(added.ID).Labels.Nodes
+
+	// Serve the labels in pages of 10, as Linear would for the first page
+	page := func(after string) map[string]any {
+		start := 0
+		if after != "" {
+			start, _ = strconv.Atoi(after)
+		}
+		end := min(start+10, len(all))
+		return map[string]any{
+			"nodes":    all[start:end],
+			"pageInfo": map[string]any{"hasNextPage": end < len(all), "endCursor": strconv.Itoa(end)},
+		}
+	}
+	fake.Handle = func(req linear.GraphQLRequest) (any, bool) {
+		if !strings.Contains(req.Query, "issue(id:") {
+			return nil, false
+		}
+		if after, ok := req.Variables["after"].(string); ok {
+			return map[string]any{"issue": map[string]any{"labels": page(after)}}, true
+		}
+		data, _ := json.Marshal(fake.Issue(added.ID))
+		var full map[string]any
+		json.Unmarshal(data, &full)
+		full["labels"] = page("")
+		return map[string]any{"issue": full}, true
+	}
+	c := fake.Client()
+	c.SetMaxLabels(2)
+
+	details, err := c.GetIssue(context.Background(), added.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// label-00 and label-01 are only on the second page
+	if got := details.LabelNames(); !slices.Equal(got, []string{"label-00", "label-01"}) {
+		t.Errorf("labels = %q, want the first two by name across every page", got)
+	}
+	if got := len(fake.Requests("after: $after")); got != 1 {
+		t.Errorf("%d label page requests, want 1", got)
+	}
+}
+
+// queryComplexity estimates the cost Linear charges for a query: 0.1 per
+// scalar field, 1 per object, and the contents of a connection multiplied
+// by its first argument.
+func queryComplexity(t *testing.T, query string) float64 {
+	t.Helper()
+	tokens := regexp.MustCompile(`\([^()]*(?:\([^()]*\)[^()]*)*\)|[{}:]|[\w$]+`).FindAllString(query, -1)
+	first := regexp.MustCompile(`first:\s*(\d+)`)
+	i := 0
+	var selection func() float64
+	selection = func() float64 {
+		var cost float64
+		for i < len(tokens) && tokens[i] != "}" {
+			i++ // field name
+			if i+1 < len(tokens) && tokens[i] == ":" {
+				i += 2 // alias
+			}
+			args := ""
+			if i < len(tokens) && strings.HasPrefix(tokens[i], "(") {
+				args = tokens[i]
+				i++
+			}
+			if i >= len(tokens) || tokens[i] != "{" {
+				cost += 0.1
+				continue
+			}
+			i++
+			inner := selection()
+			i++ // closing brace
+			if m := first.FindStringSubmatch(args); m != nil {
+				n, _ := strconv.Atoi(m[1])
+				cost += float64(n) * inner
+			} else {
+				cost += 1 + inner
+			}
+		}
+		return cost
+	}
+	for i < len(tokens) && tokens[i] != "{" {
+		i++
+	}
+	i++
+	return selection()
+}
+
+func TestGetIssuesByStatesStaysUnderComplexityLimit(t *testing.T) {
+	var states []string
+	for i := range 12 {
+		states = append(states, fmt.Sprintf("State %d", i+1))
+	}
+	fake := testutil.NewLinear(t, states...)
+	c := fake.Client()
+	// Every field linear.extra_issue_fields allows, for the largest issue selection
+	c.SetExtraIssueFields([]string{
+		"archivedAt", "assignee.displayName", "assignee.name", "branchName", "canceledAt",
+		"completedAt", "createdAt", "creator.displayName", "creator.name", "customerTicketCount",
+		"cycle.endsAt", "cycle.name", "cycle.number", "cycle.startsAt", "dueDate", "estimate",
+		"number", "parent.identifier", "parent.title", "priorityLabel", "projectMilestone.name",
+		"projectMilestone.targetDate", "slaBreachesAt", "snoozedUntilAt", "startedAt",
+		"triagedAt", "updatedAt",
+	})
+
+	byState, err := c.GetIssuesByStates(context.Background(), testutil.TeamKey, states)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byState) != len(states) {
+		t.Errorf("got issues for %d states, want %d", len(byState), len(states))
+	}
+	reqs := fake.Requests(": issues(")
+	if len(reqs) < 2 {
+		t.Fatalf("%d states fetched in %d request(s), want them split", len(states), len(reqs))
+	}
+	for i, req := range reqs {
+		if cost := queryComplexity(t, req.Query); cost > 10000 {
+			t.Errorf("request %d: complexity %.0f exceeds Linear's limit of 10000", i, cost)
+		}
+	}
+}
+
+func TestGetIssuesByStatesBatched(t *testing.T) {
+	fake := testutil.NewLinear(t, "Todo", "In Progress", "In Review", "Done")
+	add := func(state, title string) string {
+		issue := linear.IssueDetails{Title: title}
+		issue.State.Name = state
+		return fake.AddIssue(issue).ID
+	}
+	todo1, todo2 := add("Todo", "first"), add("Todo", "second")
+	doing := add("In Progress", "third")
+	add("Done", "not asked for")
+	c := fake.Client()
+	if err := c.LoadWorkflowStates(context.Background(), "ENG"); err != nil {
+		t.Fatal(err)
+	}
+
+	byState, err := c.GetIssuesByStates(context.Background(), "ENG", []string{"Todo", "In Progress", "In Review"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(fake.Requests(": issues(")); got != 1 {
+		t.Errorf("made %d issue queries, want 1 for every state", got)
+	}
+
+	ids := func(state string) []string {
+		var ids []string
+		for _, issue := range byState[state] {
+			ids = append(ids, issue.ID)
+		}
+		slices.Sort(ids)
+		return ids
+	}
+	want := []string{todo1, todo2}
+	slices.Sort(want)
+	if got := ids("Todo"); !slices.Equal(got, want) {
+		t.Errorf("Todo issues = %q, want %q", got, want)
+	}
+	if got := ids("In Progress"); !slices.Equal(got, []string{doing}) {
+		t.Errorf("In Progress issues = %q, want %q", got, doing)
+	}
+	if issues, ok := byState["In Review"]; !ok || len(issues) != 0 {
+		t.Errorf("In Review = %v (present %v), want an empty group", issues, ok)
+	}
+	if _, ok := byState["Done"]; ok {
+		t.Error("result has a group for a state that wasn't asked for")
+	}
+}
@@ -0,0 +1,52 @@
+package linear
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// HTTPOptions configures the HTTP client used to reach the Linear API.
+type HTTPOptions struct {
+	ProxyURL    string        // empty = use HTTP(S)_PROXY / NO_PROXY from the environment
+	TLSInsecure bool          // skip TLS certificate verification (e.g. behind an intercepting proxy)
+	Timeout     time.Duration // per-request timeout; 0 = none
+}
+
+// NewHTTPClient builds an http.Client with its own transport from opts.
+func NewHTTPClient(opts HTTPOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if opts.ProxyURL != "" {
+		proxy, err := url.Parse(opts.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing proxy URL: %w", err)
+		}
+		if proxy.Scheme == "" || proxy.Host == "" {
+			return nil, fmt.Errorf("proxy URL %q must include a scheme and host", opts.ProxyURL)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if opts.TLSInsecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &http.Client{Transport: transport, Timeout: opts.Timeout}, nil
+}
+
+// SetHTTPOptions replaces the client's HTTP client with one built from opts.
+func (c *Client) SetHTTPOptions(opts HTTPOptions) error {
+	httpClient, err := NewHTTPClient(opts)
+	if err != nil {
+		return err
+	}
+	c.httpClient = httpClient
+	return nil
+}
+
+// SetAPIURL points the client at another GraphQL endpoint than Linear's, such
+// as a gateway in front of it or a fake server in tests.
+func (c *Client) SetAPIURL(url string) {
+	c.apiURL = url
+}
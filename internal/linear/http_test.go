@@ -0,0 +1,72 @@
+package linear
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPClientWithProxy(t *testing.T) {
+	c, err := NewHTTPClient(HTTPOptions{ProxyURL: "http://proxy.corp:3128", TLSInsecure: true, Timeout: 20 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport, ok := c.Transport.(*http.Transport)
+	if !ok || transport == http.DefaultTransport {
+		t.Fatalf("transport = %T, want a dedicated *http.Transport", c.Transport)
+	}
+	proxy, err := transport.Proxy(httptest.NewRequest(http.MethodPost, apiURL, nil))
+	if err != nil || proxy == nil || proxy.String() != "http://proxy.corp:3128" {
+		t.Errorf("proxy for %s = %v, %v; want http://proxy.corp:3128", apiURL, proxy, err)
+	}
+	if transport.TLSClientConfig == nil || !transport.TLSClientConfig.InsecureSkipVerify {
+		t.Error("tls_insecure did not disable certificate verification")
+	}
+	if c.Timeout != 20*time.Second {
+		t.Errorf("timeout = %s, want 20s", c.Timeout)
+	}
+}
+
+func TestNewHTTPClientDefaults(t *testing.T) {
+	c, err := NewHTTPClient(HTTPOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := c.Transport.(*http.Transport)
+	if transport.Proxy == nil {
+		t.Error("default transport ignores proxy environment variables")
+	}
+	if transport.TLSClientConfig != nil && transport.TLSClientConfig.InsecureSkipVerify {
+		t.Error("default transport skips certificate verification")
+	}
+
+	for _, bad := range []string{"proxy.corp:3128", "://", "http://"} {
+		if _, err := NewHTTPClient(HTTPOptions{ProxyURL: bad}); err == nil {
+			t.Errorf("accepted proxy URL %q", bad)
+		}
+	}
+}
+
+func TestClientSendsRequestsThroughProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"commentCreate":{"success":true,"comment":{"id":"comment-1"}}}}`))
+	}))
+	defer proxy.Close()
+
+	c := NewClient("test-key")
+	c.SetAPIURL("http://linear.invalid/graphql")
+	if err := c.SetHTTPOptions(HTTPOptions{ProxyURL: proxy.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateComment(context.Background(), "issue-1", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if proxied != "http://linear.invalid/graphql" {
+		t.Errorf("proxy saw %q, want the request for http://linear.invalid/graphql", proxied)
+	}
+}
@@ -32,6 +32,11 @@ func AppendBranchMetadata(description, branchName, prURL string) string {
 	return description + block.String()
 }
 
+// StripBranchMetadata removes the block added by AppendBranchMetadata.
+func StripBranchMetadata(description string) string {
+	return branchMetadataBlock.ReplaceAllString(description, "")
+}
+
 // IssueMeta holds GitHub repository metadata parsed from a Linear issue description.
 type IssueMeta struct {
 	GithubRepo    string `yaml:"github_repo" json:"github_repo"`
@@ -40,7 +45,7 @@ type IssueMeta struct {
 
 // ParseIssueMeta extracts repository metadata from a Linear issue description.
 // It looks for a YAML frontmatter block delimited by "---" lines, or a JSON object
-// embedded in the description. If default_branch is not set, it defaults to "main".
+// embedded in the description. default_branch is left empty when not set.
 func ParseIssueMeta(description string) (*IssueMeta, error) {
 	description = strings.TrimSpace(description)
 
@@ -73,9 +78,6 @@ func parseIssueMetaJSON(description string) (*IssueMeta, error) {
 	if meta.GithubRepo == "" {
 		return nil, fmt.Errorf("github_repo is required in issue metadata")
 	}
-	if meta.DefaultBranch == "" {
-		meta.DefaultBranch = "main"
-	}
 	return &meta, nil
 }
 
@@ -119,9 +121,5 @@ func parseIssueMetaYAML(description string) (*IssueMeta, error) {
 		return nil, fmt.Errorf("github_repo is required in issue frontmatter")
 	}
 
-	if meta.DefaultBranch == "" {
-		meta.DefaultBranch = "main"
-	}
-
 	return &meta, nil
 }
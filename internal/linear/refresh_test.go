@@ -0,0 +1,85 @@
+package linear
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stateServer answers workflow state queries for team ENG with its current
+// states, counting the queries.
+type stateServer struct {
+	mu      sync.Mutex
+	states  []WorkflowState
+	queries int
+}
+
+func (s *stateServer) add(id, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states = append(s.states, WorkflowState{ID: id, Name: name, Type: "started"})
+}
+
+func (s *stateServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queries
+}
+
+func (s *stateServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries++
+	team := map[string]any{
+		"id":     "team-1",
+		"states": map[string]any{"nodes": s.states},
+		"labels": map[string]any{"nodes": []any{}},
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"data": map[string]any{"teams": map[string]any{"nodes": []any{team}}},
+	})
+}
+
+func TestStateAddedAfterStartupResolvesAfterRefresh(t *testing.T) {
+	srv := &stateServer{}
+	srv.add("state-todo", "Todo")
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	c := NewClient("test-key")
+	c.SetAPIURL(ts.URL)
+	if err := c.LoadWorkflowStates(context.Background(), "ENG"); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.add("state-deploy", "Ready to Deploy")
+
+	// Just after a load, a miss doesn't query Linear again
+	if _, ok := c.ResolveStateID("ENG", "Ready to Deploy"); ok {
+		t.Fatal("resolved a state the cache hasn't loaded yet")
+	}
+	if got := srv.count(); got != 1 {
+		t.Fatalf("%d state queries, want no reload within the refresh gap", got)
+	}
+
+	c.mu.Lock()
+	c.teams["ENG"].loadedAt = time.Now().Add(-stateMissRefreshGap)
+	c.mu.Unlock()
+
+	id, ok := c.ResolveStateID("ENG", "Ready to Deploy")
+	if !ok || id != "state-deploy" {
+		t.Fatalf("ResolveStateID after refresh = %q, %v; want state-deploy", id, ok)
+	}
+	if got := srv.count(); got != 2 {
+		t.Errorf("%d state queries, want one reload", got)
+	}
+	if name, ok := c.ResolveStateName("state-deploy"); !ok || name != "Ready to Deploy" {
+		t.Errorf("ResolveStateName = %q, %v", name, ok)
+	}
+	if id, ok := c.ResolveStateID("ENG", "Todo"); !ok || id != "state-todo" {
+		t.Errorf("existing state after refresh = %q, %v", id, ok)
+	}
+}
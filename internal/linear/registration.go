@@ -0,0 +1,75 @@
+package linear
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// GetWebhooks lists the webhooks registered in the workspace. Their secrets
+// are only returned to admins.
+func (c *Client) GetWebhooks(ctx context.Context) ([]Webhook, error) {
+	query := `query {
+		webhooks(first: 100) {
+			nodes {
+				id
+				url
+				enabled
+				secret
+				allPublicTeams
+				team { key }
+			}
+		}
+	}`
+
+	var resp GraphQLResponse[struct {
+		Webhooks struct {
+			Nodes []Webhook `json:"nodes"`
+		} `json:"webhooks"`
+	}]
+
+	if err := c.do(ctx, GraphQLRequest{Query: query}, &resp); err != nil {
+		return nil, fmt.Errorf("listing webhooks: %w", err)
+	}
+	if len(resp.Errors) > 0 {
+		return nil, fmt.Errorf("graphql errors: %s", resp.Errors[0].Message)
+	}
+
+	return resp.Data.Webhooks.Nodes, nil
+}
+
+// FindWebhook picks the enabled webhook that delivers teamKey's events: the
+// one registered at url if url is set, otherwise the only one there is. It
+// fails if none or several match, or if the match's secret wasn't returned.
+func (c *Client) FindWebhook(ctx context.Context, teamKey, url string) (*Webhook, error) {
+	hooks, err := c.GetWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Webhook
+	for _, h := range hooks {
+		if !h.Enabled || (url != "" && h.URL != url) {
+			continue
+		}
+		if h.AllPublicTeams || (h.Team != nil && strings.EqualFold(h.Team.Key, teamKey)) {
+			matches = append(matches, h)
+		}
+	}
+	switch {
+	case len(matches) == 0 && url != "":
+		return nil, fmt.Errorf("no enabled webhook for team %s is registered at %s", teamKey, url)
+	case len(matches) == 0:
+		return nil, fmt.Errorf("no enabled webhook is registered for team %s", teamKey)
+	case len(matches) > 1:
+		urls := make([]string, len(matches))
+		for i, h := range matches {
+			urls[i] = h.URL
+		}
+		return nil, fmt.Errorf("%d webhooks are registered for team %s (%s); set linear.webhook_url to pick one", len(matches), teamKey, strings.Join(urls, ", "))
+	}
+	if matches[0].Secret == "" {
+		return nil, fmt.Errorf("webhook %s has no signing secret visible to this API key (an admin key is required)", matches[0].URL)
+	}
+	return &matches[0], nil
+}
@@ -0,0 +1,75 @@
+package linear
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxSeenDeliveries bounds the replay cache; once full, the oldest entries
+// are dropped first.
+const maxSeenDeliveries = 10000
+
+// replayCache remembers the signatures of recent deliveries so an exact
+// replay is rejected even while its timestamp is still fresh. Entries expire
+// after ttl. Since every entry lives for the same ttl, the oldest entry is
+// always the next to expire, so a FIFO queue is enough for eviction.
+type replayCache struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	limit int
+	seen  map[string]time.Time // signature → expiry
+	order []string             // signatures, oldest first
+}
+
+func newReplayCache(ttl time.Duration, limit int) *replayCache {
+	return &replayCache{
+		ttl:   ttl,
+		limit: limit,
+		seen:  make(map[string]time.Time),
+	}
+}
+
+// firstSeen records sig and reports whether it had not been seen within ttl.
+func (c *replayCache) firstSeen(sig string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evict(now)
+	if _, ok := c.seen[sig]; ok {
+		return false
+	}
+	if len(c.order) >= c.limit {
+		delete(c.seen, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.seen[sig] = now.Add(c.ttl)
+	c.order = append(c.order, sig)
+	return true
+}
+
+// forget drops sig, so the same delivery is accepted again.
+func (c *replayCache) forget(sig string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.seen[sig]; !ok {
+		return
+	}
+	delete(c.seen, sig)
+	if i := slices.Index(c.order, sig); i >= 0 {
+		c.order = slices.Delete(c.order, i, i+1)
+	}
+}
+
+// evict drops entries that expired by now.
+func (c *replayCache) evict(now time.Time) {
+	n := 0
+	for n < len(c.order) && !now.Before(c.seen[c.order[n]]) {
+		delete(c.seen, c.order[n])
+		n++
+	}
+	if n > 0 {
+		c.order = append(c.order[:0:0], c.order[n:]...)
+	}
+}
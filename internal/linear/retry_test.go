@@ -0,0 +1,89 @@
+package linear
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// seededJitter is a reproducible stand-in for rand.N.
+func seededJitter(seed uint64) func(time.Duration) time.Duration {
+	r := rand.New(rand.NewPCG(seed, seed))
+	return func(n time.Duration) time.Duration { return time.Duration(r.Int64N(int64(n))) }
+}
+
+func TestRetryDelayCappedAndJittered(t *testing.T) {
+	const maxDelay = 3 * time.Second
+	jitter := seededJitter(1)
+	for attempt := 1; attempt <= 12; attempt++ {
+		backoff := min(baseRetryDelay<<(attempt-1), maxDelay)
+		seen := make(map[time.Duration]bool)
+		for range 50 {
+			d := retryDelay(attempt, maxDelay, jitter)
+			if d < 0 || d >= backoff {
+				t.Fatalf("attempt %d: delay %s outside [0, %s)", attempt, d, backoff)
+			}
+			seen[d] = true
+		}
+		if len(seen) < 40 {
+			t.Errorf("attempt %d: only %d distinct delays in 50 draws, want jitter", attempt, len(seen))
+		}
+	}
+
+	a, b := seededJitter(7), seededJitter(7)
+	for attempt := 1; attempt <= 5; attempt++ {
+		if da, db := retryDelay(attempt, maxDelay, a), retryDelay(attempt, maxDelay, b); da != db {
+			t.Errorf("attempt %d: same seed gave %s and %s", attempt, da, db)
+		}
+	}
+}
+
+func TestRetryDelayHugeAttemptStaysCapped(t *testing.T) {
+	// 2^200 overflows the duration; the cap must still apply
+	d := retryDelay(200, time.Second, func(n time.Duration) time.Duration { return n - 1 })
+	if d != time.Second-1 {
+		t.Errorf("delay = %s, want just under the 1s cap", d)
+	}
+}
+
+func TestDoRetriesWithCappedBackoff(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 4 {
+			http.Error(w, "bad gateway", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"data":{"commentCreate":{"success":true,"comment":{"id":"comment-1"}}}}`))
+	}))
+	defer srv.Close()
+
+	c := NewClient("test-key")
+	c.SetAPIURL(srv.URL)
+	c.SetRetryPolicy(4, 800*time.Millisecond)
+	var backoffs []time.Duration
+	c.jitter = func(n time.Duration) time.Duration {
+		backoffs = append(backoffs, n)
+		return 0
+	}
+
+	if _, err := c.CreateComment(context.Background(), "issue-1", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if got := calls.Load(); got != 4 {
+		t.Errorf("got %d requests, want 4", got)
+	}
+	want := []time.Duration{500 * time.Millisecond, 800 * time.Millisecond, 800 * time.Millisecond}
+	if len(backoffs) != len(want) {
+		t.Fatalf("backoffs = %v, want %v", backoffs, want)
+	}
+	for i := range want {
+		if backoffs[i] != want[i] {
+			t.Errorf("backoffs = %v, want %v", backoffs, want)
+			break
+		}
+	}
+}
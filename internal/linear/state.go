@@ -0,0 +1,42 @@
+package linear
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizeStateName returns the form of a workflow state name used to match
+// it: leading emoji, symbols and variation selectors are dropped and runs of
+// whitespace collapse to one space, so "🚀  Ready to Deploy " and
+// "Ready to Deploy" compare equal. Case is kept; compare with SameState.
+func NormalizeStateName(name string) string {
+	name = strings.TrimLeftFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// SameState reports whether two workflow state names refer to the same state,
+// ignoring case, leading emoji and whitespace differences.
+func SameState(a, b string) bool {
+	return strings.EqualFold(NormalizeStateName(a), NormalizeStateName(b))
+}
+
+// stateKey is the state cache key for name.
+func stateKey(name string) string {
+	return strings.ToLower(NormalizeStateName(name))
+}
+
+// canonicalStateName returns the team's loaded workflow state matching name,
+// spelled as Linear has it, for API filters that compare names exactly.
+// Unknown names are returned as they are.
+func (c *Client) canonicalStateName(teamKey, name string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if cache, ok := c.teams[teamKey]; ok {
+		if id, ok := cache.states[stateKey(name)]; ok {
+			return cache.names[id]
+		}
+	}
+	return name
+}
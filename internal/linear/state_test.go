@@ -0,0 +1,48 @@
+package linear_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/linear"
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+func TestSameState(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"🚀 Ready to Deploy", "Ready to Deploy", true},
+		{"🚀️ Ready to Deploy", "ready to deploy", true}, // with a variation selector
+		{"Ready  to Deploy ", "Ready to Deploy", true},
+		{"✅ Done", "Done", true},
+		{"Ready to Deploy", "Ready", false},
+		{"Todo", "Done", false},
+	}
+	for _, tt := range tests {
+		if got := linear.SameState(tt.a, tt.b); got != tt.want {
+			t.Errorf("SameState(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestResolveEmojiPrefixedState(t *testing.T) {
+	fake := testutil.NewLinear(t, "Todo", "🚀 Ready to Deploy")
+	c := fake.Client()
+	if err := c.LoadWorkflowStates(context.Background(), "ENG"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"Ready to Deploy", "🚀 Ready to Deploy", "ready  to deploy"} {
+		id, ok := c.ResolveStateID("ENG", name)
+		if !ok {
+			t.Errorf("ResolveStateID(%q) found nothing", name)
+			continue
+		}
+		// Names map back to the state as Linear spells it
+		if got, _ := c.ResolveStateName(id); got != "🚀 Ready to Deploy" {
+			t.Errorf("ResolveStateName(ResolveStateID(%q)) = %q, want the canonical name", name, got)
+		}
+	}
+}
@@ -28,8 +28,24 @@ type IssueData struct {
 
 // UpdatedFromData captures which fields changed in an update.
 type UpdatedFromData struct {
-	StateID  string `json:"stateId,omitempty"`
+	StateID   string `json:"stateId,omitempty"`
 	UpdatedAt string `json:"updatedAt,omitempty"`
+
+	// Description holds the previous description when it changed. It is raw
+	// so a previously empty (null) description still registers as a change.
+	Description json.RawMessage `json:"description,omitempty"`
+}
+
+// PreviousDescription returns the description before the update, if it changed.
+func (u UpdatedFromData) PreviousDescription() (string, bool) {
+	if u.Description == nil {
+		return "", false
+	}
+	var desc *string
+	if err := json.Unmarshal(u.Description, &desc); err != nil || desc == nil {
+		return "", true
+	}
+	return *desc, true
 }
 
 // WorkflowState represents a Linear workflow state.
@@ -46,6 +62,7 @@ type IssueDetails struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
 	URL         string `json:"url"`
+	Priority    int    `json:"priority"` // 0 = none, 1 = urgent, 2 = high, 3 = medium, 4 = low
 	State       struct {
 		ID   string `json:"id"`
 		Name string `json:"name"`
@@ -55,16 +72,58 @@ type IssueDetails struct {
 		Key string `json:"key"`
 	} `json:"team"`
 	Labels struct {
-		Nodes []struct {
-			ID   string `json:"id"`
-			Name string `json:"name"`
-		} `json:"nodes"`
+		Nodes []IssueLabel `json:"nodes"`
 	} `json:"labels"`
 	Project *struct {
 		ID          string `json:"id"`
 		Name        string `json:"name"`
 		Description string `json:"description"`
 	} `json:"project"`
+	Assignee *struct {
+		ID    string `json:"id"`
+		Email string `json:"email"`
+		URL   string `json:"url"` // profile URL; in comment markdown it becomes a mention
+	} `json:"assignee"`
+	Creator *struct {
+		ID    string `json:"id"`
+		Email string `json:"email"`
+	} `json:"creator"` // nil for issues created by integrations
+
+	// Extra holds the fields requested via SetExtraIssueFields, keyed by
+	// their dotted path (e.g. "cycle.name").
+	Extra map[string]any `json:"-"`
+}
+
+// IssueLabel is a label on an issue.
+type IssueLabel struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// LabelNames returns the names of the issue's labels.
+func (d *IssueDetails) LabelNames() []string {
+	var names []string
+	for _, l := range d.Labels.Nodes {
+		names = append(names, l.Name)
+	}
+	return names
+}
+
+// PriorityName returns the lowercase name of a Linear priority value
+// ("none", "urgent", "high", "medium", "low").
+func PriorityName(priority int) string {
+	switch priority {
+	case 1:
+		return "urgent"
+	case 2:
+		return "high"
+	case 3:
+		return "medium"
+	case 4:
+		return "low"
+	default:
+		return "none"
+	}
 }
 
 // CommentData is the comment object embedded in webhook payloads.
@@ -75,6 +134,39 @@ type CommentData struct {
 	UserID  string `json:"userId"`
 }
 
+// IssueRef identifies an issue related to another one.
+type IssueRef struct {
+	ID         string `json:"id"`
+	Identifier string `json:"identifier"`
+}
+
+// Webhook is a webhook registered in the Linear workspace. Team is nil for
+// webhooks that aren't tied to one team.
+type Webhook struct {
+	ID             string `json:"id"`
+	URL            string `json:"url"`
+	Enabled        bool   `json:"enabled"`
+	Secret         string `json:"secret"`
+	AllPublicTeams bool   `json:"allPublicTeams"`
+	Team           *struct {
+		Key string `json:"key"`
+	} `json:"team"`
+}
+
+// Attachment is a file or link attached to an issue.
+type Attachment struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// IssueRelatives holds an issue's parent (nil for top-level issues) and
+// sub-issues.
+type IssueRelatives struct {
+	Parent   *IssueRef
+	Children []IssueRef
+}
+
 // CommentNode represents a comment returned by a GraphQL query.
 type CommentNode struct {
 	ID        string `json:"id"`
@@ -7,23 +7,40 @@ import (
 	"encoding/json"
 	"io"
 	"log/slog"
-	"math"
 	"net/http"
 	"time"
 )
 
 const (
-	maxBodySize       = 1 << 20 // 1 MB
-	signatureHeader   = "Linear-Signature"
-	timestampHeader   = "Linear-Delivery"
-	maxTimestampDrift = 60 * time.Second
+	maxBodySize     = 1 << 20 // 1 MB
+	signatureHeader = "Linear-Signature"
+	timestampHeader = "Linear-Delivery"
+
+	// DefaultMaxTimestampDrift is how old a delivery may be when no drift is configured.
+	DefaultMaxTimestampDrift = 60 * time.Second
+
+	// minFutureSkew is how far ahead of the local clock a delivery may be dated.
+	// Future-dated deliveries don't carry replay risk, so this bound is looser.
+	minFutureSkew = 5 * time.Minute
 )
 
 // DispatchFunc is the callback the webhook handler invokes for valid payloads.
-type DispatchFunc func(payload WebhookPayload)
+// It is called before the delivery is acknowledged, so it should only record
+// or hand off the payload; an error fails the delivery with a 500 so Linear
+// sends it again.
+type DispatchFunc func(payload WebhookPayload) error
 
 // NewWebhookHandler returns an http.HandlerFunc that verifies and dispatches Linear webhooks.
-func NewWebhookHandler(secret string, dispatch DispatchFunc) http.HandlerFunc {
+// A delivery signed with any of secrets is accepted, so a secret can be rotated
+// without rejecting deliveries. Deliveries older than maxDrift are rejected as
+// possible replays, and so is a second delivery with the same signature while
+// the first could still pass the timestamp check.
+func NewWebhookHandler(secrets []string, maxDrift time.Duration, dispatch DispatchFunc) http.HandlerFunc {
+	if maxDrift <= 0 {
+		maxDrift = DefaultMaxTimestampDrift
+	}
+	// A delivery dated as far ahead as allowed stays fresh for this long
+	replays := newReplayCache(maxDrift+max(maxDrift, minFutureSkew), maxSeenDeliveries)
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -44,7 +61,7 @@ func NewWebhookHandler(secret string, dispatch DispatchFunc) http.HandlerFunc {
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
-		if !verifySignature(secret, body, sig) {
+		if !verifySignature(secrets, body, sig) {
 			slog.Warn("invalid webhook signature")
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
@@ -53,16 +70,19 @@ func NewWebhookHandler(secret string, dispatch DispatchFunc) http.HandlerFunc {
 		// Validate timestamp freshness
 		if ts := r.Header.Get(timestampHeader); ts != "" {
 			deliveryTime, err := time.Parse(time.RFC3339Nano, ts)
-			if err == nil {
-				drift := time.Since(deliveryTime)
-				if math.Abs(drift.Seconds()) > maxTimestampDrift.Seconds() {
-					slog.Warn("webhook timestamp too old", "drift", drift)
-					http.Error(w, "request too old", http.StatusBadRequest)
-					return
-				}
+			if err == nil && !deliveryFresh(deliveryTime, time.Now(), maxDrift) {
+				slog.Warn("webhook timestamp outside allowed drift", "drift", time.Since(deliveryTime), "maxDrift", maxDrift)
+				http.Error(w, "request too old", http.StatusBadRequest)
+				return
 			}
 		}
 
+		if !replays.firstSeen(sig, time.Now()) {
+			slog.Warn("rejecting replayed webhook delivery")
+			http.Error(w, "duplicate delivery", http.StatusConflict)
+			return
+		}
+
 		var payload WebhookPayload
 		if err := json.Unmarshal(body, &payload); err != nil {
 			slog.Error("parsing webhook payload", "error", err)
@@ -70,24 +90,44 @@ func NewWebhookHandler(secret string, dispatch DispatchFunc) http.HandlerFunc {
 			return
 		}
 
-		// Return 200 immediately
-		w.WriteHeader(http.StatusOK)
-
 		// Filter: only Issue updates and Comment creates
 		switch {
-		case payload.Type == "Issue" && payload.Action == "update":
-			go dispatch(payload)
-		case payload.Type == "Comment" && payload.Action == "create":
-			go dispatch(payload)
+		case payload.Type == "Issue" && payload.Action == "update",
+			payload.Type == "Comment" && payload.Action == "create":
+			if err := dispatch(payload); err != nil {
+				slog.Error("dispatching webhook", "error", err, "type", payload.Type)
+				// Linear's retry carries the same signature
+				replays.forget(sig)
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
 		default:
 			slog.Debug("ignoring webhook", "type", payload.Type, "action", payload.Action)
 		}
+		w.WriteHeader(http.StatusOK)
 	}
 }
 
-func verifySignature(secret string, body []byte, signature string) bool {
-	mac := hmac.New(sha256.New, []byte(secret))
-	mac.Write(body)
-	expected := hex.EncodeToString(mac.Sum(nil))
-	return hmac.Equal([]byte(expected), []byte(signature))
+// deliveryFresh reports whether a delivery timestamp is acceptable: at most
+// maxDrift in the past, or up to max(maxDrift, minFutureSkew) in the future to
+// tolerate clock skew between Linear and this host.
+func deliveryFresh(delivery, now time.Time, maxDrift time.Duration) bool {
+	age := now.Sub(delivery)
+	if age > maxDrift {
+		return false
+	}
+	return -age <= max(maxDrift, minFutureSkew)
+}
+
+// verifySignature reports whether signature is the HMAC of body under any of secrets.
+func verifySignature(secrets []string, body []byte, signature string) bool {
+	for _, secret := range secrets {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		expected := hex.EncodeToString(mac.Sum(nil))
+		if hmac.Equal([]byte(expected), []byte(signature)) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,227 @@
+package linear
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testIssueUpdate = `{"type":"Issue","action":"update","data":{"id":"issue-1"}}`
+
+// sign returns the Linear-Signature of body under secret.
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliver POSTs body signed with secret and dated at delivered (no
+// Linear-Delivery header if zero) to handler.
+func deliver(handler http.Handler, secret, body string, delivered time.Time) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	req.Header.Set(signatureHeader, sign(secret, body))
+	if !delivered.IsZero() {
+		req.Header.Set(timestampHeader, delivered.UTC().Format(time.RFC3339Nano))
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDeliveryFresh(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name     string
+		offset   time.Duration // delivery time relative to now
+		maxDrift time.Duration
+		want     bool
+	}{
+		{"just delivered", 0, time.Minute, true},
+		{"within bound", -50 * time.Second, time.Minute, true},
+		{"too old", -61 * time.Second, time.Minute, false},
+		{"slightly future", 30 * time.Second, time.Minute, true},
+		{"future within skew", 4 * time.Minute, time.Minute, true},
+		{"far future", 6 * time.Minute, time.Minute, false},
+		{"old within larger drift", -4 * time.Minute, 5 * time.Minute, true},
+		{"future within larger drift", 9 * time.Minute, 10 * time.Minute, true},
+	} {
+		if got := deliveryFresh(now.Add(tc.offset), now, tc.maxDrift); got != tc.want {
+			t.Errorf("%s: deliveryFresh(now%+v, maxDrift %s) = %v, want %v", tc.name, tc.offset, tc.maxDrift, got, tc.want)
+		}
+	}
+}
+
+func TestWebhookTimestampDrift(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		offset time.Duration
+		status int
+	}{
+		{"within bound", -30 * time.Second, http.StatusOK},
+		{"too old", -3 * time.Minute, http.StatusBadRequest},
+		{"slightly future", 90 * time.Second, http.StatusOK},
+	} {
+		dispatched := make(chan WebhookPayload, 1)
+		handler := NewWebhookHandler([]string{"secret"}, 2*time.Minute, func(p WebhookPayload) error { dispatched <- p; return nil })
+
+		rec := deliver(handler, "secret", testIssueUpdate, time.Now().Add(tc.offset))
+		if rec.Code != tc.status {
+			t.Errorf("%s: status %d, want %d (%s)", tc.name, rec.Code, tc.status, rec.Body)
+			continue
+		}
+		if tc.status != http.StatusOK {
+			continue
+		}
+		select {
+		case p := <-dispatched:
+			if p.Type != "Issue" || p.Action != "update" {
+				t.Errorf("%s: dispatched %+v", tc.name, p)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s: accepted delivery was never dispatched", tc.name)
+		}
+	}
+}
+
+func TestWebhookSecretRotation(t *testing.T) {
+	dispatched := make(chan WebhookPayload, 3)
+	handler := NewWebhookHandler([]string{"old-secret", "new-secret"}, time.Minute, func(p WebhookPayload) error { dispatched <- p; return nil })
+
+	for _, tc := range []struct {
+		secret string
+		status int
+	}{
+		{"old-secret", http.StatusOK},
+		{"new-secret", http.StatusOK},
+		{"other-secret", http.StatusUnauthorized},
+	} {
+		if rec := deliver(handler, tc.secret, testIssueUpdate, time.Now()); rec.Code != tc.status {
+			t.Errorf("signed with %s: status %d, want %d (%s)", tc.secret, rec.Code, tc.status, rec.Body)
+		}
+	}
+	for range 2 {
+		select {
+		case <-dispatched:
+		case <-time.After(5 * time.Second):
+			t.Fatal("delivery signed with an accepted secret was never dispatched")
+		}
+	}
+	select {
+	case p := <-dispatched:
+		t.Errorf("delivery signed with an unknown secret was dispatched: %+v", p)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWebhookRejectsReplay(t *testing.T) {
+	dispatched := make(chan WebhookPayload, 2)
+	handler := NewWebhookHandler([]string{"secret"}, time.Minute, func(p WebhookPayload) error { dispatched <- p; return nil })
+
+	delivered := time.Now()
+	if rec := deliver(handler, "secret", testIssueUpdate, delivered); rec.Code != http.StatusOK {
+		t.Fatalf("first delivery: status %d, want 200 (%s)", rec.Code, rec.Body)
+	}
+	if rec := deliver(handler, "secret", testIssueUpdate, delivered); rec.Code != http.StatusConflict {
+		t.Fatalf("replayed delivery: status %d, want 409 (%s)", rec.Code, rec.Body)
+	}
+	other := `{"type":"Issue","action":"update","data":{"id":"issue-2"}}`
+	if rec := deliver(handler, "secret", other, delivered); rec.Code != http.StatusOK {
+		t.Fatalf("different delivery: status %d, want 200 (%s)", rec.Code, rec.Body)
+	}
+
+	for range 2 {
+		select {
+		case <-dispatched:
+		case <-time.After(5 * time.Second):
+			t.Fatal("accepted delivery was never dispatched")
+		}
+	}
+	select {
+	case p := <-dispatched:
+		t.Errorf("replayed delivery was dispatched: %+v", p)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestReplayCacheEviction(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := newReplayCache(time.Minute, 2)
+
+	if !c.firstSeen("a", now) {
+		t.Fatal("a: first delivery reported as seen")
+	}
+	if c.firstSeen("a", now.Add(59*time.Second)) {
+		t.Error("a: replay within ttl accepted")
+	}
+	if !c.firstSeen("a", now.Add(time.Minute)) {
+		t.Error("a: delivery after ttl rejected")
+	}
+
+	c.firstSeen("b", now.Add(time.Minute))
+	c.firstSeen("c", now.Add(time.Minute))
+	if len(c.seen) != 2 || len(c.order) != 2 {
+		t.Fatalf("cache holds %d entries (%d ordered), want the limit of 2", len(c.seen), len(c.order))
+	}
+	if !c.firstSeen("a", now.Add(time.Minute)) {
+		t.Error("a: oldest entry not dropped once the cache was full")
+	}
+}
+
+func TestWebhookDispatchedBeforeAcknowledged(t *testing.T) {
+	var dispatched []WebhookPayload
+	fail := false
+	handler := NewWebhookHandler([]string{"secret"}, time.Minute, func(p WebhookPayload) error {
+		if fail {
+			return errors.New("database is locked")
+		}
+		dispatched = append(dispatched, p)
+		return nil
+	})
+
+	if rec := deliver(handler, "secret", testIssueUpdate, time.Now()); rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200 (%s)", rec.Code, rec.Body)
+	}
+	// Dispatch runs before the response, not on its own goroutine
+	if len(dispatched) != 1 {
+		t.Fatalf("dispatched %d payloads by the time the delivery was acknowledged, want 1", len(dispatched))
+	}
+
+	fail = true
+	other := `{"type":"Comment","action":"create","data":{"id":"comment-1"}}`
+	if rec := deliver(handler, "secret", other, time.Now()); rec.Code != http.StatusInternalServerError {
+		t.Errorf("status %d when dispatch fails, want 500 so Linear retries", rec.Code)
+	}
+}
+
+func TestWebhookRetryAcceptedAfterDispatchFails(t *testing.T) {
+	fail := true
+	var dispatched int
+	handler := NewWebhookHandler([]string{"secret"}, time.Minute, func(p WebhookPayload) error {
+		if fail {
+			return errors.New("database is locked")
+		}
+		dispatched++
+		return nil
+	})
+
+	delivered := time.Now()
+	if rec := deliver(handler, "secret", testIssueUpdate, delivered); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d when dispatch fails, want 500 (%s)", rec.Code, rec.Body)
+	}
+	fail = false
+	if rec := deliver(handler, "secret", testIssueUpdate, delivered); rec.Code != http.StatusOK {
+		t.Fatalf("retry: status %d, want 200 (%s)", rec.Code, rec.Body)
+	}
+	if dispatched != 1 {
+		t.Errorf("retry dispatched %d times, want 1", dispatched)
+	}
+	if rec := deliver(handler, "secret", testIssueUpdate, delivered); rec.Code != http.StatusConflict {
+		t.Errorf("replay after a successful retry: status %d, want 409 (%s)", rec.Code, rec.Body)
+	}
+}
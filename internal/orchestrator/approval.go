@@ -0,0 +1,157 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/config"
+	"github.com/mauza/ai-flow/internal/linear"
+	"github.com/mauza/ai-flow/internal/store"
+)
+
+// approvalSweepInterval is how often parked wait_for_approval issues are
+// checked against their stage's approval_timeout.
+const approvalSweepInterval = time.Minute
+
+// HasApprovalTimeouts reports whether any stage sets approval_timeout, i.e.
+// whether RunApprovalSweeper has anything to do.
+func (o *Orchestrator) HasApprovalTimeouts() bool {
+	for _, team := range o.cfg.TeamKeys() {
+		for _, stage := range o.cfg.StagesFor(team) {
+			if stage.IsEnabled() && stage.ParsedApprovalTimeout > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RunApprovalSweeper applies approval_timeout_action to timed-out approvals
+// every approvalSweepInterval until ctx is cancelled.
+func (o *Orchestrator) RunApprovalSweeper(ctx context.Context) {
+	ticker := time.NewTicker(approvalSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			o.sweepApprovals(ctx)
+		}
+	}
+}
+
+// sweepApprovals finds issues a wait_for_approval stage parked that entered
+// the stage's state longer than its approval_timeout ago and takes the
+// stage's approval_timeout_action on each. Every parked run is acted on at
+// most once.
+func (o *Orchestrator) sweepApprovals(ctx context.Context) {
+	for _, team := range o.cfg.TeamKeys() {
+		o.sweepTeamApprovals(ctx, team)
+	}
+}
+
+// sweepTeamApprovals sweeps the parked runs of one team's stages.
+func (o *Orchestrator) sweepTeamApprovals(ctx context.Context, team string) {
+	stages := o.cfg.StagesFor(team)
+	for i := range stages {
+		stage := &stages[i]
+		if !stage.IsEnabled() || stage.ParsedApprovalTimeout <= 0 {
+			continue
+		}
+		runs, err := o.store.ListAwaitingApproval(stage.Name)
+		if err != nil {
+			slog.Error("listing runs awaiting approval", "error", err, "stage", stage.Name)
+			continue
+		}
+		for _, run := range runs {
+			if ctx.Err() != nil {
+				return
+			}
+			parkedSince, err := o.stateEnteredAt(team, run.IssueID, stage.LinearState)
+			if err != nil {
+				slog.Error("looking up when issue was parked", "error", err, "issueID", run.IssueID, "stage", stage.Name)
+				continue
+			}
+			if parkedSince == nil || time.Since(*parkedSince) < stage.ParsedApprovalTimeout {
+				continue
+			}
+			o.approvalMu.Lock()
+			handled := o.approvalHandled[run.ID]
+			o.approvalHandled[run.ID] = true
+			o.approvalMu.Unlock()
+			if handled {
+				continue
+			}
+			if !o.expireApproval(ctx, team, run, stage, *parkedSince) {
+				// Lookup failed or another team's stage parked it; try
+				// again next sweep
+				o.approvalMu.Lock()
+				delete(o.approvalHandled, run.ID)
+				o.approvalMu.Unlock()
+			}
+		}
+	}
+}
+
+// expireApproval takes stage's approval_timeout_action for a parked run if
+// its issue is still in the stage's state. It returns false if the issue
+// couldn't be looked up or isn't in team, whose stage may only share the
+// parked stage's name.
+func (o *Orchestrator) expireApproval(ctx context.Context, team string, run store.RunRecord, stage *config.StageConfig, parkedSince time.Time) bool {
+	details, err := o.client.GetIssue(ctx, run.IssueID)
+	if err != nil {
+		slog.Error("fetching issue for approval timeout", "error", err, "issueID", run.IssueID)
+		return false
+	}
+	if details.Team.Key != team {
+		return false
+	}
+	if !linear.SameState(details.State.Name, stage.LinearState) {
+		// Someone already moved the issue on
+		return true
+	}
+
+	slog.Info("approval timed out",
+		"issue", details.Identifier,
+		"stage", stage.Name,
+		"parkedSince", parkedSince,
+		"action", stage.ApprovalTimeoutAction,
+	)
+	msg := fmt.Sprintf("no approval within approval_timeout (%s)", stage.ApprovalTimeout)
+
+	switch stage.ApprovalTimeoutAction {
+	case "auto_approve":
+		nextStateID, ok := o.client.ResolveStateID(details.Team.Key, stage.NextState)
+		if !ok {
+			slog.Error("cannot resolve next state", "nextState", stage.NextState, "issue", details.Identifier)
+			return true
+		}
+		if err := o.client.UpdateIssueState(ctx, details.ID, nextStateID); err != nil {
+			slog.Error("auto-approving issue", "error", err, "issue", details.Identifier, "nextState", stage.NextState)
+			return true
+		}
+		comment := fmt.Sprintf("**ai-flow: stage `%s` auto-approved** — %s, moved to %s", stage.Name, msg, stage.NextState)
+		if err := o.client.PostComment(ctx, details.ID, comment); err != nil {
+			slog.Error("posting auto-approve comment", "error", err, "issue", details.Identifier)
+		}
+	case "escalate":
+		event := escalationEvent{
+			Severity:     "warning",
+			Reason:       "approval_timeout",
+			Issue:        details.Identifier,
+			Stage:        stage.Name,
+			Summary:      msg,
+			InStateSince: o.inStateSince(details, stage),
+			Timestamp:    time.Now().UTC(),
+		}
+		if err := postEscalation(ctx, o.cfg.Notify.EscalationURL, event); err != nil {
+			slog.Error("sending approval timeout escalation", "error", err, "issue", details.Identifier, "stage", stage.Name)
+		}
+	default:
+		o.failAndTransition(ctx, details, stage, msg)
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const autoApproveYAML = `
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    args: ["-c", "echo planned"]
+    prompt: Plan it.
+    next_state: In Progress
+    wait_for_approval: true
+    approval_timeout: 1h
+    approval_timeout_action: auto_approve
+`
+
+func TestApprovalTimeoutAutoApproves(t *testing.T) {
+	h := newHarness(t, testLinearYAML+autoApproveYAML)
+	issue := h.issue("Todo")
+
+	h.process(issue)
+	if got := h.state(issue.ID); got != "Todo" {
+		t.Fatalf("state after parking run = %q, want Todo", got)
+	}
+
+	// Just parked: nothing to do yet
+	h.o.sweepApprovals(context.Background())
+	if got := h.state(issue.ID); got != "Todo" {
+		t.Fatalf("state = %q before approval_timeout, want Todo", got)
+	}
+
+	// The timeout counts from when the issue entered Todo, not from the run
+	if err := h.store.RecordStateEntry(issue.ID, "Todo", time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	h.o.sweepApprovals(context.Background())
+	if got := h.state(issue.ID); got != "In Progress" {
+		t.Fatalf("state after approval_timeout = %q, want In Progress", got)
+	}
+	approved := func() int {
+		n := 0
+		for _, body := range h.comments(issue.ID) {
+			if strings.Contains(body, "auto-approved") {
+				n++
+			}
+		}
+		return n
+	}
+	if got := approved(); got != 1 {
+		t.Fatalf("auto-approve comments = %d, want 1", got)
+	}
+
+	// A parked run is acted on once
+	h.linear.MoveIssue(issue.ID, "Todo")
+	h.o.sweepApprovals(context.Background())
+	if got := approved(); got != 1 {
+		t.Errorf("auto-approve comments after a second sweep = %d, want 1", got)
+	}
+}
@@ -0,0 +1,107 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mauza/ai-flow/internal/linear"
+)
+
+// attachmentsDirName is the workspace directory attachments are saved to. It
+// is added to the clone's info/exclude so it is never committed.
+const attachmentsDirName = ".aiflow-attachments"
+
+// downloadAttachments saves the issue's Linear attachments into workDir for
+// the command to read, replacing any from an earlier run. Attachments that
+// are web pages, fail to download, or would take the total past
+// workspace.attachments_max_mb are skipped with a warning. It returns the
+// directory, or "" when downloads are off or the issue has no attachments.
+func (o *Orchestrator) downloadAttachments(ctx context.Context, details *linear.IssueDetails, workDir string) string {
+	if !o.cfg.Workspace.DownloadAttachments {
+		return ""
+	}
+	attachments, err := o.client.GetIssueAttachments(ctx, details.ID)
+	if err != nil {
+		slog.Warn("listing issue attachments", "error", err, "issue", details.Identifier)
+		return ""
+	}
+	if len(attachments) == 0 {
+		return ""
+	}
+
+	dir := filepath.Join(workDir, attachmentsDirName)
+	if err := o.git.Exclude(ctx, workDir, "/"+attachmentsDirName+"/"); err != nil {
+		slog.Warn("excluding attachments directory from commits", "error", err, "issue", details.Identifier)
+		return ""
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		slog.Warn("clearing attachments directory", "error", err, "issue", details.Identifier)
+		return ""
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		slog.Warn("creating attachments directory", "error", err, "issue", details.Identifier)
+		return ""
+	}
+
+	remaining := int64(o.cfg.Workspace.AttachmentsMaxMB) << 20
+	saved := 0
+	for i, a := range attachments {
+		file := filepath.Join(dir, fmt.Sprintf("%d-%s", i+1, attachmentFileName(a)))
+		n, err := o.saveAttachment(ctx, a.URL, file, remaining)
+		if err != nil {
+			os.Remove(file)
+			if errors.Is(err, linear.ErrAttachmentNotFile) {
+				slog.Debug("skipping link attachment", "issue", details.Identifier, "url", a.URL)
+				continue
+			}
+			slog.Warn("skipping attachment", "error", err, "issue", details.Identifier, "attachment", logContent(o.cfg, a.Title))
+			continue
+		}
+		remaining -= n
+		saved++
+	}
+	slog.Info("downloaded issue attachments", "issue", details.Identifier, "count", saved, "dir", dir)
+	return dir
+}
+
+// saveAttachment downloads rawURL to file, allowing at most maxBytes.
+func (o *Orchestrator) saveAttachment(ctx context.Context, rawURL, file string, maxBytes int64) (int64, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return 0, err
+	}
+	n, err := o.client.DownloadAttachment(ctx, rawURL, f, maxBytes)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// attachmentFileName picks a safe file name for an attachment: its title,
+// or the last element of its URL path if it has no title.
+func attachmentFileName(a linear.Attachment) string {
+	name := a.Title
+	if name == "" {
+		if u, err := url.Parse(a.URL); err == nil {
+			name = path.Base(u.Path)
+		}
+	}
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(name, ".")
+	if name == "" {
+		return "attachment"
+	}
+	return name
+}
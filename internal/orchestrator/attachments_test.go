@@ -0,0 +1,69 @@
+package orchestrator
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/linear"
+)
+
+// attachmentStageYAML prints the names and contents of the downloaded
+// attachments, then makes a change so a PR is opened.
+const attachmentStageYAML = `
+pipeline:
+  - name: implement
+    linear_state: In Progress
+    command: sh
+    args: ["-c", "cd \"$$AIFLOW_ATTACHMENTS_DIR\" && for f in *; do echo \"$$f: $$(cat \"$$f\")\"; done; cd - >/dev/null; echo change > change.txt"]
+    prompt: Implement it.
+    next_state: In Review
+    failure_state: Failed
+    creates_pr: true
+`
+
+func TestAttachmentsDownloadedIntoWorkspace(t *testing.T) {
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/spec.md":
+			w.Header().Set("Content-Type", "text/markdown")
+			w.Write([]byte("the spec"))
+		case "/pr":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer files.Close()
+
+	h := newHarness(t, testLinearYAML+"workspace:\n  download_attachments: true\n"+attachmentStageYAML)
+	h.withGit()
+	h.linear.Handle = func(req linear.GraphQLRequest) (any, bool) {
+		if !strings.Contains(req.Query, "attachments(first") {
+			return nil, false
+		}
+		return map[string]any{"issue": map[string]any{"attachments": map[string]any{"nodes": []any{
+			map[string]any{"id": "a1", "title": "spec.md", "url": files.URL + "/spec.md"},
+			map[string]any{"id": "a2", "title": "", "url": files.URL + "/notes.txt"},
+			map[string]any{"id": "a3", "title": "Linked PR", "url": files.URL + "/pr"},
+		}}}}, true
+	}
+	issue := h.issue("In Progress")
+
+	h.process(issue)
+
+	run := h.lastRun(issue.ID)
+	if got := h.state(issue.ID); got != "In Review" {
+		t.Fatalf("state = %q, want In Review (output %q)", got, run.Output)
+	}
+	if !strings.Contains(run.Output, "1-spec.md: the spec") {
+		t.Errorf("output = %q, want the downloaded spec", run.Output)
+	}
+	for _, skipped := range []string{"notes.txt", "Linked PR"} {
+		if strings.Contains(run.Output, skipped) {
+			t.Errorf("output = %q, want %s skipped", run.Output, skipped)
+		}
+	}
+}
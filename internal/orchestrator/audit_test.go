@@ -0,0 +1,52 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/linear"
+	"github.com/mauza/ai-flow/internal/subprocess"
+)
+
+func TestAuditRecordWritten(t *testing.T) {
+	const secret = "hunter2-do-not-store"
+	h := newHarness(t, testLinearYAML+"  audit_inputs: true\n"+planStageYAML)
+	issue := h.issueWith("Todo", func(issue *linear.IssueDetails) {
+		issue.Description = "The token is " + secret
+	})
+
+	h.process(issue)
+
+	run := h.lastRun(issue.ID)
+	if len(run.Audit) == 0 {
+		t.Fatal("no audit recorded on the run")
+	}
+	if strings.Contains(string(run.Audit), secret) {
+		t.Errorf("audit contains an input value: %s", run.Audit)
+	}
+	var audit subprocess.InputAudit
+	if err := json.Unmarshal(run.Audit, &audit); err != nil {
+		t.Fatal(err)
+	}
+	if audit.Command != "sh" || len(audit.PromptSHA256) != 64 {
+		t.Errorf("audit = %+v, want the command and a prompt hash", audit)
+	}
+	for _, name := range []string{"AIFLOW_ISSUE_DESCRIPTION", "AIFLOW_PROMPT"} {
+		if !slices.Contains(audit.EnvVars, name) {
+			t.Errorf("audit env vars %q lack %s", audit.EnvVars, name)
+		}
+	}
+}
+
+func TestAuditOffByDefault(t *testing.T) {
+	h := newHarness(t, testLinearYAML+planStageYAML)
+	issue := h.issue("Todo")
+
+	h.process(issue)
+
+	if run := h.lastRun(issue.ID); len(run.Audit) != 0 {
+		t.Errorf("audit = %s without subprocess.audit_inputs", run.Audit)
+	}
+}
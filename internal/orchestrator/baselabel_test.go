@@ -0,0 +1,77 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/linear"
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+const implementStageYAML = `
+pipeline:
+  - name: implement
+    linear_state: In Progress
+    command: sh
+    args: ["-c", "echo change > change.txt"]
+    prompt: Implement it.
+    next_state: In Review
+    failure_state: Failed
+    creates_pr: true
+`
+
+const baseByLabelProjectsYAML = `
+projects:
+  ENG:
+    github_repo: acme/app
+    default_branch: main
+    base_branch_by_label:
+      - label: hotfix
+        branch: release/1.x
+      - label: urgent
+        branch: release/2.x
+`
+
+func TestBaseBranchByLabel(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		labels []string
+		base   string
+	}{
+		{"hotfix targets the release branch", []string{"hotfix"}, "release/1.x"},
+		{"first matching entry wins", []string{"urgent", "Hotfix"}, "release/1.x"},
+		{"unlabeled targets main", nil, "main"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newHarness(t, testLinearYAML+implementStageYAML+baseByLabelProjectsYAML)
+			bare := h.withGit()
+			for _, branch := range []string{"release/1.x", "release/2.x"} {
+				testutil.RunGit(t, bare, "branch", branch, "main")
+				h.repos.Commit(t, bare, branch, "RELEASE", branch+"\n")
+			}
+			issue := h.issueWith("In Progress", func(issue *linear.IssueDetails) {
+				issue.Description = "Please fix the thing."
+				for _, l := range tc.labels {
+					issue.Labels.Nodes = append(issue.Labels.Nodes, linear.IssueLabel{Name: l})
+				}
+			})
+
+			h.process(issue)
+			if got := h.state(issue.ID); got != "In Review" {
+				t.Fatalf("state = %q, want In Review (comments: %q)", got, h.comments(issue.ID))
+			}
+			creates := h.gh.Calls("pr", "create")
+			if len(creates) != 1 {
+				t.Fatalf("got %d pr create calls, want 1", len(creates))
+			}
+			if got := testutil.ArgValue(creates[0], "--base"); got != tc.base {
+				t.Errorf("PR base = %q, want %q", got, tc.base)
+			}
+
+			// The branch was cut from the base: it has the base's history
+			branch := testutil.RunGit(t, bare, "for-each-ref", "--format=%(refname:short)", "refs/heads/eng-*")
+			if got, want := testutil.RunGit(t, bare, "rev-parse", branch+"~1"), testutil.RunGit(t, bare, "rev-parse", tc.base); got != want {
+				t.Errorf("branch %s parent = %s, want the tip of %s (%s)", branch, got, tc.base, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/git"
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+// followUpYAML opens a PR in In Progress, then works on its branch in In Review.
+const followUpYAML = `
+pipeline:
+  - name: implement
+    linear_state: In Progress
+    command: sh
+    args: ["-c", "echo one > one.txt"]
+    prompt: Implement it.
+    next_state: In Review
+    failure_state: Failed
+    creates_pr: true
+  - name: address-review
+    linear_state: In Review
+    command: sh
+    args: ["-c", "echo two > two.txt"]
+    prompt: Address the review.
+    next_state: Done
+    failure_state: Failed
+    uses_branch: true
+`
+
+func TestMissingBranch(t *testing.T) {
+	for _, onMissing := range []string{"fail", "recreate"} {
+		t.Run(onMissing, func(t *testing.T) {
+			h := newHarness(t, testLinearYAML+followUpYAML+"    on_missing_branch: "+onMissing+"\n")
+			bare := h.withGit()
+			issue := h.issue("In Progress")
+			branch := git.SanitizeBranchName(issue.Identifier, issue.Title)
+
+			h.process(issue)
+			// The PR is merged and its branch deleted
+			testutil.RunGit(t, bare, "branch", "-D", branch)
+
+			h.process(issue)
+			run := h.lastRun(issue.ID)
+			switch onMissing {
+			case "fail":
+				if run.Status != "failed" || !strings.Contains(run.Error, "no longer exists on the remote") {
+					t.Errorf("run = %s %q, want it failed naming the deleted branch", run.Status, run.Error)
+				}
+				if got := h.state(issue.ID); got != "Failed" {
+					t.Errorf("state = %q, want Failed", got)
+				}
+			case "recreate":
+				if run.Status != "completed" {
+					t.Fatalf("run = %s %q, want completed", run.Status, run.Error)
+				}
+				if got := h.state(issue.ID); got != "Done" {
+					t.Errorf("state = %q, want Done", got)
+				}
+				// Recreated from main: the follow-up commit sits on main's tip
+				if got, want := testutil.RunGit(t, bare, "rev-parse", branch+"^"), testutil.RunGit(t, bare, "rev-parse", "main"); got != want {
+					t.Errorf("recreated branch's parent = %s, want main's tip %s", got, want)
+				}
+				if !runGitOK(bare, "cat-file", "-e", branch+":two.txt") {
+					t.Error("recreated branch is missing the follow-up change")
+				}
+				if creates := h.gh.Calls("pr", "create"); len(creates) != 2 {
+					t.Errorf("got %d gh pr create calls, want a new PR for the recreated branch", len(creates))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/git"
+	"github.com/mauza/ai-flow/internal/linear"
+)
+
+// errIssueCanceled is the cancellation cause of runs stopped because their
+// issue moved to one of workspace.cancel_states.
+var errIssueCanceled = errors.New("issue canceled")
+
+// cancelWait bounds how long cancelIssue waits for stopped runs to return
+// before removing their workspaces.
+const cancelWait = 30 * time.Second
+
+// activeRun is a handler working on an issue, cancellable by cancelIssue.
+type activeRun struct {
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+}
+
+// trackIssue registers a handler running for issueID and returns a context
+// that cancelIssue can stop. The returned func must be called when the
+// handler returns.
+func (o *Orchestrator) trackIssue(ctx context.Context, issueID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	run := &activeRun{cancel: cancel, done: make(chan struct{})}
+	o.activeMu.Lock()
+	o.activeRuns[issueID] = append(o.activeRuns[issueID], run)
+	o.activeMu.Unlock()
+	return ctx, func() {
+		o.activeMu.Lock()
+		runs := o.activeRuns[issueID]
+		for i, r := range runs {
+			if r == run {
+				runs = append(runs[:i], runs[i+1:]...)
+				break
+			}
+		}
+		if len(runs) == 0 {
+			delete(o.activeRuns, issueID)
+		} else {
+			o.activeRuns[issueID] = runs
+		}
+		o.activeMu.Unlock()
+		cancel(nil)
+		close(run.done)
+	}
+}
+
+// canceled reports whether ctx was stopped by cancelIssue.
+func canceled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errIssueCanceled)
+}
+
+// isCancelState reports whether state is one of workspace.cancel_states.
+func (o *Orchestrator) isCancelState(state string) bool {
+	for _, s := range o.cfg.Workspace.CancelStates {
+		if linear.SameState(s, state) {
+			return true
+		}
+	}
+	return false
+}
+
+// cancelIssue stops the issue's in-flight runs, waits briefly for them to
+// return, then removes its persistent workspaces.
+func (o *Orchestrator) cancelIssue(ctx context.Context, issue linear.IssueData, state string) {
+	o.activeMu.Lock()
+	runs := append([]*activeRun(nil), o.activeRuns[issue.ID]...)
+	o.activeMu.Unlock()
+
+	slog.Info("issue canceled, stopping its pipeline",
+		"issue", issue.Identifier,
+		"state", state,
+		"activeRuns", len(runs),
+	)
+	for _, r := range runs {
+		r.cancel(errIssueCanceled)
+	}
+	timeout := time.NewTimer(cancelWait)
+	defer timeout.Stop()
+	for _, r := range runs {
+		select {
+		case <-r.done:
+		case <-timeout.C:
+			slog.Warn("canceled runs still running, removing workspaces anyway", "issue", issue.Identifier)
+		}
+	}
+
+	if o.cfg.Workspace.Root == "" {
+		return
+	}
+	details, err := o.client.GetIssue(ctx, issue.ID)
+	if err != nil {
+		slog.Warn("fetching canceled issue for workspace cleanup", "error", err, "issue", issue.Identifier)
+		return
+	}
+	repo, _, err := o.resolveRepoConfig(ctx, details)
+	if err != nil {
+		slog.Debug("no repo for canceled issue, nothing to clean up", "error", err, "issue", issue.Identifier)
+		return
+	}
+	branches, err := o.store.BranchesForIssue(issue.ID)
+	if err != nil {
+		slog.Warn("listing branches of canceled issue", "error", err, "issue", issue.Identifier)
+	}
+	branches = append(branches, git.SanitizeBranchName(details.Identifier, details.Title))
+	seen := make(map[string]bool, len(branches))
+	for _, branch := range branches {
+		if seen[branch] {
+			continue
+		}
+		seen[branch] = true
+		o.removeWorkspace(repo, branch, "issue canceled")
+	}
+}
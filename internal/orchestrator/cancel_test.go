@@ -0,0 +1,60 @@
+package orchestrator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCancelStateStopsRunningJob(t *testing.T) {
+	started := filepath.Join(t.TempDir(), "started")
+	h := newHarness(t, testLinearYAML+`
+workspace:
+  cancel_states: [Canceled]
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    args: ["-c", "touch `+started+` && exec sleep 30"]
+    prompt: Plan it.
+    next_state: In Progress
+    failure_state: Failed
+`)
+	issue := h.issue("Todo")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.process(issue)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(started); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stage command never started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	h.linear.MoveIssue(issue.ID, "Canceled")
+	h.webhook(issue.ID, `{"stateId":"state-1"}`)
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("run still going after the issue was canceled")
+	}
+	if got := h.lastRun(issue.ID).Status; got != "canceled" {
+		t.Errorf("run status = %q, want canceled", got)
+	}
+	// A canceled run neither transitions nor reports a failure
+	if got := h.state(issue.ID); got != "Canceled" {
+		t.Errorf("state = %q, want Canceled", got)
+	}
+	if len(h.comments(issue.ID)) != 0 {
+		t.Errorf("comments = %q, want none", h.comments(issue.ID))
+	}
+}
@@ -0,0 +1,42 @@
+package orchestrator
+
+import (
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// checkpointPath returns the file a stage's command may save progress to for
+// an issue, passed as AIFLOW_CHECKPOINT_FILE. The path is the same for every
+// retry and re-run of the issue+stage, and lives outside any clone so that
+// workspace rollbacks and temp-dir cleanup leave it alone.
+func (o *Orchestrator) checkpointPath(identifier, stageName string) string {
+	root := o.cfg.Workspace.Root
+	if root == "" {
+		root = filepath.Join(os.TempDir(), "aiflow")
+	}
+	return filepath.Join(root, ".checkpoints", url.PathEscape(identifier), url.PathEscape(stageName))
+}
+
+// prepareCheckpoint returns the issue+stage checkpoint path with its parent
+// directory created, or "" if the directory can't be created.
+func (o *Orchestrator) prepareCheckpoint(identifier, stageName string) string {
+	path := o.checkpointPath(identifier, stageName)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		slog.Warn("creating checkpoint directory", "error", err, "issue", identifier)
+		return ""
+	}
+	return path
+}
+
+// clearCheckpoint removes a checkpoint once the command has finished its work,
+// so the next time the issue reaches the stage it starts fresh.
+func clearCheckpoint(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		slog.Warn("removing checkpoint file", "error", err, "path", path)
+	}
+}
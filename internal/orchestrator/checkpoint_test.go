@@ -0,0 +1,62 @@
+package orchestrator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// resumableStageYAML fails the first time after saving a checkpoint, then
+// resumes from it. Each run appends its checkpoint path to $PATHS_LOG.
+const resumableStageYAML = `
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    args: ["-c", "echo \"$$AIFLOW_CHECKPOINT_FILE\" >> \"$$PATHS_LOG\"; if [ -f \"$$AIFLOW_CHECKPOINT_FILE\" ]; then echo \"resumed $$(cat \"$$AIFLOW_CHECKPOINT_FILE\")\"; else echo step1 > \"$$AIFLOW_CHECKPOINT_FILE\"; exit 1; fi"]
+    prompt: Plan it.
+    next_state: In Progress
+    failure_state: Failed
+`
+
+func TestCheckpointPathStableAcrossRuns(t *testing.T) {
+	root := t.TempDir()
+	pathsLog := filepath.Join(t.TempDir(), "paths")
+	t.Setenv("PATHS_LOG", pathsLog)
+	h := newHarness(t, testLinearYAML+"workspace:\n  root: "+root+"\n"+resumableStageYAML)
+	issue := h.issue("Todo")
+
+	h.process(issue)
+	if got := h.state(issue.ID); got != "Failed" {
+		t.Fatalf("state after first run = %q, want Failed", got)
+	}
+	h.linear.MoveIssue(issue.ID, "Todo")
+	h.process(issue)
+	if got := h.state(issue.ID); got != "In Progress" {
+		t.Fatalf("state after second run = %q, want In Progress", got)
+	}
+
+	runs := h.runs(issue.ID)
+	if len(runs) != 2 {
+		t.Fatalf("%d runs, want 2", len(runs))
+	}
+	data, err := os.ReadFile(pathsLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := strings.Fields(string(data))
+	if len(paths) != 2 || paths[0] != paths[1] {
+		t.Fatalf("checkpoint paths = %q, want the same path for both runs", paths)
+	}
+	first := paths[0]
+	if want := filepath.Join(root, ".checkpoints", issue.Identifier, "plan"); first != want {
+		t.Errorf("checkpoint path = %q, want %q", first, want)
+	}
+	if !strings.Contains(runs[1].Output, "resumed step1") {
+		t.Errorf("second run output = %q, want it to resume from the first run's checkpoint", runs[1].Output)
+	}
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("checkpoint still present after a successful run: %v", err)
+	}
+}
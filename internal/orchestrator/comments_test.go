@@ -0,0 +1,89 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatFailureComment(t *testing.T) {
+	got := formatFailureComment("implement", "\nexit status 2: tests failed\nFAIL pkg/foo\n", "Comment `/retry` to re-run.")
+	want := "**ai-flow: stage `implement` failed**: exit status 2: tests failed\n\n" +
+		"<details>\n<summary>Error output</summary>\n\n```\nexit status 2: tests failed\nFAIL pkg/foo\n```\n\n</details>\n\n" +
+		"Comment `/retry` to re-run."
+	if got != want {
+		t.Errorf("failure comment:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := formatFailureComment("implement", "", ""); got != "**ai-flow: stage `implement` failed**" {
+		t.Errorf("failure comment without error or instructions = %q", got)
+	}
+
+	long := strings.Repeat("x", 250) + "\n" + strings.Repeat("y", 4000)
+	got = formatFailureComment("implement", long, "")
+	summary, _, _ := strings.Cut(got, "\n")
+	if want := "**ai-flow: stage `implement` failed**: " + strings.Repeat("x", 200) + "…"; summary != want {
+		t.Errorf("summary line = %q, want the first line cut at 200 bytes", summary)
+	}
+	if strings.Count(got, "y") > 3000 {
+		t.Errorf("error output was not truncated: %d bytes", len(got))
+	}
+}
+
+func TestFailureCommentIncludesRetryInstructions(t *testing.T) {
+	h := newHarness(t, linearYAML("  retry_instructions: Move the issue back to Todo to retry.\n")+failingPlanYAML)
+	issue := h.issue("Todo")
+
+	h.process(issue)
+	comment, ok := h.commentContaining(issue.ID, "failed**")
+	if !ok {
+		t.Fatalf("no failure comment: %q", h.comments(issue.ID))
+	}
+	for _, want := range []string{"failed**: broken", "<details>", "broken", "Move the issue back to Todo to retry."} {
+		if !strings.Contains(comment, want) {
+			t.Errorf("failure comment %q is missing %q", comment, want)
+		}
+	}
+}
+
+func TestFailureCommentTemplate(t *testing.T) {
+	h := newHarness(t, testLinearYAML+failingPlanYAML+`    failure_comment_template: |
+      Security scan {{.Stage}} found problems in {{.IssueURL}}:
+      {{.Error}}
+`)
+	issue := h.issue("Todo")
+
+	h.process(issue)
+	comment, ok := h.commentContaining(issue.ID, "Security scan")
+	if !ok {
+		t.Fatalf("no templated failure comment: %q", h.comments(issue.ID))
+	}
+	want := "Security scan plan found problems in " + h.linear.Issue(issue.ID).URL + ":\n"
+	if !strings.HasPrefix(comment, want) || !strings.Contains(comment, "broken") {
+		t.Errorf("failure comment = %q, want it to start with %q and include the error", comment, want)
+	}
+	if _, ok := h.commentContaining(issue.ID, "failed**"); ok {
+		t.Errorf("default failure comment posted alongside the template: %q", h.comments(issue.ID))
+	}
+}
+
+func TestMalformedFailureCommentTemplateFallsBack(t *testing.T) {
+	for name, tmpl := range map[string]string{
+		"parse error":     "Stage {{.Stage failed",
+		"execution error": "Stage {{.Missing}} failed",
+	} {
+		t.Run(name, func(t *testing.T) {
+			logs := captureLog(t)
+			h := newHarness(t, testLinearYAML+failingPlanYAML+"    failure_comment_template: '"+tmpl+"'\n")
+			issue := h.issue("Todo")
+
+			h.process(issue)
+			comment, ok := h.commentContaining(issue.ID, "failed**")
+			if !ok || !strings.Contains(comment, "broken") {
+				t.Errorf("comments = %q, want the default failure comment", h.comments(issue.ID))
+			}
+			if !strings.Contains(logs.String(), "failure_comment_template failed, using default comment") {
+				t.Errorf("no warning logged:\n%s", logs)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/git"
+	"github.com/mauza/ai-flow/internal/linear"
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+func TestCommitIncludesDescription(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		global, project string // commit_include_description values, "" for unset
+		want            bool
+	}{
+		{"off by default", "", "", false},
+		{"project on", "", "true", true},
+		{"project off overrides global", "true", "false", false},
+		{"global", "true", "", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfgYAML := testLinearYAML + "projects:\n  ENG:\n    github_repo: acme/app\n"
+			if tc.project != "" {
+				cfgYAML += "    commit_include_description: " + tc.project + "\n"
+			}
+			if tc.global != "" {
+				cfgYAML += "git:\n  commit_include_description: " + tc.global + "\n"
+			}
+			h := newHarness(t, cfgYAML+implementStageYAML)
+			bare := h.withGit()
+			issue := h.issueWith("In Progress", func(issue *linear.IssueDetails) {
+				issue.Description = "Crash on save.\r\nSteps:\x1b[31m click save\x07"
+			})
+			branch := git.SanitizeBranchName(issue.Identifier, issue.Title)
+
+			h.process(issue)
+			if run := h.lastRun(issue.ID); run.PRURL == "" {
+				t.Fatalf("run = %s %q, want a PR", run.Status, run.Error)
+			}
+			msg := testutil.RunGit(t, bare, "log", "-1", "--format=%B", branch)
+			if !strings.HasPrefix(msg, issue.Identifier+": Fix the thing\n") || !strings.HasSuffix(msg, "Generated by ai-flow") {
+				t.Errorf("commit message = %q, want the title line first and the footer last", msg)
+			}
+			body := "Crash on save.\nSteps:[31m click save"
+			if got := strings.Contains(msg, body); got != tc.want {
+				t.Errorf("commit message = %q, want description %v", msg, tc.want)
+			}
+		})
+	}
+}
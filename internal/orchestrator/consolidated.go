@@ -0,0 +1,65 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+const (
+	consolidatedHeader    = "**ai-flow: pipeline progress**"
+	consolidatedSeparator = "\n\n---\n\n"
+)
+
+// consolidated reports whether stage outcomes share one comment per issue.
+func (o *Orchestrator) consolidated() bool {
+	return o.cfg.Linear.CommentMode == "consolidated"
+}
+
+// updateConsolidated rewrites the issue's single ai-flow comment. With final
+// set, section is appended for good as a completed stage; otherwise it is shown
+// after the completed sections as the in-progress status and replaced next time.
+func (o *Orchestrator) updateConsolidated(ctx context.Context, issueID, section string, final bool) error {
+	o.consolidatedMu.Lock()
+	defer o.consolidatedMu.Unlock()
+
+	existing, err := o.store.GetIssueComment(issueID)
+	if err != nil {
+		return err
+	}
+	var commentID, completed string
+	if existing != nil {
+		commentID, completed = existing.CommentID, existing.Body
+	}
+
+	sections := completed
+	if section != "" {
+		if sections != "" {
+			sections += consolidatedSeparator
+		}
+		sections += section
+	}
+	body := consolidatedHeader
+	if sections != "" {
+		body += consolidatedSeparator + sections
+	}
+
+	if commentID != "" {
+		if err := o.client.UpdateComment(ctx, commentID, body); err != nil {
+			// The comment may have been deleted; start a fresh one
+			slog.Warn("updating consolidated comment, posting a new one", "error", err, "commentID", commentID)
+			commentID = ""
+		}
+	}
+	if commentID == "" {
+		commentID, err = o.client.CreateComment(ctx, issueID, body)
+		if err != nil {
+			return fmt.Errorf("creating consolidated comment: %w", err)
+		}
+	}
+
+	if final {
+		completed = sections
+	}
+	return o.store.SaveIssueComment(issueID, commentID, completed)
+}
@@ -0,0 +1,75 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+)
+
+const threeStageYAML = `
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    args: ["-c", "echo planned it"]
+    prompt: Plan it.
+    next_state: In Progress
+  - name: implement
+    linear_state: In Progress
+    command: sh
+    args: ["-c", "echo implemented it"]
+    prompt: Implement it.
+    next_state: In Review
+  - name: verify
+    linear_state: In Review
+    command: sh
+    args: ["-c", "echo verified it"]
+    prompt: Verify it.
+    next_state: Done
+`
+
+func TestConsolidatedCommentAcrossStages(t *testing.T) {
+	h := newHarness(t, linearYAML("  comment_mode: consolidated\n")+threeStageYAML)
+	issue := h.issue("Todo")
+
+	for _, want := range []string{"In Progress", "In Review", "Done"} {
+		h.process(issue)
+		if got := h.state(issue.ID); got != want {
+			t.Fatalf("state = %q, want %q", got, want)
+		}
+	}
+
+	comments := h.comments(issue.ID)
+	if len(comments) != 1 {
+		t.Fatalf("got %d comments, want one consolidated comment: %q", len(comments), comments)
+	}
+	if got := len(h.linear.Requests("commentCreate")); got != 1 {
+		t.Errorf("commentCreate called %d times, want 1", got)
+	}
+	body := comments[0]
+	if !strings.HasPrefix(body, consolidatedHeader) {
+		t.Errorf("comment does not start with the progress header: %q", body)
+	}
+	last := 0
+	for _, want := range []string{"planned it", "implemented it", "verified it"} {
+		i := strings.Index(body, want)
+		if i < last {
+			t.Errorf("comment is missing %q after the previous stage's section:\n%s", want, body)
+			continue
+		}
+		last = i
+	}
+	if n := strings.Count(body, consolidatedSeparator); n != 3 {
+		t.Errorf("comment has %d sections, want the header and 3 stages:\n%s", n+1, body)
+	}
+}
+
+func TestPerStageCommentsByDefault(t *testing.T) {
+	h := newHarness(t, testLinearYAML+threeStageYAML)
+	issue := h.issue("Todo")
+	for range 3 {
+		h.process(issue)
+	}
+	if comments := h.comments(issue.ID); len(comments) != 3 {
+		t.Errorf("got %d comments, want one per stage: %q", len(comments), comments)
+	}
+}
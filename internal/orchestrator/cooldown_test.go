@@ -0,0 +1,79 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const failingPlanYAML = `
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    args: ["-c", "echo broken >&2; exit 1"]
+    prompt: Plan it.
+    next_state: In Progress
+    failure_state: Failed
+`
+
+func TestFailureCooldownBlocksRetrigger(t *testing.T) {
+	h := newHarness(t, testLinearYAML+failingPlanYAML+"    failure_cooldown: 300ms\n")
+	issue := h.issue("Todo")
+
+	h.process(issue)
+	if got := h.state(issue.ID); got != "Failed" {
+		t.Fatalf("state after failure = %q, want Failed", got)
+	}
+
+	// A human moves it back right away, twice
+	for range 2 {
+		h.linear.MoveIssue(issue.ID, "Todo")
+		h.process(issue)
+	}
+	if runs := h.runs(issue.ID); len(runs) != 1 {
+		t.Fatalf("got %d runs within the cooldown, want only the failed one", len(runs))
+	}
+	var cooling []string
+	for _, body := range h.comments(issue.ID) {
+		if strings.Contains(body, "cooling down") {
+			cooling = append(cooling, body)
+		}
+	}
+	if len(cooling) != 1 || !strings.Contains(cooling[0], "retry after") {
+		t.Errorf("cooldown comments = %q, want one naming the retry time", cooling)
+	}
+	if got := h.state(issue.ID); got != "Todo" {
+		t.Errorf("state during cooldown = %q, want it left in Todo", got)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	h.process(issue)
+	if runs := h.runs(issue.ID); len(runs) != 2 {
+		t.Errorf("got %d runs after the cooldown, want the stage to run again", len(runs))
+	}
+}
+
+func TestNoFailureCooldownRerunsImmediately(t *testing.T) {
+	h := newHarness(t, testLinearYAML+failingPlanYAML)
+	issue := h.issue("Todo")
+
+	h.process(issue)
+	h.linear.MoveIssue(issue.ID, "Todo")
+	h.process(issue)
+	if runs := h.runs(issue.ID); len(runs) != 2 {
+		t.Errorf("got %d runs, want the re-trigger to run without a cooldown", len(runs))
+	}
+}
+
+func TestFailureCooldownBlocksCommentRerun(t *testing.T) {
+	h := newHarness(t, testLinearYAML+failingApprovalYAML+"    failure_cooldown: 1h\n")
+	issue := h.issue("Todo")
+
+	h.process(issue)
+	h.comment(issue.ID, "Please try again.")
+
+	if runs := h.runs(issue.ID); len(runs) != 1 {
+		t.Errorf("got %d runs after a comment within the cooldown, want only the failed one", len(runs))
+	}
+}
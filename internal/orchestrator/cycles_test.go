@@ -0,0 +1,68 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+)
+
+// loopingPipelineYAML sends every issue review rejects back to plan. Both
+// stages report the cycle count they were given.
+const loopingPipelineYAML = `
+pipeline:
+  max_cycles: 2
+  stages:
+    - name: plan
+      linear_state: Todo
+      command: sh
+      args: ["-c", "echo plan cycle $$AIFLOW_CYCLE_COUNT"]
+      prompt: Plan it.
+      next_state: In Progress
+    - name: review
+      linear_state: In Progress
+      command: sh
+      args: ["-c", "echo review cycle $$AIFLOW_CYCLE_COUNT"]
+      prompt: Review it.
+      next_state: Todo
+`
+
+func TestCycleCountAndMaxCycles(t *testing.T) {
+	h := newHarness(t, testLinearYAML+loopingPipelineYAML)
+	issue := h.issue("Todo")
+
+	want := []string{"plan cycle 0", "review cycle 0", "plan cycle 1", "review cycle 2"}
+	for i, output := range want {
+		h.process(issue)
+		runs := h.runs(issue.ID)
+		if len(runs) != i+1 {
+			t.Fatalf("after pass %d: %d runs, want %d", i+1, len(runs), i+1)
+		}
+		if got := strings.TrimSpace(runs[i].Output); got != output {
+			t.Errorf("pass %d output = %q, want %q", i+1, got, output)
+		}
+	}
+	if got, err := h.store.CycleCount(issue.ID); err != nil || got != 2 {
+		t.Fatalf("cycle count = %d, %v, want 2", got, err)
+	}
+
+	// A third loop back to plan is one more than max_cycles allows
+	if got := h.state(issue.ID); got != "Todo" {
+		t.Fatalf("state = %q, want Todo", got)
+	}
+	h.process(issue)
+	h.process(issue)
+	if got := len(h.runs(issue.ID)); got != len(want) {
+		t.Errorf("%d runs after the cycle limit, want %d", got, len(want))
+	}
+	var refusals int
+	for _, body := range h.comments(issue.ID) {
+		if strings.Contains(body, "cycle limit reached") {
+			refusals++
+		}
+	}
+	if refusals != 1 {
+		t.Errorf("cycle limit comments = %d, want 1", refusals)
+	}
+	if got := h.state(issue.ID); got != "Todo" {
+		t.Errorf("state after refusal = %q, want Todo", got)
+	}
+}
@@ -0,0 +1,89 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/linear"
+)
+
+// debouncing is a state change waiting out webhook_debounce.
+type debouncing struct {
+	issue     linear.IssueData // latest data received for the issue
+	pendingID int64            // pending work whose handler waits on it, or 0
+}
+
+// debounceIssue coalesces bursts of state-change webhooks for one issue. The
+// first change waits d and then returns the latest issue data received in the
+// meantime with ok set; changes arriving while it waits only replace that data
+// and return ok false, so a burst is handled once. A handler for pending work
+// Dispatch already registered as waiting does the waiting for it.
+func (o *Orchestrator) debounceIssue(ctx context.Context, issue linear.IssueData, d time.Duration) (latest linear.IssueData, ok bool) {
+	id, _ := ctx.Value(pendingIDKey{}).(int64)
+	o.pendingMu.Lock()
+	pending, waiting := o.pendingIssues[issue.ID]
+	if waiting && (id == 0 || pending.pendingID != id) {
+		pending.issue = issue
+		o.pendingMu.Unlock()
+		slog.Debug("folding state change into pending webhook", "issue", issue.Identifier)
+		return linear.IssueData{}, false
+	}
+	if !waiting {
+		pending = &debouncing{issue: issue, pendingID: id}
+		o.pendingIssues[issue.ID] = pending
+	}
+	o.pendingMu.Unlock()
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	o.pendingMu.Lock()
+	latest = pending.issue
+	delete(o.pendingIssues, issue.ID)
+	o.pendingMu.Unlock()
+	return latest, ctx.Err() == nil
+}
+
+// debouncedStateChange returns the issue a webhook payload changes the state
+// of, with ok set if the change is subject to webhook_debounce.
+func (o *Orchestrator) debouncedStateChange(payload linear.WebhookPayload) (issue linear.IssueData, ok bool) {
+	if payload.Type != "Issue" || o.cfg.Linear.ParsedWebhookDebounce <= 0 || payload.UpdatedFrom == nil {
+		return issue, false
+	}
+	var updatedFrom linear.UpdatedFromData
+	if err := json.Unmarshal(payload.UpdatedFrom, &updatedFrom); err != nil || updatedFrom.StateID == "" {
+		return issue, false
+	}
+	if err := json.Unmarshal(payload.Data, &issue); err != nil {
+		return issue, false
+	}
+	return issue, true
+}
+
+// foldIntoDebounce hands a state change to the handler already debouncing its
+// issue, updating that handler's pending work to replay the new payload. It
+// reports false, leaving the delivery to be dispatched on its own, when no
+// handler is waiting or the pending work can't be updated. The caller holds
+// pendingMu.
+func (o *Orchestrator) foldIntoDebounce(issue linear.IssueData, payload linear.WebhookPayload, data []byte) bool {
+	pending, waiting := o.pendingIssues[issue.ID]
+	if !waiting {
+		return false
+	}
+	if pending.pendingID != 0 {
+		if err := o.store.UpdatePending(pending.pendingID, data); err != nil {
+			slog.Warn("folding state change into pending work", "error", err, "issue", issue.Identifier)
+			return false
+		}
+	}
+	pending.issue = issue
+	slog.Debug("folding state change into pending webhook", "issue", issue.Identifier)
+	o.recordStateEntry(issue, payload.CreatedAt)
+	return true
+}
@@ -0,0 +1,85 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/linear"
+)
+
+func TestWebhookBurstFetchesIssueOnce(t *testing.T) {
+	h := newHarness(t, linearYAML("  webhook_debounce: 200ms\n")+planStageYAML)
+	issue := h.issue("Todo")
+	getIssue := func() int {
+		n := 0
+		for _, req := range h.linear.Requests("issue(id: $id)") {
+			if strings.Contains(req.Query, "labels") && !strings.Contains(req.Query, "comments(") {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Three updates in quick succession, each handled on its own goroutine
+	// as the webhook handler does
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.webhook(issue.ID, `{"stateId":"state-prev"}`)
+		}()
+	}
+	wg.Wait()
+
+	if n := getIssue(); n != 1 {
+		t.Errorf("got %d GetIssue calls for the burst, want 1", n)
+	}
+	runs := h.runs(issue.ID)
+	if len(runs) != 1 || runs[0].StageName != "plan" {
+		t.Errorf("runs = %+v, want one plan run for the burst", runs)
+	}
+	if got := h.state(issue.ID); got != "In Progress" {
+		t.Errorf("state = %q, want In Progress", got)
+	}
+}
+
+func TestDispatchedBurstStartsOneHandler(t *testing.T) {
+	h := newHarness(t, linearYAML("  webhook_debounce: 200ms\n")+planStageYAML)
+	issue := h.issue("Todo")
+
+	var last linear.WebhookPayload
+	for i := range 3 {
+		last = h.webhookPayload(issue.ID, `{"stateId":"state-prev"}`)
+		last.CreatedAt = []string{"2026-01-01T00:00:00Z", "2026-01-01T00:00:01Z", "2026-01-01T00:00:02Z"}[i]
+		if err := h.o.Dispatch(context.Background(), last); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The later deliveries were folded into the first's pending work rather
+	// than saved and handed to handlers of their own
+	items, err := h.store.RecoverPending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %d pending items for the burst, want 1", len(items))
+	}
+	var saved linear.WebhookPayload
+	if err := json.Unmarshal(items[0].Payload, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.CreatedAt != last.CreatedAt {
+		t.Errorf("pending work replays the delivery from %s, want the latest from %s", saved.CreatedAt, last.CreatedAt)
+	}
+
+	h.waitForRuns(issue.ID, 1)
+	runs := h.runs(issue.ID)
+	if len(runs) != 1 || runs[0].StageName != "plan" {
+		t.Errorf("runs = %+v, want one plan run for the burst", runs)
+	}
+}
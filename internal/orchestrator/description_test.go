@@ -0,0 +1,54 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const reviewWaitYAML = `
+pipeline:
+  - name: design
+    linear_state: In Review
+    command: sh
+    args: ["-c", "echo \"$$AIFLOW_ISSUE_DESCRIPTION\" | grep -o 'Clarification: [a-zA-Z]*' || echo no clarification"]
+    prompt: Design it.
+    next_state: Done
+    wait_for_approval: true
+`
+
+func TestDescriptionUpdateRerunsStage(t *testing.T) {
+	h := newHarness(t, testLinearYAML+reviewWaitYAML+"    rerun_on_description: true\n")
+	issue := h.issue("In Review")
+
+	// A human adds a clarification to the description
+	if err := h.client.UpdateIssueDescription(context.Background(), issue.ID, issue.Description+"\n\nClarification: use OAuth."); err != nil {
+		t.Fatal(err)
+	}
+	h.webhook(issue.ID, `{"description":"`+strings.ReplaceAll(issue.Description, "\n", `\n`)+`","updatedAt":"2026-01-01T00:00:00Z"}`)
+
+	runs := h.runs(issue.ID)
+	if len(runs) != 1 {
+		t.Fatalf("got %d runs after a description update, want one re-run", len(runs))
+	}
+	if runs[0].StageName != "design" || runs[0].Status != "completed" {
+		t.Errorf("run = %+v, want a completed design run", runs[0])
+	}
+	if got := strings.TrimSpace(runs[0].Output); got != "Clarification: use" {
+		t.Errorf("output = %q, want the re-run to see the updated description", got)
+	}
+}
+
+func TestDescriptionUpdateIgnoredWithoutToggle(t *testing.T) {
+	h := newHarness(t, testLinearYAML+reviewWaitYAML)
+	issue := h.issue("In Review")
+
+	if err := h.client.UpdateIssueDescription(context.Background(), issue.ID, "Something else entirely."); err != nil {
+		t.Fatal(err)
+	}
+	h.webhook(issue.ID, `{"description":"old"}`)
+
+	if runs := h.runs(issue.ID); len(runs) != 0 {
+		t.Errorf("got %d runs, want description updates ignored without rerun_on_description", len(runs))
+	}
+}
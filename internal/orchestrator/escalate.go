@@ -0,0 +1,122 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/config"
+	"github.com/mauza/ai-flow/internal/linear"
+)
+
+// escalationClient sends escalation events; the timeout keeps a slow receiver
+// from holding up failure handling.
+var escalationClient = &http.Client{Timeout: 10 * time.Second}
+
+// escalationEvent is the JSON body POSTed to notify.escalation_url.
+type escalationEvent struct {
+	Severity            string     `json:"severity"`
+	Reason              string     `json:"reason"` // "failure", "timeout", "repeated", or "approval_timeout"
+	Issue               string     `json:"issue"`
+	Stage               string     `json:"stage"`
+	Summary             string     `json:"summary"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	InStateSince        *time.Time `json:"in_state_since,omitempty"` // when the issue entered the stage's state
+	Timestamp           time.Time  `json:"timestamp"`
+}
+
+// escalationReason picks the condition from escalateOn that the stage's run
+// history matches, or "" if none does. statuses are the stage's most recent
+// run statuses, newest (the failure being reported) first. The most specific
+// condition wins: repeated, then timeout, then failure.
+func escalationReason(escalateOn, statuses []string, escalateAfter int) (reason string, consecutive int) {
+	for _, status := range statuses {
+		if status != "failed" && status != "timeout" {
+			break
+		}
+		consecutive++
+	}
+	switch {
+	case slices.Contains(escalateOn, "repeated") && consecutive >= escalateAfter:
+		return "repeated", consecutive
+	case slices.Contains(escalateOn, "timeout") && len(statuses) > 0 && statuses[0] == "timeout":
+		return "timeout", consecutive
+	case slices.Contains(escalateOn, "failure"):
+		return "failure", consecutive
+	}
+	return "", consecutive
+}
+
+// escalate sends a high-severity event for a stage failure when it matches
+// the stage's escalate_on conditions. Delivery failures are only logged.
+func (o *Orchestrator) escalate(ctx context.Context, details *linear.IssueDetails, stage *config.StageConfig, errMsg string) {
+	if len(stage.EscalateOn) == 0 || o.cfg.Notify.EscalationURL == "" {
+		return
+	}
+	issueID, identifier := details.ID, details.Identifier
+	statuses, err := o.store.RecentRunStatuses(issueID, stage.Name, stage.EscalateAfter)
+	if err != nil {
+		slog.Warn("reading run history for escalation", "error", err, "issue", identifier)
+	}
+	reason, consecutive := escalationReason(stage.EscalateOn, statuses, stage.EscalateAfter)
+	if reason == "" {
+		return
+	}
+
+	event := escalationEvent{
+		Severity:            "critical",
+		Reason:              reason,
+		Issue:               identifier,
+		Stage:               stage.Name,
+		Summary:             logContent(o.cfg, failureSummary(errMsg)),
+		ConsecutiveFailures: consecutive,
+		InStateSince:        o.inStateSince(details, stage),
+		Timestamp:           time.Now().UTC(),
+	}
+	if err := postEscalation(ctx, o.cfg.Notify.EscalationURL, event); err != nil {
+		slog.Error("sending escalation", "error", err, "issue", identifier, "stage", stage.Name, "reason", reason)
+		return
+	}
+	slog.Info("escalated stage failure", "issue", identifier, "stage", stage.Name, "reason", reason)
+}
+
+// inStateSince returns when the issue entered the stage's state, or nil if
+// that isn't known.
+func (o *Orchestrator) inStateSince(details *linear.IssueDetails, stage *config.StageConfig) *time.Time {
+	enteredAt, err := o.stateEnteredAt(details.Team.Key, details.ID, stage.LinearState)
+	if err != nil {
+		slog.Warn("reading state entry for escalation", "error", err, "issue", details.Identifier)
+		return nil
+	}
+	if enteredAt != nil {
+		utc := enteredAt.UTC()
+		enteredAt = &utc
+	}
+	return enteredAt
+}
+
+func postEscalation(ctx context.Context, url string, event escalationEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshaling event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := escalationClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("escalation endpoint returned %s", resp.Status)
+	}
+	return nil
+}
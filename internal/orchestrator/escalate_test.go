@@ -0,0 +1,157 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEscalationReason(t *testing.T) {
+	tests := []struct {
+		name       string
+		escalateOn []string
+		statuses   []string
+		want       string
+	}{
+		{"failure fires", []string{"failure"}, []string{"failed", "succeeded"}, "failure"},
+		{"failure fires on timeout", []string{"failure"}, []string{"timeout"}, "failure"},
+		{"timeout fires", []string{"timeout"}, []string{"timeout", "succeeded"}, "timeout"},
+		{"timeout ignores plain failure", []string{"timeout"}, []string{"failed"}, ""},
+		{"repeated fires at threshold", []string{"repeated"}, []string{"failed", "timeout", "failed"}, "repeated"},
+		{"repeated needs consecutive failures", []string{"repeated"}, []string{"failed", "succeeded", "failed"}, ""},
+		{"repeated below threshold", []string{"repeated"}, []string{"failed", "failed"}, ""},
+		{"repeated beats timeout", []string{"timeout", "repeated"}, []string{"timeout", "failed", "failed"}, "repeated"},
+		{"timeout beats failure", []string{"failure", "timeout"}, []string{"timeout"}, "timeout"},
+		{"nothing configured", nil, []string{"timeout", "failed", "failed"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := escalationReason(tt.escalateOn, tt.statuses, 3); got != tt.want {
+				t.Errorf("escalationReason(%q, %q) = %q, want %q", tt.escalateOn, tt.statuses, got, tt.want)
+			}
+		})
+	}
+}
+
+// escalationReceiver records the events POSTed to it.
+type escalationReceiver struct {
+	mu     sync.Mutex
+	events []escalationEvent
+}
+
+func newEscalationReceiver(t *testing.T) (*escalationReceiver, string) {
+	r := &escalationReceiver{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var event escalationEvent
+		if err := json.NewDecoder(req.Body).Decode(&event); err != nil {
+			t.Errorf("decoding escalation: %v", err)
+		}
+		r.mu.Lock()
+		r.events = append(r.events, event)
+		r.mu.Unlock()
+	}))
+	t.Cleanup(srv.Close)
+	return r, srv.URL
+}
+
+func (r *escalationReceiver) reasons() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var reasons []string
+	for _, e := range r.events {
+		reasons = append(reasons, e.Reason)
+	}
+	return reasons
+}
+
+func TestEscalateOnFailure(t *testing.T) {
+	recv, url := newEscalationReceiver(t)
+	h := newHarness(t, testLinearYAML+"notify:\n  escalation_url: "+url+"\n"+failingPlanYAML+"    escalate_on: [failure]\n")
+	issue := h.issue("Todo")
+
+	h.process(issue)
+	if got := recv.reasons(); len(got) != 1 || got[0] != "failure" {
+		t.Fatalf("escalations = %q, want one for the failure", got)
+	}
+	e := recv.events[0]
+	if e.Severity != "critical" || e.Stage != "plan" || e.Issue != issue.Identifier || e.ConsecutiveFailures != 1 {
+		t.Errorf("event = %+v", e)
+	}
+}
+
+func TestEscalateOnRepeatedFailures(t *testing.T) {
+	recv, url := newEscalationReceiver(t)
+	h := newHarness(t, testLinearYAML+"notify:\n  escalation_url: "+url+"\n"+failingPlanYAML+
+		"    escalate_on: [repeated]\n    escalate_after: 2\n")
+	issue := h.issue("Todo")
+
+	h.process(issue)
+	if got := recv.reasons(); len(got) != 0 {
+		t.Fatalf("escalations after one failure = %q, want none", got)
+	}
+	h.linear.MoveIssue(issue.ID, "Todo")
+	h.process(issue)
+	if got := recv.reasons(); len(got) != 1 || got[0] != "repeated" {
+		t.Fatalf("escalations after two failures = %q, want one repeated", got)
+	}
+	if got := recv.events[0].ConsecutiveFailures; got != 2 {
+		t.Errorf("consecutive failures = %d, want 2", got)
+	}
+}
+
+func TestEscalateOnTimeout(t *testing.T) {
+	recv, url := newEscalationReceiver(t)
+	h := newHarness(t, testLinearYAML+"notify:\n  escalation_url: "+url+"\n"+`
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    args: ["-c", "exec sleep 5"]
+    prompt: Plan it.
+    next_state: In Progress
+    failure_state: Failed
+    timeout: 1
+    escalate_on: [timeout]
+`)
+	issue := h.issue("Todo")
+
+	h.process(issue)
+	if got := recv.reasons(); len(got) != 1 || got[0] != "timeout" {
+		t.Fatalf("escalations = %q, want one for the timeout", got)
+	}
+}
+
+func TestNoEscalationForUnconfiguredCondition(t *testing.T) {
+	recv, url := newEscalationReceiver(t)
+	h := newHarness(t, testLinearYAML+"notify:\n  escalation_url: "+url+"\n"+failingPlanYAML+"    escalate_on: [timeout]\n")
+	issue := h.issue("Todo")
+
+	h.process(issue)
+	if got := recv.reasons(); len(got) != 0 {
+		t.Errorf("escalations = %q, want none for a plain failure", got)
+	}
+	if got := h.state(issue.ID); got != "Failed" {
+		t.Errorf("state = %q, want Failed", got)
+	}
+}
+
+func TestEscalationIncludesStateEntryTime(t *testing.T) {
+	recv, url := newEscalationReceiver(t)
+	h := newHarness(t, testLinearYAML+"notify:\n  escalation_url: "+url+"\n"+failingPlanYAML+"    escalate_on: [failure]\n")
+	issue := h.issue("Todo")
+	entered := time.Now().Add(-3 * time.Hour).UTC().Truncate(time.Second)
+	if err := h.store.RecordStateEntry(issue.ID, "Todo", entered); err != nil {
+		t.Fatal(err)
+	}
+
+	h.process(issue)
+	if len(recv.events) != 1 {
+		t.Fatalf("escalations = %q, want one", recv.reasons())
+	}
+	if got := recv.events[0].InStateSince; got == nil || !got.Equal(entered) {
+		t.Errorf("in_state_since = %v, want %v", got, entered)
+	}
+}
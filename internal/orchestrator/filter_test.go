@@ -0,0 +1,159 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/linear"
+)
+
+// setAssignee assigns the issue to the user with id and email (unassigns it
+// if both are empty).
+func setAssignee(t *testing.T, issue *linear.IssueDetails, id, email string) {
+	t.Helper()
+	issue.Assignee = nil
+	if id == "" && email == "" {
+		return
+	}
+	user, _ := json.Marshal(map[string]string{"id": id, "email": email, "url": "https://linear.app/acme/profiles/" + id})
+	if err := json.Unmarshal(user, &issue.Assignee); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAssigneeFilter(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		filter    string
+		id, email string
+		runs      bool
+	}{
+		{"match by id", "user-bot", "user-bot", "bot@acme.dev", true},
+		{"match by email", "Bot@Acme.dev", "user-bot", "bot@acme.dev", true},
+		{"other assignee", "user-bot", "user-alice", "alice@acme.dev", false},
+		{"unassigned", "user-bot", "", "", false},
+		{"no filter, unassigned", "", "", "", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := testLinearYAML
+			if tc.filter != "" {
+				cfg = linearYAML("  assignee_filter: " + tc.filter + "\n")
+			}
+			h := newHarness(t, cfg+planStageYAML)
+			issue := h.issueWith("Todo", func(issue *linear.IssueDetails) { setAssignee(t, issue, tc.id, tc.email) })
+
+			h.process(issue)
+			if ran := len(h.runs(issue.ID)) > 0; ran != tc.runs {
+				t.Errorf("stage ran = %v, want %v", ran, tc.runs)
+			}
+			wantState := "Todo"
+			if tc.runs {
+				wantState = "In Progress"
+			}
+			if got := h.state(issue.ID); got != wantState {
+				t.Errorf("state = %q, want %q", got, wantState)
+			}
+		})
+	}
+}
+
+// setCreator records the user with id and email as the issue's creator.
+func setCreator(t *testing.T, issue *linear.IssueDetails, id, email string) {
+	t.Helper()
+	user, _ := json.Marshal(map[string]string{"id": id, "email": email})
+	if err := json.Unmarshal(user, &issue.Creator); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSkipBotIssues(t *testing.T) {
+	h := newHarness(t, linearYAML("  skip_bot_issues: true\n  bot_users: [user-bot]\n")+planStageYAML)
+	bot := h.issueWith("Todo", func(issue *linear.IssueDetails) { setCreator(t, issue, "user-bot", "bot@acme.dev") })
+	human := h.issueWith("Todo", func(issue *linear.IssueDetails) { setCreator(t, issue, "user-alice", "alice@acme.dev") })
+
+	h.process(bot)
+	if runs := h.runs(bot.ID); len(runs) != 0 {
+		t.Errorf("bot-created issue ran %d times, want skipped", len(runs))
+	}
+	if got := h.state(bot.ID); got != "Todo" {
+		t.Errorf("bot-created issue state = %q, want Todo", got)
+	}
+
+	h.process(human)
+	if got := h.state(human.ID); got != "In Progress" {
+		t.Errorf("human-created issue state = %q, want In Progress", got)
+	}
+}
+
+func TestMentionAssigneeOnFailure(t *testing.T) {
+	const profile = "https://linear.app/acme/profiles/user-alice"
+	for _, tc := range []struct {
+		name     string
+		enabled  bool
+		assignee string
+		mention  bool
+	}{
+		{"assigned", true, "user-alice", true},
+		{"unassigned", true, "", false},
+		{"disabled", false, "user-alice", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := testLinearYAML
+			if tc.enabled {
+				cfg = linearYAML("  mention_assignee_on_failure: true\n")
+			}
+			h := newHarness(t, cfg+failingPlanYAML)
+			issue := h.issueWith("Todo", func(issue *linear.IssueDetails) {
+				if tc.assignee != "" {
+					setAssignee(t, issue, tc.assignee, "alice@acme.dev")
+				}
+			})
+
+			h.process(issue)
+			comment, ok := h.commentContaining(issue.ID, "broken")
+			if !ok {
+				t.Fatalf("no failure comment, comments: %q", h.comments(issue.ID))
+			}
+			if !strings.HasPrefix(comment, "**ai-flow: stage `plan` failed**") {
+				t.Errorf("comment doesn't start with the ai-flow header:\n%s", comment)
+			}
+			if got := strings.Contains(comment, "\n\n"+profile); got != tc.mention {
+				t.Errorf("comment mentions the assignee = %v, want %v:\n%s", got, tc.mention, comment)
+			}
+			if !tc.mention && strings.Contains(comment, profile) {
+				t.Errorf("comment mentions the assignee:\n%s", comment)
+			}
+		})
+	}
+}
+
+const failingApprovalYAML = `
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    args: ["-c", "echo broken >&2; exit 1"]
+    prompt: Plan it.
+    next_state: In Progress
+    wait_for_approval: true
+`
+
+func TestFailureCommentWithMentionIgnoredAsOwn(t *testing.T) {
+	h := newHarness(t, linearYAML("  mention_assignee_on_failure: true\n")+failingApprovalYAML)
+	issue := h.issueWith("Todo", func(issue *linear.IssueDetails) { setAssignee(t, issue, "user-alice", "alice@acme.dev") })
+	h.process(issue)
+
+	comment, ok := h.commentContaining(issue.ID, "broken")
+	if !ok {
+		t.Fatalf("no failure comment, comments: %q", h.comments(issue.ID))
+	}
+	if !strings.Contains(comment, "profiles/user-alice") {
+		t.Fatalf("failure comment doesn't mention the assignee:\n%s", comment)
+	}
+	h.comment(issue.ID, comment)
+
+	if got := len(h.runs(issue.ID)); got != 1 {
+		t.Errorf("%d runs after ai-flow's own failure comment, want 1", got)
+	}
+}
@@ -0,0 +1,243 @@
+package orchestrator
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/config"
+	"github.com/mauza/ai-flow/internal/git"
+	"github.com/mauza/ai-flow/internal/linear"
+	"github.com/mauza/ai-flow/internal/store"
+	"github.com/mauza/ai-flow/internal/subprocess"
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+// testLinearYAML is a linear section for configs that need nothing special.
+const testLinearYAML = `
+linear:
+  api_key: test-key
+  team_key: ENG
+  webhook_secret: secret
+subprocess:
+  skip_command_check: true
+`
+
+// linearYAML is testLinearYAML with extra (indented as linear keys) added to
+// its linear section.
+func linearYAML(extra string) string {
+	return strings.Replace(testLinearYAML, "subprocess:", extra+"subprocess:", 1)
+}
+
+// testStates are the workflow states of the fake Linear team.
+var testStates = []string{"Todo", "In Progress", "In Review", "Done", "Failed", "Canceled", "Backlog"}
+
+// harness is an Orchestrator wired to a fake Linear, a temporary store, and
+// (after withGit) local git remotes and a fake gh.
+type harness struct {
+	t      *testing.T
+	cfg    *config.Config
+	linear *testutil.Linear
+	client *linear.Client
+	store  *store.Store
+	git    *git.Manager
+	repos  *testutil.Git
+	gh     *testutil.GH
+	o      *Orchestrator
+}
+
+// newHarness loads cfgYAML as the config and builds an orchestrator around it.
+func newHarness(t *testing.T, cfgYAML string) *harness {
+	t.Helper()
+	cfg := loadConfig(t, cfgYAML)
+
+	fake := testutil.NewLinear(t, testStates...)
+	client := fake.Client()
+	client.SetMaxLabels(cfg.Linear.MaxLabels)
+	client.SetExtraIssueFields(cfg.Linear.ExtraIssueFields)
+	for _, team := range cfg.TeamKeys() {
+		if err := client.LoadWorkflowStates(context.Background(), team); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := store.New(filepath.Join(t.TempDir(), "ai-flow.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	h := &harness{t: t, cfg: cfg, linear: fake, client: client, store: db}
+	h.o = New(cfg, client, db, subprocess.NewRunner(cfg.Subprocess.MaxConcurrent), nil)
+	return h
+}
+
+// loadConfig writes cfgYAML to a temporary file and loads it.
+func loadConfig(t *testing.T, cfgYAML string) *config.Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(cfgYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// withGit gives the orchestrator a git manager whose clones of acme/app come
+// from a local bare repo, and installs the fake gh. It returns the bare repo.
+func (h *harness) withGit() string {
+	h.t.Helper()
+	h.repos = testutil.NewGit(h.t)
+	h.gh = testutil.NewGH(h.t)
+	bare := h.repos.Remote(h.t, "acme/app")
+	h.git = &git.Manager{
+		AuthorName:  "ai-flow",
+		AuthorEmail: "ai-flow@noreply",
+		GHTimeout:   time.Minute,
+	}
+	h.o.git = h.git
+	return bare
+}
+
+// issue adds an issue in state to the fake Linear. Its description points
+// git stages at acme/app.
+func (h *harness) issue(state string, labels ...string) *linear.IssueDetails {
+	return h.issueWith(state, func(issue *linear.IssueDetails) {
+		for _, l := range labels {
+			issue.Labels.Nodes = append(issue.Labels.Nodes, linear.IssueLabel{Name: l})
+		}
+	})
+}
+
+// issueWith is issue with edit applied to the issue before it is added.
+func (h *harness) issueWith(state string, edit func(*linear.IssueDetails)) *linear.IssueDetails {
+	issue := linear.IssueDetails{
+		Title:       "Fix the thing",
+		Description: "---\ngithub_repo: acme/app\ndefault_branch: main\n---\nPlease fix the thing.",
+	}
+	issue.State.Name = state
+	edit(&issue)
+	return h.linear.AddIssue(issue)
+}
+
+// process runs the pipeline stage for the issue's current state, as a poll
+// or webhook would.
+func (h *harness) process(issue *linear.IssueDetails) {
+	h.t.Helper()
+	details, err := h.client.GetIssue(context.Background(), issue.ID)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	stage := h.cfg.FindStage(details.Team.Key, details.State.Name, details.LabelNames())
+	if stage == nil {
+		h.t.Fatalf("no stage for state %q", details.State.Name)
+	}
+	h.o.ProcessIssue(context.Background(), details, stage)
+}
+
+// webhook delivers an issue update webhook carrying the issue's current
+// data in the fake Linear and updatedFrom (JSON) as the changed fields.
+func (h *harness) webhook(issueID, updatedFrom string) {
+	h.t.Helper()
+	h.o.HandleWebhook(context.Background(), h.webhookPayload(issueID, updatedFrom))
+}
+
+// webhookPayload builds the payload webhook delivers.
+func (h *harness) webhookPayload(issueID, updatedFrom string) linear.WebhookPayload {
+	h.t.Helper()
+	issue := h.linear.Issue(issueID)
+	data, err := json.Marshal(linear.IssueData{
+		ID:          issue.ID,
+		Identifier:  issue.Identifier,
+		Title:       issue.Title,
+		Description: issue.Description,
+		StateID:     issue.State.ID,
+		TeamID:      issue.Team.ID,
+		URL:         issue.URL,
+	})
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	payload := linear.WebhookPayload{
+		Type:      "Issue",
+		Action:    "update",
+		Data:      data,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if updatedFrom != "" {
+		payload.UpdatedFrom = json.RawMessage(updatedFrom)
+	}
+	return payload
+}
+
+// runs returns the issue's runs, oldest first.
+func (h *harness) runs(issueID string) []store.RunRecord {
+	h.t.Helper()
+	all, err := h.store.ListRecentRuns(1000)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	var runs []store.RunRecord
+	for _, r := range all {
+		if r.IssueID == issueID {
+			runs = append(runs, r)
+		}
+	}
+	slices.SortFunc(runs, func(a, b store.RunRecord) int { return cmp.Compare(a.ID, b.ID) })
+	return runs
+}
+
+// lastRun returns the issue's most recent run.
+func (h *harness) lastRun(issueID string) store.RunRecord {
+	h.t.Helper()
+	runs := h.runs(issueID)
+	if len(runs) == 0 {
+		h.t.Fatal("no runs recorded")
+	}
+	return runs[len(runs)-1]
+}
+
+// state returns the issue's current state name in the fake Linear.
+func (h *harness) state(issueID string) string {
+	return h.linear.Issue(issueID).State.Name
+}
+
+// comments returns the bodies of the comments on the issue.
+func (h *harness) comments(issueID string) []string {
+	var bodies []string
+	for _, c := range h.linear.Comments(issueID) {
+		bodies = append(bodies, c.Body)
+	}
+	return bodies
+}
+
+// commentContaining returns the first comment on the issue containing substr.
+func (h *harness) commentContaining(issueID, substr string) (string, bool) {
+	for _, body := range h.comments(issueID) {
+		if strings.Contains(body, substr) {
+			return body, true
+		}
+	}
+	return "", false
+}
+
+// captureLog sends slog's default logger to a buffer for the rest of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
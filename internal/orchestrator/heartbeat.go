@@ -0,0 +1,151 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/linear"
+	"github.com/mauza/ai-flow/internal/subprocess"
+)
+
+const heartbeatTailBytes = 2000
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = t.buf[len(t.buf)-t.max:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
+
+// runSubprocess runs the stage's subprocess, applying the running status label
+// as it starts. With linear.post_start_comment it
+// first posts a "started" status comment, and when linear.heartbeat_interval is
+// configured, it also keeps the run's status comment updated with the tail of
+// the live output until the subprocess exits. A successful run clears the
+// stage's checkpoint file.
+func (o *Orchestrator) runSubprocess(ctx context.Context, details *linear.IssueDetails, input subprocess.Input) (result *subprocess.Result, err error) {
+	// Review passes share the run; keep the main pass's record
+	if o.cfg.Subprocess.AuditInputs && input.RunID != 0 && input.ReviewOutput == "" {
+		if err := o.store.SetRunAudit(input.RunID, subprocess.Audit(input)); err != nil {
+			slog.Warn("recording input audit", "error", err, "issue", details.Identifier)
+		}
+	}
+	defer func() {
+		if err == nil && result.ExitCode == 0 {
+			clearCheckpoint(input.CheckpointFile)
+		}
+	}()
+	if o.cfg.Linear.StatusLabels.Running != "" {
+		input.OnStart = func() { o.markRunning(ctx, details) }
+	}
+
+	interval := o.cfg.Linear.ParsedHeartbeatInterval
+	if interval <= 0 {
+		if o.cfg.Linear.PostStartComment {
+			o.postStarted(ctx, details, input.StageName)
+		}
+		result, err = o.runner.Run(ctx, input)
+		reportTruncation(details, input.StageName, result)
+		return result, err
+	}
+
+	tail := &tailBuffer{max: heartbeatTailBytes}
+	input.LiveOutput = tail
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		o.heartbeat(ctx, details, input.StageName, interval, tail, done)
+	}()
+
+	result, err = o.runner.Run(ctx, input)
+	close(done)
+	wg.Wait()
+	reportTruncation(details, input.StageName, result)
+	return result, err
+}
+
+// heartbeat posts a "started" status comment immediately and edits it with the
+// latest output on every tick, so a long run produces one comment rather than many.
+func (o *Orchestrator) heartbeat(ctx context.Context, details *linear.IssueDetails, stageName string, interval time.Duration, tail *tailBuffer, done <-chan struct{}) {
+	o.postStarted(ctx, details, stageName)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		body := formatHeartbeatComment(stageName, time.Since(start), tail.String())
+		if err := o.postStatus(ctx, details.ID, stageName, body); err != nil {
+			slog.Warn("posting heartbeat comment", "error", err, "issue", details.Identifier)
+		}
+	}
+}
+
+// postStarted posts the run's "started" status comment, with the stage's
+// typical duration when linear.post_start_comment is set and it has history.
+func (o *Orchestrator) postStarted(ctx context.Context, details *linear.IssueDetails, stageName string) {
+	var typical time.Duration
+	if o.cfg.Linear.PostStartComment {
+		var err error
+		if typical, err = o.store.AverageDuration(stageName); err != nil {
+			slog.Warn("estimating stage duration", "error", err, "stage", stageName)
+		}
+	}
+	if err := o.postStatus(ctx, details.ID, stageName, formatStartedComment(stageName, typical)); err != nil {
+		slog.Warn("posting status comment", "error", err, "issue", details.Identifier)
+	}
+}
+
+// formatStartedComment returns the "started" status comment, mentioning the
+// typical duration if it's known.
+func formatStartedComment(stageName string, typical time.Duration) string {
+	started := fmt.Sprintf("**ai-flow: stage `%s` started**", stageName)
+	if typical <= 0 {
+		return started
+	}
+	minutes := int(typical.Round(time.Minute) / time.Minute)
+	if minutes < 1 {
+		return started + " (typical duration <1m)"
+	}
+	return fmt.Sprintf("%s (typical duration ~%dm)", started, minutes)
+}
+
+func formatHeartbeatComment(stageName string, elapsed time.Duration, output string) string {
+	header := fmt.Sprintf("**ai-flow: stage `%s` running** (%s elapsed)", stageName, elapsed.Round(time.Second))
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return header + "\n\n_No output yet._"
+	}
+	return fmt.Sprintf("%s\n\nLatest output:\n\n```\n%s\n```", header, output)
+}
@@ -0,0 +1,116 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const planStageYAML = `
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    args: ["-c", "echo planned"]
+    prompt: Plan it.
+    next_state: In Progress
+`
+
+func TestHeartbeatUpdatesStatusCommentInPlace(t *testing.T) {
+	h := newHarness(t, testLinearYAML+planStageYAML)
+	issue := h.issue("Todo")
+
+	tail := &tailBuffer{max: heartbeatTailBytes}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		h.o.heartbeat(context.Background(), issue, "plan", 10*time.Millisecond, tail, done)
+		close(finished)
+	}()
+	tail.Write([]byte("step 1 of 3\n"))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		comments := h.linear.Comments(issue.ID)
+		if len(comments) == 1 && comments[0].Edits >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no heartbeat edits after 5s, comments: %+v", comments)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(done)
+	<-finished
+
+	if err := h.o.finishStatus(context.Background(), issue.ID, "plan", "**ai-flow: stage `plan` completed**"); err != nil {
+		t.Fatal(err)
+	}
+
+	comments := h.linear.Comments(issue.ID)
+	if len(comments) != 1 {
+		t.Fatalf("got %d comments, want the status comment edited in place: %+v", len(comments), comments)
+	}
+	if !strings.Contains(comments[0].Body, "completed") {
+		t.Errorf("final comment = %q, want the completed status", comments[0].Body)
+	}
+	if got := len(h.linear.Requests("commentCreate")); got != 1 {
+		t.Errorf("commentCreate called %d times, want 1", got)
+	}
+}
+
+func TestFormatHeartbeatCommentShowsLatestOutput(t *testing.T) {
+	body := formatHeartbeatComment("plan", 90*time.Second, "step 2 of 3\n")
+	for _, want := range []string{"`plan` running", "1m30s elapsed", "step 2 of 3"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("heartbeat comment %q is missing %q", body, want)
+		}
+	}
+	if body := formatHeartbeatComment("plan", time.Second, " "); !strings.Contains(body, "No output yet") {
+		t.Errorf("heartbeat comment without output = %q", body)
+	}
+}
+
+func TestStartCommentIncludesTypicalDuration(t *testing.T) {
+	h := newHarness(t, linearYAML("  post_start_comment: true\n")+
+		strings.Replace(planStageYAML, "echo planned", "sleep 1.1; echo planned", 1))
+	issue := h.issue("Todo")
+
+	started := func() []string {
+		var bodies []string
+		for _, req := range h.linear.Requests("commentCreate") {
+			if body, _ := req.Variables["body"].(string); strings.Contains(body, "started") {
+				bodies = append(bodies, body)
+			}
+		}
+		return bodies
+	}
+
+	// Without history there is nothing to estimate from
+	h.process(issue)
+	if got := started(); len(got) != 1 || got[0] != "**ai-flow: stage `plan` started**" {
+		t.Fatalf("first start comment = %q", got)
+	}
+
+	h.linear.MoveIssue(issue.ID, "Todo")
+	h.process(issue)
+	if got := started(); len(got) != 2 || got[1] != "**ai-flow: stage `plan` started** (typical duration <1m)" {
+		t.Errorf("start comments = %q, want the second with the typical duration", got)
+	}
+}
+
+func TestFormatStartedComment(t *testing.T) {
+	tests := []struct {
+		typical time.Duration
+		want    string
+	}{
+		{0, "**ai-flow: stage `plan` started**"},
+		{20 * time.Second, "**ai-flow: stage `plan` started** (typical duration <1m)"},
+		{7*time.Minute + 40*time.Second, "**ai-flow: stage `plan` started** (typical duration ~8m)"},
+	}
+	for _, tt := range tests {
+		if got := formatStartedComment("plan", tt.typical); got != tt.want {
+			t.Errorf("formatStartedComment(%s) = %q, want %q", tt.typical, got, tt.want)
+		}
+	}
+}
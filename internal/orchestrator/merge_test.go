@@ -0,0 +1,115 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/linear"
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+const mergePipelineYAML = `
+pipeline:
+  - name: implement
+    linear_state: In Progress
+    command: sh
+    args: ["-c", "echo change > change.txt"]
+    prompt: Implement it.
+    next_state: In Review
+    failure_state: Failed
+    creates_pr: true
+  - name: merge
+    linear_state: In Review
+    command: sh
+    args: ["-c", "true"]
+    prompt: Merge it.
+    next_state: Done
+    failure_state: Failed
+    uses_branch: true
+    merges_pr: true
+`
+
+func TestMergeConflict(t *testing.T) {
+	const conflicting = `{"reviewDecision":"APPROVED","mergeable":"CONFLICTING","statusCheckRollup":[]}`
+	const mergeable = `{"reviewDecision":"APPROVED","mergeable":"MERGEABLE","statusCheckRollup":[]}`
+	for _, tc := range []struct {
+		name       string
+		onConflict string
+		prView     string
+		mergeErr   string
+		wantState  string
+	}{
+		{name: "requeue on status", onConflict: "requeue", prView: conflicting, wantState: "In Progress"},
+		{name: "requeue on merge error", onConflict: "requeue", prView: mergeable,
+			mergeErr: "Pull request acme/app#1 is not mergeable: the merge commit cannot be cleanly created.", wantState: "In Progress"},
+		{name: "fail", onConflict: "fail", prView: conflicting, wantState: "Failed"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := mergePipelineYAML + "    on_conflict: " + tc.onConflict + "\n"
+			if tc.onConflict == "requeue" {
+				cfg += "    requeue_state: In Progress\n"
+			}
+			h := newHarness(t, testLinearYAML+cfg)
+			h.withGit()
+			issue := h.issue("In Progress")
+
+			h.process(issue)
+			if got := h.state(issue.ID); got != "In Review" {
+				t.Fatalf("after implement, state = %q, want In Review", got)
+			}
+
+			h.gh.Respond(t, "pr view", tc.prView, "", 0)
+			if tc.mergeErr != "" {
+				h.gh.Respond(t, "pr merge", "", tc.mergeErr, 1)
+			}
+			h.process(issue)
+
+			if got := h.state(issue.ID); got != tc.wantState {
+				t.Errorf("after merge, state = %q, want %q", got, tc.wantState)
+			}
+			run := h.lastRun(issue.ID)
+			if run.Status != "failed" || !strings.Contains(run.Error, "merge conflict") {
+				t.Errorf("merge run = %s %q, want a failed run naming the conflict", run.Status, run.Error)
+			}
+			_, requeued := h.commentContaining(issue.ID, "hit a merge conflict")
+			if requeued != (tc.onConflict == "requeue") {
+				t.Errorf("requeue comment posted = %v, comments: %q", requeued, h.comments(issue.ID))
+			}
+			merges := h.gh.Calls("pr", "merge")
+			switch {
+			case tc.mergeErr == "" && len(merges) != 0:
+				t.Errorf("gh pr merge called %d times on a PR reported as conflicting", len(merges))
+			case tc.mergeErr != "" && (len(merges) != 1 || merges[0][2] != testutil.DefaultPRURL):
+				t.Errorf("gh pr merge calls = %q, want one for %s", merges, testutil.DefaultPRURL)
+			}
+		})
+	}
+}
+
+func TestCreatedPRIsLinkedToIssue(t *testing.T) {
+	h := newHarness(t, testLinearYAML+mergePipelineYAML)
+	h.withGit()
+	issue := h.issue("In Progress")
+	h.linear.Handle = func(req linear.GraphQLRequest) (any, bool) {
+		if !strings.Contains(req.Query, "attachmentLinkGitHubPR") {
+			return nil, false
+		}
+		return map[string]any{"attachmentLinkGitHubPR": map[string]any{"success": false}}, true
+	}
+
+	h.process(issue)
+	links := h.linear.Requests("attachmentLinkGitHubPR")
+	if len(links) != 1 {
+		t.Fatalf("got %d attachmentLinkGitHubPR requests, want 1", len(links))
+	}
+	if vars := links[0].Variables; vars["issueId"] != issue.ID || vars["url"] != testutil.DefaultPRURL {
+		t.Errorf("attachmentLinkGitHubPR variables = %v", vars)
+	}
+	// A failed link leaves the PR reachable from the comment
+	if _, ok := h.commentContaining(issue.ID, testutil.DefaultPRURL); !ok {
+		t.Errorf("no comment links the PR: %q", h.comments(issue.ID))
+	}
+	if got := h.state(issue.ID); got != "In Review" {
+		t.Errorf("state = %q, want In Review despite the failed link", got)
+	}
+}
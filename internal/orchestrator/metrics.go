@@ -0,0 +1,31 @@
+package orchestrator
+
+import (
+	"expvar"
+	"log/slog"
+
+	"github.com/mauza/ai-flow/internal/linear"
+	"github.com/mauza/ai-flow/internal/subprocess"
+)
+
+// Counters published via expvar (served at /debug/vars).
+var (
+	truncatedRuns  = expvar.NewInt("subprocess_output_truncated_runs")
+	truncatedBytes = expvar.NewInt("subprocess_output_truncated_bytes")
+)
+
+// reportTruncation warns and counts when a run's captured output hit the
+// capture limit, since the stored output and comments are then incomplete.
+func reportTruncation(details *linear.IssueDetails, stageName string, result *subprocess.Result) {
+	if result == nil || result.StdoutTruncated+result.StderrTruncated == 0 {
+		return
+	}
+	truncatedRuns.Add(1)
+	truncatedBytes.Add(int64(result.StdoutTruncated + result.StderrTruncated))
+	slog.Warn("subprocess output truncated",
+		"issue", details.Identifier,
+		"stage", stageName,
+		"stdoutDroppedBytes", result.StdoutTruncated,
+		"stderrDroppedBytes", result.StderrTruncated,
+	)
+}
@@ -0,0 +1,33 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncatedOutputIsReported(t *testing.T) {
+	h := newHarness(t, testLinearYAML+`
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    args: ["-c", "head -c 1050000 /dev/zero | tr '\\0' x"]
+    prompt: Plan it.
+    next_state: In Progress
+`)
+	issue := h.issue("Todo")
+	runs, bytes := truncatedRuns.Value(), truncatedBytes.Value()
+	logs := captureLog(t)
+
+	h.process(issue)
+	const dropped = 1050000 - 1<<20
+	if got := truncatedRuns.Value() - runs; got != 1 {
+		t.Errorf("truncated runs grew by %d, want 1", got)
+	}
+	if got := truncatedBytes.Value() - bytes; got != dropped {
+		t.Errorf("truncated bytes grew by %d, want %d", got, dropped)
+	}
+	if !strings.Contains(logs.String(), "subprocess output truncated") || !strings.Contains(logs.String(), "stdoutDroppedBytes=1424") {
+		t.Errorf("no truncation warning with the dropped count in logs:\n%s", logs)
+	}
+}
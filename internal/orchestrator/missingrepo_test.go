@@ -0,0 +1,70 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/linear"
+)
+
+// missingRepoYAML is a git stage with pipeline.on_missing_repo set to onMissing.
+func missingRepoYAML(onMissing string) string {
+	return `
+pipeline:
+  on_missing_repo: ` + onMissing + `
+  stages:
+    - name: implement
+      linear_state: In Progress
+      command: sh
+      args: ["-c", "echo change > change.txt"]
+      prompt: Implement it.
+      next_state: In Review
+      failure_state: Failed
+      creates_pr: true
+`
+}
+
+func TestProjectlessIssueAtGitStage(t *testing.T) {
+	noRepo := func(issue *linear.IssueDetails) { issue.Description = "Please fix the thing." }
+
+	t.Run("skip", func(t *testing.T) {
+		h := newHarness(t, testLinearYAML+missingRepoYAML("skip"))
+		h.withGit()
+		issue := h.issueWith("In Progress", noRepo)
+
+		h.process(issue)
+		h.process(issue)
+		if got := h.state(issue.ID); got != "In Progress" {
+			t.Errorf("state = %q, want the issue left in In Progress", got)
+		}
+		if runs := h.runs(issue.ID); len(runs) != 0 {
+			t.Errorf("got %d runs, want none", len(runs))
+		}
+		var notices []string
+		for _, body := range h.comments(issue.ID) {
+			if strings.Contains(body, "doesn't name one") {
+				notices = append(notices, body)
+			}
+		}
+		if len(notices) != 1 || !strings.Contains(notices[0], "stage `implement` skipped") {
+			t.Errorf("missing repo comments = %q, want one naming the stage", notices)
+		}
+		if creates := h.gh.Calls("pr", "create"); len(creates) != 0 {
+			t.Errorf("got %d gh pr create calls, want 0", len(creates))
+		}
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		h := newHarness(t, testLinearYAML+missingRepoYAML("fail"))
+		h.withGit()
+		issue := h.issueWith("In Progress", noRepo)
+
+		h.process(issue)
+		if got := h.state(issue.ID); got != "Failed" {
+			t.Errorf("state = %q, want Failed", got)
+		}
+		if run := h.lastRun(issue.ID); run.Status != "failed" {
+			t.Errorf("run status = %s, want failed", run.Status)
+		}
+	})
+}
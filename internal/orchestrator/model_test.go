@@ -0,0 +1,54 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+)
+
+const modelPipelineYAML = `
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    args: ["-c", "echo \"model=$$AIFLOW_MODEL provider=$$AIFLOW_PROVIDER\""]
+    prompt: Plan it.
+    next_state: In Progress
+  - name: implement
+    linear_state: In Progress
+    command: sh
+    args: ["-c", "echo \"model=$$AIFLOW_MODEL provider=$$AIFLOW_PROVIDER\""]
+    prompt: Implement it.
+    next_state: In Review
+    model: opus
+    provider: bedrock
+`
+
+func TestModelAndProviderEnv(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		defaults  string
+		plan      string
+		implement string
+	}{
+		{"global defaults", "  model: sonnet\n  provider: anthropic\n", "model=sonnet provider=anthropic", "model=opus provider=bedrock"},
+		{"no defaults", "", "model= provider=", "model=opus provider=bedrock"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newHarness(t, testLinearYAML+tc.defaults+modelPipelineYAML)
+			issue := h.issue("Todo")
+
+			h.process(issue)
+			h.process(issue)
+			runs := h.runs(issue.ID)
+			if len(runs) != 2 {
+				t.Fatalf("got %d runs, want plan and implement", len(runs))
+			}
+			if got := strings.TrimSpace(runs[0].Output); got != tc.plan {
+				t.Errorf("plan saw %q, want %q", got, tc.plan)
+			}
+			if got := strings.TrimSpace(runs[1].Output); got != tc.implement {
+				t.Errorf("implement saw %q, want the stage's override %q", got, tc.implement)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package orchestrator
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitStageFailsWithoutGitManager(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "count")
+	h := newHarness(t, testLinearYAML+`
+pipeline:
+  - name: implement
+    linear_state: In Progress
+    command: sh
+    args: ["-c", "echo run >> `+counter+`"]
+    prompt: Implement it.
+    next_state: In Review
+    failure_state: Failed
+    creates_pr: true
+`)
+	// No withGit: the git manager failed to initialize
+	issue := h.issue("In Progress")
+
+	h.process(issue)
+	if got := executions(t, counter); got != 0 {
+		t.Errorf("command ran %d times, want the stage failed before running git-less", got)
+	}
+	run := h.lastRun(issue.ID)
+	if run.Status != "failed" || !strings.Contains(run.Error, "git unavailable") {
+		t.Errorf("run = %s %q, want failed with git unavailable", run.Status, run.Error)
+	}
+	if got := h.state(issue.ID); got != "Failed" {
+		t.Errorf("state = %q, want Failed", got)
+	}
+	if _, ok := h.commentContaining(issue.ID, "git unavailable"); !ok {
+		t.Errorf("comments = %q, want one explaining git is unavailable", h.comments(issue.ID))
+	}
+}
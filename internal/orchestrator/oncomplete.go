@@ -0,0 +1,56 @@
+package orchestrator
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/config"
+)
+
+// onCompleteTimeout bounds an on_complete_command run.
+const onCompleteTimeout = time.Minute
+
+// runOnComplete starts the stage's on_complete_command in the background with
+// the run's outcome in its environment. nextState is the state the issue was
+// moved to, or "" if it wasn't moved. Failures are only logged.
+func (o *Orchestrator) runOnComplete(ctx context.Context, issueID, identifier string, stage *config.StageConfig, result, nextState, prURL string) {
+	if stage.OnCompleteCommand == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), onCompleteTimeout)
+	cmd := exec.CommandContext(ctx, stage.OnCompleteCommand, stage.OnCompleteArgs...)
+	cmd.Env = append(os.Environ(), onCompleteEnv(issueID, identifier, stage.Name, result, nextState, prURL)...)
+	go func() {
+		defer cancel()
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			slog.Warn("on_complete_command failed",
+				"error", err,
+				"issue", identifier,
+				"stage", stage.Name,
+				"output", logContent(o.cfg, truncate(strings.TrimSpace(string(out)), 2000)),
+			)
+			return
+		}
+		slog.Debug("on_complete_command finished", "issue", identifier, "stage", stage.Name)
+	}()
+}
+
+// onCompleteEnv returns the AIFLOW_* variables set for an on_complete_command.
+func onCompleteEnv(issueID, identifier, stageName, result, nextState, prURL string) []string {
+	env := []string{
+		"AIFLOW_RESULT=" + result,
+		"AIFLOW_ISSUE_ID=" + issueID,
+		"AIFLOW_ISSUE_IDENTIFIER=" + identifier,
+		"AIFLOW_STAGE_NAME=" + stageName,
+		"AIFLOW_NEXT_STATE=" + nextState,
+	}
+	if prURL != "" {
+		env = append(env, "AIFLOW_PR_URL="+prURL)
+	}
+	return env
+}
@@ -0,0 +1,84 @@
+package orchestrator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+// onCompleteHookYAML adds an on_complete_command to the last stage that
+// writes its AIFLOW_* variables to $HOOK_LOG.
+const onCompleteHookYAML = `    on_complete_command: sh
+    on_complete_args: ["-c", "env | grep ^AIFLOW_ | sort > \"$$HOOK_LOG.tmp\" && mv \"$$HOOK_LOG.tmp\" \"$$HOOK_LOG\""]
+`
+
+// waitForHook returns the hook's environment once it has run.
+func waitForHook(t *testing.T, path string) map[string]string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			env := make(map[string]string)
+			for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+				k, v, _ := strings.Cut(line, "=")
+				env[k] = v
+			}
+			return env
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("on_complete_command never ran")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOnCompleteHookEnv(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		hookLog := filepath.Join(t.TempDir(), "hook")
+		t.Setenv("HOOK_LOG", hookLog)
+		h := newHarness(t, testLinearYAML+implementStageYAML+onCompleteHookYAML)
+		h.withGit()
+		issue := h.issue("In Progress")
+
+		h.process(issue)
+		env := waitForHook(t, hookLog)
+		for k, want := range map[string]string{
+			"AIFLOW_RESULT":           "success",
+			"AIFLOW_NEXT_STATE":       "In Review",
+			"AIFLOW_PR_URL":           testutil.DefaultPRURL,
+			"AIFLOW_ISSUE_ID":         issue.ID,
+			"AIFLOW_ISSUE_IDENTIFIER": issue.Identifier,
+			"AIFLOW_STAGE_NAME":       "implement",
+		} {
+			if env[k] != want {
+				t.Errorf("%s = %q, want %q", k, env[k], want)
+			}
+		}
+		if got := h.state(issue.ID); got != "In Review" {
+			t.Errorf("state = %q, want In Review", got)
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		hookLog := filepath.Join(t.TempDir(), "hook")
+		t.Setenv("HOOK_LOG", hookLog)
+		stage := strings.Replace(implementStageYAML, "echo change > change.txt", "exit 3", 1)
+		h := newHarness(t, testLinearYAML+stage+onCompleteHookYAML)
+		h.withGit()
+		issue := h.issue("In Progress")
+
+		h.process(issue)
+		env := waitForHook(t, hookLog)
+		if env["AIFLOW_RESULT"] != "failed" || env["AIFLOW_NEXT_STATE"] != "Failed" {
+			t.Errorf("AIFLOW_RESULT = %q, AIFLOW_NEXT_STATE = %q; want failed and Failed", env["AIFLOW_RESULT"], env["AIFLOW_NEXT_STATE"])
+		}
+		if _, ok := env["AIFLOW_PR_URL"]; ok {
+			t.Errorf("AIFLOW_PR_URL = %q on a failed run, want it unset", env["AIFLOW_PR_URL"])
+		}
+	})
+}
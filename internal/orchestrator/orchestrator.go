@@ -3,18 +3,25 @@ package orchestrator
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
+	"unicode"
 
 	"github.com/mauza/ai-flow/internal/config"
 	"github.com/mauza/ai-flow/internal/git"
 	"github.com/mauza/ai-flow/internal/linear"
 	"github.com/mauza/ai-flow/internal/store"
 	"github.com/mauza/ai-flow/internal/subprocess"
+	"github.com/mauza/ai-flow/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Orchestrator coordinates webhook events through the pipeline.
@@ -24,6 +31,35 @@ type Orchestrator struct {
 	store  *store.Store
 	runner *subprocess.Runner
 	git    *git.Manager
+
+	statusMu       sync.Mutex
+	statusComments map[string]string // issueID+stage → status comment ID
+
+	consolidatedMu sync.Mutex // serializes read-modify-write of consolidated comments
+
+	cooldownMu       sync.Mutex
+	cooldownNotified map[string]time.Time // issueID+stage → failure already announced as cooling down
+
+	budgetMu       sync.Mutex
+	budgetNotified map[string]bool // issueID → runtime cap already announced
+
+	rerunMu       sync.Mutex
+	rerunNotified map[string]time.Time // issueID+stage → run whose too-soon comments were already acknowledged
+
+	cycleMu       sync.Mutex
+	cycleNotified map[string]bool // issueID → cycle limit already announced
+
+	noRepoMu       sync.Mutex
+	noRepoNotified map[string]bool // issueID+stage → missing repo already announced
+
+	approvalMu      sync.Mutex
+	approvalHandled map[int64]bool // runID → approval timeout already acted on
+
+	pendingMu     sync.Mutex
+	pendingIssues map[string]*debouncing // issueID → latest state change waiting out webhook_debounce
+
+	activeMu   sync.Mutex
+	activeRuns map[string][]*activeRun // issueID → handlers cancelIssue can stop
 }
 
 // New creates a new Orchestrator.
@@ -34,6 +70,16 @@ func New(cfg *config.Config, client *linear.Client, store *store.Store, runner *
 		store:  store,
 		runner: runner,
 		git:    gitMgr,
+
+		statusComments:   make(map[string]string),
+		cooldownNotified: make(map[string]time.Time),
+		budgetNotified:   make(map[string]bool),
+		rerunNotified:    make(map[string]time.Time),
+		cycleNotified:    make(map[string]bool),
+		noRepoNotified:   make(map[string]bool),
+		approvalHandled:  make(map[int64]bool),
+		pendingIssues:    make(map[string]*debouncing),
+		activeRuns:       make(map[string][]*activeRun),
 	}
 }
 
@@ -46,22 +92,30 @@ func (o *Orchestrator) workspacePath(repo, branch string) string {
 	return filepath.Join(o.cfg.Workspace.Root, repo, branch)
 }
 
+// primaryPath returns the shared clone that worktrees for repo are added from.
+// The leading underscore keeps it clear of sanitized branch names.
+func (o *Orchestrator) primaryPath(repo string) string {
+	return filepath.Join(o.cfg.Workspace.Root, repo, "_primary")
+}
+
 // setupWorkspace prepares a workspace directory for a git operation.
 // If persistent workspaces are configured, it reuses or creates the workspace.
 // Otherwise, it creates a temp directory. Returns the work directory and a cleanup
-// function (no-op for persistent workspaces).
-func (o *Orchestrator) setupWorkspace(ctx context.Context, repo, baseBranch, targetBranch, identifier string) (workDir string, cleanup func(), err error) {
+// function (no-op for persistent workspaces). depth is the stage's clone_depth.
+func (o *Orchestrator) setupWorkspace(ctx context.Context, repo, baseBranch, targetBranch, identifier string, depth int) (workDir string, cleanup func(), err error) {
 	wsPath := o.workspacePath(repo, targetBranch)
 	if wsPath != "" {
 		if err := os.MkdirAll(filepath.Dir(wsPath), 0755); err != nil {
 			return "", nil, fmt.Errorf("creating workspace parent: %w", err)
 		}
 
+		// In a worktree .git is a file pointing at the primary clone
 		gitDir := filepath.Join(wsPath, ".git")
-		if info, statErr := os.Stat(gitDir); statErr == nil && info.IsDir() {
+		_, statErr := os.Stat(wsPath)
+		if info, err := os.Stat(gitDir); err == nil && (info.IsDir() || o.cfg.Workspace.UseWorktrees) {
 			// Existing workspace: fetch + reset to clean state
 			slog.Info("reusing persistent workspace", "path", wsPath, "issue", identifier)
-			if err := o.git.Fetch(ctx, wsPath); err != nil {
+			if err := o.git.Fetch(ctx, wsPath, depth); err != nil {
 				return "", nil, fmt.Errorf("fetching in workspace: %w", err)
 			}
 			// Try the target branch first; fall back to base branch if it
@@ -80,12 +134,37 @@ func (o *Orchestrator) setupWorkspace(ctx context.Context, repo, baseBranch, tar
 			return wsPath, func() {}, nil
 		}
 
+		// A directory without .git is left over from an interrupted setup
+		if statErr == nil {
+			slog.Warn("removing incomplete workspace", "path", wsPath, "issue", identifier)
+			if err := os.RemoveAll(wsPath); err != nil {
+				return "", nil, fmt.Errorf("removing incomplete workspace: %w", err)
+			}
+		}
+
 		// First time: clone into workspace dir
 		cloneCtx, cloneCancel := context.WithTimeout(ctx, 2*time.Minute)
 		defer cloneCancel()
-		if err := o.git.Clone(cloneCtx, repo, baseBranch, wsPath); err != nil {
+		if o.cfg.Workspace.UseWorktrees {
+			if err := o.git.AddWorktree(cloneCtx, repo, o.primaryPath(repo), wsPath, baseBranch, depth); err != nil {
+				return "", nil, fmt.Errorf("adding worktree: %w", err)
+			}
+			return wsPath, func() {}, nil
+		}
+		// Clone into a sibling and rename it into place, so an interrupted
+		// clone never looks like a workspace
+		partial, err := os.MkdirTemp(filepath.Dir(wsPath), filepath.Base(wsPath)+partialWorkspaceMarker+"*")
+		if err != nil {
+			return "", nil, fmt.Errorf("creating partial workspace: %w", err)
+		}
+		if err := o.git.Clone(cloneCtx, repo, baseBranch, partial, depth); err != nil {
+			os.RemoveAll(partial)
 			return "", nil, fmt.Errorf("cloning into workspace: %w", err)
 		}
+		if err := os.Rename(partial, wsPath); err != nil {
+			os.RemoveAll(partial)
+			return "", nil, fmt.Errorf("moving clone into workspace: %w", err)
+		}
 		return wsPath, func() {}, nil
 	}
 
@@ -96,35 +175,103 @@ func (o *Orchestrator) setupWorkspace(ctx context.Context, repo, baseBranch, tar
 	}
 	cloneCtx, cloneCancel := context.WithTimeout(ctx, 2*time.Minute)
 	defer cloneCancel()
-	if err := o.git.Clone(cloneCtx, repo, baseBranch, tmpDir); err != nil {
+	if err := o.git.Clone(cloneCtx, repo, baseBranch, tmpDir, depth); err != nil {
 		o.git.Cleanup(tmpDir)
 		return "", nil, fmt.Errorf("cloning repo: %w", err)
 	}
 	return tmpDir, func() { o.git.Cleanup(tmpDir) }, nil
 }
 
+// partialWorkspaceMarker is part of the name of a clone that hasn't been
+// renamed into its workspace directory yet.
+const partialWorkspaceMarker = ".partial-"
+
+// CleanPartialWorkspaces removes clones left mid-way by an interrupted process:
+// in-progress clone directories and workspace directories without a .git.
+// It is meant to run once at startup, before any stage handlers.
+func (o *Orchestrator) CleanPartialWorkspaces() {
+	root := o.cfg.Workspace.Root
+	if root == "" {
+		return
+	}
+	// Workspaces live at root/<owner>/<repo>/<branch>
+	owners, _ := os.ReadDir(root)
+	for _, owner := range owners {
+		if !owner.IsDir() {
+			continue
+		}
+		ownerDir := filepath.Join(root, owner.Name())
+		if mirrors := o.cfg.Workspace.MirrorRoot; mirrors != "" && strings.HasPrefix(filepath.Clean(mirrors)+string(filepath.Separator), ownerDir+string(filepath.Separator)) {
+			continue // bare mirrors have no .git directory
+		}
+		repos, _ := os.ReadDir(ownerDir)
+		for _, repo := range repos {
+			if !repo.IsDir() {
+				continue
+			}
+			repoDir := filepath.Join(ownerDir, repo.Name())
+			entries, _ := os.ReadDir(repoDir)
+			for _, entry := range entries {
+				if !entry.IsDir() {
+					continue
+				}
+				dir := filepath.Join(repoDir, entry.Name())
+				if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil && !strings.Contains(entry.Name(), partialWorkspaceMarker) {
+					continue
+				}
+				slog.Warn("removing incomplete workspace", "path", dir)
+				if err := os.RemoveAll(dir); err != nil {
+					slog.Error("removing incomplete workspace", "path", dir, "error", err)
+				}
+			}
+		}
+	}
+}
+
 // cleanupWorkspaceIfDone removes the persistent workspace directory when the
 // issue transitions to the Done state.
 func (o *Orchestrator) cleanupWorkspaceIfDone(stage *config.StageConfig, repo, branchName string) {
-	if !strings.EqualFold(stage.NextState, "Done") {
+	if !linear.SameState(stage.NextState, "Done") {
 		return
 	}
+	o.removeWorkspace(repo, branchName, "issue done")
+}
+
+// removeWorkspace deletes the persistent workspace for repo and branchName,
+// if there is one.
+func (o *Orchestrator) removeWorkspace(repo, branchName, reason string) {
 	wsPath := o.workspacePath(repo, branchName)
 	if wsPath == "" {
 		return
 	}
-	slog.Info("cleaning up workspace (issue done)", "path", wsPath)
+	if _, err := os.Stat(wsPath); err != nil {
+		return
+	}
+	slog.Info("cleaning up workspace ("+reason+")", "path", wsPath)
+	if o.cfg.Workspace.UseWorktrees {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := o.git.RemoveWorktree(ctx, o.primaryPath(repo), wsPath); err != nil {
+			slog.Warn("removing worktree", "path", wsPath, "error", err)
+			os.RemoveAll(wsPath)
+		}
+		return
+	}
 	os.RemoveAll(wsPath)
 }
 
 // HandleWebhook processes a validated webhook payload through the pipeline.
 func (o *Orchestrator) HandleWebhook(ctx context.Context, payload linear.WebhookPayload) {
+	ctx, span := telemetry.Tracer().Start(ctx, "webhook issue")
+	defer span.End()
+
 	// Parse issue data from payload
 	var issue linear.IssueData
 	if err := json.Unmarshal(payload.Data, &issue); err != nil {
 		slog.Error("parsing issue data from webhook", "error", err)
 		return
 	}
+	span.SetAttributes(attribute.String("issue", issue.Identifier))
 
 	// Check if state actually changed
 	var updatedFrom linear.UpdatedFromData
@@ -134,10 +281,24 @@ func (o *Orchestrator) HandleWebhook(ctx context.Context, payload linear.Webhook
 		}
 	}
 	if updatedFrom.StateID == "" {
+		if prev, changed := updatedFrom.PreviousDescription(); changed {
+			o.handleDescriptionUpdate(ctx, issue, prev)
+			return
+		}
 		slog.Debug("ignoring update without state change", "issue", issue.Identifier)
 		return
 	}
 
+	o.recordStateEntry(issue, payload.CreatedAt)
+
+	if d := o.cfg.Linear.ParsedWebhookDebounce; d > 0 {
+		latest, ok := o.debounceIssue(ctx, issue, d)
+		if !ok {
+			return
+		}
+		issue = latest
+	}
+
 	// Resolve current state name from ID
 	stateName, ok := o.client.ResolveStateName(issue.StateID)
 	if !ok {
@@ -145,15 +306,21 @@ func (o *Orchestrator) HandleWebhook(ctx context.Context, payload linear.Webhook
 		return
 	}
 
+	if o.isCancelState(stateName) {
+		o.cancelIssue(ctx, issue, stateName)
+		return
+	}
+
 	slog.Info("issue state changed",
 		"issue", issue.Identifier,
 		"state", stateName,
 	)
 
-	// Find matching pipeline stage
-	stage := o.cfg.FindStage(stateName)
-	if stage == nil {
-		slog.Debug("no pipeline stage for state", "state", stateName, "issue", issue.Identifier)
+	// Find matching pipeline stage in the issue's team's pipeline. The state
+	// resolved, so the team is one ai-flow serves.
+	team, _ := o.client.TeamKey(issue.TeamID)
+	if o.cfg.FindStage(team, stateName, nil) == nil {
+		slog.Debug("no pipeline stage for state", "team", team, "state", stateName, "issue", issue.Identifier)
 		return
 	}
 
@@ -163,21 +330,64 @@ func (o *Orchestrator) HandleWebhook(ctx context.Context, payload linear.Webhook
 		slog.Error("fetching issue details", "error", err, "issue", issue.Identifier)
 		return
 	}
+	// A delayed or replayed event may describe a state the issue has left;
+	// the event for its current state handles it
+	if details.State.Name != stateName {
+		slog.Info("issue has moved on since the webhook, ignoring",
+			"issue", issue.Identifier,
+			"webhookState", stateName,
+			"currentState", details.State.Name,
+		)
+		return
+	}
+	stage := o.cfg.FindStage(details.Team.Key, stateName, details.LabelNames())
+	if stage == nil {
+		slog.Debug("no pipeline stage for state", "team", details.Team.Key, "state", stateName, "issue", issue.Identifier)
+		return
+	}
 
 	o.ProcessIssue(ctx, details, stage)
 }
 
+// recordStateEntry stores when a state-change webhook says the issue entered
+// its new state: the delivery's createdAt, or now if that is missing.
+func (o *Orchestrator) recordStateEntry(issue linear.IssueData, createdAt string) {
+	stateName, ok := o.client.ResolveStateName(issue.StateID)
+	if !ok {
+		return
+	}
+	enteredAt, err := time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		enteredAt = time.Now()
+	}
+	if err := o.store.RecordStateEntry(issue.ID, stateName, enteredAt); err != nil {
+		slog.Warn("recording state entry", "error", err, "issue", issue.Identifier)
+	}
+}
+
+// stateEnteredAt returns when the issue entered the team's state named state
+// (as the config spells it), or nil if the store doesn't know it to be there.
+func (o *Orchestrator) stateEnteredAt(team, issueID, state string) (*time.Time, error) {
+	// The store keeps the state name as Linear spells it
+	if id, ok := o.client.ResolveStateID(team, state); ok {
+		if name, ok := o.client.ResolveStateName(id); ok {
+			state = name
+		}
+	}
+	return o.store.StateEnteredAt(issueID, state)
+}
+
 // ProcessIssue handles label filtering, dedup, and handler routing for an issue
 // that has been matched to a pipeline stage. Used by both webhook and poll modes.
 func (o *Orchestrator) ProcessIssue(ctx context.Context, details *linear.IssueDetails, stage *config.StageConfig) {
-	// Collect label names
-	var labelNames []string
-	for _, l := range details.Labels.Nodes {
-		labelNames = append(labelNames, l.Name)
+	// Poll mode only learns of a state on first sight
+	if err := o.store.RecordStateSeen(details.ID, details.State.Name, time.Now()); err != nil {
+		slog.Warn("recording issue state", "error", err, "issue", details.Identifier)
 	}
 
 	// Check label filters using resolved label names
-	if !matchesLabels(stage.Labels, labelNames) {
+	labelNames := details.LabelNames()
+	if !stage.MatchesLabels(labelNames) {
 		slog.Debug("issue does not match label filter",
 			"issue", details.Identifier,
 			"stage", stage.Name,
@@ -187,6 +397,37 @@ func (o *Orchestrator) ProcessIssue(ctx context.Context, details *linear.IssueDe
 		return
 	}
 
+	if !o.matchesAssignee(details) {
+		slog.Debug("issue not assigned to assignee_filter user, skipping",
+			"issue", details.Identifier,
+			"stage", stage.Name,
+		)
+		return
+	}
+	if o.createdByBot(details) {
+		slog.Debug("issue created by a bot user, skipping",
+			"issue", details.Identifier,
+			"stage", stage.Name,
+		)
+		return
+	}
+
+	if o.coolingDown(ctx, details, stage) || o.overRuntimeCap(ctx, details, stage) {
+		return
+	}
+
+	reentry, err := o.store.IsStageReentry(details.ID, stage.Name)
+	if err != nil {
+		slog.Warn("checking stage re-entry", "error", err, "issue", details.Identifier)
+	}
+	if reentry && o.overCycleLimit(ctx, details, stage) {
+		return
+	}
+
+	if o.skipMissingRepo(ctx, details, stage) {
+		return
+	}
+
 	// Dedup check
 	runID, inserted, err := o.store.StartRun(details.ID, stage.Name)
 	if err != nil {
@@ -200,6 +441,23 @@ func (o *Orchestrator) ProcessIssue(ctx context.Context, details *linear.IssueDe
 		)
 		return
 	}
+	o.markPendingRunning(ctx)
+	ctx, untrack := o.trackIssue(ctx, details.ID)
+	defer untrack()
+	o.markQueued(ctx, details)
+	defer o.clearStatusLabels(ctx, details)
+
+	if reentry {
+		if cycles, err := o.store.IncrementCycleCount(details.ID); err != nil {
+			slog.Warn("incrementing cycle count", "error", err, "issue", details.Identifier)
+		} else {
+			slog.Info("issue re-entered stage",
+				"issue", details.Identifier,
+				"stage", stage.Name,
+				"cycleCount", cycles,
+			)
+		}
+	}
 
 	slog.Info("starting pipeline stage",
 		"issue", details.Identifier,
@@ -208,15 +466,236 @@ func (o *Orchestrator) ProcessIssue(ctx context.Context, details *linear.IssueDe
 
 	stateName := details.State.Name
 
-	if stage.UsesBranch && o.git != nil {
+	ctx, span := telemetry.Tracer().Start(ctx, "stage "+stage.Name, trace.WithAttributes(
+		attribute.String("issue", details.Identifier),
+	))
+	defer span.End()
+
+	ctx, cancel := o.handlerContext(ctx)
+	defer cancel()
+
+	if o.gitUnavailable(ctx, runID, details, stage) {
+		return
+	}
+
+	if stage.PreviewOnly {
+		o.handlePreview(ctx, runID, details, stage, stateName, labelNames, nil)
+	} else if stage.UsesBranch {
 		o.handleWithExistingBranch(ctx, runID, details, stage, stateName, labelNames)
-	} else if stage.CreatesPR && o.git != nil {
+	} else if stage.CreatesPR {
 		o.handleWithGit(ctx, runID, details, stage, stateName, labelNames)
 	} else {
 		o.handleWithoutGit(ctx, runID, details, stage, stateName, labelNames)
 	}
 }
 
+// gitUnavailable fails the run when the stage needs git but the git manager
+// failed to initialize, rather than silently running the stage without it.
+func (o *Orchestrator) gitUnavailable(ctx context.Context, runID int64, details *linear.IssueDetails, stage *config.StageConfig) bool {
+	if o.git != nil || !(stage.CreatesPR || stage.UsesBranch) {
+		return false
+	}
+	slog.Error("stage requires git but git is unavailable",
+		"issue", details.Identifier,
+		"stage", stage.Name,
+	)
+	msg := "git unavailable: stage requires git (creates_pr or uses_branch) but the git manager failed to initialize"
+	o.failRun(ctx, runID, -1, msg)
+	o.failAndTransition(ctx, details, stage, msg)
+	return true
+}
+
+// skipMissingRepo reports whether a git stage should be skipped because the
+// issue names no repo and pipeline.on_missing_repo is "skip". The first skip
+// per issue and stage posts a comment; later ones are only logged.
+func (o *Orchestrator) skipMissingRepo(ctx context.Context, details *linear.IssueDetails, stage *config.StageConfig) bool {
+	if o.cfg.Pipeline.OnMissingRepo != "skip" || !(stage.CreatesPR || stage.UsesBranch || stage.PreviewOnly) {
+		return false
+	}
+	projectName := ""
+	if details.Project != nil {
+		projectName = details.Project.Name
+	}
+	if _, ok := o.cfg.RepoFor(projectName, details.Team.Key); ok {
+		return false
+	}
+	if _, err := linear.ParseIssueMeta(details.Description); err == nil {
+		return false
+	}
+
+	slog.Info("issue has no repo for git stage, skipping",
+		"issue", details.Identifier,
+		"stage", stage.Name,
+		"project", projectName,
+	)
+
+	key := statusKey(details.ID, stage.Name)
+	o.noRepoMu.Lock()
+	announced := o.noRepoNotified[key]
+	o.noRepoNotified[key] = true
+	o.noRepoMu.Unlock()
+	if announced {
+		return true
+	}
+
+	msg := fmt.Sprintf("**ai-flow: stage `%s` skipped** — it works in a git repository, but this issue doesn't name one. Add the issue to a project listed in `projects`, or put `github_repo` in its description, for the stage to run",
+		stage.Name)
+	if err := o.client.PostComment(ctx, details.ID, msg); err != nil {
+		slog.Error("posting missing repo comment", "error", err, "issue", details.Identifier)
+	}
+	return true
+}
+
+// matchesAssignee reports whether the issue passes linear.assignee_filter.
+// With a filter set, only issues assigned to that user (by ID or email) match.
+func (o *Orchestrator) matchesAssignee(details *linear.IssueDetails) bool {
+	filter := o.cfg.Linear.AssigneeFilter
+	if filter == "" {
+		return true
+	}
+	if details.Assignee == nil {
+		return false
+	}
+	return details.Assignee.ID == filter || strings.EqualFold(details.Assignee.Email, filter)
+}
+
+// createdByBot reports whether linear.skip_bot_issues applies to the issue:
+// its creator is one of linear.bot_users (by ID or email).
+func (o *Orchestrator) createdByBot(details *linear.IssueDetails) bool {
+	if !o.cfg.Linear.SkipBotIssues || details.Creator == nil {
+		return false
+	}
+	for _, bot := range o.cfg.Linear.BotUsers {
+		if details.Creator.ID == bot || strings.EqualFold(details.Creator.Email, bot) {
+			return true
+		}
+	}
+	return false
+}
+
+// overRuntimeCap reports whether the issue's runs have used up
+// pipeline.max_runtime_per_issue. The first refusal posts a comment; later
+// ones are only logged.
+func (o *Orchestrator) overRuntimeCap(ctx context.Context, details *linear.IssueDetails, stage *config.StageConfig) bool {
+	limit := o.cfg.Pipeline.ParsedMaxRuntimePerIssue
+	if limit <= 0 {
+		return false
+	}
+	used, err := o.store.TotalRuntimeForIssue(details.ID)
+	if err != nil {
+		slog.Warn("checking issue runtime", "error", err, "issue", details.Identifier)
+		return false
+	}
+	if used < limit {
+		return false
+	}
+
+	slog.Warn("issue exceeded max_runtime_per_issue, skipping",
+		"issue", details.Identifier,
+		"stage", stage.Name,
+		"used", used.Round(time.Second),
+		"limit", limit,
+	)
+
+	o.budgetMu.Lock()
+	announced := o.budgetNotified[details.ID]
+	o.budgetNotified[details.ID] = true
+	o.budgetMu.Unlock()
+	if announced {
+		return true
+	}
+
+	msg := fmt.Sprintf("**ai-flow: runtime limit reached** — runs on this issue have used %s of the %s allowed by `max_runtime_per_issue`, so stage `%s` will not run",
+		used.Round(time.Second), limit, stage.Name)
+	if err := o.client.PostComment(ctx, details.ID, msg); err != nil {
+		slog.Error("posting runtime limit comment", "error", err, "issue", details.Identifier)
+	}
+	return true
+}
+
+// overCycleLimit reports whether an issue re-entering a stage has already
+// looped pipeline.max_cycles times. The first refusal posts a comment; later
+// ones are only logged.
+func (o *Orchestrator) overCycleLimit(ctx context.Context, details *linear.IssueDetails, stage *config.StageConfig) bool {
+	limit := o.cfg.Pipeline.MaxCycles
+	if limit <= 0 {
+		return false
+	}
+	cycles, err := o.store.CycleCount(details.ID)
+	if err != nil {
+		slog.Warn("checking cycle count", "error", err, "issue", details.Identifier)
+		return false
+	}
+	if cycles < limit {
+		return false
+	}
+
+	slog.Warn("issue exceeded max_cycles, skipping",
+		"issue", details.Identifier,
+		"stage", stage.Name,
+		"cycleCount", cycles,
+		"limit", limit,
+	)
+
+	o.cycleMu.Lock()
+	announced := o.cycleNotified[details.ID]
+	o.cycleNotified[details.ID] = true
+	o.cycleMu.Unlock()
+	if announced {
+		return true
+	}
+
+	msg := fmt.Sprintf("**ai-flow: cycle limit reached** — this issue has looped back through the pipeline %d times, the most `max_cycles` allows, so stage `%s` will not run",
+		cycles, stage.Name)
+	if err := o.client.PostComment(ctx, details.ID, msg); err != nil {
+		slog.Error("posting cycle limit comment", "error", err, "issue", details.Identifier)
+	}
+	return true
+}
+
+// coolingDown reports whether the stage failed for this issue less than
+// failure_cooldown ago. The first blocked attempt after each failure posts a
+// comment saying when the stage can be retried; later ones are only logged.
+func (o *Orchestrator) coolingDown(ctx context.Context, details *linear.IssueDetails, stage *config.StageConfig) bool {
+	if stage.ParsedFailureCooldown <= 0 {
+		return false
+	}
+	failedAt, err := o.store.LastFailureTime(details.ID, stage.Name)
+	if err != nil {
+		slog.Warn("checking failure cooldown", "error", err, "issue", details.Identifier)
+		return false
+	}
+	if failedAt == nil {
+		return false
+	}
+	retryAfter := failedAt.Add(stage.ParsedFailureCooldown)
+	if !time.Now().Before(retryAfter) {
+		return false
+	}
+
+	slog.Info("stage cooling down after failure, skipping",
+		"issue", details.Identifier,
+		"stage", stage.Name,
+		"retryAfter", retryAfter,
+	)
+
+	key := statusKey(details.ID, stage.Name)
+	o.cooldownMu.Lock()
+	announced := o.cooldownNotified[key].Equal(*failedAt)
+	o.cooldownNotified[key] = *failedAt
+	o.cooldownMu.Unlock()
+	if announced {
+		return true
+	}
+
+	msg := fmt.Sprintf("**ai-flow: stage `%s` cooling down** after a recent failure, retry after %s",
+		stage.Name, retryAfter.UTC().Format(time.RFC3339))
+	if err := o.client.PostComment(ctx, details.ID, msg); err != nil {
+		slog.Error("posting cooldown comment", "error", err, "issue", details.Identifier)
+	}
+	return true
+}
+
 func (o *Orchestrator) handleWithoutGit(ctx context.Context, runID int64, details *linear.IssueDetails, stage *config.StageConfig, stateName string, labelNames []string) {
 	input := o.buildInput(details, stage, stateName, labelNames)
 	input.RunID = runID
@@ -229,32 +708,33 @@ func (o *Orchestrator) handleWithoutGit(ctx context.Context, runID int64, detail
 		input.Comments = convertComments(commentNodes)
 	}
 
-	result, err := o.runner.Run(ctx, input)
+	result, err := o.runStageSubprocess(ctx, details, input)
 	if err != nil {
 		slog.Error("subprocess execution error",
 			"error", err,
 			"issue", details.Identifier,
 			"stage", stage.Name,
 		)
-		o.store.TimeoutRun(runID, err.Error())
-		o.failAndTransition(ctx, details.ID, details.Identifier, stage, err.Error())
+		o.recordRunError(ctx, runID, err)
+		o.failAndTransition(ctx, details, stage, err.Error())
 		return
 	}
 
 	switch result.ExitCode {
 	case 0:
+		output := successOutput(stage, result)
 		slog.Info("subprocess succeeded",
 			"issue", details.Identifier,
 			"stage", stage.Name,
 		)
-		o.store.CompleteRun(runID, 0, result.Stdout, "", "")
+		o.store.CompleteRun(runID, 0, output, "", "")
 		if stage.WaitForApproval {
-			comment := formatSuccessComment(stage.Name, result.Stdout, "")
-			if err := o.client.PostComment(ctx, details.ID, comment); err != nil {
+			comment := formatSuccessComment(stage.Name, output, "")
+			if err := o.postOutput(ctx, details.ID, details.Identifier, stage, comment); err != nil {
 				slog.Error("posting comment", "error", err, "issue", details.Identifier)
 			}
 		} else {
-			o.transitionAndComment(ctx, details.ID, details.Identifier, stage, result.Stdout, "")
+			o.transitionAndComment(ctx, details, stage, output, "")
 		}
 
 	case 2:
@@ -262,49 +742,144 @@ func (o *Orchestrator) handleWithoutGit(ctx context.Context, runID int64, detail
 			"issue", details.Identifier,
 			"stage", stage.Name,
 		)
-		o.store.CompleteRun(runID, 2, "skipped", "", "")
+		o.store.SkipRun(runID, "", "")
+		o.settleStatus(ctx, details.ID, stage.Name, fmt.Sprintf("**ai-flow: stage `%s` skipped**", stage.Name))
 
 	default:
 		slog.Warn("subprocess failed",
 			"issue", details.Identifier,
 			"stage", stage.Name,
 			"exitCode", result.ExitCode,
-			"stderr", result.Stderr,
+			"stderr", logContent(o.cfg, result.Stderr),
 		)
 		errMsg := result.Stderr
 		if errMsg == "" {
 			errMsg = result.Stdout
 		}
-		o.store.FailRun(runID, result.ExitCode, errMsg)
-		o.failAndTransition(ctx, details.ID, details.Identifier, stage, errMsg)
+		o.failRun(ctx, runID, result.ExitCode, errMsg)
+		o.failAndTransition(ctx, details, stage, errMsg)
+	}
+}
+
+// handlerContext bounds an entire stage handler (git operations and subprocess)
+// by pipeline.handler_timeout. Without a configured timeout it returns ctx as-is.
+func (o *Orchestrator) handlerContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.cfg.Pipeline.ParsedHandlerTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, o.cfg.Pipeline.ParsedHandlerTimeout)
+}
+
+// recordRunError records a run whose subprocess returned an error: a timeout
+// as timed out, anything else (e.g. the command failing to start) as failed.
+func (o *Orchestrator) recordRunError(ctx context.Context, runID int64, err error) {
+	if errors.Is(err, subprocess.ErrTimeout) {
+		o.store.TimeoutRun(runID, err.Error())
+		return
+	}
+	o.failRun(ctx, runID, -1, err.Error())
+}
+
+// failRun records a failed run, or a timed-out or canceled run if the handler
+// context expired or the issue was canceled while the failing operation was in
+// progress.
+func (o *Orchestrator) failRun(ctx context.Context, runID int64, exitCode int, errMsg string) {
+	if canceled(ctx) {
+		o.store.CancelRun(runID, "issue canceled: "+errMsg)
+		return
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		o.store.TimeoutRun(runID, fmt.Sprintf("handler timed out after %s: %s", o.cfg.Pipeline.ParsedHandlerTimeout, errMsg))
+		return
+	}
+	o.store.FailRun(runID, exitCode, errMsg)
+}
+
+// reportContext returns a context usable for reporting an outcome back to
+// Linear, even when ctx has already been cancelled by the handler timeout.
+func reportContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx.Err() == nil {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+}
+
+// resolveRepoConfig returns the GitHub repo and base branch for an issue, from
+// the config's projects map if the issue's project or team is listed there
+// (honoring base_branch_by_label), otherwise from metadata in the issue's description.
+// When neither names a branch, the repo's default branch is detected.
+func (o *Orchestrator) resolveRepoConfig(ctx context.Context, details *linear.IssueDetails) (repo, branch string, err error) {
+	projectName := ""
+	if details.Project != nil {
+		projectName = details.Project.Name
+	}
+	if p, ok := o.cfg.RepoFor(projectName, details.Team.Key); ok {
+		repo, branch = p.GithubRepo, p.BaseBranchFor(details.LabelNames())
+	} else {
+		meta, err := linear.ParseIssueMeta(details.Description)
+		if err != nil {
+			return "", "", fmt.Errorf("issue %s: %w", details.Identifier, err)
+		}
+		repo, branch = meta.GithubRepo, meta.DefaultBranch
+	}
+	if branch == "" {
+		branch = o.detectDefaultBranch(ctx, repo)
 	}
+	return repo, branch, nil
 }
 
-// resolveRepoConfig extracts GitHub repo metadata from the issue's description.
-func resolveRepoConfig(details *linear.IssueDetails) (repo, branch string, err error) {
-	meta, err := linear.ParseIssueMeta(details.Description)
+// detectDefaultBranch asks the remote for repo's default branch, falling back
+// to "main" if it can't be determined.
+func (o *Orchestrator) detectDefaultBranch(ctx context.Context, repo string) string {
+	if o.git == nil {
+		return "main"
+	}
+	branch, err := o.git.DefaultBranch(ctx, repo)
 	if err != nil {
-		return "", "", fmt.Errorf("issue %s: %w", details.Identifier, err)
+		slog.Warn("detecting default branch, assuming main", "error", err, "repo", repo)
+		return "main"
 	}
-	return meta.GithubRepo, meta.DefaultBranch, nil
+	return branch
+}
+
+// stackBranch returns the base and head branches for a stage. For branch_from
+// "previous" it stacks on the issue's previous branch, branching off it and
+// targeting it in the PR; otherwise baseBranch and branchName are unchanged.
+func (o *Orchestrator) stackBranch(details *linear.IssueDetails, stage *config.StageConfig, baseBranch, branchName string) (string, string) {
+	if stage.BranchFrom != "previous" {
+		return baseBranch, branchName
+	}
+	prev, err := o.store.GetPreviousBranchForIssue(details.ID, stage.Name)
+	if err != nil {
+		slog.Warn("looking up previous branch", "error", err, "issue", details.Identifier)
+	}
+	if prev == nil {
+		slog.Info("no previous branch to stack on, branching from base", "baseBranch", baseBranch, "issue", details.Identifier)
+		return baseBranch, branchName
+	}
+	stacked := git.StackedBranchName(prev.BranchName, stage.Name)
+	slog.Info("stacking branch on previous stage", "parent", prev.BranchName, "branch", stacked, "issue", details.Identifier)
+	return prev.BranchName, stacked
 }
 
 func (o *Orchestrator) handleWithGit(ctx context.Context, runID int64, details *linear.IssueDetails, stage *config.StageConfig, stateName string, labelNames []string) {
 	branchName := git.SanitizeBranchName(details.Identifier, details.Title)
-	repo, baseBranch, err := resolveRepoConfig(details)
+	repo, baseBranch, err := o.resolveRepoConfig(ctx, details)
 	if err != nil {
 		slog.Error("resolving repo config", "error", err, "issue", details.Identifier)
-		o.store.FailRun(runID, -1, err.Error())
-		o.failAndTransition(ctx, details.ID, details.Identifier, stage, err.Error())
+		o.failRun(ctx, runID, -1, err.Error())
+		o.failAndTransition(ctx, details, stage, err.Error())
 		return
 	}
 
+	baseBranch, branchName = o.stackBranch(details, stage, baseBranch, branchName)
+
 	// Set up workspace (persistent or temp)
-	workDir, cleanup, err := o.setupWorkspace(ctx, repo, baseBranch, branchName, details.Identifier)
+	workDir, cleanup, err := o.setupWorkspace(ctx, repo, baseBranch, branchName, details.Identifier, *stage.CloneDepth)
 	if err != nil {
 		slog.Error("setting up workspace", "error", err, "issue", details.Identifier)
-		o.store.FailRun(runID, -1, err.Error())
-		o.failAndTransition(ctx, details.ID, details.Identifier, stage, "failed to set up workspace: "+err.Error())
+		o.failRun(ctx, runID, -1, err.Error())
+		o.failAndTransition(ctx, details, stage, "failed to set up workspace: "+err.Error())
 		return
 	}
 	defer cleanup()
@@ -316,24 +891,31 @@ func (o *Orchestrator) handleWithGit(ctx context.Context, runID int64, details *
 		branchExists = false
 	}
 
-	// Look up existing PR URL from previous runs
+	// Look up existing PR URL from previous runs. A stacked branch has its own
+	// PR, recorded on this stage's runs rather than the issue's first branch.
 	prURL := ""
 	if branchExists {
-		if prevRun, err := o.store.GetFirstBranchForIssue(details.ID); err == nil && prevRun != nil {
+		var prevRun *store.RunInfo
+		if stage.BranchFrom == "previous" {
+			prevRun, err = o.store.GetLastCompletedRun(details.ID, stage.Name)
+		} else {
+			prevRun, err = o.store.GetFirstBranchForIssue(details.ID)
+		}
+		if err == nil && prevRun != nil {
 			prURL = prevRun.PRURL
 		}
 		if err := o.git.FetchAndCheckout(ctx, workDir, branchName); err != nil {
 			slog.Error("fetching existing branch", "error", err, "issue", details.Identifier, "branch", branchName)
-			o.store.FailRun(runID, -1, err.Error())
-			o.failAndTransition(ctx, details.ID, details.Identifier, stage, "failed to fetch existing branch: "+err.Error())
+			o.failRun(ctx, runID, -1, err.Error())
+			o.failAndTransition(ctx, details, stage, "failed to fetch existing branch: "+err.Error())
 			return
 		}
 		slog.Info("reusing existing branch", "branch", branchName, "issue", details.Identifier)
 	} else {
 		if err := o.git.CreateBranch(ctx, workDir, branchName); err != nil {
 			slog.Error("creating branch", "error", err, "issue", details.Identifier)
-			o.store.FailRun(runID, -1, err.Error())
-			o.failAndTransition(ctx, details.ID, details.Identifier, stage, "failed to create branch: "+err.Error())
+			o.failRun(ctx, runID, -1, err.Error())
+			o.failAndTransition(ctx, details, stage, "failed to create branch: "+err.Error())
 			return
 		}
 	}
@@ -342,7 +924,9 @@ func (o *Orchestrator) handleWithGit(ctx context.Context, runID int64, details *
 	input := o.buildInput(details, stage, stateName, labelNames)
 	input.RunID = runID
 	input.WorkDir = workDir
+	input.AttachmentsDir = o.downloadAttachments(ctx, details, workDir)
 	input.BranchName = branchName
+	input.PRURL = prURL
 
 	// Fetch cross-stage comments for context
 	commentNodes, err := o.client.GetIssueComments(ctx, details.ID)
@@ -352,27 +936,34 @@ func (o *Orchestrator) handleWithGit(ctx context.Context, runID int64, details *
 		input.Comments = convertComments(commentNodes)
 	}
 
-	result, err := o.runner.Run(ctx, input)
+	snap := o.snapshotWorkspace(ctx, workDir, details.Identifier)
+	defer snap.rollback(ctx)
+
+	result, err := o.runStageSubprocess(ctx, details, input)
 	if err != nil {
 		slog.Error("subprocess execution error",
 			"error", err,
 			"issue", details.Identifier,
 			"stage", stage.Name,
 		)
-		o.store.TimeoutRun(runID, err.Error())
-		o.failAndTransition(ctx, details.ID, details.Identifier, stage, err.Error())
+		o.recordRunError(ctx, runID, err)
+		o.failAndTransition(ctx, details, stage, err.Error())
 		return
 	}
 
 	switch result.ExitCode {
 	case 0:
+		output := successOutput(stage, result)
+		if stage.ReviewCommand != "" && !o.reviewPass(ctx, runID, details, stage, input, output, o.failAndTransition) {
+			return
+		}
 		if branchExists {
 			// Push to existing branch, create PR if needed
-			newPRURL, pushed, err := o.commitPushAndEnsurePR(ctx, workDir, branchName, baseBranch, details, stage.Name, prURL)
+			newPRURL, pushed, err := o.commitPushAndEnsurePR(ctx, repo, workDir, branchName, baseBranch, details, stage, prURL)
 			if err != nil {
 				slog.Error("commit/push/PR failed (cycling)", "error", err, "issue", details.Identifier)
-				o.store.FailRun(runID, -1, err.Error())
-				o.failAndTransition(ctx, details.ID, details.Identifier, stage, "subprocess succeeded but git operations failed: "+err.Error())
+				o.failRun(ctx, runID, -1, err.Error())
+				o.failAndTransition(ctx, details, stage, "subprocess succeeded but git operations failed: "+err.Error())
 				return
 			}
 			prURL = newPRURL
@@ -381,11 +972,11 @@ func (o *Orchestrator) handleWithGit(ctx context.Context, runID int64, details *
 			}
 		} else {
 			var err error
-			prURL, err = o.commitAndCreatePR(ctx, workDir, branchName, baseBranch, details)
+			prURL, err = o.commitAndCreatePR(ctx, repo, workDir, branchName, baseBranch, details, stage)
 			if err != nil {
 				slog.Error("creating PR", "error", err, "issue", details.Identifier)
-				o.store.FailRun(runID, -1, err.Error())
-				o.failAndTransition(ctx, details.ID, details.Identifier, stage, "subprocess succeeded but PR creation failed: "+err.Error())
+				o.failRun(ctx, runID, -1, err.Error())
+				o.failAndTransition(ctx, details, stage, "subprocess succeeded but PR creation failed: "+err.Error())
 				return
 			}
 
@@ -403,14 +994,15 @@ func (o *Orchestrator) handleWithGit(ctx context.Context, runID int64, details *
 			"stage", stage.Name,
 			"prURL", prURL,
 		)
-		o.store.CompleteRun(runID, 0, result.Stdout, prURL, branchName)
+		o.store.CompleteRun(runID, 0, output, prURL, branchName)
+		snap.keep()
 		if stage.WaitForApproval {
-			comment := formatSuccessComment(stage.Name, result.Stdout, prURL)
-			if err := o.client.PostComment(ctx, details.ID, comment); err != nil {
+			comment := formatSuccessComment(stage.Name, output, prURL)
+			if err := o.postOutput(ctx, details.ID, details.Identifier, stage, comment); err != nil {
 				slog.Error("posting comment", "error", err, "issue", details.Identifier)
 			}
 		} else {
-			o.transitionAndComment(ctx, details.ID, details.Identifier, stage, result.Stdout, prURL)
+			o.transitionAndComment(ctx, details, stage, output, prURL)
 			o.cleanupWorkspaceIfDone(stage, repo, branchName)
 		}
 
@@ -419,30 +1011,32 @@ func (o *Orchestrator) handleWithGit(ctx context.Context, runID int64, details *
 			"issue", details.Identifier,
 			"stage", stage.Name,
 		)
-		o.store.CompleteRun(runID, 2, "skipped", "", branchName)
+		o.store.SkipRun(runID, "", branchName)
+		snap.keep()
+		o.settleStatus(ctx, details.ID, stage.Name, fmt.Sprintf("**ai-flow: stage `%s` skipped**", stage.Name))
 
 	default:
 		slog.Warn("subprocess failed",
 			"issue", details.Identifier,
 			"stage", stage.Name,
 			"exitCode", result.ExitCode,
-			"stderr", result.Stderr,
+			"stderr", logContent(o.cfg, result.Stderr),
 		)
 		errMsg := result.Stderr
 		if errMsg == "" {
 			errMsg = result.Stdout
 		}
-		o.store.FailRun(runID, result.ExitCode, errMsg)
-		o.failAndTransition(ctx, details.ID, details.Identifier, stage, errMsg)
+		o.failRun(ctx, runID, result.ExitCode, errMsg)
+		o.failAndTransition(ctx, details, stage, errMsg)
 	}
 }
 
 func (o *Orchestrator) handleWithExistingBranch(ctx context.Context, runID int64, details *linear.IssueDetails, stage *config.StageConfig, stateName string, labelNames []string) {
-	repo, baseBranch, err := resolveRepoConfig(details)
+	repo, baseBranch, err := o.resolveRepoConfig(ctx, details)
 	if err != nil {
 		slog.Error("resolving repo config", "error", err, "issue", details.Identifier)
-		o.store.FailRun(runID, -1, err.Error())
-		o.failAndTransition(ctx, details.ID, details.Identifier, stage, err.Error())
+		o.failRun(ctx, runID, -1, err.Error())
+		o.failAndTransition(ctx, details, stage, err.Error())
 		return
 	}
 
@@ -450,27 +1044,41 @@ func (o *Orchestrator) handleWithExistingBranch(ctx context.Context, runID int64
 	prevRun, err := o.store.GetFirstBranchForIssue(details.ID)
 	if err != nil {
 		slog.Error("looking up branch for issue", "error", err, "issue", details.Identifier)
-		o.store.FailRun(runID, -1, err.Error())
-		o.failAndTransition(ctx, details.ID, details.Identifier, stage, "failed to look up branch: "+err.Error())
+		o.failRun(ctx, runID, -1, err.Error())
+		o.failAndTransition(ctx, details, stage, "failed to look up branch: "+err.Error())
 		return
 	}
-	if prevRun == nil || prevRun.BranchName == "" {
+	var branchName, prURL string
+	bootstrapped := false
+	switch {
+	case prevRun != nil && prevRun.BranchName != "":
+		branchName = prevRun.BranchName
+		prURL = prevRun.PRURL
+	case stage.CreateBranchIfMissing:
+		// Start the branch a creates_pr stage would have made; it is created
+		// from the base branch below, like any branch that was never pushed
+		branchName = git.SanitizeBranchName(details.Identifier, details.Title)
+		bootstrapped = true
+		slog.Info("no existing branch for issue, starting one from base",
+			"issue", details.Identifier,
+			"stage", stage.Name,
+			"branch", branchName,
+			"baseBranch", baseBranch,
+		)
+	default:
 		errMsg := "no existing branch found for this issue"
 		slog.Error(errMsg, "issue", details.Identifier, "stage", stage.Name)
-		o.store.FailRun(runID, -1, errMsg)
-		o.failAndTransition(ctx, details.ID, details.Identifier, stage, errMsg)
+		o.failRun(ctx, runID, -1, errMsg)
+		o.failAndTransition(ctx, details, stage, errMsg)
 		return
 	}
 
-	branchName := prevRun.BranchName
-	prURL := prevRun.PRURL
-
 	// Set up workspace (persistent or temp)
-	workDir, cleanup, err := o.setupWorkspace(ctx, repo, baseBranch, branchName, details.Identifier)
+	workDir, cleanup, err := o.setupWorkspace(ctx, repo, baseBranch, branchName, details.Identifier, *stage.CloneDepth)
 	if err != nil {
 		slog.Error("setting up workspace", "error", err, "issue", details.Identifier)
-		o.store.FailRun(runID, -1, err.Error())
-		o.failAndTransition(ctx, details.ID, details.Identifier, stage, "failed to set up workspace: "+err.Error())
+		o.failRun(ctx, runID, -1, err.Error())
+		o.failAndTransition(ctx, details, stage, "failed to set up workspace: "+err.Error())
 		return
 	}
 	defer cleanup()
@@ -480,19 +1088,45 @@ func (o *Orchestrator) handleWithExistingBranch(ctx context.Context, runID int64
 	if err != nil {
 		slog.Warn("checking remote branch", "error", err, "issue", details.Identifier)
 	}
+
+	// A branch that had a PR but is gone from the remote was deleted, usually
+	// after its PR was merged
+	if err == nil && !branchOnRemote && prURL != "" {
+		if stage.OnMissingBranch != "recreate" {
+			errMsg := fmt.Sprintf("branch %s no longer exists on the remote (its PR may have been merged and the branch deleted): %s", branchName, prURL)
+			slog.Error("branch deleted on remote", "issue", details.Identifier, "stage", stage.Name, "branch", branchName)
+			o.failRun(ctx, runID, -1, errMsg)
+			o.failAndTransition(ctx, details, stage, errMsg)
+			return
+		}
+		slog.Info("branch deleted on remote, recreating from base",
+			"issue", details.Identifier,
+			"branch", branchName,
+			"baseBranch", baseBranch,
+		)
+		if err := o.git.RecreateBranch(ctx, workDir, branchName, baseBranch); err != nil {
+			slog.Error("recreating branch", "error", err, "issue", details.Identifier)
+			o.failRun(ctx, runID, -1, err.Error())
+			o.failAndTransition(ctx, details, stage, "failed to recreate branch: "+err.Error())
+			return
+		}
+		// The old PR is closed or merged; a new one is opened on push
+		prURL = ""
+	}
+
 	if branchOnRemote {
 		if err := o.git.FetchAndCheckout(ctx, workDir, branchName); err != nil {
 			slog.Error("fetching existing branch", "error", err, "issue", details.Identifier, "branch", branchName)
-			o.store.FailRun(runID, -1, err.Error())
-			o.failAndTransition(ctx, details.ID, details.Identifier, stage, "failed to fetch branch: "+err.Error())
+			o.failRun(ctx, runID, -1, err.Error())
+			o.failAndTransition(ctx, details, stage, "failed to fetch branch: "+err.Error())
 			return
 		}
 	} else {
 		// Branch was never pushed — create it locally
 		if err := o.git.CreateBranch(ctx, workDir, branchName); err != nil {
 			slog.Error("creating branch", "error", err, "issue", details.Identifier)
-			o.store.FailRun(runID, -1, err.Error())
-			o.failAndTransition(ctx, details.ID, details.Identifier, stage, "failed to create branch: "+err.Error())
+			o.failRun(ctx, runID, -1, err.Error())
+			o.failAndTransition(ctx, details, stage, "failed to create branch: "+err.Error())
 			return
 		}
 	}
@@ -501,7 +1135,9 @@ func (o *Orchestrator) handleWithExistingBranch(ctx context.Context, runID int64
 	input := o.buildInput(details, stage, stateName, labelNames)
 	input.RunID = runID
 	input.WorkDir = workDir
+	input.AttachmentsDir = o.downloadAttachments(ctx, details, workDir)
 	input.BranchName = branchName
+	input.PRURL = prURL
 
 	commentNodes, err := o.client.GetIssueComments(ctx, details.ID)
 	if err != nil {
@@ -510,45 +1146,64 @@ func (o *Orchestrator) handleWithExistingBranch(ctx context.Context, runID int64
 		input.Comments = convertComments(commentNodes)
 	}
 
-	result, err := o.runner.Run(ctx, input)
+	snap := o.snapshotWorkspace(ctx, workDir, details.Identifier)
+	defer snap.rollback(ctx)
+
+	result, err := o.runStageSubprocess(ctx, details, input)
 	if err != nil {
 		slog.Error("subprocess execution error",
 			"error", err,
 			"issue", details.Identifier,
 			"stage", stage.Name,
 		)
-		o.store.TimeoutRun(runID, err.Error())
-		o.failAndTransition(ctx, details.ID, details.Identifier, stage, err.Error())
+		o.recordRunError(ctx, runID, err)
+		o.failAndTransition(ctx, details, stage, err.Error())
 		return
 	}
 
 	switch result.ExitCode {
 	case 0:
-		newPRURL, pushed, err := o.commitPushAndEnsurePR(ctx, workDir, branchName, baseBranch, details, stage.Name, prURL)
+		output := successOutput(stage, result)
+		if stage.ReviewCommand != "" && !o.reviewPass(ctx, runID, details, stage, input, output, o.failAndTransition) {
+			return
+		}
+		var newPRURL string
+		var pushed bool
+		if bootstrapped {
+			// A bootstrapped branch is only pushed; opening its PR is left
+			// to a later stage
+			pushed, err = o.commitAndPush(ctx, repo, workDir, branchName, baseBranch, details, stage.Name)
+		} else {
+			newPRURL, pushed, err = o.commitPushAndEnsurePR(ctx, repo, workDir, branchName, baseBranch, details, stage, prURL)
+		}
 		if err != nil {
 			slog.Error("commit/push/PR failed", "error", err, "issue", details.Identifier)
-			o.store.FailRun(runID, -1, err.Error())
-			o.failAndTransition(ctx, details.ID, details.Identifier, stage, "subprocess succeeded but git operations failed: "+err.Error())
+			o.failRun(ctx, runID, -1, err.Error())
+			o.failAndTransition(ctx, details, stage, "subprocess succeeded but git operations failed: "+err.Error())
 			return
 		}
 		prURL = newPRURL
 		if pushed && prURL != "" {
 			o.commentOnPR(ctx, workDir, prURL, stage.Name, details.Identifier)
 		}
+		if stage.MergesPR && !o.mergePR(ctx, runID, workDir, prURL, details, stage) {
+			return
+		}
 
 		slog.Info("subprocess succeeded",
 			"issue", details.Identifier,
 			"stage", stage.Name,
 			"prURL", prURL,
 		)
-		o.store.CompleteRun(runID, 0, result.Stdout, prURL, branchName)
+		o.store.CompleteRun(runID, 0, output, prURL, branchName)
+		snap.keep()
 		if stage.WaitForApproval {
-			comment := formatSuccessComment(stage.Name, result.Stdout, prURL)
-			if err := o.client.PostComment(ctx, details.ID, comment); err != nil {
+			comment := formatSuccessComment(stage.Name, output, prURL)
+			if err := o.postOutput(ctx, details.ID, details.Identifier, stage, comment); err != nil {
 				slog.Error("posting comment", "error", err, "issue", details.Identifier)
 			}
 		} else {
-			o.transitionAndComment(ctx, details.ID, details.Identifier, stage, result.Stdout, prURL)
+			o.transitionAndComment(ctx, details, stage, output, prURL)
 			o.cleanupWorkspaceIfDone(stage, repo, branchName)
 		}
 
@@ -557,40 +1212,160 @@ func (o *Orchestrator) handleWithExistingBranch(ctx context.Context, runID int64
 			"issue", details.Identifier,
 			"stage", stage.Name,
 		)
-		o.store.CompleteRun(runID, 2, "skipped", prURL, branchName)
+		o.store.SkipRun(runID, prURL, branchName)
+		snap.keep()
+		o.settleStatus(ctx, details.ID, stage.Name, fmt.Sprintf("**ai-flow: stage `%s` skipped**", stage.Name))
 
 	default:
 		slog.Warn("subprocess failed",
 			"issue", details.Identifier,
 			"stage", stage.Name,
 			"exitCode", result.ExitCode,
-			"stderr", result.Stderr,
+			"stderr", logContent(o.cfg, result.Stderr),
 		)
 		errMsg := result.Stderr
 		if errMsg == "" {
 			errMsg = result.Stdout
 		}
-		o.store.FailRun(runID, result.ExitCode, errMsg)
-		o.failAndTransition(ctx, details.ID, details.Identifier, stage, errMsg)
+		o.failRun(ctx, runID, result.ExitCode, errMsg)
+		o.failAndTransition(ctx, details, stage, errMsg)
+	}
+}
+
+// reviewPass runs the stage's review command in the same workspace, with the
+// main pass's output as context, before anything is committed. On rejection or
+// error it records the run and reports to Linear through fail, returning false.
+func (o *Orchestrator) reviewPass(ctx context.Context, runID int64, details *linear.IssueDetails, stage *config.StageConfig, input subprocess.Input, output string, fail func(context.Context, *linear.IssueDetails, *config.StageConfig, string)) bool {
+	input.Command = stage.ReviewCommand
+	input.Args = stage.ReviewArgs
+	input.Prompt = stage.ReviewPrompt
+	input.ReviewOutput = output
+
+	slog.Info("running review pass", "issue", details.Identifier, "stage", stage.Name)
+	result, err := o.runSubprocess(ctx, details, input)
+	if err != nil {
+		slog.Error("review subprocess execution error", "error", err, "issue", details.Identifier, "stage", stage.Name)
+		o.failRun(ctx, runID, -1, "review: "+err.Error())
+		fail(ctx, details, stage, "review pass failed: "+err.Error())
+		return false
+	}
+	if result.ExitCode != 0 {
+		slog.Warn("review pass rejected changes",
+			"issue", details.Identifier,
+			"stage", stage.Name,
+			"exitCode", result.ExitCode,
+		)
+		errMsg := result.Stdout
+		if errMsg == "" {
+			errMsg = result.Stderr
+		}
+		o.failRun(ctx, runID, result.ExitCode, "review: "+errMsg)
+		fail(ctx, details, stage, "review pass rejected the changes (nothing was committed):\n"+errMsg)
+		return false
+	}
+	return true
+}
+
+// mergePR merges the issue's PR for a merges_pr stage once it is approved (or
+// needs no review), mergeable, and has no failing checks. On failure it records
+// the run and reports to Linear itself, returning false. A merge conflict on a
+// stage with on_conflict "requeue" sends the issue back to requeue_state
+// instead of failure_state.
+func (o *Orchestrator) mergePR(ctx context.Context, runID int64, dir, prURL string, details *linear.IssueDetails, stage *config.StageConfig) bool {
+	if prURL == "" {
+		errMsg := "no PR found for this issue to merge"
+		slog.Error(errMsg, "issue", details.Identifier, "stage", stage.Name)
+		o.failRun(ctx, runID, -1, errMsg)
+		o.failAndTransition(ctx, details, stage, errMsg)
+		return false
+	}
+
+	// Check merge readiness first. A conflict goes through the on_conflict
+	// handling below; unapproved PRs or failing checks are reported as failures.
+	var err error
+	status, statusErr := o.git.PRStatus(ctx, dir, prURL)
+	if statusErr != nil {
+		slog.Warn("checking PR status, attempting merge anyway", "error", statusErr, "issue", details.Identifier, "prURL", prURL)
+	}
+	switch {
+	case status != nil && status.Conflicting():
+		err = fmt.Errorf("PR %s conflicts with its base branch: %w", prURL, git.ErrMergeConflict)
+	case status != nil && status.NotReadyReason() != "":
+		errMsg := fmt.Sprintf("PR is not ready to merge: %s\n\n%s", status.NotReadyReason(), prURL)
+		slog.Warn("PR not ready to merge", "issue", details.Identifier, "prURL", prURL, "reason", status.NotReadyReason())
+		o.failRun(ctx, runID, -1, errMsg)
+		o.failAndTransition(ctx, details, stage, errMsg)
+		return false
+	default:
+		err = o.git.MergePR(ctx, dir, prURL)
+		if err == nil {
+			slog.Info("merged PR", "issue", details.Identifier, "stage", stage.Name, "prURL", prURL)
+			return true
+		}
+	}
+
+	if errors.Is(err, git.ErrMergeConflict) && stage.OnConflict == "requeue" {
+		slog.Warn("merge conflict, requeueing issue",
+			"issue", details.Identifier,
+			"stage", stage.Name,
+			"requeueState", stage.RequeueState,
+		)
+		o.failRun(ctx, runID, -1, err.Error())
+		o.requeue(ctx, details, stage, prURL)
+		return false
 	}
+
+	slog.Error("merging PR", "error", err, "issue", details.Identifier, "prURL", prURL)
+	o.failRun(ctx, runID, -1, err.Error())
+	o.failAndTransition(ctx, details, stage, "merging PR failed: "+err.Error())
+	return false
+}
+
+// requeue moves an issue whose PR conflicts with its base back to the stage's
+// requeue_state, so an earlier stage re-runs against the updated base.
+func (o *Orchestrator) requeue(ctx context.Context, details *linear.IssueDetails, stage *config.StageConfig, prURL string) {
+	ctx, cancel := reportContext(ctx)
+	defer cancel()
+	issueID, identifier := details.ID, details.Identifier
+
+	comment := fmt.Sprintf("**ai-flow: stage `%s` hit a merge conflict**\n\n**PR:** %s\n\nMoving back to `%s` to re-run against the updated base branch.",
+		stage.Name, prURL, stage.RequeueState)
+	if err := o.finishStatus(ctx, issueID, stage.Name, comment); err != nil {
+		slog.Error("posting requeue comment", "error", err, "issue", identifier)
+	}
+
+	stateID, ok := o.client.ResolveStateID(details.Team.Key, stage.RequeueState)
+	if !ok {
+		slog.Error("cannot resolve requeue state", "requeueState", stage.RequeueState, "issue", identifier)
+		return
+	}
+	if err := o.client.UpdateIssueState(ctx, issueID, stateID); err != nil {
+		slog.Error("transitioning issue to requeue state",
+			"error", err,
+			"issue", identifier,
+			"requeueState", stage.RequeueState,
+		)
+		return
+	}
+	slog.Info("requeued issue", "issue", identifier, "to", stage.RequeueState)
 }
 
 // commitAndCreatePR handles the git commit, push, and PR creation after a successful subprocess.
 // Returns the PR URL, or empty string if there were no changes (still considered success).
-func (o *Orchestrator) commitAndCreatePR(ctx context.Context, dir, branch, baseBranch string, details *linear.IssueDetails) (string, error) {
+func (o *Orchestrator) commitAndCreatePR(ctx context.Context, repo, dir, branch, baseBranch string, details *linear.IssueDetails, stage *config.StageConfig) (string, error) {
 	hasChanges, err := o.git.HasChanges(ctx, dir)
 	if err != nil {
 		return "", fmt.Errorf("checking for changes: %w", err)
 	}
 	if hasChanges {
-		commitMsg := fmt.Sprintf("%s: %s\n\nGenerated by ai-flow", details.Identifier, details.Title)
-		if err := o.git.CommitAll(ctx, dir, commitMsg); err != nil {
+		commitMsg := o.commitMessage(repo, details, "Generated by ai-flow")
+		if err := o.git.CommitAll(ctx, repo, dir, commitMsg); err != nil {
 			return "", fmt.Errorf("committing changes: %w", err)
 		}
 	}
 
 	// Check for commits the subprocess may have made directly
-	hasCommits, err := o.git.HasUnpushedCommits(ctx, dir, baseBranch)
+	hasCommits, err := o.git.HasUnpushedCommits(ctx, dir, branch, baseBranch)
 	if err != nil {
 		return "", fmt.Errorf("checking for unpushed commits: %w", err)
 	}
@@ -599,24 +1374,108 @@ func (o *Orchestrator) commitAndCreatePR(ctx context.Context, dir, branch, baseB
 		return "", nil
 	}
 
+	if o.cfg.NormalizeCommits(repo) {
+		if err := o.git.NormalizeCommits(ctx, dir, branch, baseBranch); err != nil {
+			return "", fmt.Errorf("normalizing commits: %w", err)
+		}
+	}
+
 	pushCtx, pushCancel := context.WithTimeout(ctx, 2*time.Minute)
 	defer pushCancel()
 	if err := o.git.Push(pushCtx, dir, branch); err != nil {
 		return "", fmt.Errorf("pushing branch: %w", err)
 	}
 
-	prTitle := fmt.Sprintf("%s: %s", details.Identifier, details.Title)
-	prBody := fmt.Sprintf("Generated by ai-flow\n\nLinear issue: %s", details.URL)
-	prURL, err := o.git.CreatePR(ctx, dir, prTitle, prBody, baseBranch, branch)
+	prURL, err := o.createPR(ctx, repo, dir, branch, baseBranch, details, stage)
 	if err != nil {
 		return "", fmt.Errorf("creating PR: %w", err)
 	}
+	return prURL, nil
+}
+
+// commitMessage builds the message for a commit ai-flow makes: the issue
+// title, then (with commit_include_description for repo) the description,
+// then footer.
+func (o *Orchestrator) commitMessage(repo string, details *linear.IssueDetails, footer string) string {
+	msg := fmt.Sprintf("%s: %s\n\n", details.Identifier, details.Title)
+	if o.cfg.CommitIncludeDescription(repo) {
+		if body := commitBody(details.Description); body != "" {
+			msg += body + "\n\n"
+		}
+	}
+	return msg + footer
+}
 
+// maxCommitBody caps the issue description in a commit message.
+const maxCommitBody = 4096
+
+// commitBody cleans an issue description for a commit message body: control
+// characters other than newlines and tabs are dropped, and it is cut to
+// maxCommitBody bytes.
+func commitBody(description string) string {
+	description = strings.ReplaceAll(description, "\r\n", "\n")
+	description = strings.Map(func(r rune) rune {
+		if r != '\n' && r != '\t' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, description)
+	description = strings.TrimSpace(description)
+	if len(description) > maxCommitBody {
+		description = strings.ToValidUTF8(description[:maxCommitBody], "") + "\n... (truncated)"
+	}
+	return description
+}
+
+// createPR opens the PR for a pushed branch, with the stage's pr_reviewers,
+// pr_assignees and pr_labels, and links it to the issue. If gh
+// fails, it first checks whether the PR was opened anyway; if not, the branch
+// is marked as pending a PR so the next run opens one even when it has no new
+// commits to push.
+func (o *Orchestrator) createPR(ctx context.Context, repo, dir, branch, baseBranch string, details *linear.IssueDetails, stage *config.StageConfig) (string, error) {
+	prTitle := fmt.Sprintf("%s: %s", details.Identifier, details.Title)
+	prBody := fmt.Sprintf("Generated by ai-flow\n\nLinear issue: %s", details.URL)
+	prURL, err := o.git.CreatePR(ctx, repo, dir, prTitle, prBody, baseBranch, branch, git.PROptions{
+		Reviewers: stage.PRReviewers,
+		Assignees: stage.PRAssignees,
+		Labels:    stage.PRLabels,
+	})
+	if err != nil {
+		if existing, _ := o.git.FindPR(ctx, repo, dir, branch); existing != "" {
+			slog.Warn("PR creation reported an error but the PR exists", "error", err, "issue", details.Identifier, "prURL", existing)
+			prURL, err = existing, nil
+		}
+	}
+	if err != nil {
+		if markErr := o.store.MarkPRPending(details.ID, branch); markErr != nil {
+			slog.Error("recording pending PR", "error", markErr, "issue", details.Identifier, "branch", branch)
+		}
+		return "", err
+	}
+	if err := o.store.ClearPRPending(details.ID, branch); err != nil {
+		slog.Warn("clearing pending PR", "error", err, "issue", details.Identifier, "branch", branch)
+	}
+	o.linkPR(ctx, details, prURL)
 	return prURL, nil
 }
 
+// linkPR attaches the PR to the Linear issue. Failure is only logged: the PR
+// link is still included in the stage's outcome comment.
+func (o *Orchestrator) linkPR(ctx context.Context, details *linear.IssueDetails, prURL string) {
+	if prURL == "" {
+		return
+	}
+	if err := o.client.LinkPR(ctx, details.ID, prURL); err != nil {
+		slog.Warn("linking PR to issue", "error", err, "issue", details.Identifier, "prURL", prURL)
+	}
+}
+
+// buildInput assembles the subprocess input for a stage. A priority_overrides
+// entry matching the issue's priority takes precedence over the stage's
+// command, args, and timeout.
 func (o *Orchestrator) buildInput(details *linear.IssueDetails, stage *config.StageConfig, stateName string, labelNames []string) subprocess.Input {
-	return subprocess.Input{
+	priority := linear.PriorityName(details.Priority)
+	input := subprocess.Input{
 		IssueID:          details.ID,
 		IssueIdentifier:  details.Identifier,
 		IssueTitle:       details.Title,
@@ -624,6 +1483,7 @@ func (o *Orchestrator) buildInput(details *linear.IssueDetails, stage *config.St
 		IssueURL:         details.URL,
 		IssueState:       stateName,
 		IssueLabels:      labelNames,
+		IssuePriority:    priority,
 		StageName:        stage.Name,
 		NextState:        stage.NextState,
 		Prompt:           stage.Prompt,
@@ -631,27 +1491,43 @@ func (o *Orchestrator) buildInput(details *linear.IssueDetails, stage *config.St
 		Args:             stage.Args,
 		Timeout:          time.Duration(stage.Timeout) * time.Second,
 		ContextMode:      o.cfg.Subprocess.ContextMode,
+		PromptArg:        stage.PromptArg,
+		Model:            stage.Model,
+		Provider:         stage.Provider,
+		Extra:            details.Extra,
+		CheckpointFile:   o.prepareCheckpoint(details.Identifier, stage.Name),
+	}
+	if cycles, err := o.store.CycleCount(details.ID); err != nil {
+		slog.Warn("reading cycle count", "error", err, "issue", details.Identifier)
+	} else {
+		input.CycleCount = cycles
 	}
-}
 
-func matchesLabels(required, issueLabels []string) bool {
-	if len(required) == 0 {
-		return true
-	}
-	labelSet := make(map[string]bool, len(issueLabels))
-	for _, l := range issueLabels {
-		labelSet[strings.ToLower(l)] = true
-	}
-	for _, req := range required {
-		if labelSet[strings.ToLower(req)] {
-			return true
+	if override, ok := stage.PriorityOverrides[priority]; ok {
+		if override.Command != "" {
+			input.Command = override.Command
+		}
+		if override.Args != nil {
+			input.Args = override.Args
+		}
+		if override.Timeout > 0 {
+			input.Timeout = time.Duration(override.Timeout) * time.Second
 		}
+		slog.Debug("applying priority override", "issue", details.Identifier, "stage", stage.Name, "priority", priority)
 	}
-	return false
+	return input
 }
 
-func (o *Orchestrator) transitionAndComment(ctx context.Context, issueID, identifier string, stage *config.StageConfig, output, prURL string) {
-	nextStateID, ok := o.client.ResolveStateID(stage.NextState)
+func (o *Orchestrator) transitionAndComment(ctx context.Context, details *linear.IssueDetails, stage *config.StageConfig, output, prURL string) {
+	issueID, identifier := details.ID, details.Identifier
+	if canceled(ctx) {
+		slog.Info("issue canceled, not advancing it", "issue", identifier, "stage", stage.Name)
+		return
+	}
+	movedTo := ""
+	defer func() { o.runOnComplete(ctx, issueID, identifier, stage, "success", movedTo, prURL) }()
+
+	nextStateID, ok := o.client.ResolveStateID(details.Team.Key, stage.NextState)
 	if !ok {
 		slog.Error("cannot resolve next state",
 			"nextState", stage.NextState,
@@ -669,6 +1545,7 @@ func (o *Orchestrator) transitionAndComment(ctx context.Context, issueID, identi
 		return
 	}
 
+	movedTo = stage.NextState
 	slog.Info("transitioned issue",
 		"issue", identifier,
 		"to", stage.NextState,
@@ -676,16 +1553,111 @@ func (o *Orchestrator) transitionAndComment(ctx context.Context, issueID, identi
 
 	// Post output as comment (truncate if very long)
 	comment := formatSuccessComment(stage.Name, output, prURL)
-	if err := o.client.PostComment(ctx, issueID, comment); err != nil {
+	if err := o.postOutput(ctx, issueID, identifier, stage, comment); err != nil {
 		slog.Error("posting comment", "error", err, "issue", identifier)
 	}
 }
 
-func (o *Orchestrator) postFailureComment(ctx context.Context, issueID, identifier, stageName, errMsg string) {
-	comment := fmt.Sprintf("**ai-flow: stage `%s` failed**\n\n```\n%s\n```", stageName, truncate(errMsg, 3000))
-	if err := o.client.PostComment(ctx, issueID, comment); err != nil {
-		slog.Error("posting failure comment", "error", err, "issue", identifier)
+func (o *Orchestrator) postFailureComment(ctx context.Context, details *linear.IssueDetails, stage *config.StageConfig, errMsg string) {
+	ctx, cancel := reportContext(ctx)
+	defer cancel()
+	comment := o.renderFailureComment(details, stage, errMsg)
+	if mention := o.assigneeMention(details); mention != "" {
+		// Below the header line, so the comment still starts with the
+		// "**ai-flow:" prefix that loop prevention looks for
+		header, rest, _ := strings.Cut(comment, "\n")
+		comment = header + "\n\n" + mention
+		if rest != "" {
+			comment += "\n" + rest
+		}
+	}
+	if err := o.finishStatus(ctx, details.ID, stage.Name, comment); err != nil {
+		slog.Error("posting failure comment", "error", err, "issue", details.Identifier)
+	}
+}
+
+// assigneeMention returns the markdown that mentions the issue's assignee in
+// a comment, or "" if linear.mention_assignee_on_failure is off or the issue
+// is unassigned. Linear renders a user's profile URL as a mention.
+func (o *Orchestrator) assigneeMention(details *linear.IssueDetails) string {
+	if !o.cfg.Linear.MentionAssigneeOnFailure || details.Assignee == nil {
+		return ""
+	}
+	return details.Assignee.URL
+}
+
+// failureCommentData is the data available to a stage's failure_comment_template.
+type failureCommentData struct {
+	Stage    string
+	Error    string
+	IssueURL string
+}
+
+// renderFailureComment renders the stage's failure_comment_template, falling
+// back to the default format when it is unset or fails to render.
+func (o *Orchestrator) renderFailureComment(details *linear.IssueDetails, stage *config.StageConfig, errMsg string) string {
+	if stage.FailureCommentTemplate != "" {
+		comment, err := executeFailureTemplate(stage.FailureCommentTemplate, failureCommentData{
+			Stage:    stage.Name,
+			Error:    truncate(strings.TrimSpace(errMsg), 3000),
+			IssueURL: details.URL,
+		})
+		if err == nil {
+			return comment
+		}
+		slog.Warn("failure_comment_template failed, using default comment",
+			"error", err,
+			"stage", stage.Name,
+			"issue", details.Identifier,
+		)
+	}
+	return formatFailureComment(stage.Name, errMsg, o.cfg.Linear.RetryInstructions)
+}
+
+func executeFailureTemplate(text string, data failureCommentData) (string, error) {
+	tmpl, err := template.New("failure_comment").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// formatFailureComment leads with a one-line summary of the error and tucks
+// the full error into a collapsible block, followed by the retry instructions.
+func formatFailureComment(stageName, errMsg, retryInstructions string) string {
+	errMsg = strings.TrimSpace(errMsg)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "**ai-flow: stage `%s` failed**", stageName)
+	if summary := failureSummary(errMsg); summary != "" {
+		fmt.Fprintf(&b, ": %s", summary)
+	}
+	if errMsg != "" {
+		fmt.Fprintf(&b, "\n\n<details>\n<summary>Error output</summary>\n\n```\n%s\n```\n\n</details>", truncate(errMsg, 3000))
+	}
+	if retryInstructions = strings.TrimSpace(retryInstructions); retryInstructions != "" {
+		fmt.Fprintf(&b, "\n\n%s", retryInstructions)
+	}
+	return b.String()
+}
+
+// failureSummary returns the first non-empty line of errMsg, shortened to fit on one line.
+func failureSummary(errMsg string) string {
+	for _, line := range strings.Split(errMsg, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if len(line) > 200 {
+			line = line[:200] + "…"
+		}
+		return line
 	}
+	return ""
 }
 
 func formatSuccessComment(stageName, output, prURL string) string {
@@ -707,6 +1679,17 @@ func formatSuccessComment(stageName, output, prURL string) string {
 	return strings.Join(parts, "\n\n")
 }
 
+// successOutput is a successful run's stdout, followed by its stderr when the
+// stage sets include_stderr_on_success.
+func successOutput(stage *config.StageConfig, result *subprocess.Result) string {
+	stderr := strings.TrimSpace(result.Stderr)
+	if !stage.IncludeStderrOnSuccess || stderr == "" {
+		return result.Stdout
+	}
+	return fmt.Sprintf("%s\n\n<details>\n<summary>stderr</summary>\n\n```\n%s\n```\n\n</details>",
+		strings.TrimRight(result.Stdout, "\n"), truncate(stderr, 3000))
+}
+
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
@@ -716,6 +1699,9 @@ func truncate(s string, maxLen int) string {
 
 // HandleCommentWebhook processes a Comment create webhook for re-runs.
 func (o *Orchestrator) HandleCommentWebhook(ctx context.Context, payload linear.WebhookPayload) {
+	ctx, span := telemetry.Tracer().Start(ctx, "webhook comment")
+	defer span.End()
+
 	var comment linear.CommentData
 	if err := json.Unmarshal(payload.Data, &comment); err != nil {
 		slog.Error("parsing comment data from webhook", "error", err)
@@ -736,7 +1722,7 @@ func (o *Orchestrator) HandleCommentWebhook(ctx context.Context, payload linear.
 	}
 
 	// Find matching stage for the issue's current state
-	stage := o.cfg.FindStage(details.State.Name)
+	stage := o.cfg.FindStage(details.Team.Key, details.State.Name, details.LabelNames())
 	if stage == nil {
 		slog.Debug("no pipeline stage for comment's issue state",
 			"state", details.State.Name,
@@ -754,49 +1740,160 @@ func (o *Orchestrator) HandleCommentWebhook(ctx context.Context, payload linear.
 		return
 	}
 
-	// Collect label names and check label filters
-	var labelNames []string
-	for _, l := range details.Labels.Nodes {
-		labelNames = append(labelNames, l.Name)
+	if o.rerunTooSoon(ctx, details, stage) {
+		return
 	}
-	if !matchesLabels(stage.Labels, labelNames) {
-		slog.Debug("issue does not match label filter for comment re-run",
+
+	o.rerunStage(ctx, details, stage, "comment")
+}
+
+// rerunTooSoon reports whether the stage's last run for the issue was less
+// than linear.rerun_min_interval ago, so a comment shouldn't re-run it yet.
+// The first ignored comment after each run is acknowledged with a comment.
+func (o *Orchestrator) rerunTooSoon(ctx context.Context, details *linear.IssueDetails, stage *config.StageConfig) bool {
+	interval := o.cfg.Linear.ParsedRerunMinInterval
+	if interval <= 0 {
+		return false
+	}
+	lastRun, err := o.store.LastRunTime(details.ID, stage.Name)
+	if err != nil {
+		slog.Warn("checking last run for comment re-run", "error", err, "issue", details.Identifier)
+		return false
+	}
+	if lastRun == nil {
+		return false
+	}
+	rerunAfter := lastRun.Add(interval)
+	if !time.Now().Before(rerunAfter) {
+		return false
+	}
+
+	slog.Info("comment re-run too soon after previous run, ignoring",
+		"issue", details.Identifier,
+		"stage", stage.Name,
+		"rerunAfter", rerunAfter,
+	)
+
+	key := statusKey(details.ID, stage.Name)
+	o.rerunMu.Lock()
+	announced := o.rerunNotified[key].Equal(*lastRun)
+	o.rerunNotified[key] = *lastRun
+	o.rerunMu.Unlock()
+	if announced {
+		return true
+	}
+
+	msg := fmt.Sprintf("**ai-flow: comment noted** — stage `%s` ran less than %s ago, so this comment won't re-run it. Comment again after %s to re-run",
+		stage.Name, interval, rerunAfter.UTC().Format(time.RFC3339))
+	if err := o.client.PostComment(ctx, details.ID, msg); err != nil {
+		slog.Error("posting re-run interval comment", "error", err, "issue", details.Identifier)
+	}
+	return true
+}
+
+// handleDescriptionUpdate re-runs the issue's current stage after a human
+// edits the description, if the stage has rerun_on_description set.
+func (o *Orchestrator) handleDescriptionUpdate(ctx context.Context, issue linear.IssueData, previous string) {
+	details, err := o.client.GetIssue(ctx, issue.ID)
+	if err != nil {
+		slog.Error("fetching issue for description update", "error", err, "issue", issue.Identifier)
+		return
+	}
+
+	stage := o.cfg.FindStage(details.Team.Key, details.State.Name, details.LabelNames())
+	if stage == nil || !stage.RerunOnDescription {
+		slog.Debug("ignoring description update", "issue", details.Identifier, "state", details.State.Name)
+		return
+	}
+
+	// Loop prevention: ai-flow's own edits only touch the branch metadata block
+	if strings.TrimSpace(linear.StripBranchMetadata(previous)) == strings.TrimSpace(linear.StripBranchMetadata(details.Description)) {
+		slog.Debug("ignoring description update to branch metadata only", "issue", details.Identifier)
+		return
+	}
+
+	o.rerunStage(ctx, details, stage, "description")
+}
+
+// rerunStage re-runs stage for an issue already in its state, in response to
+// trigger ("comment" or "description"), with the issue's comments as context.
+func (o *Orchestrator) rerunStage(ctx context.Context, details *linear.IssueDetails, stage *config.StageConfig, trigger string) {
+	// Check label filters
+	labelNames := details.LabelNames()
+	if !stage.MatchesLabels(labelNames) {
+		slog.Debug("issue does not match label filter for "+trigger+" re-run",
 			"issue", details.Identifier,
 			"stage", stage.Name,
 		)
 		return
 	}
+	if !o.matchesAssignee(details) {
+		slog.Debug("issue not assigned to assignee_filter user, ignoring "+trigger+" re-run",
+			"issue", details.Identifier,
+		)
+		return
+	}
+	if o.createdByBot(details) {
+		slog.Debug("issue created by a bot user, ignoring "+trigger+" re-run",
+			"issue", details.Identifier,
+		)
+		return
+	}
+
+	if o.coolingDown(ctx, details, stage) || o.overRuntimeCap(ctx, details, stage) {
+		return
+	}
 
 	// Dedup check
 	runID, inserted, err := o.store.StartRun(details.ID, stage.Name)
 	if err != nil {
-		slog.Error("dedup check failed for comment re-run", "error", err, "issue", details.Identifier)
+		slog.Error("dedup check failed for "+trigger+" re-run", "error", err, "issue", details.Identifier)
 		return
 	}
 	if !inserted {
-		slog.Info("run already in progress, skipping comment re-run",
+		slog.Info("run already in progress, skipping "+trigger+" re-run",
 			"issue", details.Identifier,
 			"stage", stage.Name,
 		)
 		return
 	}
+	o.markPendingRunning(ctx)
+	ctx, untrack := o.trackIssue(ctx, details.ID)
+	defer untrack()
+	o.markQueued(ctx, details)
+	defer o.clearStatusLabels(ctx, details)
 
 	// Fetch all comments and filter out ai-flow's own
 	commentNodes, err := o.client.GetIssueComments(ctx, details.ID)
 	if err != nil {
 		slog.Error("fetching issue comments", "error", err, "issue", details.Identifier)
-		o.store.FailRun(runID, -1, "failed to fetch comments: "+err.Error())
+		o.failRun(ctx, runID, -1, "failed to fetch comments: "+err.Error())
 		return
 	}
 	comments := filterComments(commentNodes)
 
-	slog.Info("starting comment re-run",
+	slog.Info("starting "+trigger+" re-run",
 		"issue", details.Identifier,
 		"stage", stage.Name,
 		"commentCount", len(comments),
 	)
 
-	if (stage.CreatesPR || stage.UsesBranch) && o.git != nil {
+	ctx, span := telemetry.Tracer().Start(ctx, "stage "+stage.Name, trace.WithAttributes(
+		attribute.String("issue", details.Identifier),
+		attribute.String("trigger", trigger),
+	))
+	defer span.End()
+
+	ctx, cancel := o.handlerContext(ctx)
+	defer cancel()
+
+	if o.gitUnavailable(ctx, runID, details, stage) {
+		return
+	}
+
+	if stage.PreviewOnly {
+		o.handlePreview(ctx, runID, details, stage, details.State.Name, labelNames, comments)
+	} else if stage.CreatesPR || stage.UsesBranch {
 		o.handleRerunWithGit(ctx, runID, details, stage, details.State.Name, labelNames, comments)
 	} else {
 		o.handleRerunWithoutGit(ctx, runID, details, stage, details.State.Name, labelNames, comments)
@@ -808,27 +1905,28 @@ func (o *Orchestrator) handleRerunWithoutGit(ctx context.Context, runID int64, d
 	input.RunID = runID
 	input.Comments = comments
 
-	result, err := o.runner.Run(ctx, input)
+	result, err := o.runStageSubprocess(ctx, details, input)
 	if err != nil {
 		slog.Error("subprocess execution error (re-run)",
 			"error", err,
 			"issue", details.Identifier,
 			"stage", stage.Name,
 		)
-		o.store.TimeoutRun(runID, err.Error())
-		o.postFailureComment(ctx, details.ID, details.Identifier, stage.Name, err.Error())
+		o.recordRunError(ctx, runID, err)
+		o.postFailureComment(ctx, details, stage, err.Error())
 		return
 	}
 
 	switch result.ExitCode {
 	case 0:
+		output := successOutput(stage, result)
 		slog.Info("subprocess re-run succeeded",
 			"issue", details.Identifier,
 			"stage", stage.Name,
 		)
-		o.store.CompleteRun(runID, 0, result.Stdout, "", "")
-		outputComment := formatSuccessComment(stage.Name, result.Stdout, "")
-		if err := o.client.PostComment(ctx, details.ID, outputComment); err != nil {
+		o.store.CompleteRun(runID, 0, output, "", "")
+		outputComment := formatSuccessComment(stage.Name, output, "")
+		if err := o.postOutput(ctx, details.ID, details.Identifier, stage, outputComment); err != nil {
 			slog.Error("posting comment", "error", err, "issue", details.Identifier)
 		}
 
@@ -837,7 +1935,8 @@ func (o *Orchestrator) handleRerunWithoutGit(ctx context.Context, runID int64, d
 			"issue", details.Identifier,
 			"stage", stage.Name,
 		)
-		o.store.CompleteRun(runID, 2, "skipped", "", "")
+		o.store.SkipRun(runID, "", "")
+		o.settleStatus(ctx, details.ID, stage.Name, fmt.Sprintf("**ai-flow: stage `%s` skipped**", stage.Name))
 
 	default:
 		slog.Warn("subprocess re-run failed",
@@ -849,17 +1948,17 @@ func (o *Orchestrator) handleRerunWithoutGit(ctx context.Context, runID int64, d
 		if errMsg == "" {
 			errMsg = result.Stdout
 		}
-		o.store.FailRun(runID, result.ExitCode, errMsg)
-		o.postFailureComment(ctx, details.ID, details.Identifier, stage.Name, errMsg)
+		o.failRun(ctx, runID, result.ExitCode, errMsg)
+		o.postFailureComment(ctx, details, stage, errMsg)
 	}
 }
 
 func (o *Orchestrator) handleRerunWithGit(ctx context.Context, runID int64, details *linear.IssueDetails, stage *config.StageConfig, stateName string, labelNames []string, comments []subprocess.Comment) {
-	repo, baseBranch, err := resolveRepoConfig(details)
+	repo, baseBranch, err := o.resolveRepoConfig(ctx, details)
 	if err != nil {
 		slog.Error("resolving repo config", "error", err, "issue", details.Identifier)
-		o.store.FailRun(runID, -1, err.Error())
-		o.postFailureComment(ctx, details.ID, details.Identifier, stage.Name, err.Error())
+		o.failRun(ctx, runID, -1, err.Error())
+		o.postFailureComment(ctx, details, stage, err.Error())
 		return
 	}
 
@@ -873,11 +1972,11 @@ func (o *Orchestrator) handleRerunWithGit(ctx context.Context, runID int64, deta
 	}
 	if err != nil {
 		slog.Error("looking up previous run", "error", err, "issue", details.Identifier)
-		o.store.FailRun(runID, -1, err.Error())
+		o.failRun(ctx, runID, -1, err.Error())
 		return
 	}
 
-	branchName := git.SanitizeBranchName(details.Identifier, details.Title)
+	baseBranch, branchName := o.stackBranch(details, stage, baseBranch, git.SanitizeBranchName(details.Identifier, details.Title))
 	prURL := ""
 	isRerun := prevRun != nil && prevRun.BranchName != ""
 	if isRerun {
@@ -886,11 +1985,11 @@ func (o *Orchestrator) handleRerunWithGit(ctx context.Context, runID int64, deta
 	}
 
 	// Set up workspace (persistent or temp)
-	workDir, cleanup, err := o.setupWorkspace(ctx, repo, baseBranch, branchName, details.Identifier)
+	workDir, cleanup, err := o.setupWorkspace(ctx, repo, baseBranch, branchName, details.Identifier, *stage.CloneDepth)
 	if err != nil {
 		slog.Error("setting up workspace", "error", err, "issue", details.Identifier)
-		o.store.FailRun(runID, -1, err.Error())
-		o.postFailureComment(ctx, details.ID, details.Identifier, stage.Name, "failed to set up workspace: "+err.Error())
+		o.failRun(ctx, runID, -1, err.Error())
+		o.postFailureComment(ctx, details, stage, "failed to set up workspace: "+err.Error())
 		return
 	}
 	defer cleanup()
@@ -903,15 +2002,15 @@ func (o *Orchestrator) handleRerunWithGit(ctx context.Context, runID int64, deta
 		if branchOnRemote {
 			if err := o.git.FetchAndCheckout(ctx, workDir, branchName); err != nil {
 				slog.Error("fetching existing branch", "error", err, "issue", details.Identifier, "branch", branchName)
-				o.store.FailRun(runID, -1, err.Error())
-				o.postFailureComment(ctx, details.ID, details.Identifier, stage.Name, "failed to fetch branch: "+err.Error())
+				o.failRun(ctx, runID, -1, err.Error())
+				o.postFailureComment(ctx, details, stage, "failed to fetch branch: "+err.Error())
 				return
 			}
 		} else {
 			if err := o.git.CreateBranch(ctx, workDir, branchName); err != nil {
 				slog.Error("creating branch", "error", err, "issue", details.Identifier)
-				o.store.FailRun(runID, -1, err.Error())
-				o.postFailureComment(ctx, details.ID, details.Identifier, stage.Name, "failed to create branch: "+err.Error())
+				o.failRun(ctx, runID, -1, err.Error())
+				o.postFailureComment(ctx, details, stage, "failed to create branch: "+err.Error())
 				return
 			}
 		}
@@ -919,8 +2018,8 @@ func (o *Orchestrator) handleRerunWithGit(ctx context.Context, runID int64, deta
 		// First run: create new branch
 		if err := o.git.CreateBranch(ctx, workDir, branchName); err != nil {
 			slog.Error("creating branch", "error", err, "issue", details.Identifier)
-			o.store.FailRun(runID, -1, err.Error())
-			o.postFailureComment(ctx, details.ID, details.Identifier, stage.Name, "failed to create branch: "+err.Error())
+			o.failRun(ctx, runID, -1, err.Error())
+			o.postFailureComment(ctx, details, stage, "failed to create branch: "+err.Error())
 			return
 		}
 	}
@@ -929,30 +2028,39 @@ func (o *Orchestrator) handleRerunWithGit(ctx context.Context, runID int64, deta
 	input := o.buildInput(details, stage, stateName, labelNames)
 	input.RunID = runID
 	input.WorkDir = workDir
+	input.AttachmentsDir = o.downloadAttachments(ctx, details, workDir)
 	input.BranchName = branchName
+	input.PRURL = prURL
 	input.Comments = comments
 
-	result, err := o.runner.Run(ctx, input)
+	snap := o.snapshotWorkspace(ctx, workDir, details.Identifier)
+	defer snap.rollback(ctx)
+
+	result, err := o.runStageSubprocess(ctx, details, input)
 	if err != nil {
 		slog.Error("subprocess execution error (re-run)",
 			"error", err,
 			"issue", details.Identifier,
 			"stage", stage.Name,
 		)
-		o.store.TimeoutRun(runID, err.Error())
-		o.postFailureComment(ctx, details.ID, details.Identifier, stage.Name, err.Error())
+		o.recordRunError(ctx, runID, err)
+		o.postFailureComment(ctx, details, stage, err.Error())
 		return
 	}
 
 	switch result.ExitCode {
 	case 0:
+		output := successOutput(stage, result)
+		if stage.ReviewCommand != "" && !o.reviewPass(ctx, runID, details, stage, input, output, o.postFailureComment) {
+			return
+		}
 		if isRerun {
 			// Push to existing branch, create PR if needed
-			newPRURL, pushed, err := o.commitPushAndEnsurePR(ctx, workDir, branchName, baseBranch, details, stage.Name, prURL)
+			newPRURL, pushed, err := o.commitPushAndEnsurePR(ctx, repo, workDir, branchName, baseBranch, details, stage, prURL)
 			if err != nil {
 				slog.Error("commit/push/PR failed (re-run)", "error", err, "issue", details.Identifier)
-				o.store.FailRun(runID, -1, err.Error())
-				o.postFailureComment(ctx, details.ID, details.Identifier, stage.Name, "re-run succeeded but git operations failed: "+err.Error())
+				o.failRun(ctx, runID, -1, err.Error())
+				o.postFailureComment(ctx, details, stage, "re-run succeeded but git operations failed: "+err.Error())
 				return
 			}
 			prURL = newPRURL
@@ -962,11 +2070,11 @@ func (o *Orchestrator) handleRerunWithGit(ctx context.Context, runID int64, deta
 		} else {
 			// First run via comment: create PR
 			var err error
-			prURL, err = o.commitAndCreatePR(ctx, workDir, branchName, baseBranch, details)
+			prURL, err = o.commitAndCreatePR(ctx, repo, workDir, branchName, baseBranch, details, stage)
 			if err != nil {
 				slog.Error("creating PR (comment first run)", "error", err, "issue", details.Identifier)
-				o.store.FailRun(runID, -1, err.Error())
-				o.postFailureComment(ctx, details.ID, details.Identifier, stage.Name, "subprocess succeeded but PR creation failed: "+err.Error())
+				o.failRun(ctx, runID, -1, err.Error())
+				o.postFailureComment(ctx, details, stage, "subprocess succeeded but PR creation failed: "+err.Error())
 				return
 			}
 
@@ -984,9 +2092,10 @@ func (o *Orchestrator) handleRerunWithGit(ctx context.Context, runID int64, deta
 			"stage", stage.Name,
 			"prURL", prURL,
 		)
-		o.store.CompleteRun(runID, 0, result.Stdout, prURL, branchName)
-		outputComment := formatSuccessComment(stage.Name, result.Stdout, prURL)
-		if err := o.client.PostComment(ctx, details.ID, outputComment); err != nil {
+		o.store.CompleteRun(runID, 0, output, prURL, branchName)
+		snap.keep()
+		outputComment := formatSuccessComment(stage.Name, output, prURL)
+		if err := o.postOutput(ctx, details.ID, details.Identifier, stage, outputComment); err != nil {
 			slog.Error("posting comment", "error", err, "issue", details.Identifier)
 		}
 
@@ -995,7 +2104,9 @@ func (o *Orchestrator) handleRerunWithGit(ctx context.Context, runID int64, deta
 			"issue", details.Identifier,
 			"stage", stage.Name,
 		)
-		o.store.CompleteRun(runID, 2, "skipped", "", branchName)
+		o.store.SkipRun(runID, "", branchName)
+		snap.keep()
+		o.settleStatus(ctx, details.ID, stage.Name, fmt.Sprintf("**ai-flow: stage `%s` skipped**", stage.Name))
 
 	default:
 		slog.Warn("subprocess re-run failed",
@@ -1007,27 +2118,27 @@ func (o *Orchestrator) handleRerunWithGit(ctx context.Context, runID int64, deta
 		if errMsg == "" {
 			errMsg = result.Stdout
 		}
-		o.store.FailRun(runID, result.ExitCode, errMsg)
-		o.postFailureComment(ctx, details.ID, details.Identifier, stage.Name, errMsg)
+		o.failRun(ctx, runID, result.ExitCode, errMsg)
+		o.postFailureComment(ctx, details, stage, errMsg)
 	}
 }
 
 // commitAndPush commits all changes and pushes to the existing branch (no PR creation).
 // Returns true if changes were committed and pushed.
-func (o *Orchestrator) commitAndPush(ctx context.Context, dir, branch, baseBranch string, details *linear.IssueDetails, stageName string) (bool, error) {
+func (o *Orchestrator) commitAndPush(ctx context.Context, repo, dir, branch, baseBranch string, details *linear.IssueDetails, stageName string) (bool, error) {
 	hasChanges, err := o.git.HasChanges(ctx, dir)
 	if err != nil {
 		return false, fmt.Errorf("checking for changes: %w", err)
 	}
 	if hasChanges {
-		commitMsg := fmt.Sprintf("%s: %s\n\nGenerated by ai-flow (stage: %s)", details.Identifier, details.Title, stageName)
-		if err := o.git.CommitAll(ctx, dir, commitMsg); err != nil {
+		commitMsg := o.commitMessage(repo, details, fmt.Sprintf("Generated by ai-flow (stage: %s)", stageName))
+		if err := o.git.CommitAll(ctx, repo, dir, commitMsg); err != nil {
 			return false, fmt.Errorf("committing changes: %w", err)
 		}
 	}
 
 	// Check for commits the subprocess may have made directly
-	hasCommits, err := o.git.HasUnpushedCommits(ctx, dir, baseBranch)
+	hasCommits, err := o.git.HasUnpushedCommits(ctx, dir, branch, baseBranch)
 	if err != nil {
 		return false, fmt.Errorf("checking for unpushed commits: %w", err)
 	}
@@ -1036,6 +2147,12 @@ func (o *Orchestrator) commitAndPush(ctx context.Context, dir, branch, baseBranc
 		return false, nil
 	}
 
+	if o.cfg.NormalizeCommits(repo) {
+		if err := o.git.NormalizeCommits(ctx, dir, branch, baseBranch); err != nil {
+			return false, fmt.Errorf("normalizing commits: %w", err)
+		}
+	}
+
 	pushCtx, pushCancel := context.WithTimeout(ctx, 2*time.Minute)
 	defer pushCancel()
 	if err := o.git.Push(pushCtx, dir, branch); err != nil {
@@ -1048,31 +2165,43 @@ func (o *Orchestrator) commitAndPush(ctx context.Context, dir, branch, baseBranc
 // commitPushAndEnsurePR commits and pushes changes, then creates a PR if one
 // doesn't already exist. Returns the (possibly new) PR URL and whether changes
 // were pushed. This handles the case where an earlier creates_pr stage had no
-// changes and skipped PR creation.
-func (o *Orchestrator) commitPushAndEnsurePR(ctx context.Context, dir, branch, baseBranch string, details *linear.IssueDetails, stageName, existingPRURL string) (prURL string, pushed bool, err error) {
-	pushed, err = o.commitAndPush(ctx, dir, branch, baseBranch, details, stageName)
+// changes and skipped PR creation, and the case where an earlier run pushed
+// the branch but failed to open its PR.
+func (o *Orchestrator) commitPushAndEnsurePR(ctx context.Context, repo, dir, branch, baseBranch string, details *linear.IssueDetails, stage *config.StageConfig, existingPRURL string) (prURL string, pushed bool, err error) {
+	pushed, err = o.commitAndPush(ctx, repo, dir, branch, baseBranch, details, stage.Name)
 	if err != nil {
 		return "", false, err
 	}
 	prURL = existingPRURL
 
-	if pushed && prURL == "" {
+	pending := false
+	if !pushed && prURL == "" {
+		var pendErr error
+		if pending, pendErr = o.store.IsPRPending(details.ID, branch); pendErr != nil {
+			slog.Warn("checking for pending PR", "error", pendErr, "issue", details.Identifier)
+		}
+	}
+
+	if (pushed || pending) && prURL == "" {
 		// Check if a PR already exists on GitHub (may have been created outside ai-flow
 		// or from a previous run where the URL wasn't stored)
-		existingURL, err := o.git.FindPR(ctx, dir, branch)
+		existingURL, err := o.git.FindPR(ctx, repo, dir, branch)
 		if err != nil {
 			slog.Warn("checking for existing PR", "error", err, "issue", details.Identifier)
 		}
 		if existingURL != "" {
 			slog.Info("found existing PR", "issue", details.Identifier, "prURL", existingURL)
 			prURL = existingURL
+			if pending {
+				if err := o.store.ClearPRPending(details.ID, branch); err != nil {
+					slog.Warn("clearing pending PR", "error", err, "issue", details.Identifier, "branch", branch)
+				}
+			}
 		} else {
-			slog.Info("no PR exists yet, creating one", "issue", details.Identifier, "stage", stageName)
-			prTitle := fmt.Sprintf("%s: %s", details.Identifier, details.Title)
-			prBody := fmt.Sprintf("Generated by ai-flow\n\nLinear issue: %s", details.URL)
-			prURL, err = o.git.CreatePR(ctx, dir, prTitle, prBody, baseBranch, branch)
+			slog.Info("no PR exists yet, creating one", "issue", details.Identifier, "stage", stage.Name, "pending", pending)
+			prURL, err = o.createPR(ctx, repo, dir, branch, baseBranch, details, stage)
 			if err != nil {
-				return "", true, fmt.Errorf("creating PR: %w", err)
+				return "", pushed, fmt.Errorf("creating PR: %w", err)
 			}
 		}
 
@@ -1127,12 +2256,22 @@ func (o *Orchestrator) commentOnPR(ctx context.Context, dir, prURL, stageName, i
 }
 
 // failAndTransition posts a failure comment then transitions to the stage's FailureState.
-func (o *Orchestrator) failAndTransition(ctx context.Context, issueID, identifier string, stage *config.StageConfig, errMsg string) {
-	o.postFailureComment(ctx, issueID, identifier, stage.Name, errMsg)
+func (o *Orchestrator) failAndTransition(ctx context.Context, details *linear.IssueDetails, stage *config.StageConfig, errMsg string) {
+	if canceled(ctx) {
+		slog.Info("run stopped by issue cancellation", "issue", details.Identifier, "stage", stage.Name)
+		return
+	}
+	ctx, cancel := reportContext(ctx)
+	defer cancel()
+	issueID, identifier := details.ID, details.Identifier
+	movedTo := ""
+	defer func() { o.runOnComplete(ctx, issueID, identifier, stage, "failed", movedTo, "") }()
+	o.postFailureComment(ctx, details, stage, errMsg)
+	o.escalate(ctx, details, stage, errMsg)
 	if stage.FailureState == "" {
 		return
 	}
-	failStateID, ok := o.client.ResolveStateID(stage.FailureState)
+	failStateID, ok := o.client.ResolveStateID(details.Team.Key, stage.FailureState)
 	if !ok {
 		slog.Error("cannot resolve failure state",
 			"failureState", stage.FailureState,
@@ -1148,6 +2287,7 @@ func (o *Orchestrator) failAndTransition(ctx context.Context, issueID, identifie
 		)
 		return
 	}
+	movedTo = stage.FailureState
 	slog.Info("transitioned issue to failure state",
 		"issue", identifier,
 		"to", stage.FailureState,
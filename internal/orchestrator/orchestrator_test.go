@@ -0,0 +1,61 @@
+package orchestrator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowGitClone puts a git wrapper first on PATH whose clone hangs.
+func slowGitClone(t *testing.T) {
+	t.Helper()
+	realGit, err := exec.LookPath("git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\nif [ \"$1\" = clone ]; then exec sleep 30; fi\nexec " + realGit + " \"$@\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "git"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestHandlerTimeoutAbortsSlowGitOperation(t *testing.T) {
+	h := newHarness(t, testLinearYAML+`
+pipeline:
+  handler_timeout: 1s
+  stages:
+    - name: implement
+      linear_state: In Progress
+      command: sh
+      args: ["-c", "true"]
+      prompt: Implement it.
+      next_state: In Review
+      failure_state: Failed
+      creates_pr: true
+`)
+	h.withGit()
+	slowGitClone(t)
+	issue := h.issue("In Progress")
+
+	start := time.Now()
+	h.process(issue)
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("handler took %s, want it aborted at the 1s bound", elapsed)
+	}
+
+	run := h.lastRun(issue.ID)
+	if run.Status != "timeout" {
+		t.Errorf("run status = %q, want timeout", run.Status)
+	}
+	if !strings.Contains(run.Error, "handler timed out after 1s") {
+		t.Errorf("run error = %q, want it to name the handler timeout", run.Error)
+	}
+	if got := h.state(issue.ID); got != "Failed" {
+		t.Errorf("issue state = %q, want Failed", got)
+	}
+}
@@ -0,0 +1,94 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/mauza/ai-flow/internal/linear"
+)
+
+// pendingIDKey carries the store ID of the pending work a handler is
+// processing, so the run it starts can mark it running.
+type pendingIDKey struct{}
+
+// Dispatch saves a webhook payload as pending work and hands it to a new
+// goroutine. It returns once the payload is stored, so a delivery is only
+// acknowledged when it can't be lost: work that hasn't started a run when the
+// process stops is handled again by ResumePending on the next start. A state
+// change for an issue that is waiting out webhook_debounce is folded into the
+// waiting work instead.
+func (o *Orchestrator) Dispatch(ctx context.Context, payload linear.WebhookPayload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encoding webhook payload: %w", err)
+	}
+	// A debounced state change is folded into, or registered as, the issue's
+	// waiting work before returning, so the next delivery in a burst sees it
+	issue, debounced := o.debouncedStateChange(payload)
+	if debounced {
+		o.pendingMu.Lock()
+		defer o.pendingMu.Unlock()
+		if o.foldIntoDebounce(issue, payload, data) {
+			return nil
+		}
+	}
+	id, err := o.store.EnqueuePending(payload.Type, data)
+	if err != nil {
+		return err
+	}
+	if debounced {
+		o.pendingIssues[issue.ID] = &debouncing{issue: issue, pendingID: id}
+	}
+	go o.handlePending(ctx, id, payload)
+	return nil
+}
+
+// ResumePending re-dispatches the work left pending by the previous process
+// and returns how many items there were.
+func (o *Orchestrator) ResumePending(ctx context.Context) (int, error) {
+	items, err := o.store.RecoverPending()
+	if err != nil {
+		return 0, err
+	}
+	for _, item := range items {
+		var payload linear.WebhookPayload
+		if err := json.Unmarshal(item.Payload, &payload); err != nil {
+			slog.Warn("dropping unreadable pending work", "error", err, "id", item.ID)
+			o.store.DeletePending(item.ID)
+			continue
+		}
+		go o.handlePending(ctx, item.ID, payload)
+	}
+	return len(items), nil
+}
+
+// handlePending routes a payload to its handler and forgets the pending work
+// once the handler returns.
+func (o *Orchestrator) handlePending(ctx context.Context, id int64, payload linear.WebhookPayload) {
+	ctx = context.WithValue(ctx, pendingIDKey{}, id)
+	defer func() {
+		if err := o.store.DeletePending(id); err != nil {
+			slog.Warn("clearing pending work", "error", err, "id", id)
+		}
+	}()
+	switch payload.Type {
+	case "Issue":
+		o.HandleWebhook(ctx, payload)
+	case "Comment":
+		o.HandleCommentWebhook(ctx, payload)
+	}
+}
+
+// markPendingRunning records that the pending work behind ctx, if any, has
+// started a run.
+func (o *Orchestrator) markPendingRunning(ctx context.Context) {
+	id, ok := ctx.Value(pendingIDKey{}).(int64)
+	if !ok {
+		return
+	}
+	if err := o.store.MarkPendingRunning(id); err != nil {
+		slog.Warn("marking pending work running", "error", err, "id", id)
+	}
+}
@@ -0,0 +1,116 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/linear"
+	"github.com/mauza/ai-flow/internal/subprocess"
+)
+
+// waitForRuns waits until the issue has n finished runs.
+func (h *harness) waitForRuns(issueID string, n int) {
+	h.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		finished := 0
+		for _, run := range h.runs(issueID) {
+			if run.EndedAt != nil {
+				finished++
+			}
+		}
+		if finished >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatalf("issue has %d finished runs, want %d", finished, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDispatchPersistsBeforeReturning(t *testing.T) {
+	h := newHarness(t, testLinearYAML+planStageYAML)
+	issue := h.issue("Todo")
+
+	// Hold the handler at its issue lookup so the saved work can be
+	// inspected before it runs
+	release := make(chan struct{})
+	h.linear.Handle = func(req linear.GraphQLRequest) (any, bool) {
+		if strings.Contains(req.Query, "issue(id: $id)") {
+			<-release
+		}
+		return nil, false
+	}
+	if err := h.o.Dispatch(context.Background(), h.webhookPayload(issue.ID, `{"stateId":"state-prev"}`)); err != nil {
+		t.Fatal(err)
+	}
+	items, err := h.store.RecoverPending()
+	close(release)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Kind != "Issue" {
+		t.Fatalf("pending work = %+v, want the delivery saved by the time Dispatch returns", items)
+	}
+	h.waitForRuns(issue.ID, 1)
+}
+
+func TestPendingWorkResumedAfterRestart(t *testing.T) {
+	h := newHarness(t, testLinearYAML+planStageYAML)
+	issue := h.issue("Todo")
+	started := h.issue("Todo")
+
+	// The previous process saved both deliveries and started a run for the
+	// second, then stopped
+	for _, id := range []string{issue.ID, started.ID} {
+		data, err := json.Marshal(h.webhookPayload(id, `{"stateId":"state-prev"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pendingID, err := h.store.EnqueuePending("Issue", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id == started.ID {
+			if err := h.store.MarkPendingRunning(pendingID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Restart: a new orchestrator over the same store
+	h.o = New(h.cfg, h.client, h.store, subprocess.NewRunner(h.cfg.Subprocess.MaxConcurrent), nil)
+	n, err := h.o.ResumePending(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("resumed %d items, want only the one that hadn't started", n)
+	}
+	h.waitForRuns(issue.ID, 1)
+	if got := h.state(issue.ID); got != "In Progress" {
+		t.Errorf("state = %q, want In Progress after the resumed delivery ran", got)
+	}
+	if runs := h.runs(started.ID); len(runs) != 0 {
+		t.Errorf("got %d runs for the delivery that had already started, want it left to stale-run cleanup", len(runs))
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		items, err := h.store.RecoverPending()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pending work = %+v, want it cleared once handled", items)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
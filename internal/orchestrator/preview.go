@@ -0,0 +1,157 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/config"
+	"github.com/mauza/ai-flow/internal/linear"
+	"github.com/mauza/ai-flow/internal/subprocess"
+)
+
+// handlePreview runs a preview_only stage: the command runs in a throwaway
+// clone of the branch the stage would work on, and the resulting diff is
+// posted with its output. Nothing is committed, pushed, or opened as a PR.
+// comments, when non-nil, are the issue comments of a re-run.
+func (o *Orchestrator) handlePreview(ctx context.Context, runID int64, details *linear.IssueDetails, stage *config.StageConfig, stateName string, labelNames []string, comments []subprocess.Comment) {
+	repo, baseBranch, err := o.resolveRepoConfig(ctx, details)
+	if err != nil {
+		slog.Error("resolving repo config", "error", err, "issue", details.Identifier)
+		o.failRun(ctx, runID, -1, err.Error())
+		o.failAndTransition(ctx, details, stage, err.Error())
+		return
+	}
+
+	workDir, err := os.MkdirTemp("", "aiflow-preview-"+details.Identifier+"-*")
+	if err != nil {
+		o.failRun(ctx, runID, -1, err.Error())
+		o.failAndTransition(ctx, details, stage, "failed to create preview dir: "+err.Error())
+		return
+	}
+	defer o.git.Cleanup(workDir)
+
+	cloneCtx, cloneCancel := context.WithTimeout(ctx, 2*time.Minute)
+	err = o.git.Clone(cloneCtx, repo, baseBranch, workDir, *stage.CloneDepth)
+	cloneCancel()
+	if err != nil {
+		slog.Error("cloning for preview", "error", err, "issue", details.Identifier)
+		o.failRun(ctx, runID, -1, err.Error())
+		o.failAndTransition(ctx, details, stage, "failed to clone repo: "+err.Error())
+		return
+	}
+
+	// uses_branch stages preview against the issue's branch when it exists
+	if stage.UsesBranch {
+		if prev, err := o.store.GetFirstBranchForIssue(details.ID); err != nil {
+			slog.Warn("looking up branch for preview", "error", err, "issue", details.Identifier)
+		} else if prev != nil && prev.BranchName != "" {
+			if onRemote, _ := o.git.BranchExistsOnRemote(ctx, workDir, prev.BranchName); onRemote {
+				if err := o.git.FetchAndCheckout(ctx, workDir, prev.BranchName); err != nil {
+					o.failRun(ctx, runID, -1, err.Error())
+					o.failAndTransition(ctx, details, stage, "failed to fetch existing branch: "+err.Error())
+					return
+				}
+			}
+		}
+	}
+
+	head, err := o.git.HeadCommit(ctx, workDir)
+	if err != nil {
+		o.failRun(ctx, runID, -1, err.Error())
+		o.failAndTransition(ctx, details, stage, err.Error())
+		return
+	}
+
+	input := o.buildInput(details, stage, stateName, labelNames)
+	input.RunID = runID
+	input.WorkDir = workDir
+	input.AttachmentsDir = o.downloadAttachments(ctx, details, workDir)
+	if comments != nil {
+		input.Comments = comments
+	} else if commentNodes, err := o.client.GetIssueComments(ctx, details.ID); err != nil {
+		slog.Warn("fetching cross-stage comments", "error", err, "issue", details.Identifier)
+	} else if len(commentNodes) > 0 {
+		input.Comments = convertComments(commentNodes)
+	}
+
+	// Always run: a reused output would have no diff to show
+	result, err := o.runSubprocess(ctx, details, input)
+	if err != nil {
+		slog.Error("subprocess execution error (preview)",
+			"error", err,
+			"issue", details.Identifier,
+			"stage", stage.Name,
+		)
+		o.recordRunError(ctx, runID, err)
+		o.failAndTransition(ctx, details, stage, err.Error())
+		return
+	}
+
+	switch result.ExitCode {
+	case 0:
+		diff, err := o.git.DiffFrom(ctx, workDir, head)
+		if err != nil {
+			slog.Error("computing preview diff", "error", err, "issue", details.Identifier)
+			o.failRun(ctx, runID, -1, err.Error())
+			o.failAndTransition(ctx, details, stage, "subprocess succeeded but computing the diff failed: "+err.Error())
+			return
+		}
+		output := previewOutput(successOutput(stage, result), diff)
+		slog.Info("preview succeeded",
+			"issue", details.Identifier,
+			"stage", stage.Name,
+			"diffBytes", len(diff),
+		)
+		o.store.CompleteRun(runID, 0, output, "", "")
+		if stage.WaitForApproval {
+			comment := formatSuccessComment(stage.Name, output, "")
+			if err := o.postOutput(ctx, details.ID, details.Identifier, stage, comment); err != nil {
+				slog.Error("posting comment", "error", err, "issue", details.Identifier)
+			}
+		} else {
+			o.transitionAndComment(ctx, details, stage, output, "")
+		}
+
+	case 2:
+		slog.Info("subprocess skipped",
+			"issue", details.Identifier,
+			"stage", stage.Name,
+		)
+		o.store.SkipRun(runID, "", "")
+		o.settleStatus(ctx, details.ID, stage.Name, fmt.Sprintf("**ai-flow: stage `%s` skipped**", stage.Name))
+
+	default:
+		slog.Warn("subprocess failed",
+			"issue", details.Identifier,
+			"stage", stage.Name,
+			"exitCode", result.ExitCode,
+			"stderr", logContent(o.cfg, result.Stderr),
+		)
+		errMsg := result.Stderr
+		if errMsg == "" {
+			errMsg = result.Stdout
+		}
+		o.failRun(ctx, runID, result.ExitCode, errMsg)
+		o.failAndTransition(ctx, details, stage, errMsg)
+	}
+}
+
+// previewOutput appends a preview's diff to the command output. Both parts
+// are truncated so the closing fence survives formatSuccessComment's limit.
+func previewOutput(output, diff string) string {
+	output = strings.TrimSpace(output)
+	var section string
+	if strings.TrimSpace(diff) == "" {
+		section = "**Preview (not pushed):** no file changes"
+	} else {
+		section = fmt.Sprintf("**Preview (not pushed):**\n\n```diff\n%s\n```", truncate(strings.TrimRight(diff, "\n"), 6000))
+	}
+	if output == "" {
+		return section
+	}
+	return truncate(output, 3000) + "\n\n" + section
+}
@@ -0,0 +1,53 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+const previewStageYAML = `
+pipeline:
+  - name: implement
+    linear_state: In Progress
+    command: sh
+    args: ["-c", "echo change > change.txt && echo edited >> README.md"]
+    prompt: Implement it.
+    next_state: In Review
+    failure_state: Failed
+    creates_pr: true
+    preview_only: true
+`
+
+func TestPreviewPostsDiffWithoutPushing(t *testing.T) {
+	h := newHarness(t, testLinearYAML+previewStageYAML)
+	bare := h.withGit()
+	before := testutil.RunGit(t, bare, "for-each-ref", "--format=%(refname) %(objectname)")
+	issue := h.issue("In Progress")
+
+	h.process(issue)
+
+	if run := h.lastRun(issue.ID); run.Status != "completed" || run.PRURL != "" || run.BranchName != "" {
+		t.Errorf("run = %+v, want completed with no PR or branch", run)
+	}
+	if got := testutil.RunGit(t, bare, "for-each-ref", "--format=%(refname) %(objectname)"); got != before {
+		t.Errorf("remote refs changed:\n%s\nwant\n%s", got, before)
+	}
+	if calls := h.gh.Calls("pr"); len(calls) != 0 {
+		t.Errorf("gh pr calls = %q, want none", calls)
+	}
+
+	comment, ok := h.commentContaining(issue.ID, "Preview (not pushed)")
+	if !ok {
+		t.Fatalf("no preview comment among %q", h.comments(issue.ID))
+	}
+	for _, want := range []string{"```diff", "+change", "change.txt", "+edited", "README.md"} {
+		if !strings.Contains(comment, want) {
+			t.Errorf("preview comment lacks %q:\n%s", want, comment)
+		}
+	}
+	if got := h.state(issue.ID); got != "In Review" {
+		t.Errorf("state = %q, want In Review", got)
+	}
+}
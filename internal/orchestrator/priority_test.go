@@ -0,0 +1,51 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/linear"
+)
+
+func TestPriorityOverride(t *testing.T) {
+	h := newHarness(t, testLinearYAML+`
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    args: ["-c", "echo default"]
+    prompt: Plan it.
+    next_state: In Progress
+    timeout: 600
+    priority_overrides:
+      urgent:
+        command: bash
+        args: ["-c", "echo urgent"]
+        timeout: 60
+`)
+	urgent := h.issueWith("Todo", func(issue *linear.IssueDetails) { issue.Priority = 1 })
+	normal := h.issueWith("Todo", func(issue *linear.IssueDetails) { issue.Priority = 3 })
+
+	for _, tc := range []struct {
+		name    string
+		issueID string
+		command string
+		timeout time.Duration
+		output  string
+	}{
+		{"urgent", urgent.ID, "bash", time.Minute, "urgent"},
+		{"medium", normal.ID, "sh", 10 * time.Minute, "default"},
+	} {
+		details := h.linear.Issue(tc.issueID)
+		input := h.o.buildInput(&details, &h.cfg.Pipeline.Stages[0], "Todo", nil)
+		if input.Command != tc.command || input.Timeout != tc.timeout {
+			t.Errorf("%s: command %s with timeout %s, want %s with %s", tc.name, input.Command, input.Timeout, tc.command, tc.timeout)
+		}
+
+		h.process(&details)
+		if out := strings.TrimSpace(h.lastRun(tc.issueID).Output); out != tc.output {
+			t.Errorf("%s: run output %q, want %q", tc.name, out, tc.output)
+		}
+	}
+}
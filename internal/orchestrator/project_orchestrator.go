@@ -42,7 +42,7 @@ type plannedIssue struct {
 // ProcessProject runs the project pipeline stage for a single project.
 // It handles dedup, subprocess execution, issue creation, and label removal.
 func (po *ProjectOrchestrator) ProcessProject(ctx context.Context, project linear.Project, stage config.ProjectStageConfig) {
-	log := slog.With("project", project.Name, "stage", stage.Name)
+	log := slog.With("project", logContent(po.cfg, project.Name), "projectID", project.ID, "stage", stage.Name)
 
 	// Concurrent dedup via DB unique index
 	runID, err := po.store.StartProjectRun(project.ID, stage.Name)
@@ -93,6 +93,8 @@ func (po *ProjectOrchestrator) processProject(ctx context.Context, runID int64,
 		Args:               stage.Args,
 		Timeout:            stage.ParsedTimeout(),
 		ContextMode:        po.cfg.Subprocess.ContextMode,
+		Model:              po.cfg.Subprocess.Model,
+		Provider:           po.cfg.Subprocess.Provider,
 		ProjectID:          project.ID,
 		ProjectName:        project.Name,
 		ProjectDescription: project.Description,
@@ -124,17 +126,18 @@ func (po *ProjectOrchestrator) processProject(ctx context.Context, runID int64,
 	}
 	log.Info("subprocess returned planned issues", "count", len(planned))
 
-	// 6. Resolve next_state → state ID
-	stateID, ok := po.linear.ResolveStateID(stage.NextState)
+	// 6. Resolve next_state → state ID. Planned issues go to linear.team_key.
+	team := po.cfg.Linear.TeamKey
+	stateID, ok := po.linear.ResolveStateID(team, stage.NextState)
 	if !ok {
 		return fmt.Errorf("next_state %q not found in Linear workflow states", stage.NextState)
 	}
 
 	// 7. Create each planned issue
-	teamID := po.linear.TeamID()
+	teamID := po.linear.TeamID(team)
 	created := 0
 	for _, pi := range planned {
-		labelIDs := po.linear.ResolveIssueLabels(pi.Labels)
+		labelIDs := po.linear.ResolveIssueLabels(team, pi.Labels)
 
 		issueID, err := po.linear.CreateIssue(ctx, linear.CreateIssueInput{
 			TeamID:      teamID,
@@ -146,11 +149,11 @@ func (po *ProjectOrchestrator) processProject(ctx context.Context, runID int64,
 			LabelIDs:    labelIDs,
 		})
 		if err != nil {
-			log.Error("creating planned issue", "title", pi.Title, "error", err)
+			log.Error("creating planned issue", "title", logContent(po.cfg, pi.Title), "error", err)
 			// Continue creating other issues rather than aborting
 			continue
 		}
-		log.Info("created issue", "title", pi.Title, "id", issueID)
+		log.Info("created issue", "title", logContent(po.cfg, pi.Title), "id", issueID)
 		created++
 	}
 
@@ -0,0 +1,44 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+func TestFailedPRCreationRecoveredNextRun(t *testing.T) {
+	h := newHarness(t, testLinearYAML+implementStageYAML)
+	bare := h.withGit()
+	h.gh.Respond(t, "pr create", "", "HTTP 422: Validation Failed", 1)
+	issue := h.issue("In Progress")
+
+	h.process(issue)
+	if got := h.state(issue.ID); got != "Failed" {
+		t.Fatalf("state after failed PR creation = %q, want Failed", got)
+	}
+	branch := testutil.RunGit(t, bare, "for-each-ref", "--format=%(refname:short)", "refs/heads/eng-*")
+	if branch == "" {
+		t.Fatal("branch was not pushed before PR creation failed")
+	}
+	if pending, err := h.store.IsPRPending(issue.ID, branch); err != nil || !pending {
+		t.Fatalf("PR pending = %v, %v, want the branch marked", pending, err)
+	}
+
+	// GitHub recovers; the re-run has nothing new to push but still opens the PR
+	h.gh.Respond(t, "pr create", testutil.DefaultPRURL+"\n", "", 0)
+	h.linear.MoveIssue(issue.ID, "In Progress")
+	h.process(issue)
+
+	if got := h.state(issue.ID); got != "In Review" {
+		t.Errorf("state after re-run = %q, want In Review", got)
+	}
+	if run := h.lastRun(issue.ID); run.PRURL != testutil.DefaultPRURL {
+		t.Errorf("re-run PR = %q, want %s", run.PRURL, testutil.DefaultPRURL)
+	}
+	if got := len(h.gh.Calls("pr", "create")); got != 2 {
+		t.Errorf("gh pr create called %d times, want 2", got)
+	}
+	if pending, err := h.store.IsPRPending(issue.ID, branch); err != nil || pending {
+		t.Errorf("PR pending after the re-run = %v, %v, want cleared", pending, err)
+	}
+}
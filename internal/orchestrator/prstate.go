@@ -0,0 +1,171 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/git"
+	"github.com/mauza/ai-flow/internal/github"
+	"github.com/mauza/ai-flow/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Labels mirroring the state of an issue's PR when track_pr_state is set for
+// its repo.
+const (
+	prOpenLabel   = "pr-open"
+	prMergedLabel = "pr-merged"
+	prClosedLabel = "pr-closed"
+)
+
+// prStateLabels maps a gh PR state to the labels to add to and remove from
+// the issue. Unknown states map to nothing.
+func prStateLabels(state string) (add, remove []string) {
+	switch state {
+	case "OPEN":
+		return []string{prOpenLabel}, []string{prMergedLabel, prClosedLabel}
+	case "MERGED":
+		return []string{prMergedLabel}, []string{prOpenLabel, prClosedLabel}
+	case "CLOSED":
+		return []string{prClosedLabel}, []string{prOpenLabel, prMergedLabel}
+	}
+	return nil, nil
+}
+
+// RunPRStateReconciler syncs PR state labels every interval until ctx is
+// cancelled.
+func (o *Orchestrator) RunPRStateReconciler(ctx context.Context, interval time.Duration) {
+	o.reconcilePRStates(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			o.reconcilePRStates(ctx)
+		}
+	}
+}
+
+// reconcilePRStates checks every PR that was open (or never checked) on a
+// repo with track_pr_state and relabels its issue when the state changed. A
+// PR whose labels can't be updated keeps its old recorded state, so it is
+// retried next time.
+func (o *Orchestrator) reconcilePRStates(ctx context.Context) {
+	prs, err := o.store.ListOpenPRs()
+	if err != nil {
+		slog.Error("listing open PRs", "error", err)
+		return
+	}
+	for _, pr := range prs {
+		if ctx.Err() != nil {
+			return
+		}
+		if !o.cfg.TrackPRState(git.RepoOfPR(pr.PRURL)) {
+			continue
+		}
+		state, err := o.git.PRState(ctx, pr.PRURL)
+		if err != nil {
+			slog.Warn("checking PR state", "error", err, "prURL", pr.PRURL)
+			continue
+		}
+		if state == pr.State {
+			continue
+		}
+		add, remove := prStateLabels(state)
+		if add == nil {
+			slog.Warn("unknown PR state", "state", state, "prURL", pr.PRURL)
+			continue
+		}
+		// Label IDs belong to the issue's team
+		details, err := o.client.GetIssue(ctx, pr.IssueID)
+		if err != nil {
+			slog.Error("fetching issue for PR state labels", "error", err, "issueID", pr.IssueID, "prURL", pr.PRURL)
+			continue
+		}
+		if err := o.client.RemoveLabels(ctx, pr.IssueID, o.client.ResolveIssueLabels(details.Team.Key, remove)); err != nil {
+			slog.Error("removing PR state labels", "error", err, "issueID", pr.IssueID, "prURL", pr.PRURL)
+			continue
+		}
+		if err := o.client.AddLabels(ctx, pr.IssueID, o.client.ResolveIssueLabels(details.Team.Key, add)); err != nil {
+			slog.Error("adding PR state label", "error", err, "issueID", pr.IssueID, "prURL", pr.PRURL)
+			continue
+		}
+		if err := o.store.SetPRState(pr.PRURL, state); err != nil {
+			slog.Error("recording PR state", "error", err, "prURL", pr.PRURL)
+			continue
+		}
+		slog.Info("updated PR state label",
+			"issueID", pr.IssueID,
+			"prURL", pr.PRURL,
+			"state", state,
+		)
+	}
+}
+
+// prClosedState returns the Linear state an issue moves to when its PR is
+// closed, or "" to leave it where it is. Only merges move issues.
+func prClosedState(event github.PullRequestEvent, mergedState string) string {
+	if event.Merged {
+		return mergedState
+	}
+	return ""
+}
+
+// HandlePullRequestClosed handles a GitHub pull_request "closed" delivery.
+// If a run opened the PR and it was merged, the run's issue moves to the
+// on_pr_merged_state of the PR's repo.
+func (o *Orchestrator) HandlePullRequestClosed(ctx context.Context, event github.PullRequestEvent) {
+	ctx, span := telemetry.Tracer().Start(ctx, "webhook pull_request", trace.WithAttributes(
+		attribute.String("pr", event.URL),
+	))
+	defer span.End()
+
+	runs, err := o.store.GetRunsByPRURL(event.URL)
+	if err != nil {
+		slog.Error("looking up runs for PR", "error", err, "prURL", event.URL)
+		return
+	}
+	if len(runs) == 0 {
+		slog.Debug("ignoring PR not opened by ai-flow", "prURL", event.URL)
+		return
+	}
+	issueID := runs[0].IssueID
+
+	target := prClosedState(event, o.cfg.OnPRMergedState(git.RepoOfPR(event.URL)))
+	if target == "" {
+		slog.Info("PR closed, leaving issue as is",
+			"issueID", issueID,
+			"prURL", event.URL,
+			"merged", event.Merged,
+		)
+		return
+	}
+	details, err := o.client.GetIssue(ctx, issueID)
+	if err != nil {
+		slog.Error("fetching issue for merged PR", "error", err, "issueID", issueID)
+		return
+	}
+	stateID, ok := o.client.ResolveStateID(details.Team.Key, target)
+	if !ok {
+		slog.Error("cannot resolve on_pr_merged_state", "state", target, "issueID", issueID)
+		return
+	}
+	if err := o.client.UpdateIssueState(ctx, issueID, stateID); err != nil {
+		slog.Error("transitioning issue for merged PR", "error", err, "issueID", issueID, "state", target)
+		return
+	}
+	slog.Info("PR merged, transitioned issue",
+		"issueID", issueID,
+		"prURL", event.URL,
+		"to", target,
+	)
+	comment := fmt.Sprintf("**ai-flow: PR merged** — %s, moved to %s", event.URL, target)
+	if err := o.client.PostComment(ctx, issueID, comment); err != nil {
+		slog.Error("posting PR merged comment", "error", err, "issueID", issueID)
+	}
+}
@@ -0,0 +1,142 @@
+package orchestrator
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/github"
+)
+
+func TestPRStateLabels(t *testing.T) {
+	tests := []struct {
+		state       string
+		add, remove []string
+	}{
+		{"OPEN", []string{"pr-open"}, []string{"pr-merged", "pr-closed"}},
+		{"MERGED", []string{"pr-merged"}, []string{"pr-open", "pr-closed"}},
+		{"CLOSED", []string{"pr-closed"}, []string{"pr-open", "pr-merged"}},
+		{"DRAFT", nil, nil},
+	}
+	for _, tt := range tests {
+		add, remove := prStateLabels(tt.state)
+		if !slices.Equal(add, tt.add) || !slices.Equal(remove, tt.remove) {
+			t.Errorf("prStateLabels(%q) = %q, %q, want %q, %q", tt.state, add, remove, tt.add, tt.remove)
+		}
+	}
+}
+
+// trackPRStateYAML tracks PR state globally but not for acme/docs.
+const trackPRStateYAML = `
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    prompt: Plan it.
+    next_state: In Progress
+github:
+  track_pr_state: true
+projects:
+  Docs:
+    github_repo: acme/docs
+    track_pr_state: false
+`
+
+func TestReconcileRelabelsMergedPR(t *testing.T) {
+	h := newHarness(t, testLinearYAML+trackPRStateYAML)
+	h.withGit()
+	h.gh.Respond(t, "pr view", "MERGED\n", "", 0)
+	app := h.issue("In Review", "pr-open")
+	docs := h.issue("In Review", "pr-open")
+	h.linear.LabelID("pr-merged")
+	if err := h.client.LoadWorkflowStates(context.Background(), "ENG"); err != nil {
+		t.Fatal(err)
+	}
+	const appPR, docsPR = "https://github.com/acme/app/pull/1", "https://github.com/acme/docs/pull/2"
+	for issueID, prURL := range map[string]string{app.ID: appPR, docs.ID: docsPR} {
+		runID, _, err := h.store.StartRun(issueID, "implement")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := h.store.CompleteRun(runID, 0, "", prURL, "branch"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h.o.reconcilePRStates(context.Background())
+
+	labels := func(id string) []string {
+		issue := h.linear.Issue(id)
+		return issue.LabelNames()
+	}
+	if got := labels(app.ID); !slices.Equal(got, []string{"pr-merged"}) {
+		t.Errorf("acme/app issue labels = %q, want pr-open swapped for pr-merged", got)
+	}
+	if got := labels(docs.ID); !slices.Equal(got, []string{"pr-open"}) {
+		t.Errorf("acme/docs issue labels = %q, want them untouched", got)
+	}
+	calls := h.gh.Calls("pr", "view")
+	if len(calls) != 1 || calls[0][2] != appPR {
+		t.Errorf("gh pr view calls = %q, want only %s", calls, appPR)
+	}
+
+	// A merged PR is no longer checked
+	h.o.reconcilePRStates(context.Background())
+	if got := len(h.gh.Calls("pr", "view")); got != 1 {
+		t.Errorf("gh pr view calls after a second pass = %d, want 1", got)
+	}
+}
+
+// mergedStateYAML moves issues to Done when their PR merges, and acme/docs
+// issues to In Review instead.
+const mergedStateYAML = `
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    prompt: Plan it.
+    next_state: In Progress
+github:
+  webhook_secret: gh-secret
+  on_pr_merged_state: Done
+projects:
+  Docs:
+    github_repo: acme/docs
+    on_pr_merged_state: In Review
+`
+
+func TestMergedPRTransitionsIssue(t *testing.T) {
+	h := newHarness(t, testLinearYAML+mergedStateYAML)
+	app := h.issue("In Progress")
+	docs := h.issue("In Progress")
+	closed := h.issue("In Progress")
+	const appPR, docsPR, closedPR = "https://github.com/acme/app/pull/1", "https://github.com/acme/docs/pull/2", "https://github.com/acme/app/pull/3"
+	for issueID, prURL := range map[string]string{app.ID: appPR, docs.ID: docsPR, closed.ID: closedPR} {
+		runID, _, err := h.store.StartRun(issueID, "implement")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := h.store.CompleteRun(runID, 0, "", prURL, "branch"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	h.o.HandlePullRequestClosed(ctx, github.PullRequestEvent{Action: "closed", URL: appPR, Merged: true})
+	h.o.HandlePullRequestClosed(ctx, github.PullRequestEvent{Action: "closed", URL: docsPR, Merged: true})
+	h.o.HandlePullRequestClosed(ctx, github.PullRequestEvent{Action: "closed", URL: closedPR, Merged: false})
+	h.o.HandlePullRequestClosed(ctx, github.PullRequestEvent{Action: "closed", URL: "https://github.com/acme/app/pull/99", Merged: true})
+
+	if got := h.state(app.ID); got != "Done" {
+		t.Errorf("acme/app issue state = %q, want github.on_pr_merged_state Done", got)
+	}
+	if _, ok := h.commentContaining(app.ID, "PR merged"); !ok {
+		t.Errorf("acme/app comments = %q, want a PR merged comment", h.comments(app.ID))
+	}
+	if got := h.state(docs.ID); got != "In Review" {
+		t.Errorf("acme/docs issue state = %q, want the project's In Review", got)
+	}
+	if got := h.state(closed.ID); got != "In Progress" {
+		t.Errorf("issue with a PR closed unmerged moved to %q", got)
+	}
+}
@@ -0,0 +1,16 @@
+package orchestrator
+
+import "github.com/mauza/ai-flow/internal/config"
+
+// redacted replaces issue content in log attributes when
+// server.redact_issue_content is set.
+const redacted = "[redacted]"
+
+// logContent returns s for use as a log attribute, or a placeholder when the
+// config asks for titles, descriptions, and subprocess output to stay out of logs.
+func logContent(cfg *config.Config, s string) string {
+	if cfg.Server.RedactIssueContent {
+		return redacted
+	}
+	return s
+}
@@ -0,0 +1,50 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/linear"
+)
+
+const sensitiveTitle = "Acquire Initech before Q3"
+
+// sensitivePipelineYAML fails a stage with output quoting the issue, so issue
+// content reaches the failure log lines.
+const sensitivePipelineYAML = `
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    args: ["-c", "echo 'cannot plan: Acquire Initech before Q3' >&2; exit 1"]
+    prompt: Plan it.
+    next_state: In Progress
+    failure_state: Failed
+`
+
+func TestRedactIssueContentKeepsTitlesOutOfLogs(t *testing.T) {
+	for _, redact := range []bool{false, true} {
+		cfg := testLinearYAML + sensitivePipelineYAML
+		if redact {
+			cfg += "server:\n  redact_issue_content: true\n"
+		}
+		h := newHarness(t, cfg)
+		issue := h.issueWith("Todo", func(issue *linear.IssueDetails) {
+			issue.Title = sensitiveTitle
+			issue.Description = "Board approved the " + sensitiveTitle + " plan."
+		})
+		logs := captureLog(t)
+
+		h.process(issue)
+		if h.state(issue.ID) != "Failed" {
+			t.Fatalf("redact=%v: stage did not fail", redact)
+		}
+		leaked := strings.Contains(logs.String(), sensitiveTitle)
+		if leaked == redact {
+			t.Errorf("redact=%v: title in logs = %v; logs:\n%s", redact, leaked, logs)
+		}
+		if redact && !strings.Contains(logs.String(), issue.Identifier) {
+			t.Errorf("redacted logs lost the issue identifier:\n%s", logs)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/linear"
+)
+
+// issueJSON decodes an IssueDetails literal, for fields like Project whose
+// types are anonymous.
+func issueJSON(t *testing.T, s string) *linear.IssueDetails {
+	t.Helper()
+	var details linear.IssueDetails
+	if err := json.Unmarshal([]byte(s), &details); err != nil {
+		t.Fatal(err)
+	}
+	return &details
+}
+
+func TestResolveRepoConfigFromProjectMap(t *testing.T) {
+	h := newHarness(t, testLinearYAML+planStageYAML+`
+projects:
+  Web:
+    github_repo: acme/web
+    default_branch: develop
+  OPS:
+    github_repo: acme/infra
+    default_branch: main
+`)
+	description := `"description": "---\ngithub_repo: acme/app\ndefault_branch: trunk\n---\nFix it."`
+	for _, tc := range []struct {
+		name       string
+		issue      string
+		repo, base string
+	}{
+		{"project hit", `{"identifier": "ENG-1", "team": {"key": "ENG"}, "project": {"name": "Web"}, ` + description + `}`, "acme/web", "develop"},
+		{"team hit", `{"identifier": "OPS-1", "team": {"key": "OPS"}, "project": {"name": "Unmapped"}, ` + description + `}`, "acme/infra", "main"},
+		{"project miss", `{"identifier": "ENG-2", "team": {"key": "ENG"}, "project": {"name": "Unmapped"}, ` + description + `}`, "acme/app", "trunk"},
+		{"no project", `{"identifier": "ENG-3", "team": {"key": "ENG"}, ` + description + `}`, "acme/app", "trunk"},
+	} {
+		repo, base, err := h.o.resolveRepoConfig(context.Background(), issueJSON(t, tc.issue))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if repo != tc.repo || base != tc.base {
+			t.Errorf("%s: resolved %s@%s, want %s@%s", tc.name, repo, base, tc.repo, tc.base)
+		}
+	}
+
+	if _, _, err := h.o.resolveRepoConfig(context.Background(), issueJSON(t, `{"identifier": "ENG-4", "team": {"key": "ENG"}, "description": "No metadata."}`)); err == nil {
+		t.Error("resolved a repo for an unmapped issue without description metadata")
+	}
+}
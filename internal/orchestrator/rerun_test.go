@@ -0,0 +1,65 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/linear"
+)
+
+const approvalStageYAML = `
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    args: ["-c", "echo planned"]
+    prompt: Plan it.
+    next_state: In Progress
+    wait_for_approval: true
+`
+
+// comment delivers a Comment create webhook for a human comment on the issue.
+func (h *harness) comment(issueID, body string) {
+	h.t.Helper()
+	data, err := json.Marshal(linear.CommentData{ID: "comment-" + body, Body: body, IssueID: issueID, UserID: "user-1"})
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	h.o.HandleCommentWebhook(context.Background(), linear.WebhookPayload{Type: "Comment", Action: "create", Data: data})
+}
+
+func TestCommentRerunsApprovalStage(t *testing.T) {
+	h := newHarness(t, testLinearYAML+approvalStageYAML)
+	issue := h.issue("Todo")
+	h.process(issue)
+
+	h.comment(issue.ID, "Please also cover the edge case.")
+
+	if got := len(h.runs(issue.ID)); got != 2 {
+		t.Errorf("%d runs after a comment, want 2", got)
+	}
+}
+
+func TestCommentWithinRerunIntervalIgnored(t *testing.T) {
+	h := newHarness(t, linearYAML("  rerun_min_interval: 1h\n")+approvalStageYAML)
+	issue := h.issue("Todo")
+	h.process(issue)
+
+	h.comment(issue.ID, "Please also cover the edge case.")
+	h.comment(issue.ID, "And the other one.")
+
+	if got := len(h.runs(issue.ID)); got != 1 {
+		t.Errorf("%d runs after comments within rerun_min_interval, want 1", got)
+	}
+	var notes int
+	for _, body := range h.comments(issue.ID) {
+		if strings.Contains(body, "comment noted") {
+			notes++
+		}
+	}
+	if notes != 1 {
+		t.Errorf("%d cooling-down comments, want 1 for the first ignored comment only", notes)
+	}
+}
@@ -0,0 +1,104 @@
+package orchestrator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+// reviewStageYAML is a creates_pr stage whose review pass runs reviewScript.
+func reviewStageYAML(t *testing.T, reviewScript string) string {
+	t.Helper()
+	prompt := filepath.Join(t.TempDir(), "review.md")
+	if err := os.WriteFile(prompt, []byte("Review the change."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return `
+pipeline:
+  - name: implement
+    linear_state: In Progress
+    command: sh
+    args: ["-c", "echo change > change.txt"]
+    prompt: Implement it.
+    next_state: In Review
+    failure_state: Failed
+    creates_pr: true
+    review_command: sh
+    review_args: ["-c", "` + reviewScript + `"]
+    review_prompt_file: ` + prompt + `
+`
+}
+
+func TestReviewPassCreatesPR(t *testing.T) {
+	h := newHarness(t, testLinearYAML+reviewStageYAML(t, "test -f change.txt"))
+	bare := h.withGit()
+	issue := h.issue("In Progress")
+
+	h.process(issue)
+	if got := h.state(issue.ID); got != "In Review" {
+		t.Errorf("state = %q, want In Review", got)
+	}
+	if calls := h.gh.Calls("pr", "create"); len(calls) != 1 {
+		t.Errorf("got %d gh pr create calls, want 1", len(calls))
+	}
+	branch := h.lastRun(issue.ID).BranchName
+	if branch == "" {
+		t.Fatal("run recorded no branch")
+	}
+	if !runGitOK(bare, "cat-file", "-e", branch+":change.txt") {
+		t.Errorf("pushed branch %s is missing change.txt", branch)
+	}
+}
+
+func TestReviewFailCommitsNothing(t *testing.T) {
+	h := newHarness(t, testLinearYAML+reviewStageYAML(t, "echo tests are missing; exit 1"))
+	bare := h.withGit()
+	issue := h.issue("In Progress")
+
+	h.process(issue)
+	if got := h.state(issue.ID); got != "Failed" {
+		t.Errorf("state = %q, want Failed", got)
+	}
+	if calls := h.gh.Calls("pr", "create"); len(calls) != 0 {
+		t.Errorf("review failed but gh pr create was called: %q", calls)
+	}
+	if branches := testutil.RunGit(t, bare, "branch", "--list"); branches != "* main" && branches != "main" {
+		t.Errorf("remote branches = %q, want only main", branches)
+	}
+	run := h.lastRun(issue.ID)
+	if run.Status != "failed" || run.Error != "review: tests are missing\n" {
+		t.Errorf("run = %s %q, want failed with the review's output", run.Status, run.Error)
+	}
+	if _, ok := h.commentContaining(issue.ID, "nothing was committed"); !ok {
+		t.Errorf("no failure comment saying nothing was committed: %q", h.comments(issue.ID))
+	}
+}
+
+func TestReviewFailCommitsNothingOnCommentRerun(t *testing.T) {
+	h := newHarness(t, testLinearYAML+reviewStageYAML(t, "echo tests are missing; exit 1")+"    wait_for_approval: true\n")
+	bare := h.withGit()
+	issue := h.issue("In Progress")
+
+	h.comment(issue.ID, "Please also cover the edge case.")
+	if calls := h.gh.Calls("pr", "create"); len(calls) != 0 {
+		t.Errorf("review failed but gh pr create was called: %q", calls)
+	}
+	if branches := testutil.RunGit(t, bare, "branch", "--list"); branches != "* main" && branches != "main" {
+		t.Errorf("remote branches = %q, want only main", branches)
+	}
+	run := h.lastRun(issue.ID)
+	if run.Status != "failed" || run.Error != "review: tests are missing\n" {
+		t.Errorf("run = %s %q, want failed with the review's output", run.Status, run.Error)
+	}
+	if _, ok := h.commentContaining(issue.ID, "nothing was committed"); !ok {
+		t.Errorf("no failure comment saying nothing was committed: %q", h.comments(issue.ID))
+	}
+}
+
+// runGitOK reports whether git succeeds in dir.
+func runGitOK(dir string, args ...string) bool {
+	return exec.Command("git", append([]string{"-C", dir}, args...)...).Run() == nil
+}
@@ -0,0 +1,56 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+)
+
+const runtimeCapYAML = `
+pipeline:
+  max_runtime_per_issue: 100ms
+  stages:
+    - name: plan
+      linear_state: Todo
+      command: sh
+      args: ["-c", "sleep 0.2; echo planned"]
+      prompt: Plan it.
+      next_state: In Progress
+`
+
+func TestRuntimeCapRefusesNewRuns(t *testing.T) {
+	h := newHarness(t, testLinearYAML+runtimeCapYAML)
+	issue := h.issue("Todo")
+	other := h.issue("Todo")
+
+	h.process(issue)
+	if runs := h.runs(issue.ID); len(runs) != 1 || runs[0].Status != "completed" {
+		t.Fatalf("runs = %+v, want the first run to go ahead", runs)
+	}
+
+	// Back to Todo twice: both refused, only the first with a comment
+	for range 2 {
+		h.linear.MoveIssue(issue.ID, "Todo")
+		h.process(issue)
+	}
+	if runs := h.runs(issue.ID); len(runs) != 1 {
+		t.Errorf("got %d runs, want no new runs once the cap is used up", len(runs))
+	}
+	var notices []string
+	for _, body := range h.comments(issue.ID) {
+		if strings.Contains(body, "runtime limit reached") {
+			notices = append(notices, body)
+		}
+	}
+	if len(notices) != 1 || !strings.Contains(notices[0], "stage `plan` will not run") {
+		t.Errorf("runtime limit comments = %q, want one naming the stage", notices)
+	}
+	if got := h.state(issue.ID); got != "Todo" {
+		t.Errorf("state = %q, want the refused issue left in Todo", got)
+	}
+
+	// The cap is per issue
+	h.process(other)
+	if runs := h.runs(other.ID); len(runs) != 1 {
+		t.Errorf("got %d runs for another issue, want its own budget", len(runs))
+	}
+}
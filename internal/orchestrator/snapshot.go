@@ -0,0 +1,54 @@
+package orchestrator
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/mauza/ai-flow/internal/git"
+)
+
+// workspaceSnapshot records a persistent workspace's HEAD before a stage runs,
+// so a run that fails after the command has touched the tree doesn't leave
+// dirty changes behind for the next run to trip over.
+type workspaceSnapshot struct {
+	git        *git.Manager
+	dir        string
+	head       string
+	identifier string
+	kept       bool
+}
+
+// snapshotWorkspace records dir's current HEAD. It returns nil for temporary
+// workspaces, which are discarded after the run anyway.
+func (o *Orchestrator) snapshotWorkspace(ctx context.Context, dir, identifier string) *workspaceSnapshot {
+	if o.cfg.Workspace.Root == "" {
+		return nil
+	}
+	head, err := o.git.HeadCommit(ctx, dir)
+	if err != nil {
+		slog.Warn("snapshotting workspace, rollback disabled for this run", "error", err, "issue", identifier)
+		return nil
+	}
+	return &workspaceSnapshot{git: o.git, dir: dir, head: head, identifier: identifier}
+}
+
+// keep marks the run's changes as wanted, so rollback leaves them in place.
+func (s *workspaceSnapshot) keep() {
+	if s != nil {
+		s.kept = true
+	}
+}
+
+// rollback resets the workspace to the snapshot unless keep was called.
+func (s *workspaceSnapshot) rollback(ctx context.Context) {
+	if s == nil || s.kept {
+		return
+	}
+	ctx, cancel := reportContext(ctx)
+	defer cancel()
+	if err := s.git.ResetHard(ctx, s.dir, s.head); err != nil {
+		slog.Warn("rolling back workspace", "error", err, "issue", s.identifier, "commit", s.head)
+		return
+	}
+	slog.Info("rolled back workspace to pre-run commit", "issue", s.identifier, "commit", s.head)
+}
@@ -0,0 +1,39 @@
+package orchestrator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+func TestFailedPushRollsWorkspaceBack(t *testing.T) {
+	root := t.TempDir()
+	h := newHarness(t, testLinearYAML+"workspace:\n  root: "+root+"\n"+implementStageYAML)
+	bare := h.withGit()
+	base := testutil.RunGit(t, bare, "rev-parse", "main")
+
+	// The remote turns every push away
+	hook := filepath.Join(bare, "hooks", "pre-receive")
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\necho rejected >&2\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	issue := h.issue("In Progress")
+	h.process(issue)
+
+	if run := h.lastRun(issue.ID); run.Status != "failed" {
+		t.Fatalf("run status = %q, want failed", run.Status)
+	}
+	ws := h.o.workspacePath("acme/app", "eng-1-fix-the-thing")
+	if got := testutil.RunGit(t, ws, "rev-parse", "HEAD"); got != base {
+		t.Errorf("workspace HEAD = %s, want pre-run commit %s", got, base)
+	}
+	if got := testutil.RunGit(t, ws, "status", "--porcelain", "--untracked-files=all"); got != "" {
+		t.Errorf("workspace status = %q, want clean", got)
+	}
+	if _, err := os.Stat(filepath.Join(ws, "change.txt")); !os.IsNotExist(err) {
+		t.Errorf("the command's change.txt survived the rollback: %v", err)
+	}
+}
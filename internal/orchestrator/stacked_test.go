@@ -0,0 +1,169 @@
+package orchestrator
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/git"
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+func TestStackedBranchTargetsPreviousBranch(t *testing.T) {
+	h := newHarness(t, testLinearYAML+`
+pipeline:
+  - name: implement
+    linear_state: In Progress
+    command: sh
+    args: ["-c", "echo one > one.txt"]
+    prompt: Implement it.
+    next_state: In Review
+    creates_pr: true
+  - name: Follow Up
+    linear_state: In Review
+    command: sh
+    args: ["-c", "echo two > two.txt"]
+    prompt: Follow up.
+    next_state: Done
+    creates_pr: true
+    branch_from: previous
+`)
+	bare := h.withGit()
+	issue := h.issue("In Progress")
+
+	h.process(issue)
+	h.process(issue)
+	if got := h.state(issue.ID); got != "Done" {
+		t.Fatalf("state = %q, want Done", got)
+	}
+
+	parent := git.SanitizeBranchName(issue.Identifier, issue.Title)
+	child := parent + "-follow-up"
+	creates := h.gh.Calls("pr", "create")
+	if len(creates) != 2 {
+		t.Fatalf("got %d gh pr create calls, want 2", len(creates))
+	}
+	if base, head := testutil.ArgValue(creates[0], "--base"), testutil.ArgValue(creates[0], "--head"); base != "main" || head != parent {
+		t.Errorf("first PR %s <- %s, want main <- %s", base, head, parent)
+	}
+	if base, head := testutil.ArgValue(creates[1], "--base"), testutil.ArgValue(creates[1], "--head"); base != parent || head != child {
+		t.Errorf("stacked PR %s <- %s, want %s <- %s", base, head, parent, child)
+	}
+
+	// The stacked branch builds on the parent's commit
+	if got, want := testutil.RunGit(t, bare, "rev-parse", child+"^"), testutil.RunGit(t, bare, "rev-parse", parent); got != want {
+		t.Errorf("%s's parent commit = %s, want %s's tip %s", child, got, parent, want)
+	}
+	if run := h.lastRun(issue.ID); run.BranchName != child {
+		t.Errorf("recorded branch = %q, want %q", run.BranchName, child)
+	}
+}
+
+func TestStackedBranchWithoutPreviousUsesBase(t *testing.T) {
+	h := newHarness(t, testLinearYAML+`
+pipeline:
+  - name: implement
+    linear_state: In Progress
+    command: sh
+    args: ["-c", "echo one > one.txt"]
+    prompt: Implement it.
+    next_state: In Review
+    creates_pr: true
+    branch_from: previous
+`)
+	h.withGit()
+	issue := h.issue("In Progress")
+
+	h.process(issue)
+	creates := h.gh.Calls("pr", "create")
+	if len(creates) != 1 {
+		t.Fatalf("got %d gh pr create calls, want 1", len(creates))
+	}
+	want := git.SanitizeBranchName(issue.Identifier, issue.Title)
+	if base, head := testutil.ArgValue(creates[0], "--base"), testutil.ArgValue(creates[0], "--head"); base != "main" || head != want {
+		t.Errorf("PR %s <- %s, want main <- %s", base, head, want)
+	}
+}
+
+func TestStackedBranchCycleKeepsItsOwnPR(t *testing.T) {
+	h := newHarness(t, testLinearYAML+`
+pipeline:
+  - name: implement
+    linear_state: In Progress
+    command: sh
+    args: ["-c", "echo one > one.txt"]
+    prompt: Implement it.
+    next_state: In Review
+    creates_pr: true
+  - name: Follow Up
+    linear_state: In Review
+    command: sh
+    args: ["-c", "date +%s%N >> two.txt"]
+    prompt: Follow up.
+    next_state: Done
+    creates_pr: true
+    branch_from: previous
+`)
+	h.withGit()
+	issue := h.issue("In Progress")
+
+	h.process(issue)
+	stackedURL := "https://github.com/acme/app/pull/2"
+	h.gh.Respond(t, "pr create", stackedURL+"\n", "", 0)
+	h.process(issue)
+
+	// Cycle back: the stacked branch already exists on the remote
+	h.linear.MoveIssue(issue.ID, "In Review")
+	h.process(issue)
+
+	if creates := h.gh.Calls("pr", "create"); len(creates) != 2 {
+		t.Errorf("got %d gh pr create calls, want 2", len(creates))
+	}
+	if run := h.lastRun(issue.ID); run.PRURL != stackedURL {
+		t.Errorf("recorded PR = %q, want the stacked branch's %q", run.PRURL, stackedURL)
+	}
+	for _, c := range h.gh.Calls("pr", "comment") {
+		if !slices.Contains(c, stackedURL) {
+			t.Errorf("gh pr comment %q is not on the stacked PR", c)
+		}
+	}
+}
+
+func TestStackedBranchFirstRunViaComment(t *testing.T) {
+	h := newHarness(t, testLinearYAML+`
+pipeline:
+  - name: implement
+    linear_state: In Progress
+    command: sh
+    args: ["-c", "echo one > one.txt"]
+    prompt: Implement it.
+    next_state: In Review
+    creates_pr: true
+  - name: Follow Up
+    linear_state: In Review
+    command: sh
+    args: ["-c", "echo two > two.txt"]
+    prompt: Follow up.
+    next_state: Done
+    creates_pr: true
+    branch_from: previous
+    wait_for_approval: true
+`)
+	bare := h.withGit()
+	issue := h.issue("In Progress")
+
+	h.process(issue)
+	h.comment(issue.ID, "Please also cover the edge case.")
+
+	parent := git.SanitizeBranchName(issue.Identifier, issue.Title)
+	child := parent + "-follow-up"
+	creates := h.gh.Calls("pr", "create")
+	if len(creates) != 2 {
+		t.Fatalf("got %d gh pr create calls, want 2", len(creates))
+	}
+	if base, head := testutil.ArgValue(creates[1], "--base"), testutil.ArgValue(creates[1], "--head"); base != parent || head != child {
+		t.Errorf("stacked PR %s <- %s, want %s <- %s", base, head, parent, child)
+	}
+	if got, want := testutil.RunGit(t, bare, "rev-parse", child+"^"), testutil.RunGit(t, bare, "rev-parse", parent); got != want {
+		t.Errorf("%s's parent commit = %s, want %s's tip %s", child, got, parent, want)
+	}
+}
@@ -0,0 +1,43 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMissingCommandAndTimeoutRecordDifferentStatuses(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		command string
+		args    string
+		status  string
+		errText string
+	}{
+		{"missing command", "ai-flow-no-such-command", "[]", "failed", "starting ai-flow-no-such-command"},
+		{"timeout", "sh", `["-c", "exec sleep 5"]`, "timeout", "timed out"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := newHarness(t, testLinearYAML+`
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: `+tc.command+`
+    args: `+tc.args+`
+    prompt: Plan it.
+    next_state: In Progress
+    failure_state: Failed
+    timeout: 1
+`)
+			issue := h.issue("Todo")
+
+			h.process(issue)
+			run := h.lastRun(issue.ID)
+			if run.Status != tc.status || !strings.Contains(run.Error, tc.errText) {
+				t.Errorf("run = %s %q, want %s mentioning %q", run.Status, run.Error, tc.status, tc.errText)
+			}
+			if got := h.state(issue.ID); got != "Failed" {
+				t.Errorf("state = %q, want Failed", got)
+			}
+		})
+	}
+}
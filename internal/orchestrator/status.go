@@ -0,0 +1,97 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// statusKey identifies the status comment of an in-flight run. The store's
+// dedup index allows only one running record per issue+stage, so the pair
+// uniquely identifies a run while it is active.
+func statusKey(issueID, stageName string) string {
+	return issueID + "/" + stageName
+}
+
+// postStatus creates the run's status comment on first use and edits it on
+// every later call, so a run's start → running → done states share one comment.
+// In consolidated comment mode the status is shown in the issue's single comment.
+func (o *Orchestrator) postStatus(ctx context.Context, issueID, stageName, body string) error {
+	key := statusKey(issueID, stageName)
+
+	if o.consolidated() {
+		o.statusMu.Lock()
+		o.statusComments[key] = ""
+		o.statusMu.Unlock()
+		return o.updateConsolidated(ctx, issueID, body, false)
+	}
+
+	o.statusMu.Lock()
+	commentID := o.statusComments[key]
+	o.statusMu.Unlock()
+
+	if commentID != "" {
+		if err := o.client.UpdateComment(ctx, commentID, body); err != nil {
+			return fmt.Errorf("updating status comment: %w", err)
+		}
+		return nil
+	}
+
+	commentID, err := o.client.CreateComment(ctx, issueID, body)
+	if err != nil {
+		return fmt.Errorf("creating status comment: %w", err)
+	}
+	o.statusMu.Lock()
+	o.statusComments[key] = commentID
+	o.statusMu.Unlock()
+	return nil
+}
+
+// finishStatus posts the run's final outcome, replacing the status comment if
+// one was created during the run, and forgets the comment afterwards.
+func (o *Orchestrator) finishStatus(ctx context.Context, issueID, stageName, body string) error {
+	key := statusKey(issueID, stageName)
+
+	o.statusMu.Lock()
+	commentID := o.statusComments[key]
+	delete(o.statusComments, key)
+	o.statusMu.Unlock()
+
+	if o.consolidated() {
+		return o.updateConsolidated(ctx, issueID, body, true)
+	}
+
+	if commentID != "" {
+		err := o.client.UpdateComment(ctx, commentID, body)
+		if err == nil {
+			return nil
+		}
+		// Post a fresh comment instead so the outcome isn't lost
+		slog.Warn("updating status comment, posting a new one", "error", err, "commentID", commentID)
+	}
+	return o.client.PostComment(ctx, issueID, body)
+}
+
+// settleStatus finalizes the status comment only if the run created one, for
+// outcomes (like skips) that otherwise post nothing.
+func (o *Orchestrator) settleStatus(ctx context.Context, issueID, stageName, body string) {
+	key := statusKey(issueID, stageName)
+
+	o.statusMu.Lock()
+	commentID, ok := o.statusComments[key]
+	delete(o.statusComments, key)
+	o.statusMu.Unlock()
+
+	if !ok {
+		return
+	}
+	if o.consolidated() {
+		if err := o.updateConsolidated(ctx, issueID, body, true); err != nil {
+			slog.Warn("updating consolidated comment", "error", err, "issueID", issueID)
+		}
+		return
+	}
+	if err := o.client.UpdateComment(ctx, commentID, body); err != nil {
+		slog.Warn("updating status comment", "error", err, "commentID", commentID)
+	}
+}
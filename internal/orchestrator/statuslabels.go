@@ -0,0 +1,55 @@
+package orchestrator
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/mauza/ai-flow/internal/linear"
+)
+
+// markQueued applies the linear.status_labels queued label to an issue whose
+// run was just recorded.
+func (o *Orchestrator) markQueued(ctx context.Context, details *linear.IssueDetails) {
+	o.setStatusLabels(ctx, details, o.cfg.Linear.StatusLabels.Queued, "")
+}
+
+// markRunning swaps the queued label for the running one as the run's
+// command starts.
+func (o *Orchestrator) markRunning(ctx context.Context, details *linear.IssueDetails) {
+	labels := o.cfg.Linear.StatusLabels
+	o.setStatusLabels(ctx, details, labels.Running, labels.Queued)
+}
+
+// clearStatusLabels removes both status labels once the run has ended,
+// however it ended.
+func (o *Orchestrator) clearStatusLabels(ctx context.Context, details *linear.IssueDetails) {
+	labels := o.cfg.Linear.StatusLabels
+	if labels.Queued == "" && labels.Running == "" {
+		return
+	}
+	ctx, cancel := reportContext(ctx)
+	defer cancel()
+	o.setStatusLabels(ctx, details, "", labels.Queued, labels.Running)
+}
+
+// setStatusLabels adds the add label, if any, and removes the remove labels.
+// Failures are only logged: the labels are informational.
+func (o *Orchestrator) setStatusLabels(ctx context.Context, details *linear.IssueDetails, add string, remove ...string) {
+	issueID := details.ID
+	var names []string
+	for _, name := range remove {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		if err := o.client.RemoveLabels(ctx, issueID, o.client.ResolveIssueLabels(details.Team.Key, names)); err != nil {
+			slog.Warn("removing status labels", "error", err, "issueID", issueID, "labels", names)
+		}
+	}
+	if add != "" {
+		if err := o.client.AddLabels(ctx, issueID, o.client.ResolveIssueLabels(details.Team.Key, []string{add})); err != nil {
+			slog.Warn("adding status label", "error", err, "issueID", issueID, "label", add)
+		}
+	}
+}
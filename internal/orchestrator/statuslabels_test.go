@@ -0,0 +1,111 @@
+package orchestrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStatusLabelsFollowRunLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	h := newHarness(t, `
+linear:
+  api_key: test-key
+  team_key: ENG
+  webhook_secret: secret
+  status_labels:
+    queued: ai-queued
+    running: ai-running
+subprocess:
+  skip_command_check: true
+  max_concurrent: 1
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    args: ["-c", "touch `+dir+`/$$AIFLOW_ISSUE_IDENTIFIER; while [ ! -e `+dir+`/release ]; do sleep 0.01; done; [ $$AIFLOW_ISSUE_IDENTIFIER = ENG-1 ]"]
+    prompt: Plan it.
+    next_state: In Progress
+    failure_state: Failed
+`)
+	h.linear.LabelID("ai-queued")
+	h.linear.LabelID("ai-running")
+	if err := h.client.LoadWorkflowStates(context.Background(), "ENG"); err != nil {
+		t.Fatal(err)
+	}
+	// ENG-1 succeeds; ENG-2 waits for the only slot, then fails
+	first, second := h.issue("Todo"), h.issue("Todo")
+
+	labels := func(issueID string) string {
+		issue := h.linear.Issue(issueID)
+		return strings.Join(issue.LabelNames(), ",")
+	}
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s (labels: %q and %q)", what, labels(first.ID), labels(second.ID))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	started := func(identifier string) bool {
+		_, err := os.Stat(filepath.Join(dir, identifier))
+		return err == nil
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.process(first)
+	}()
+	waitFor("ENG-1 to start", func() bool { return started("ENG-1") })
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.process(second)
+	}()
+	waitFor("ENG-2 to be queued", func() bool { return labels(second.ID) == "ai-queued" })
+
+	if got := labels(first.ID); got != "ai-running" {
+		t.Errorf("running issue labels = %q, want ai-running", got)
+	}
+	if started("ENG-2") {
+		t.Fatal("ENG-2 started while ENG-1 held the only slot")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "release"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	for _, issue := range []string{first.ID, second.ID} {
+		if got := labels(issue); got != "" {
+			t.Errorf("labels after the run = %q, want none", got)
+		}
+	}
+	if h.state(first.ID) != "In Progress" || h.state(second.ID) != "Failed" {
+		t.Errorf("states = %q and %q, want In Progress and Failed", h.state(first.ID), h.state(second.ID))
+	}
+
+	// ENG-2 went queued → running → cleared even though it failed
+	var added []string
+	for _, req := range h.linear.Requests("addedLabelIds") {
+		if req.Variables["id"] == second.ID {
+			for _, id := range req.Variables["labelIds"].([]any) {
+				added = append(added, id.(string))
+			}
+		}
+	}
+	if want := []string{h.linear.LabelID("ai-queued"), h.linear.LabelID("ai-running")}; !slices.Equal(added, want) {
+		t.Errorf("labels added to ENG-2 = %q, want queued then running %q", added, want)
+	}
+}
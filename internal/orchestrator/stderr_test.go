@@ -0,0 +1,53 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+)
+
+const noisyPlanYAML = `
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    args: ["-c", "echo planned; echo 'summary: 3 files' >&2"]
+    prompt: Plan it.
+    next_state: In Progress
+`
+
+func TestStderrOnSuccess(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"default", false},
+		{"enabled", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := testLinearYAML + noisyPlanYAML
+			if tc.enabled {
+				cfg += "    include_stderr_on_success: true\n"
+			}
+			h := newHarness(t, cfg)
+			issue := h.issue("Todo")
+
+			h.process(issue)
+			comment, ok := h.commentContaining(issue.ID, "completed**")
+			if !ok {
+				t.Fatalf("no success comment: %q", h.comments(issue.ID))
+			}
+			output := h.lastRun(issue.ID).Output
+			for where, text := range map[string]string{"comment": comment, "stored output": output} {
+				if !strings.Contains(text, "planned") {
+					t.Errorf("%s %q is missing stdout", where, text)
+				}
+				if got := strings.Contains(text, "summary: 3 files"); got != tc.enabled {
+					t.Errorf("%s includes stderr = %v, want %v: %q", where, got, tc.enabled, text)
+				}
+				if got := strings.Contains(text, "<summary>stderr</summary>"); got != tc.enabled {
+					t.Errorf("%s has a stderr section = %v, want %v", where, got, tc.enabled)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/mauza/ai-flow/internal/config"
+	"github.com/mauza/ai-flow/internal/linear"
+)
+
+// postOutput posts a stage's output comment on the issues its comment_target
+// names. For "parent" and "children" the triggering issue's status comment is
+// finished with a pointer to where the output went; if there are no such
+// issues, or posting to every one of them fails, the output stays on the
+// triggering issue.
+func (o *Orchestrator) postOutput(ctx context.Context, issueID, identifier string, stage *config.StageConfig, comment string) error {
+	targets := o.commentTargets(ctx, issueID, identifier, stage)
+	if len(targets) == 0 {
+		return o.finishStatus(ctx, issueID, stage.Name, comment)
+	}
+
+	var posted []string
+	var errs []error
+	for _, target := range targets {
+		if err := o.client.PostComment(ctx, target.ID, comment); err != nil {
+			errs = append(errs, fmt.Errorf("posting output on %s: %w", target.Identifier, err))
+			continue
+		}
+		posted = append(posted, target.Identifier)
+	}
+	if len(posted) == 0 {
+		return errors.Join(append(errs, o.finishStatus(ctx, issueID, stage.Name, comment))...)
+	}
+
+	slog.Info("posted output on related issues",
+		"issue", identifier,
+		"stage", stage.Name,
+		"commentTarget", stage.CommentTarget,
+		"targets", posted,
+	)
+	note := fmt.Sprintf("**ai-flow: stage `%s` completed** — output posted on %s", stage.Name, strings.Join(posted, ", "))
+	return errors.Join(append(errs, o.finishStatus(ctx, issueID, stage.Name, note))...)
+}
+
+// commentTargets resolves the stage's comment_target to the issues that should
+// get its output. It returns nil when the output belongs on the triggering
+// issue, including when the relatives can't be looked up or don't exist.
+func (o *Orchestrator) commentTargets(ctx context.Context, issueID, identifier string, stage *config.StageConfig) []linear.IssueRef {
+	if stage.CommentTarget != "parent" && stage.CommentTarget != "children" {
+		return nil
+	}
+	relatives, err := o.client.GetIssueRelatives(ctx, issueID)
+	if err != nil {
+		slog.Warn("looking up comment_target issues, posting on the triggering issue", "error", err, "issue", identifier)
+		return nil
+	}
+
+	var targets []linear.IssueRef
+	if stage.CommentTarget == "parent" {
+		if relatives.Parent != nil {
+			targets = []linear.IssueRef{*relatives.Parent}
+		}
+	} else {
+		targets = relatives.Children
+	}
+	if len(targets) == 0 {
+		slog.Warn("issue has no comment_target issues, posting on the triggering issue",
+			"issue", identifier,
+			"stage", stage.Name,
+			"commentTarget", stage.CommentTarget,
+		)
+	}
+	return targets
+}
@@ -0,0 +1,56 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/linear"
+)
+
+const parentTargetYAML = `
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    args: ["-c", "echo planned for the epic"]
+    prompt: Plan it.
+    next_state: In Progress
+    comment_target: parent
+`
+
+func TestOutputPostedOnParentIssue(t *testing.T) {
+	h := newHarness(t, testLinearYAML+parentTargetYAML)
+	parent := h.issue("Backlog")
+	child := h.issue("Todo")
+	h.linear.SetRelatives(child.ID, linear.IssueRelatives{
+		Parent: &linear.IssueRef{ID: parent.ID, Identifier: parent.Identifier},
+	})
+
+	h.process(child)
+
+	if _, ok := h.commentContaining(parent.ID, "planned for the epic"); !ok {
+		t.Errorf("parent comments = %q, want the stage output", h.comments(parent.ID))
+	}
+	for _, body := range h.comments(child.ID) {
+		if strings.Contains(body, "planned for the epic") {
+			t.Errorf("output also posted on the triggering issue: %q", body)
+		}
+	}
+	if _, ok := h.commentContaining(child.ID, "output posted on "+parent.Identifier); !ok {
+		t.Errorf("child comments = %q, want a pointer to %s", h.comments(child.ID), parent.Identifier)
+	}
+	if got := h.state(child.ID); got != "In Progress" {
+		t.Errorf("child state = %q, want In Progress", got)
+	}
+}
+
+func TestOutputStaysOnIssueWithoutParent(t *testing.T) {
+	h := newHarness(t, testLinearYAML+parentTargetYAML)
+	issue := h.issue("Todo")
+
+	h.process(issue)
+
+	if _, ok := h.commentContaining(issue.ID, "planned for the epic"); !ok {
+		t.Errorf("comments = %q, want the output on the issue itself", h.comments(issue.ID))
+	}
+}
@@ -0,0 +1,103 @@
+package orchestrator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/linear"
+)
+
+// teamPipelinesYAML gives OPS its own pipeline; ENG uses the default stages.
+const teamPipelinesYAML = `
+pipeline:
+  stages:
+    - name: plan
+      linear_state: Todo
+      command: sh
+      args: ["-c", "echo planned"]
+      prompt: Plan it.
+      next_state: In Progress
+  teams:
+    OPS:
+      - name: triage
+        linear_state: Todo
+        command: sh
+        args: ["-c", "echo triaged"]
+        prompt: Triage it.
+        next_state: Backlog
+`
+
+// newTeamsHarness is a harness whose OPS team has workflow states of its own,
+// with IDs unlike ENG's states of the same names.
+func newTeamsHarness(t *testing.T) *harness {
+	h := newHarness(t, testLinearYAML+teamPipelinesYAML)
+	h.linear.AddTeam("OPS", testStates...)
+	if err := h.client.LoadWorkflowStates(context.Background(), "OPS"); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func (h *harness) opsIssue(state string) *linear.IssueDetails {
+	return h.issueWith(state, func(issue *linear.IssueDetails) {
+		issue.Identifier = "OPS-1"
+		issue.Team.Key = "OPS"
+	})
+}
+
+func TestWebhookUsesIssueTeamPipeline(t *testing.T) {
+	h := newTeamsHarness(t)
+	eng := h.issue("Todo")
+	ops := h.opsIssue("Todo")
+	if h.linear.Issue(ops.ID).State.ID == h.linear.Issue(eng.ID).State.ID {
+		t.Fatal("OPS and ENG share state IDs; the test would not tell the teams apart")
+	}
+
+	h.webhook(eng.ID, `{"stateId":"state-old"}`)
+	h.webhook(ops.ID, `{"stateId":"state-old"}`)
+
+	if run := h.lastRun(eng.ID); run.StageName != "plan" {
+		t.Errorf("ENG ran %q, want the default plan stage", run.StageName)
+	}
+	if got := h.state(eng.ID); got != "In Progress" {
+		t.Errorf("ENG state = %q, want In Progress", got)
+	}
+	if run := h.lastRun(ops.ID); run.StageName != "triage" {
+		t.Errorf("OPS ran %q, want its own triage stage", run.StageName)
+	}
+	// Moved with OPS's Backlog state, not ENG's
+	issue := h.linear.Issue(ops.ID)
+	if issue.State.Name != "Backlog" {
+		t.Errorf("OPS state = %q, want Backlog", issue.State.Name)
+	}
+	if id, _ := h.client.ResolveStateID("OPS", "Backlog"); issue.State.ID != id {
+		t.Errorf("OPS state ID = %s, want OPS's Backlog %s", issue.State.ID, id)
+	}
+}
+
+func TestResolveStateIDIsPerTeam(t *testing.T) {
+	h := newTeamsHarness(t)
+
+	eng, ok := h.client.ResolveStateID("ENG", "Todo")
+	if !ok {
+		t.Fatal("ENG Todo not resolved")
+	}
+	ops, ok := h.client.ResolveStateID("OPS", "Todo")
+	if !ok {
+		t.Fatal("OPS Todo not resolved")
+	}
+	if eng == ops {
+		t.Errorf("ENG and OPS Todo both resolved to %s", eng)
+	}
+	for _, id := range []string{eng, ops} {
+		if name, ok := h.client.ResolveStateName(id); !ok || name != "Todo" {
+			t.Errorf("ResolveStateName(%s) = %q, %v, want Todo", id, name, ok)
+		}
+	}
+	if key, ok := h.client.TeamKey("team-OPS"); !ok || key != "OPS" {
+		t.Errorf("TeamKey(team-OPS) = %q, %v, want OPS", key, ok)
+	}
+	if _, ok := h.client.ResolveStateID("DATA", "Todo"); ok {
+		t.Error("a team whose states were never loaded resolved a state")
+	}
+}
@@ -0,0 +1,118 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/git"
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+// toolCommitYAML's stages commit their own changes, leaving a clean tree
+// with unpushed commits.
+const toolCommitYAML = `
+pipeline:
+  - name: implement
+    linear_state: In Progress
+    command: sh
+    args: ["-c", "echo one > one.txt && git add one.txt && git -c user.name=tool -c user.email=tool@example.com commit -qm 'tool: implement'"]
+    prompt: Implement it.
+    next_state: In Review
+    failure_state: Failed
+    creates_pr: true
+  - name: address-review
+    linear_state: In Review
+    command: sh
+    args: ["-c", "echo two > two.txt && git add two.txt && git -c user.name=tool -c user.email=tool@example.com commit -qm 'tool: address review'"]
+    prompt: Address the review.
+    next_state: Done
+    failure_state: Failed
+    uses_branch: true
+`
+
+func TestToolCommittedChangesArePushed(t *testing.T) {
+	h := newHarness(t, testLinearYAML+toolCommitYAML)
+	bare := h.withGit()
+	issue := h.issue("In Progress")
+	branch := git.SanitizeBranchName(issue.Identifier, issue.Title)
+
+	h.process(issue)
+	run := h.lastRun(issue.ID)
+	if run.PRURL != testutil.DefaultPRURL {
+		t.Fatalf("run = %s %q with PR %q, want a PR for the tool's commit", run.Status, run.Error, run.PRURL)
+	}
+	if creates := h.gh.Calls("pr", "create"); len(creates) != 1 {
+		t.Fatalf("got %d gh pr create calls, want 1", len(creates))
+	}
+	if got := testutil.RunGit(t, bare, "log", "-1", "--format=%s", branch); got != "tool: implement" {
+		t.Errorf("branch tip = %q, want the tool's own commit with nothing added on top", got)
+	}
+
+	h.process(issue)
+	if got := h.state(issue.ID); got != "Done" {
+		t.Fatalf("state = %q, want Done (run error %q)", got, h.lastRun(issue.ID).Error)
+	}
+	if got := testutil.RunGit(t, bare, "log", "-1", "--format=%s", branch); got != "tool: address review" {
+		t.Errorf("branch tip after follow-up = %q, want the tool's follow-up commit", got)
+	}
+	if !runGitOK(bare, "cat-file", "-e", branch+":two.txt") {
+		t.Error("pushed branch is missing the follow-up change")
+	}
+}
+
+func TestNoChangesOpensNoPR(t *testing.T) {
+	h := newHarness(t, testLinearYAML+strings.Replace(implementStageYAML, "echo change > change.txt", "true", 1))
+	h.withGit()
+	issue := h.issue("In Progress")
+
+	h.process(issue)
+	if creates := h.gh.Calls("pr", "create"); len(creates) != 0 {
+		t.Errorf("got %d gh pr create calls for a run that changed nothing, want 0", len(creates))
+	}
+	if run := h.lastRun(issue.ID); run.PRURL != "" {
+		t.Errorf("run PR = %q, want none", run.PRURL)
+	}
+}
+
+func TestNormalizeCommitsPerProject(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		global, project string // normalize_commits values, "" for unset
+		want            bool
+	}{
+		{"project on", "", "true", true},
+		{"project off overrides global", "true", "false", false},
+		{"global", "true", "", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfgYAML := testLinearYAML + "projects:\n  ENG:\n    github_repo: acme/app\n"
+			if tc.project != "" {
+				cfgYAML += "    normalize_commits: " + tc.project + "\n"
+			}
+			if tc.global != "" {
+				cfgYAML += "git:\n  normalize_commits: " + tc.global + "\n"
+			}
+			h := newHarness(t, cfgYAML+toolCommitYAML)
+			bare := h.withGit()
+			issue := h.issue("In Progress")
+			branch := git.SanitizeBranchName(issue.Identifier, issue.Title)
+
+			h.process(issue)
+			if run := h.lastRun(issue.ID); run.PRURL == "" {
+				t.Fatalf("run = %s %q, want a PR", run.Status, run.Error)
+			}
+			msg := testutil.RunGit(t, bare, "log", "-1", "--format=%B", branch)
+			author := testutil.RunGit(t, bare, "log", "-1", "--format=%an <%ae>", branch)
+			if got := strings.Contains(msg, "Generated-by: ai-flow"); got != tc.want {
+				t.Errorf("pushed commit message = %q, want trailer %v", msg, tc.want)
+			}
+			wantAuthor := "tool <tool@example.com>"
+			if tc.want {
+				wantAuthor = "ai-flow <ai-flow@noreply>"
+			}
+			if author != wantAuthor {
+				t.Errorf("pushed commit author = %q, want %q", author, wantAuthor)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordSpans installs a tracer provider that keeps finished spans in memory
+// for the rest of the test.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		tp.Shutdown(context.Background())
+		otel.SetTracerProvider(prev)
+	})
+	return exporter
+}
+
+func TestWebhookRunSpanHierarchy(t *testing.T) {
+	exporter := recordSpans(t)
+	h := newHarness(t, testLinearYAML+implementStageYAML)
+	h.withGit()
+	issue := h.issue("In Progress")
+
+	h.webhook(issue.ID, `{"stateId":"state-old"}`)
+	if got := h.state(issue.ID); got != "In Review" {
+		t.Fatalf("state = %q, want In Review", got)
+	}
+
+	spans := exporter.GetSpans()
+	byID := make(map[trace.SpanID]tracetest.SpanStub, len(spans))
+	for _, s := range spans {
+		byID[s.SpanContext.SpanID()] = s
+	}
+	parent := func(s tracetest.SpanStub) string {
+		return byID[s.Parent.SpanID()].Name
+	}
+	// under reports whether ancestor is s's parent, grandparent, and so on
+	under := func(s tracetest.SpanStub, ancestor string) bool {
+		for s.Parent.IsValid() {
+			s = byID[s.Parent.SpanID()]
+			if s.Name == ancestor {
+				return true
+			}
+		}
+		return false
+	}
+
+	var root tracetest.SpanStub
+	found := make(map[string]bool)
+	for _, s := range spans {
+		switch {
+		case s.Name == "webhook issue":
+			root = s
+			if s.Parent.IsValid() {
+				t.Errorf("webhook span has parent %s, want none", parent(s))
+			}
+		case s.Name == "stage implement":
+			found["stage"] = true
+			if got := parent(s); got != "webhook issue" {
+				t.Errorf("stage span parent = %q, want webhook issue", got)
+			}
+		case s.Name == "subprocess implement":
+			found["subprocess"] = true
+			if got := parent(s); got != "stage implement" {
+				t.Errorf("subprocess span parent = %q, want stage implement", got)
+			}
+		case strings.HasPrefix(s.Name, "git "):
+			found["git"] = true
+			if !under(s, "stage implement") {
+				t.Errorf("%s span is not inside the stage span", s.Name)
+			}
+		case s.Name == "linear graphql":
+			if under(s, "webhook issue") {
+				found["linear"] = true
+			}
+		}
+	}
+	if !root.SpanContext.IsValid() {
+		t.Fatalf("no webhook span among %d spans", len(spans))
+	}
+	for _, kind := range []string{"stage", "subprocess", "git", "linear"} {
+		if !found[kind] {
+			t.Errorf("no %s span in the webhook's trace", kind)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package orchestrator
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/linear"
+	"github.com/mauza/ai-flow/internal/subprocess"
+)
+
+// runStageSubprocess runs a stage's command, or with subprocess.skip_unchanged
+// reuses the output of the stage's last successful run when that run was
+// given identical inputs within the configured window.
+func (o *Orchestrator) runStageSubprocess(ctx context.Context, details *linear.IssueDetails, input subprocess.Input) (*subprocess.Result, error) {
+	if !o.cfg.Subprocess.SkipUnchanged {
+		return o.runSubprocess(ctx, details, input)
+	}
+
+	// Include the checked-out commit so new pushes to the branch count as a change
+	var rev string
+	if input.WorkDir != "" && o.git != nil {
+		head, err := o.git.HeadCommit(ctx, input.WorkDir)
+		if err != nil {
+			slog.Warn("reading workspace commit, running stage", "error", err, "issue", details.Identifier)
+			return o.runSubprocess(ctx, details, input)
+		}
+		rev = head
+	}
+	hash := subprocess.InputHash(input, rev)
+
+	last, err := o.store.GetLastHashedRun(details.ID, input.StageName)
+	if err != nil {
+		slog.Warn("looking up previous run inputs", "error", err, "issue", details.Identifier)
+	}
+	if last != nil && last.InputHash == hash && time.Since(last.EndedAt) < o.cfg.Subprocess.ParsedSkipUnchangedWindow {
+		slog.Info("inputs unchanged since previous run, reusing its output",
+			"issue", details.Identifier,
+			"stage", input.StageName,
+			"previousRun", last.ID,
+		)
+		return &subprocess.Result{ExitCode: 0, Stdout: last.Output}, nil
+	}
+
+	// Only runs that actually executed record a hash, so the window is
+	// measured from the run whose output is being reused
+	if err := o.store.SetRunInputHash(input.RunID, hash); err != nil {
+		slog.Warn("recording run input hash", "error", err, "issue", details.Identifier)
+	}
+	return o.runSubprocess(ctx, details, input)
+}
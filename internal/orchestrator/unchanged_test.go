@@ -0,0 +1,83 @@
+package orchestrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// countingPlanYAML is a plan stage that appends a line to counter each time
+// its command actually runs.
+func countingPlanYAML(counter string) string {
+	return `
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    args: ["-c", "echo run >> ` + counter + `; echo planned"]
+    prompt: Plan it.
+    next_state: In Progress
+`
+}
+
+// executions returns how many times the command writing counter ran.
+func executions(t *testing.T, counter string) int {
+	t.Helper()
+	data, err := os.ReadFile(counter)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "run\n")
+}
+
+func TestSkipUnchangedReusesIdenticalRun(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "count")
+	h := newHarness(t, testLinearYAML+"  skip_unchanged: true\n"+countingPlanYAML(counter))
+	issue := h.issue("Todo")
+
+	h.process(issue)
+	h.linear.MoveIssue(issue.ID, "Todo")
+	h.process(issue)
+
+	if got := executions(t, counter); got != 1 {
+		t.Errorf("command ran %d times, want the identical re-run skipped", got)
+	}
+	runs := h.runs(issue.ID)
+	if len(runs) != 2 {
+		t.Fatalf("got %d runs, want 2", len(runs))
+	}
+	if runs[1].Status != "completed" || strings.TrimSpace(runs[1].Output) != "planned" {
+		t.Errorf("skipped run = %s %q, want it completed with the previous output", runs[1].Status, runs[1].Output)
+	}
+	if got := h.state(issue.ID); got != "In Progress" {
+		t.Errorf("state = %q, want the reused result to advance the issue", got)
+	}
+
+	// Changed inputs run the command again
+	if err := h.client.UpdateIssueDescription(context.Background(), issue.ID, "Now with more detail."); err != nil {
+		t.Fatal(err)
+	}
+	h.linear.MoveIssue(issue.ID, "Todo")
+	h.process(issue)
+	if got := executions(t, counter); got != 2 {
+		t.Errorf("command ran %d times, want a re-run after the description changed", got)
+	}
+}
+
+func TestWithoutSkipUnchangedEveryRunExecutes(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "count")
+	h := newHarness(t, testLinearYAML+countingPlanYAML(counter))
+	issue := h.issue("Todo")
+
+	h.process(issue)
+	h.linear.MoveIssue(issue.ID, "Todo")
+	h.process(issue)
+	if got := executions(t, counter); got != 2 {
+		t.Errorf("command ran %d times, want 2", got)
+	}
+}
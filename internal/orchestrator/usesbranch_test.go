@@ -0,0 +1,92 @@
+package orchestrator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+const usesBranchYAML = `
+pipeline:
+  - name: implement
+    linear_state: In Progress
+    command: sh
+    args: ["-c", "echo implemented > implement.txt"]
+    prompt: Implement it.
+    next_state: In Review
+    failure_state: Failed
+    creates_pr: true
+  - name: polish
+    linear_state: In Review
+    command: sh
+    args: ["-c", "echo polished > polish.txt"]
+    prompt: Polish it.
+    next_state: Done
+    failure_state: Failed
+    uses_branch: true
+`
+
+const issueBranch = "eng-1-fix-the-thing"
+
+func TestUsesBranchBootstrapsMissingBranch(t *testing.T) {
+	h := newHarness(t, testLinearYAML+usesBranchYAML+"    create_branch_if_missing: true\n")
+	bare := h.withGit()
+	// A misordered webhook brought the issue straight to the uses_branch stage
+	issue := h.issue("In Review")
+
+	h.process(issue)
+	if got := h.state(issue.ID); got != "Done" {
+		t.Fatalf("state = %q, want Done (comments: %q)", got, h.comments(issue.ID))
+	}
+	run := h.lastRun(issue.ID)
+	if run.Status != "completed" || run.BranchName != issueBranch {
+		t.Errorf("run = %s on %q, want completed on %s", run.Status, run.BranchName, issueBranch)
+	}
+	if got, want := testutil.RunGit(t, bare, "rev-parse", issueBranch+"~1"), testutil.RunGit(t, bare, "rev-parse", "main"); got != want {
+		t.Errorf("bootstrapped branch starts at %s, want main (%s)", got, want)
+	}
+	if got := testutil.RunGit(t, bare, "show", issueBranch+":polish.txt"); got != "polished" {
+		t.Errorf("polish.txt on the branch = %q", got)
+	}
+	if creates := h.gh.Calls("pr", "create"); len(creates) != 0 {
+		t.Errorf("bootstrapping opened a PR: %q", creates)
+	}
+}
+
+func TestUsesBranchWithoutBranchFails(t *testing.T) {
+	h := newHarness(t, testLinearYAML+usesBranchYAML)
+	h.withGit()
+	issue := h.issue("In Review")
+
+	h.process(issue)
+	if got := h.state(issue.ID); got != "Failed" {
+		t.Errorf("state = %q, want Failed", got)
+	}
+	if run := h.lastRun(issue.ID); !strings.Contains(run.Error, "no existing branch found") {
+		t.Errorf("run error = %q", run.Error)
+	}
+}
+
+func TestUsesBranchContinuesExistingBranch(t *testing.T) {
+	h := newHarness(t, testLinearYAML+usesBranchYAML+"    create_branch_if_missing: true\n")
+	bare := h.withGit()
+	issue := h.issue("In Progress")
+
+	h.process(issue)
+	implemented := testutil.RunGit(t, bare, "rev-parse", issueBranch)
+	h.process(issue)
+
+	if got := h.state(issue.ID); got != "Done" {
+		t.Fatalf("state = %q, want Done (comments: %q)", got, h.comments(issue.ID))
+	}
+	if got := testutil.RunGit(t, bare, "rev-parse", issueBranch+"~1"); got != implemented {
+		t.Errorf("polish commit's parent = %s, want the implement commit %s", got, implemented)
+	}
+	if got := testutil.RunGit(t, bare, "show", issueBranch+":implement.txt"); got != "implemented" {
+		t.Errorf("implement.txt on the branch = %q, want the earlier stage's work kept", got)
+	}
+	if run := h.lastRun(issue.ID); run.PRURL != testutil.DefaultPRURL {
+		t.Errorf("polish run PR = %q, want the implement stage's PR %s", run.PRURL, testutil.DefaultPRURL)
+	}
+}
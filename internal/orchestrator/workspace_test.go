@@ -0,0 +1,82 @@
+package orchestrator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+func TestPartialWorkspaceIsCleanedAndRecloned(t *testing.T) {
+	root := t.TempDir()
+	h := newHarness(t, testLinearYAML+"workspace:\n  root: "+root+"\n"+planStageYAML)
+	bare := h.withGit()
+
+	// An interrupted clone left a .git-less workspace and an unrenamed sibling
+	ws := h.o.workspacePath("acme/app", "eng-1-fix-the-thing")
+	partial := ws + partialWorkspaceMarker + "123"
+	for _, dir := range []string{ws, partial} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("half"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A complete workspace next to them must survive
+	done := h.o.workspacePath("acme/app", "eng-2-fix-the-thing")
+	if _, _, err := h.o.setupWorkspace(context.Background(), "acme/app", "main", "eng-2-fix-the-thing", "ENG-2", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	h.o.CleanPartialWorkspaces()
+	for _, dir := range []string{ws, partial} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("%s survived startup cleanup: %v", filepath.Base(dir), err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(done, ".git")); err != nil {
+		t.Errorf("startup cleanup removed a complete workspace: %v", err)
+	}
+
+	workDir, _, err := h.o.setupWorkspace(context.Background(), "acme/app", "main", "eng-1-fix-the-thing", "ENG-1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workDir != ws {
+		t.Errorf("workspace = %s, want %s", workDir, ws)
+	}
+	if got, want := testutil.RunGit(t, ws, "rev-parse", "HEAD"), testutil.RunGit(t, bare, "rev-parse", "main"); got != want {
+		t.Errorf("re-cloned HEAD = %s, want %s", got, want)
+	}
+	if got := testutil.RunGit(t, ws, "status", "--porcelain"); got != "" {
+		t.Errorf("re-cloned workspace status = %q, want clean", got)
+	}
+}
+
+func TestSetupWorkspaceReplacesIncompleteDirectory(t *testing.T) {
+	root := t.TempDir()
+	h := newHarness(t, testLinearYAML+"workspace:\n  root: "+root+"\n"+planStageYAML)
+	h.withGit()
+
+	// Without a startup cleanup, setup itself must not clone into the leftover
+	ws := h.o.workspacePath("acme/app", "eng-1-fix-the-thing")
+	if err := os.MkdirAll(filepath.Join(ws, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := h.o.setupWorkspace(context.Background(), "acme/app", "main", "eng-1-fix-the-thing", "ENG-1", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(ws, ".git")); err != nil {
+		t.Fatalf("workspace was not cloned: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(ws, "src")); !os.IsNotExist(err) {
+		t.Errorf("leftover content survived the re-clone: %v", err)
+	}
+	siblings, _ := filepath.Glob(ws + partialWorkspaceMarker + "*")
+	if len(siblings) != 0 {
+		t.Errorf("partial clones left behind: %q", siblings)
+	}
+}
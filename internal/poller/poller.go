@@ -3,6 +3,8 @@ package poller
 import (
 	"context"
 	"log/slog"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/mauza/ai-flow/internal/config"
@@ -15,6 +17,24 @@ type Poller struct {
 	cfg    *config.Config
 	client *linear.Client
 	orch   *orchestrator.Orchestrator
+
+	mu       sync.Mutex
+	lastPoll time.Time // last poll whose query succeeded
+
+	// Found issues are handed to linear.poll_concurrency workers
+	jobs      chan pollJob
+	pendingMu sync.Mutex
+	pending   map[string]bool // issueID+stage queued or being processed
+}
+
+// pollJob is one issue found in a stage's state, waiting for a worker.
+type pollJob struct {
+	issue linear.IssueDetails
+	stage config.StageConfig
+}
+
+func (j pollJob) key() string {
+	return j.issue.ID + "/" + j.stage.Name
 }
 
 // New creates a new Poller.
@@ -23,6 +43,9 @@ func New(cfg *config.Config, client *linear.Client, orch *orchestrator.Orchestra
 		cfg:    cfg,
 		client: client,
 		orch:   orch,
+
+		jobs:    make(chan pollJob),
+		pending: make(map[string]bool),
 	}
 }
 
@@ -30,7 +53,14 @@ func New(cfg *config.Config, client *linear.Client, orch *orchestrator.Orchestra
 // poll_interval. It blocks until ctx is cancelled.
 func (p *Poller) Run(ctx context.Context) {
 	interval := p.cfg.Linear.ParsedPollInterval
-	slog.Info("poller starting", "interval", interval, "stages", len(p.cfg.Pipeline))
+	slog.Info("poller starting", "interval", interval, "teams", p.cfg.TeamKeys())
+
+	// Count from startup so readiness has a full window for the first poll
+	p.recordPoll()
+
+	for range max(p.cfg.Linear.PollConcurrency, 1) {
+		go p.worker(ctx)
+	}
 
 	// Poll immediately on start
 	p.poll(ctx)
@@ -49,35 +79,149 @@ func (p *Poller) Run(ctx context.Context) {
 	}
 }
 
-// poll queries each pipeline stage's linear_state and processes any matching issues.
+// LastPoll returns when the poll loop last queried Linear successfully (or
+// started, if no query has succeeded yet). Handing issues to workers is not
+// part of it.
+func (p *Poller) LastPoll() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastPoll
+}
+
+func (p *Poller) recordPoll() {
+	p.mu.Lock()
+	p.lastPoll = time.Now()
+	p.mu.Unlock()
+}
+
+// poll fetches the issues in every pipeline stage's linear_state and hands
+// them to the workers. Liveness is recorded as soon as the query succeeds, so
+// readiness reflects the poll loop rather than how busy the workers are.
 func (p *Poller) poll(ctx context.Context) {
-	for _, stage := range p.cfg.Pipeline {
-		if ctx.Err() != nil {
-			return
-		}
+	found, ok := p.fetch(ctx)
+	if !ok {
+		return
+	}
+	p.recordPoll()
+	p.dispatch(ctx, found)
+}
 
-		issues, err := p.client.GetIssuesByState(ctx, p.cfg.Linear.TeamKey, stage.LinearState)
-		if err != nil {
-			slog.Error("polling issues for stage",
-				"stage", stage.Name,
-				"state", stage.LinearState,
-				"error", err,
-			)
+// fetch queries the issues in the pipeline stages' linear_states of every
+// team ai-flow serves, pairing each with the stage FindStage picks for its
+// team and labels. ok is false if no team's query was made and succeeded.
+func (p *Poller) fetch(ctx context.Context) (found []pollJob, ok bool) {
+	for _, team := range p.cfg.TeamKeys() {
+		jobs, queried := p.fetchTeam(ctx, team)
+		found = append(found, jobs...)
+		ok = ok || queried
+	}
+	return found, ok
+}
+
+// fetchTeam queries the team's issues in every stage's linear_state with one
+// batched request. queried is false if there was nothing to query or the
+// query failed.
+func (p *Poller) fetchTeam(ctx context.Context, team string) (found []pollJob, queried bool) {
+	// Stages sharing a state are told apart by labels below
+	var states []string
+	seen := make(map[string]bool)
+	for _, stage := range p.cfg.StagesFor(team) {
+		if !stage.IsEnabled() {
+			slog.Debug("skipping disabled stage", "team", team, "stage", stage.Name)
+			continue
+		}
+		key := strings.ToLower(linear.NormalizeStateName(stage.LinearState))
+		if seen[key] {
 			continue
 		}
+		seen[key] = true
+		states = append(states, stage.LinearState)
+	}
+	if len(states) == 0 || ctx.Err() != nil {
+		return nil, false
+	}
 
+	byState, err := p.client.GetIssuesByStates(ctx, team, states)
+	if err != nil {
+		slog.Error("polling issues", "team", team, "states", states, "error", err)
+		return nil, false
+	}
+
+	for _, state := range states {
+		issues := byState[state]
 		if len(issues) > 0 {
 			slog.Debug("found issues in state",
-				"stage", stage.Name,
-				"state", stage.LinearState,
+				"team", team,
+				"state", state,
 				"count", len(issues),
 			)
 		}
+		for _, issue := range issues {
+			match := p.cfg.FindStage(issue.Team.Key, state, issue.LabelNames())
+			if match == nil {
+				continue
+			}
+			found = append(found, pollJob{issue: issue, stage: *match})
+		}
+	}
+	return found, true
+}
+
+// dispatch hands found issues to idle workers, skipping those already queued
+// or being processed. It never waits for a worker: an issue found while all
+// linear.poll_concurrency workers are busy is left for the next poll, so a
+// slow run can't stall the poll loop.
+func (p *Poller) dispatch(ctx context.Context, found []pollJob) {
+	for _, job := range found {
+		if ctx.Err() != nil {
+			return
+		}
+		if !p.claim(job) {
+			slog.Debug("issue already queued, skipping",
+				"issue", job.issue.Identifier,
+				"stage", job.stage.Name,
+			)
+			continue
+		}
+		select {
+		case p.jobs <- job:
+		default:
+			p.release(job)
+			slog.Debug("all poll workers busy, leaving issue for the next poll",
+				"issue", job.issue.Identifier,
+				"stage", job.stage.Name,
+			)
+		}
+	}
+}
+
+// claim marks a job pending, returning false if it already was.
+func (p *Poller) claim(job pollJob) bool {
+	p.pendingMu.Lock()
+	defer p.pendingMu.Unlock()
+	if p.pending[job.key()] {
+		return false
+	}
+	p.pending[job.key()] = true
+	return true
+}
 
-		stageCopy := stage // capture for goroutine
-		for i := range issues {
-			issue := issues[i] // capture for goroutine
-			go p.orch.ProcessIssue(ctx, &issue, &stageCopy)
+// release clears a job's pending mark.
+func (p *Poller) release(job pollJob) {
+	p.pendingMu.Lock()
+	delete(p.pending, job.key())
+	p.pendingMu.Unlock()
+}
+
+// worker processes queued issues one at a time until ctx is cancelled.
+func (p *Poller) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-p.jobs:
+			p.orch.ProcessIssue(ctx, &job.issue, &job.stage)
+			p.release(job)
 		}
 	}
 }
@@ -0,0 +1,166 @@
+package poller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/config"
+	"github.com/mauza/ai-flow/internal/linear"
+	"github.com/mauza/ai-flow/internal/orchestrator"
+	"github.com/mauza/ai-flow/internal/store"
+	"github.com/mauza/ai-flow/internal/subprocess"
+	"github.com/mauza/ai-flow/internal/testutil"
+)
+
+// slowPollYAML runs a stage that takes a while and appends the number of runs
+// in progress (marker files in $ACTIVE_DIR) to $ACTIVE_LOG.
+const slowPollYAML = `
+linear:
+  api_key: test-key
+  team_key: ENG
+  mode: poll
+  poll_interval: 10s
+  poll_concurrency: 2
+subprocess:
+  skip_command_check: true
+  max_concurrent: 10
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    args: ["-c", "touch \"$$ACTIVE_DIR/$$AIFLOW_ISSUE_ID\"; ls \"$$ACTIVE_DIR\" | wc -l >> \"$$ACTIVE_LOG\"; sleep 0.3; rm \"$$ACTIVE_DIR/$$AIFLOW_ISSUE_ID\""]
+    prompt: Plan it.
+    next_state: In Progress
+`
+
+func newTestPoller(t *testing.T, cfgYAML string) (*Poller, *testutil.Linear) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(cfgYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := testutil.NewLinear(t, "Todo", "In Progress")
+	client := fake.Client()
+	if err := client.LoadWorkflowStates(context.Background(), cfg.Linear.TeamKey); err != nil {
+		t.Fatal(err)
+	}
+	db, err := store.New(filepath.Join(t.TempDir(), "ai-flow.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	orch := orchestrator.New(cfg, client, db, subprocess.NewRunner(cfg.Subprocess.MaxConcurrent), nil)
+	return New(cfg, client, orch), fake
+}
+
+func TestDispatchBoundsConcurrencyWithoutBlocking(t *testing.T) {
+	activeDir, activeLog := t.TempDir(), filepath.Join(t.TempDir(), "active")
+	t.Setenv("ACTIVE_DIR", activeDir)
+	t.Setenv("ACTIVE_LOG", activeLog)
+	p, fake := newTestPoller(t, slowPollYAML)
+	var ids []string
+	for range 5 {
+		issue := linear.IssueDetails{Title: "Fix the thing"}
+		issue.State.Name = "Todo"
+		ids = append(ids, fake.AddIssue(issue).ID)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for range p.cfg.Linear.PollConcurrency {
+		go p.worker(ctx)
+	}
+
+	done := func() bool {
+		for _, id := range ids {
+			if issue := fake.Issue(id); issue.State.Name != "In Progress" {
+				return false
+			}
+		}
+		return true
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatal("issues not all processed")
+		}
+		found, ok := p.fetch(ctx)
+		if !ok {
+			t.Fatal("fetch failed")
+		}
+		start := time.Now()
+		p.dispatch(ctx, found)
+		if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+			t.Fatalf("dispatch blocked for %s with busy workers", elapsed)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	data, err := os.ReadFile(activeLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := strings.Fields(string(data))
+	if len(counts) != len(ids) {
+		t.Errorf("%d runs, want %d", len(counts), len(ids))
+	}
+	for _, c := range counts {
+		if n, _ := strconv.Atoi(c); n > p.cfg.Linear.PollConcurrency {
+			t.Errorf("%d runs at once, want at most poll_concurrency %d", n, p.cfg.Linear.PollConcurrency)
+		}
+	}
+}
+
+func TestFetchQueriesEveryStateAtOnce(t *testing.T) {
+	p, fake := newTestPoller(t, `
+linear:
+  api_key: test-key
+  team_key: ENG
+  mode: poll
+  poll_interval: 10s
+subprocess:
+  skip_command_check: true
+pipeline:
+  - name: plan
+    linear_state: Todo
+    command: sh
+    prompt: Plan it.
+    next_state: In Progress
+  - name: implement
+    linear_state: In Progress
+    command: sh
+    prompt: Build it.
+    next_state: Todo
+`)
+	stageOf := make(map[string]string)
+	for state, stage := range map[string]string{"Todo": "plan", "In Progress": "implement"} {
+		issue := linear.IssueDetails{Title: "Fix the thing"}
+		issue.State.Name = state
+		stageOf[fake.AddIssue(issue).ID] = stage
+	}
+
+	found, ok := p.fetch(context.Background())
+	if !ok {
+		t.Fatal("fetch failed")
+	}
+	if got := len(fake.Requests(": issues(")); got != 1 {
+		t.Errorf("made %d issue queries for 2 states, want 1", got)
+	}
+	if len(found) != len(stageOf) {
+		t.Fatalf("found %d issues, want %d", len(found), len(stageOf))
+	}
+	for _, job := range found {
+		if want := stageOf[job.issue.ID]; job.stage.Name != want {
+			t.Errorf("issue in %s paired with stage %q, want %q", job.issue.State.Name, job.stage.Name, want)
+		}
+	}
+}
@@ -2,14 +2,17 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"net/url"
 	"time"
 
 	_ "modernc.org/sqlite"
 )
 
 type Store struct {
-	db *sql.DB
+	db   *sql.DB
+	path string // database file, for the read-only connections of ExportRuns
 }
 
 // New opens (or creates) a SQLite database and initializes the schema.
@@ -40,7 +43,7 @@ func New(dbPath string) (*Store, error) {
 		return nil, fmt.Errorf("migrating database: %w", err)
 	}
 
-	return &Store{db: db}, nil
+	return &Store{db: db, path: dbPath}, nil
 }
 
 // RunInfo holds metadata from a previous completed run.
@@ -83,6 +86,46 @@ func migrate(db *sql.DB) error {
 		CREATE UNIQUE INDEX IF NOT EXISTS idx_project_plan_runs_active
 			ON project_plan_runs(project_id, stage_name)
 			WHERE status = 'running';
+
+		CREATE TABLE IF NOT EXISTS issue_comments (
+			issue_id   TEXT PRIMARY KEY,
+			comment_id TEXT NOT NULL,
+			body       TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS issue_cycles (
+			issue_id TEXT PRIMARY KEY,
+			count    INTEGER NOT NULL DEFAULT 0
+		);
+
+		CREATE TABLE IF NOT EXISTS pr_states (
+			pr_url     TEXT PRIMARY KEY,
+			state      TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS pending_prs (
+			issue_id    TEXT NOT NULL,
+			branch_name TEXT NOT NULL,
+			created_at  DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (issue_id, branch_name)
+		);
+
+		CREATE TABLE IF NOT EXISTS issue_state (
+			issue_id   TEXT NOT NULL,
+			state      TEXT NOT NULL,
+			entered_at DATETIME NOT NULL,
+			PRIMARY KEY (issue_id, state)
+		);
+
+		CREATE TABLE IF NOT EXISTS pending (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			kind       TEXT NOT NULL,
+			payload    TEXT NOT NULL,
+			status     TEXT NOT NULL DEFAULT 'enqueued',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
 	`)
 	if err != nil {
 		return err
@@ -91,6 +134,16 @@ func migrate(db *sql.DB) error {
 	// Migration for existing databases: add branch_name column if missing
 	_, _ = db.Exec(`ALTER TABLE runs ADD COLUMN branch_name TEXT`)
 
+	// Migration for existing databases: add input_hash column if missing
+	_, _ = db.Exec(`ALTER TABLE runs ADD COLUMN input_hash TEXT`)
+
+	// Migration for existing databases: add audit column if missing
+	_, _ = db.Exec(`ALTER TABLE runs ADD COLUMN audit TEXT`)
+
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_runs_pr_url ON runs (pr_url)`); err != nil {
+		return fmt.Errorf("creating pr_url index: %w", err)
+	}
+
 	return nil
 }
 
@@ -118,6 +171,55 @@ func (s *Store) StartRun(issueID, stageName string) (int64, bool, error) {
 	return id, true, nil
 }
 
+// SetRunInputHash records the hash of the inputs a run's subprocess was started with.
+func (s *Store) SetRunInputHash(runID int64, hash string) error {
+	_, err := s.db.Exec(`UPDATE runs SET input_hash = ? WHERE id = ?`, hash, runID)
+	return err
+}
+
+// SetRunAudit records what context a run's subprocess was given, as JSON.
+func (s *Store) SetRunAudit(runID int64, audit any) error {
+	data, err := json.Marshal(audit)
+	if err != nil {
+		return fmt.Errorf("encoding run audit: %w", err)
+	}
+	if _, err := s.db.Exec(`UPDATE runs SET audit = ? WHERE id = ?`, string(data), runID); err != nil {
+		return fmt.Errorf("saving run audit: %w", err)
+	}
+	return nil
+}
+
+// HashedRun is a successful run whose subprocess inputs were recorded.
+type HashedRun struct {
+	ID        int64
+	InputHash string
+	Output    string
+	EndedAt   time.Time
+}
+
+// GetLastHashedRun returns the most recent successful run for an issue+stage
+// that recorded an input hash. Returns nil if there is none.
+func (s *Store) GetLastHashedRun(issueID, stageName string) (*HashedRun, error) {
+	var run HashedRun
+	var output sql.NullString
+	var endedAt sql.NullTime
+	err := s.db.QueryRow(
+		`SELECT id, input_hash, output, ended_at FROM runs
+		 WHERE issue_id = ? AND stage_name = ? AND status = 'completed' AND exit_code = 0 AND input_hash IS NOT NULL
+		 ORDER BY id DESC LIMIT 1`,
+		issueID, stageName,
+	).Scan(&run.ID, &run.InputHash, &output, &endedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying last hashed run: %w", err)
+	}
+	run.Output = output.String
+	run.EndedAt = endedAt.Time
+	return &run, nil
+}
+
 // CompleteRun marks a run as completed with the given exit code, output, optional PR URL, and branch name.
 func (s *Store) CompleteRun(runID int64, exitCode int, output, prURL, branchName string) error {
 	_, err := s.db.Exec(
@@ -127,6 +229,16 @@ func (s *Store) CompleteRun(runID int64, exitCode int, output, prURL, branchName
 	return err
 }
 
+// SkipRun marks a run whose command exited 2 as skipped. Skipped runs keep
+// exit code 2 but have their own status, so they never count as successes.
+func (s *Store) SkipRun(runID int64, prURL, branchName string) error {
+	_, err := s.db.Exec(
+		`UPDATE runs SET status = 'skipped', exit_code = 2, pr_url = ?, branch_name = ?, ended_at = ? WHERE id = ?`,
+		prURL, branchName, time.Now().UTC(), runID,
+	)
+	return err
+}
+
 // FailRun marks a run as failed with the given error message.
 func (s *Store) FailRun(runID int64, exitCode int, errMsg string) error {
 	_, err := s.db.Exec(
@@ -189,6 +301,164 @@ func (s *Store) GetBranchForIssue(issueID string) (*RunInfo, error) {
 	return &info, nil
 }
 
+// LastFailureTime returns when the most recent failed or timed-out run for an
+// issue+stage ended. Returns nil if the stage has never failed for the issue.
+func (s *Store) LastFailureTime(issueID, stageName string) (*time.Time, error) {
+	var endedAt sql.NullTime
+	err := s.db.QueryRow(
+		`SELECT ended_at FROM runs
+		 WHERE issue_id = ? AND stage_name = ? AND status IN ('failed', 'timeout') AND ended_at IS NOT NULL
+		 ORDER BY ended_at DESC LIMIT 1`,
+		issueID, stageName,
+	).Scan(&endedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying last failure: %w", err)
+	}
+	if !endedAt.Valid {
+		return nil, nil
+	}
+	return &endedAt.Time, nil
+}
+
+// LastRunTime returns when the latest run for an issue+stage ended, or when
+// it started if it is still running. Returns nil if the stage never ran.
+func (s *Store) LastRunTime(issueID, stageName string) (*time.Time, error) {
+	var startedAt time.Time
+	var endedAt sql.NullTime
+	err := s.db.QueryRow(
+		`SELECT started_at, ended_at FROM runs
+		 WHERE issue_id = ? AND stage_name = ?
+		 ORDER BY id DESC LIMIT 1`,
+		issueID, stageName,
+	).Scan(&startedAt, &endedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying last run: %w", err)
+	}
+	if endedAt.Valid {
+		return &endedAt.Time, nil
+	}
+	return &startedAt, nil
+}
+
+// RecentRunStatuses returns the statuses of the latest runs for an issue+stage,
+// newest first, up to limit entries.
+func (s *Store) RecentRunStatuses(issueID, stageName string, limit int) ([]string, error) {
+	rows, err := s.db.Query(
+		`SELECT status FROM runs WHERE issue_id = ? AND stage_name = ? ORDER BY id DESC LIMIT ?`,
+		issueID, stageName, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying recent runs: %w", err)
+	}
+	defer rows.Close()
+
+	var statuses []string
+	for rows.Next() {
+		var status string
+		if err := rows.Scan(&status); err != nil {
+			return nil, fmt.Errorf("scanning run status: %w", err)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, rows.Err()
+}
+
+// TotalRuntimeForIssue sums how long every finished run of an issue took,
+// across all stages and retries.
+func (s *Store) TotalRuntimeForIssue(issueID string) (time.Duration, error) {
+	rows, err := s.db.Query(
+		`SELECT started_at, ended_at FROM runs WHERE issue_id = ? AND ended_at IS NOT NULL`,
+		issueID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("querying run durations: %w", err)
+	}
+	defer rows.Close()
+
+	// Timestamps are summed in Go: started_at and ended_at are written in
+	// different formats, which SQLite date functions don't both understand.
+	var total time.Duration
+	for rows.Next() {
+		var startedAt, endedAt sql.NullTime
+		if err := rows.Scan(&startedAt, &endedAt); err != nil {
+			return 0, fmt.Errorf("scanning run durations: %w", err)
+		}
+		if startedAt.Valid && endedAt.Valid && endedAt.Time.After(startedAt.Time) {
+			total += endedAt.Time.Sub(startedAt.Time)
+		}
+	}
+	return total, rows.Err()
+}
+
+// averageDurationRuns is how many recent successful runs AverageDuration
+// looks at.
+const averageDurationRuns = 20
+
+// AverageDuration returns how long the stage's recent successful runs took
+// on average, or 0 if it has none.
+func (s *Store) AverageDuration(stageName string) (time.Duration, error) {
+	rows, err := s.db.Query(
+		`SELECT started_at, ended_at FROM runs
+		 WHERE stage_name = ? AND status = 'completed' AND exit_code = 0 AND ended_at IS NOT NULL
+		 ORDER BY started_at DESC LIMIT ?`,
+		stageName, averageDurationRuns,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("querying stage durations: %w", err)
+	}
+	defer rows.Close()
+
+	// Averaged in Go for the same reason as TotalRuntimeForIssue
+	var total time.Duration
+	var n int64
+	for rows.Next() {
+		var startedAt, endedAt sql.NullTime
+		if err := rows.Scan(&startedAt, &endedAt); err != nil {
+			return 0, fmt.Errorf("scanning stage durations: %w", err)
+		}
+		if startedAt.Valid && endedAt.Valid && endedAt.Time.After(startedAt.Time) {
+			total += endedAt.Time.Sub(startedAt.Time)
+			n++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	return total / time.Duration(n), nil
+}
+
+// GetPreviousBranchForIssue returns the most recent branch/PR info from a completed
+// run of any stage other than stageName, i.e. the branch a stacked stage builds on.
+// Returns nil if no such run exists.
+func (s *Store) GetPreviousBranchForIssue(issueID, stageName string) (*RunInfo, error) {
+	var info RunInfo
+	var branchName, prURL sql.NullString
+	err := s.db.QueryRow(
+		`SELECT id, branch_name, pr_url FROM runs
+		 WHERE issue_id = ? AND stage_name != ? AND status = 'completed' AND exit_code = 0 AND branch_name IS NOT NULL AND branch_name != ''
+		 ORDER BY ended_at DESC LIMIT 1`,
+		issueID, stageName,
+	).Scan(&info.ID, &branchName, &prURL)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying previous branch for issue: %w", err)
+	}
+	info.BranchName = branchName.String
+	info.PRURL = prURL.String
+	return &info, nil
+}
+
 // IsRunning checks whether there is currently a running record for the given issue+stage.
 func (s *Store) IsRunning(issueID, stageName string) (bool, error) {
 	var count int
@@ -217,6 +487,37 @@ func (s *Store) CleanStaleRuns(maxAge time.Duration) (int64, error) {
 	return res.RowsAffected()
 }
 
+// BranchesForIssue returns every branch the issue's runs have recorded.
+func (s *Store) BranchesForIssue(issueID string) ([]string, error) {
+	rows, err := s.db.Query(
+		`SELECT DISTINCT branch_name FROM runs
+		 WHERE issue_id = ? AND branch_name IS NOT NULL AND branch_name != ''`,
+		issueID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying issue branches: %w", err)
+	}
+	defer rows.Close()
+	var branches []string
+	for rows.Next() {
+		var branch string
+		if err := rows.Scan(&branch); err != nil {
+			return nil, fmt.Errorf("scanning issue branch: %w", err)
+		}
+		branches = append(branches, branch)
+	}
+	return branches, rows.Err()
+}
+
+// CancelRun marks a run as canceled, e.g. because its issue was canceled.
+func (s *Store) CancelRun(runID int64, reason string) error {
+	_, err := s.db.Exec(
+		`UPDATE runs SET status = 'canceled', error = ?, ended_at = ? WHERE id = ?`,
+		reason, time.Now().UTC(), runID,
+	)
+	return err
+}
+
 // GetFirstBranchForIssue returns the branch/PR info from the earliest completed run
 // that has a branch for this issue. This ensures uses_branch stages always pick up
 // the branch created by the first creates_pr stage rather than the most recent run.
@@ -253,6 +554,9 @@ type RunRecord struct {
 	Error      string     `json:"error"`
 	StartedAt  time.Time  `json:"started_at"`
 	EndedAt    *time.Time `json:"ended_at"`
+
+	// Audit is the run's subprocess.InputAudit, recorded with subprocess.audit_inputs
+	Audit json.RawMessage `json:"audit,omitempty"`
 }
 
 // ListRecentRuns returns the most recent runs, newest first.
@@ -260,7 +564,7 @@ func (s *Store) ListRecentRuns(limit int) ([]RunRecord, error) {
 	rows, err := s.db.Query(
 		`SELECT id, issue_id, stage_name, status, exit_code,
 		        COALESCE(output,''), COALESCE(pr_url,''), COALESCE(branch_name,''),
-		        COALESCE(error,''), started_at, ended_at
+		        COALESCE(error,''), started_at, ended_at, COALESCE(audit,'')
 		 FROM runs ORDER BY started_at DESC LIMIT ?`,
 		limit,
 	)
@@ -280,12 +584,151 @@ func (s *Store) ListRecentRuns(limit int) ([]RunRecord, error) {
 	return records, rows.Err()
 }
 
+// GetRunsByPRURL returns every run that recorded the given PR URL, newest first.
+func (s *Store) GetRunsByPRURL(url string) ([]RunRecord, error) {
+	rows, err := s.db.Query(
+		`SELECT id, issue_id, stage_name, status, exit_code,
+		        COALESCE(output,''), COALESCE(pr_url,''), COALESCE(branch_name,''),
+		        COALESCE(error,''), started_at, ended_at, COALESCE(audit,'')
+		 FROM runs WHERE pr_url = ? ORDER BY id DESC`,
+		url,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying runs by pr url: %w", err)
+	}
+	defer rows.Close()
+
+	var records []RunRecord
+	for rows.Next() {
+		r, err := scanRunRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// TrackedPR is a PR opened by a run, with the last state recorded for it.
+type TrackedPR struct {
+	IssueID string
+	PRURL   string
+	State   string // "" until the first SetPRState
+}
+
+// ListOpenPRs returns the PRs of all runs whose recorded state is unknown or
+// OPEN, i.e. those that may still change state.
+func (s *Store) ListOpenPRs() ([]TrackedPR, error) {
+	rows, err := s.db.Query(
+		`SELECT DISTINCT r.issue_id, r.pr_url, COALESCE(p.state, '')
+		 FROM runs r LEFT JOIN pr_states p ON p.pr_url = r.pr_url
+		 WHERE r.pr_url != '' AND (p.state IS NULL OR p.state = 'OPEN')`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying open prs: %w", err)
+	}
+	defer rows.Close()
+
+	var prs []TrackedPR
+	for rows.Next() {
+		var pr TrackedPR
+		if err := rows.Scan(&pr.IssueID, &pr.PRURL, &pr.State); err != nil {
+			return nil, fmt.Errorf("scanning open pr: %w", err)
+		}
+		prs = append(prs, pr)
+	}
+	return prs, rows.Err()
+}
+
+// SetPRState records the last seen state of a PR.
+func (s *Store) SetPRState(prURL, state string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO pr_states (pr_url, state) VALUES (?, ?)
+		 ON CONFLICT(pr_url) DO UPDATE SET state = excluded.state, updated_at = CURRENT_TIMESTAMP`,
+		prURL, state,
+	)
+	if err != nil {
+		return fmt.Errorf("saving pr state: %w", err)
+	}
+	return nil
+}
+
+// MarkPRPending records that branch was pushed for an issue but its PR
+// couldn't be created, so a later run knows to open it.
+func (s *Store) MarkPRPending(issueID, branchName string) error {
+	_, err := s.db.Exec(
+		`INSERT OR IGNORE INTO pending_prs (issue_id, branch_name) VALUES (?, ?)`,
+		issueID, branchName,
+	)
+	if err != nil {
+		return fmt.Errorf("marking pr pending: %w", err)
+	}
+	return nil
+}
+
+// IsPRPending reports whether MarkPRPending was called for the issue's branch
+// and ClearPRPending hasn't been since.
+func (s *Store) IsPRPending(issueID, branchName string) (bool, error) {
+	var n int
+	err := s.db.QueryRow(
+		`SELECT COUNT(*) FROM pending_prs WHERE issue_id = ? AND branch_name = ?`,
+		issueID, branchName,
+	).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("checking pending pr: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ClearPRPending forgets a pending PR once it exists.
+func (s *Store) ClearPRPending(issueID, branchName string) error {
+	_, err := s.db.Exec(
+		`DELETE FROM pending_prs WHERE issue_id = ? AND branch_name = ?`,
+		issueID, branchName,
+	)
+	if err != nil {
+		return fmt.Errorf("clearing pending pr: %w", err)
+	}
+	return nil
+}
+
+// ListAwaitingApproval returns successful runs of stageName that are still
+// their issue's most recent run, i.e. issues a wait_for_approval stage has
+// parked and nothing has picked up since. Whether each issue is still in the
+// stage's state is left to the caller.
+func (s *Store) ListAwaitingApproval(stageName string) ([]RunRecord, error) {
+	rows, err := s.db.Query(
+		`SELECT id, issue_id, stage_name, status, exit_code,
+		        COALESCE(output,''), COALESCE(pr_url,''), COALESCE(branch_name,''),
+		        COALESCE(error,''), started_at, ended_at, COALESCE(audit,'')
+		 FROM runs r
+		 WHERE stage_name = ? AND status = 'completed' AND exit_code = 0
+		   AND id = (SELECT MAX(id) FROM runs WHERE issue_id = r.issue_id)
+		 ORDER BY id`,
+		stageName,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("querying runs awaiting approval: %w", err)
+	}
+	defer rows.Close()
+
+	var records []RunRecord
+	for rows.Next() {
+		r, err := scanRunRecord(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
 // GetRun returns a single run by ID.
 func (s *Store) GetRun(id int64) (*RunRecord, error) {
 	row := s.db.QueryRow(
 		`SELECT id, issue_id, stage_name, status, exit_code,
 		        COALESCE(output,''), COALESCE(pr_url,''), COALESCE(branch_name,''),
-		        COALESCE(error,''), started_at, ended_at
+		        COALESCE(error,''), started_at, ended_at, COALESCE(audit,'')
 		 FROM runs WHERE id = ?`,
 		id,
 	)
@@ -307,14 +750,18 @@ func scanRunRecord(row rowScanner) (RunRecord, error) {
 	var r RunRecord
 	var exitCode sql.NullInt64
 	var endedAt sql.NullTime
+	var audit string
 	err := row.Scan(
 		&r.ID, &r.IssueID, &r.StageName, &r.Status,
 		&exitCode, &r.Output, &r.PRURL, &r.BranchName,
-		&r.Error, &r.StartedAt, &endedAt,
+		&r.Error, &r.StartedAt, &endedAt, &audit,
 	)
 	if err != nil {
 		return r, err
 	}
+	if audit != "" {
+		r.Audit = json.RawMessage(audit)
+	}
 	if exitCode.Valid {
 		ec := int(exitCode.Int64)
 		r.ExitCode = &ec
@@ -325,6 +772,44 @@ func scanRunRecord(row rowScanner) (RunRecord, error) {
 	return r, nil
 }
 
+// ExportRuns calls fn with every run started in [since, until), oldest first.
+// A zero until means no upper bound. Rows are read as fn consumes them, so
+// the result is never held in memory; they come from a separate read-only
+// connection, so a slow consumer doesn't hold up the pipeline's writes.
+func (s *Store) ExportRuns(since, until time.Time, fn func(RunRecord) error) error {
+	db, err := sql.Open("sqlite", (&url.URL{Scheme: "file", Path: s.path, RawQuery: "mode=ro"}).String())
+	if err != nil {
+		return fmt.Errorf("opening database for export: %w", err)
+	}
+	defer db.Close()
+
+	query := `SELECT id, issue_id, stage_name, status, exit_code,
+	                 COALESCE(output,''), COALESCE(pr_url,''), COALESCE(branch_name,''),
+	                 COALESCE(error,''), started_at, ended_at, COALESCE(audit,'')
+	          FROM runs WHERE started_at >= ?`
+	args := []any{since.UTC()}
+	if !until.IsZero() {
+		query += ` AND started_at < ?`
+		args = append(args, until.UTC())
+	}
+	rows, err := db.Query(query+` ORDER BY started_at, id`, args...)
+	if err != nil {
+		return fmt.Errorf("querying runs for export: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r, err := scanRunRecord(rows)
+		if err != nil {
+			return fmt.Errorf("scanning exported run: %w", err)
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 // StartProjectRun inserts a new running record for a project stage.
 // Returns the run ID, or an error (including a unique constraint error if already running).
 func (s *Store) StartProjectRun(projectID, stageName string) (int64, error) {
@@ -367,6 +852,201 @@ func (s *Store) FailProjectRun(id int64, errMsg string) error {
 	return err
 }
 
+// IssueComment is the consolidated ai-flow comment kept on an issue.
+type IssueComment struct {
+	CommentID string
+	Body      string // completed stage sections, without any in-progress status
+}
+
+// GetIssueComment returns the consolidated comment for an issue, or nil if none exists.
+func (s *Store) GetIssueComment(issueID string) (*IssueComment, error) {
+	var c IssueComment
+	err := s.db.QueryRow(
+		`SELECT comment_id, body FROM issue_comments WHERE issue_id = ?`,
+		issueID,
+	).Scan(&c.CommentID, &c.Body)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying issue comment: %w", err)
+	}
+	return &c, nil
+}
+
+// SaveIssueComment records the consolidated comment ID and body for an issue.
+func (s *Store) SaveIssueComment(issueID, commentID, body string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO issue_comments (issue_id, comment_id, body, updated_at) VALUES (?, ?, ?, ?)
+		 ON CONFLICT(issue_id) DO UPDATE SET comment_id = excluded.comment_id, body = excluded.body, updated_at = excluded.updated_at`,
+		issueID, commentID, body, time.Now().UTC(),
+	)
+	return err
+}
+
+// IsStageReentry reports whether an issue is coming back to a stage it has run
+// before after moving on: its latest run is of another stage, and an earlier
+// run is of stageName.
+func (s *Store) IsStageReentry(issueID, stageName string) (bool, error) {
+	var reentry bool
+	err := s.db.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM runs WHERE issue_id = ? AND stage_name = ?)
+		    AND (SELECT stage_name FROM runs WHERE issue_id = ? ORDER BY id DESC LIMIT 1) != ?`,
+		issueID, stageName, issueID, stageName,
+	).Scan(&reentry)
+	if err != nil {
+		return false, fmt.Errorf("checking stage re-entry: %w", err)
+	}
+	return reentry, nil
+}
+
+// CycleCount returns how many times an issue has looped back to a stage it
+// already ran.
+func (s *Store) CycleCount(issueID string) (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT count FROM issue_cycles WHERE issue_id = ?`, issueID).Scan(&count)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("querying cycle count: %w", err)
+	}
+	return count, nil
+}
+
+// IncrementCycleCount records another loop for an issue and returns the new count.
+func (s *Store) IncrementCycleCount(issueID string) (int, error) {
+	var count int
+	err := s.db.QueryRow(
+		`INSERT INTO issue_cycles (issue_id, count) VALUES (?, 1)
+		 ON CONFLICT(issue_id) DO UPDATE SET count = count + 1
+		 RETURNING count`,
+		issueID,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("incrementing cycle count: %w", err)
+	}
+	return count, nil
+}
+
+// RecordStateEntry records that an issue entered state at the given time,
+// replacing any earlier entry into it, and forgets its other states.
+func (s *Store) RecordStateEntry(issueID, state string, at time.Time) error {
+	return s.recordState(issueID, state, at,
+		`INSERT INTO issue_state (issue_id, state, entered_at) VALUES (?, ?, ?)
+		 ON CONFLICT(issue_id, state) DO UPDATE SET entered_at = excluded.entered_at`)
+}
+
+// RecordStateSeen records that an issue was seen in state at the given time.
+// Unlike RecordStateEntry it keeps an existing entry time, so the first
+// sighting in the state anchors it.
+func (s *Store) RecordStateSeen(issueID, state string, at time.Time) error {
+	return s.recordState(issueID, state, at,
+		`INSERT OR IGNORE INTO issue_state (issue_id, state, entered_at) VALUES (?, ?, ?)`)
+}
+
+func (s *Store) recordState(issueID, state string, at time.Time, insert string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("recording issue state: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM issue_state WHERE issue_id = ? AND state != ?`, issueID, state); err != nil {
+		return fmt.Errorf("recording issue state: %w", err)
+	}
+	if _, err := tx.Exec(insert, issueID, state, at.UTC()); err != nil {
+		return fmt.Errorf("recording issue state: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("recording issue state: %w", err)
+	}
+	return nil
+}
+
+// StateEnteredAt returns when the issue entered state, or nil if it isn't
+// known to be in it.
+func (s *Store) StateEnteredAt(issueID, state string) (*time.Time, error) {
+	var at time.Time
+	err := s.db.QueryRow(
+		`SELECT entered_at FROM issue_state WHERE issue_id = ? AND state = ?`,
+		issueID, state,
+	).Scan(&at)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying state entry time: %w", err)
+	}
+	return &at, nil
+}
+
+// PendingItem is received work that hadn't started a run when it was saved.
+type PendingItem struct {
+	ID      int64
+	Kind    string
+	Payload []byte
+}
+
+// EnqueuePending saves received work of the given kind before it is handled,
+// so it can be picked up again if the process stops first.
+func (s *Store) EnqueuePending(kind string, payload []byte) (int64, error) {
+	res, err := s.db.Exec(`INSERT INTO pending (kind, payload) VALUES (?, ?)`, kind, string(payload))
+	if err != nil {
+		return 0, fmt.Errorf("enqueuing pending work: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// MarkPendingRunning records that pending work has started a run, after which
+// an interrupted run is left to CleanStaleRuns rather than replayed.
+func (s *Store) MarkPendingRunning(id int64) error {
+	if _, err := s.db.Exec(`UPDATE pending SET status = 'running' WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("marking pending work running: %w", err)
+	}
+	return nil
+}
+
+// UpdatePending replaces the payload of pending work, as when a later delivery
+// is folded into work that is still waiting.
+func (s *Store) UpdatePending(id int64, payload []byte) error {
+	if _, err := s.db.Exec(`UPDATE pending SET payload = ? WHERE id = ?`, string(payload), id); err != nil {
+		return fmt.Errorf("updating pending work: %w", err)
+	}
+	return nil
+}
+
+// DeletePending forgets pending work once it has been handled.
+func (s *Store) DeletePending(id int64) error {
+	if _, err := s.db.Exec(`DELETE FROM pending WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("deleting pending work: %w", err)
+	}
+	return nil
+}
+
+// RecoverPending is called on startup. It drops pending work that had
+// started a run and returns the work that hadn't, oldest first.
+func (s *Store) RecoverPending() ([]PendingItem, error) {
+	if _, err := s.db.Exec(`DELETE FROM pending WHERE status = 'running'`); err != nil {
+		return nil, fmt.Errorf("dropping started pending work: %w", err)
+	}
+	rows, err := s.db.Query(`SELECT id, kind, payload FROM pending WHERE status = 'enqueued' ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("querying pending work: %w", err)
+	}
+	defer rows.Close()
+	var items []PendingItem
+	for rows.Next() {
+		var item PendingItem
+		var payload string
+		if err := rows.Scan(&item.ID, &item.Kind, &payload); err != nil {
+			return nil, fmt.Errorf("scanning pending work: %w", err)
+		}
+		item.Payload = []byte(payload)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
 // Close closes the database connection.
 func (s *Store) Close() error {
 	return s.db.Close()
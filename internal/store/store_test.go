@@ -0,0 +1,247 @@
+package store
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := New(filepath.Join(t.TempDir(), "ai-flow.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// finishedRun records a run of stage on issueID that took d, finished with
+// finish (e.g. s.CompleteRun), ending at endedAt. started_at is written the
+// way SQLite's CURRENT_TIMESTAMP default writes it.
+func finishedRun(t *testing.T, s *Store, issueID, stage string, endedAt time.Time, d time.Duration, finish func(id int64) error) int64 {
+	t.Helper()
+	id, ok, err := s.StartRun(issueID, stage)
+	if err != nil || !ok {
+		t.Fatalf("StartRun: inserted %v, %v", ok, err)
+	}
+	if err := finish(id); err != nil {
+		t.Fatal(err)
+	}
+	started := endedAt.Add(-d).UTC().Format("2006-01-02 15:04:05")
+	if _, err := s.db.Exec(`UPDATE runs SET started_at = ?, ended_at = ? WHERE id = ?`, started, endedAt.UTC(), id); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestTotalRuntimeForIssue(t *testing.T) {
+	s := newTestStore(t)
+	end := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	finishedRun(t, s, "issue-1", "plan", end, 90*time.Second, func(id int64) error { return s.CompleteRun(id, 0, "ok", "", "") })
+	finishedRun(t, s, "issue-1", "implement", end.Add(time.Hour), 5*time.Minute, func(id int64) error { return s.FailRun(id, 1, "boom") })
+	finishedRun(t, s, "issue-1", "implement", end.Add(2*time.Hour), 10*time.Minute, func(id int64) error { return s.TimeoutRun(id, "timed out") })
+	finishedRun(t, s, "issue-2", "plan", end, time.Hour, func(id int64) error { return s.CompleteRun(id, 0, "ok", "", "") })
+	// A run still in progress has no duration yet
+	if _, _, err := s.StartRun("issue-1", "review"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.TotalRuntimeForIssue("issue-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 90*time.Second + 15*time.Minute; got != want {
+		t.Errorf("TotalRuntimeForIssue = %s, want %s", got, want)
+	}
+	if got, err := s.TotalRuntimeForIssue("issue-3"); err != nil || got != 0 {
+		t.Errorf("TotalRuntimeForIssue for an issue without runs = %s, %v, want 0", got, err)
+	}
+}
+
+func TestGetRunsByPRURL(t *testing.T) {
+	s := newTestStore(t)
+	const pr1 = "https://github.com/acme/app/pull/1"
+	const pr2 = "https://github.com/acme/app/pull/2"
+	complete := func(issueID, stage, prURL string) int64 {
+		t.Helper()
+		id, _, err := s.StartRun(issueID, stage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CompleteRun(id, 0, "ok", prURL, "eng-branch"); err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	implement := complete("issue-1", "implement", pr1)
+	complete("issue-2", "implement", pr2)
+	review := complete("issue-1", "review", pr1)
+	complete("issue-3", "plan", "")
+
+	runs, err := s.GetRunsByPRURL(pr1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].ID != review || runs[1].ID != implement {
+		t.Fatalf("runs for %s = %+v, want review then implement", pr1, runs)
+	}
+	if r := runs[1]; r.IssueID != "issue-1" || r.StageName != "implement" || r.PRURL != pr1 || r.BranchName != "eng-branch" {
+		t.Errorf("run = %+v", r)
+	}
+
+	if runs, err := s.GetRunsByPRURL("https://github.com/acme/app/pull/3"); err != nil || len(runs) != 0 {
+		t.Errorf("runs for an unknown PR = %+v, %v; want none", runs, err)
+	}
+
+	var plan string
+	if err := s.db.QueryRow(`EXPLAIN QUERY PLAN SELECT id FROM runs WHERE pr_url = ?`, pr1).Scan(new(int), new(int), new(int), &plan); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(plan, "idx_runs_pr_url") {
+		t.Errorf("query plan %q does not use the pr_url index", plan)
+	}
+}
+
+func TestCycleCount(t *testing.T) {
+	s := newTestStore(t)
+	if got, err := s.CycleCount("issue-1"); err != nil || got != 0 {
+		t.Fatalf("CycleCount before any loop = %d, %v, want 0", got, err)
+	}
+	for want := 1; want <= 3; want++ {
+		got, err := s.IncrementCycleCount("issue-1")
+		if err != nil || got != want {
+			t.Fatalf("IncrementCycleCount = %d, %v, want %d", got, err, want)
+		}
+	}
+	if got, _ := s.CycleCount("issue-1"); got != 3 {
+		t.Errorf("CycleCount = %d, want 3", got)
+	}
+	if got, _ := s.CycleCount("issue-2"); got != 0 {
+		t.Errorf("another issue's CycleCount = %d, want 0", got)
+	}
+}
+
+func TestIsStageReentry(t *testing.T) {
+	s := newTestStore(t)
+	complete := func(id int64) error { return s.CompleteRun(id, 0, "", "", "") }
+	reentry := func(stage string) bool {
+		t.Helper()
+		r, err := s.IsStageReentry("issue-1", stage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	if reentry("plan") {
+		t.Error("first run of plan counted as a re-entry")
+	}
+	finishedRun(t, s, "issue-1", "plan", time.Now(), time.Second, complete)
+	if reentry("plan") {
+		t.Error("plan right after plan counted as a re-entry")
+	}
+	finishedRun(t, s, "issue-1", "review", time.Now(), time.Second, complete)
+	if !reentry("plan") {
+		t.Error("plan after review was not a re-entry")
+	}
+	if reentry("implement") {
+		t.Error("a stage never run counted as a re-entry")
+	}
+}
+
+func TestSkippedRunsAreNotSuccesses(t *testing.T) {
+	s := newTestStore(t)
+	end := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	done := finishedRun(t, s, "issue-1", "implement", end, time.Minute, func(id int64) error {
+		return s.CompleteRun(id, 0, "ok", "https://github.com/acme/app/pull/1", "eng-1-done")
+	})
+	skipped := finishedRun(t, s, "issue-1", "implement", end.Add(time.Hour), time.Hour, func(id int64) error {
+		return s.SkipRun(id, "https://github.com/acme/app/pull/2", "eng-1-skipped")
+	})
+
+	run, err := s.GetRun(skipped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != "skipped" || run.ExitCode == nil || *run.ExitCode != 2 {
+		t.Errorf("skipped run = %s with exit code %v, want status skipped and exit code 2", run.Status, run.ExitCode)
+	}
+	if statuses, err := s.RecentRunStatuses("issue-1", "implement", 5); err != nil || strings.Join(statuses, ",") != "skipped,completed" {
+		t.Errorf("RecentRunStatuses = %q, %v; want skipped then completed", statuses, err)
+	}
+
+	if avg, err := s.AverageDuration("implement"); err != nil || avg != time.Minute {
+		t.Errorf("AverageDuration = %s, %v; want 1m from the successful run alone", avg, err)
+	}
+	if info, err := s.GetLastCompletedRun("issue-1", "implement"); err != nil || info == nil || info.ID != done {
+		t.Errorf("GetLastCompletedRun = %+v, %v; want the successful run %d", info, err, done)
+	}
+	if info, err := s.GetBranchForIssue("issue-1"); err != nil || info == nil || info.BranchName != "eng-1-done" {
+		t.Errorf("GetBranchForIssue = %+v, %v; want the successful run's branch", info, err)
+	}
+	if info, err := s.GetPreviousBranchForIssue("issue-1", "review"); err != nil || info == nil || info.BranchName != "eng-1-done" {
+		t.Errorf("GetPreviousBranchForIssue = %+v, %v; want the successful run's branch", info, err)
+	}
+}
+
+func TestStateEnteredAt(t *testing.T) {
+	s := newTestStore(t)
+	entered := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	if got, err := s.StateEnteredAt("issue-1", "Todo"); err != nil || got != nil {
+		t.Fatalf("StateEnteredAt before recording = %v, %v; want nil", got, err)
+	}
+	if err := s.RecordStateEntry("issue-1", "Todo", entered); err != nil {
+		t.Fatal(err)
+	}
+	// A later sighting keeps the recorded entry time
+	if err := s.RecordStateSeen("issue-1", "Todo", entered.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.StateEnteredAt("issue-1", "Todo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || !got.Equal(entered) {
+		t.Errorf("StateEnteredAt = %v, want %v", got, entered)
+	}
+
+	// Entering another state forgets the previous one
+	if err := s.RecordStateEntry("issue-1", "In Progress", entered.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.StateEnteredAt("issue-1", "Todo"); err != nil || got != nil {
+		t.Errorf("StateEnteredAt(Todo) after leaving = %v, %v; want nil", got, err)
+	}
+	if got, err := s.StateEnteredAt("issue-1", "In Progress"); err != nil || got == nil || !got.Equal(entered.Add(2*time.Hour)) {
+		t.Errorf("StateEnteredAt(In Progress) = %v, %v", got, err)
+	}
+}
+
+func TestAverageDuration(t *testing.T) {
+	s := newTestStore(t)
+	end := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	complete := func(id int64) error { return s.CompleteRun(id, 0, "ok", "", "") }
+
+	if got, err := s.AverageDuration("plan"); err != nil || got != 0 {
+		t.Fatalf("AverageDuration without runs = %s, %v, want 0", got, err)
+	}
+	finishedRun(t, s, "issue-1", "plan", end, 2*time.Minute, complete)
+	finishedRun(t, s, "issue-2", "plan", end.Add(time.Hour), 4*time.Minute, complete)
+	// Only successful runs of the stage count
+	finishedRun(t, s, "issue-3", "plan", end.Add(2*time.Hour), time.Hour, func(id int64) error { return s.FailRun(id, 1, "boom") })
+	finishedRun(t, s, "issue-4", "plan", end.Add(3*time.Hour), time.Hour, func(id int64) error { return s.CompleteRun(id, 2, "", "", "") })
+	finishedRun(t, s, "issue-1", "implement", end, time.Hour, complete)
+
+	got, err := s.AverageDuration("plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 3 * time.Minute; got != want {
+		t.Errorf("AverageDuration = %s, want %s", got, want)
+	}
+}
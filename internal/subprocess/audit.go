@@ -0,0 +1,57 @@
+package subprocess
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+)
+
+// StdinSchemaVersion identifies the shape of the stdin JSON object. Bump it
+// when fields are renamed or removed.
+const StdinSchemaVersion = 1
+
+// InputAudit describes what context a run handed its command, without the
+// values themselves: the prompt only as a hash, and variables and stdin
+// fields only by name.
+type InputAudit struct {
+	Command            string   `json:"command"`
+	ContextMode        string   `json:"context_mode"`
+	PromptSHA256       string   `json:"prompt_sha256"`
+	EnvVars            []string `json:"env_vars"`                       // AIFLOW_* names; the inherited environment isn't listed
+	StdinSchemaVersion int      `json:"stdin_schema_version,omitempty"` // 0 when nothing was piped
+	StdinFields        []string `json:"stdin_fields,omitempty"`
+}
+
+// Audit describes the context Run passes to the command for input.
+func Audit(input Input) InputAudit {
+	prompt := composePrompt(input)
+	sum := sha256.Sum256([]byte(prompt))
+	audit := InputAudit{
+		Command:      input.Command,
+		ContextMode:  input.ContextMode,
+		PromptSHA256: hex.EncodeToString(sum[:]),
+	}
+	for _, kv := range aiflowEnv(input, prompt) {
+		name, _, _ := strings.Cut(kv, "=")
+		audit.EnvVars = append(audit.EnvVars, name)
+	}
+	if input.ContextMode == "stdin" || input.ContextMode == "both" {
+		audit.StdinSchemaVersion = StdinSchemaVersion
+		for field := range buildStdin(input) {
+			audit.StdinFields = append(audit.StdinFields, field)
+		}
+		slices.Sort(audit.StdinFields)
+	}
+	if input.ContextMode == "stdin-ndjson" {
+		audit.StdinSchemaVersion = StdinSchemaVersion
+		audit.StdinFields = []string{"type"}
+		for field := range buildStdin(input) {
+			if field != "comments" {
+				audit.StdinFields = append(audit.StdinFields, field)
+			}
+		}
+		slices.Sort(audit.StdinFields)
+	}
+	return audit
+}
@@ -0,0 +1,17 @@
+package subprocess
+
+// Limits lower the priority of, and cap the memory of, every command the
+// Runner starts. They're in place before the command runs, and are only
+// supported on Linux (see LimitsSupported); elsewhere they're ignored.
+type Limits struct {
+	// Nice is the command's nice value, 1 (slightly lower priority) to 19
+	// (lowest). Negative values need privileges. 0 leaves it unchanged.
+	Nice int
+
+	// MaxMemoryBytes caps the command's address space (RLIMIT_AS), which
+	// its child processes inherit. 0 means no cap.
+	MaxMemoryBytes uint64
+}
+
+// SetLimits sets the resource limits applied to each command.
+func (r *Runner) SetLimits(l Limits) { r.limits = l }
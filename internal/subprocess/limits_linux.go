@@ -0,0 +1,39 @@
+package subprocess
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// LimitsSupported reports whether Limits take effect on this platform.
+const LimitsSupported = true
+
+// limitCommand makes cmd start through sh, which caps its address space with
+// ulimit and execs it under nice, so the limits are in place before the
+// command runs. It must be called before cmd is started.
+func limitCommand(cmd *exec.Cmd, l Limits) error {
+	if cmd.Err != nil || (l.Nice == 0 && l.MaxMemoryBytes == 0) {
+		return nil
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		return fmt.Errorf("finding sh to apply limits: %w", err)
+	}
+
+	var script strings.Builder
+	if l.MaxMemoryBytes > 0 {
+		// ulimit -v counts KiB; a cap that can't be set is reported but
+		// doesn't stop the run
+		fmt.Fprintf(&script, `ulimit -v %d || echo "ai-flow: cannot set memory limit" >&2; `, max(l.MaxMemoryBytes>>10, 1))
+	}
+	script.WriteString("exec ")
+	if l.Nice != 0 {
+		fmt.Fprintf(&script, "nice -n %d ", l.Nice)
+	}
+	script.WriteString(`"$0" "$@"`)
+
+	cmd.Args = append([]string{"sh", "-c", script.String(), cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = sh
+	return nil
+}
@@ -0,0 +1,50 @@
+package subprocess
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitsAppliedBeforeCommandRuns(t *testing.T) {
+	r := NewRunner(1)
+	r.SetLimits(Limits{Nice: 7, MaxMemoryBytes: 512 << 20})
+
+	// The command's first action reads its own attributes, so a limit set
+	// after it started would be missed
+	result, err := r.Run(context.Background(), shInput(`cut -d' ' -f19 /proc/$$/stat; ulimit -v`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Fields(result.Stdout); len(got) != 2 || got[0] != "7" || got[1] != "524288" {
+		t.Errorf("nice and address space cap (KiB) = %q, want [7 524288]", got)
+	}
+}
+
+func TestLimitsKeepArguments(t *testing.T) {
+	r := NewRunner(1)
+	r.SetLimits(Limits{Nice: 1})
+
+	input := shInput(`printf '%s|' "$0" "$@"`)
+	input.Args = append(input.Args, "name", "two words", "$HOME")
+	result, err := r.Run(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "name|two words|$HOME|"; result.Stdout != want {
+		t.Errorf("arguments = %q, want %q", result.Stdout, want)
+	}
+}
+
+func TestLimitsMissingCommandIsStartError(t *testing.T) {
+	r := NewRunner(1)
+	r.SetLimits(Limits{Nice: 5})
+
+	_, err := r.Run(context.Background(), Input{Command: "ai-flow-no-such-command", PromptArg: "none", Timeout: time.Minute})
+	var startErr *StartError
+	if !errors.As(err, &startErr) {
+		t.Errorf("err = %v, want a StartError", err)
+	}
+}
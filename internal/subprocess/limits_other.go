@@ -0,0 +1,14 @@
+//go:build !linux
+
+package subprocess
+
+import "os/exec"
+
+// LimitsSupported reports whether Limits take effect on this platform.
+const LimitsSupported = false
+
+// limitCommand does nothing: starting commands under nice and an address
+// space cap is only implemented on Linux.
+func limitCommand(cmd *exec.Cmd, l Limits) error {
+	return nil
+}
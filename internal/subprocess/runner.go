@@ -3,13 +3,23 @@ package subprocess
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"os/exec"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/mauza/ai-flow/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // OutputTracker receives live output from subprocesses.
@@ -38,8 +48,11 @@ func (w *limitedWriter) Write(p []byte) (int, error) {
 		return len(p), nil // discard silently to avoid blocking subprocess
 	}
 	if len(p) > remaining {
+		// Report the whole write as done; a short count would make
+		// io.MultiWriter fail the copy with io.ErrShortWrite
 		w.dropped += len(p) - remaining
-		p = p[:remaining]
+		w.buf.Write(p[:remaining])
+		return len(p), nil
 	}
 	return w.buf.Write(p)
 }
@@ -73,6 +86,7 @@ type Input struct {
 	IssueURL         string
 	IssueState       string
 	IssueLabels      []string
+	IssuePriority    string // "urgent", "high", "medium", "low", or "none"
 
 	// Stage config
 	StageName   string
@@ -81,15 +95,45 @@ type Input struct {
 	Command     string
 	Args        []string
 	Timeout     time.Duration
-	ContextMode string // "env", "stdin", "both"
+	ContextMode string // "env", "stdin", "both", "stdin-ndjson"
+	PromptArg   string // "positional" (default), "none", or a flag such as "--prompt"
+	Model       string // passed through as AIFLOW_MODEL for commands that route by model
+	Provider    string // passed through as AIFLOW_PROVIDER
+
+	// Extra holds linear.extra_issue_fields values, sent as "extra" on stdin
+	Extra map[string]any
+
+	// CycleCount is how many times the issue has looped back to a stage it
+	// already ran
+	CycleCount int
+
+	// CheckpointFile is where the command may save progress to resume from
+	// on a retry; it survives failed runs
+	CheckpointFile string
+
+	// AttachmentsDir holds the issue's downloaded attachments (git stages
+	// with workspace.download_attachments)
+	AttachmentsDir string
 
 	// Git context (set when stage creates a PR)
 	WorkDir    string
 	BranchName string
+	PRURL      string // existing PR for the branch, if one is known (e.g. on re-runs)
 
 	// Comments from the issue (filtered, human-only)
 	Comments []Comment
 
+	// ReviewOutput is the stdout of the pass under review (set for review passes)
+	ReviewOutput string
+
+	// LiveOutput optionally receives stdout and stderr as they are produced
+	// (e.g. for progress heartbeats). It must be safe for concurrent writes.
+	LiveOutput io.Writer
+
+	// OnStart, if set, is called once the run holds a concurrency slot,
+	// just before the command starts.
+	OnStart func()
+
 	// Project context (set when processing project pipeline)
 	ProjectID          string
 	ProjectName        string
@@ -104,12 +148,17 @@ type Result struct {
 	ExitCode int
 	Stdout   string
 	Stderr   string
+
+	// Bytes dropped from Stdout/Stderr after hitting the 1 MB capture limit.
+	StdoutTruncated int
+	StderrTruncated int
 }
 
 // Runner manages subprocess execution with concurrency control.
 type Runner struct {
 	sem     chan struct{}
 	tracker OutputTracker // optional, set via SetTracker
+	limits  Limits        // optional, set via SetLimits
 }
 
 // NewRunner creates a runner with the given max concurrency.
@@ -124,6 +173,35 @@ func (r *Runner) SetTracker(t OutputTracker) { r.tracker = t }
 
 // Run executes a subprocess with the given input, respecting concurrency limits.
 func (r *Runner) Run(ctx context.Context, input Input) (*Result, error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "subprocess "+input.StageName, trace.WithAttributes(
+		attribute.String("command", input.Command),
+		attribute.String("issue", input.IssueIdentifier),
+	))
+	result, err := r.run(ctx, input)
+	if result != nil {
+		span.SetAttributes(attribute.Int("exit_code", result.ExitCode))
+	}
+	telemetry.End(span, err)
+	return result, err
+}
+
+// commandArgs returns the configured args with the prompt added as
+// input.PromptArg asks: as the final arg, after a flag, or not at all (the
+// command reads it from AIFLOW_PROMPT or stdin instead).
+func commandArgs(input Input, prompt string) []string {
+	args := make([]string, len(input.Args), len(input.Args)+2)
+	copy(args, input.Args)
+	switch input.PromptArg {
+	case "", "positional":
+		return append(args, prompt)
+	case "none":
+		return args
+	default:
+		return append(args, input.PromptArg, prompt)
+	}
+}
+
+func (r *Runner) run(ctx context.Context, input Input) (*Result, error) {
 	// Acquire semaphore
 	select {
 	case r.sem <- struct{}{}:
@@ -131,6 +209,9 @@ func (r *Runner) Run(ctx context.Context, input Input) (*Result, error) {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	}
+	if input.OnStart != nil {
+		input.OnStart()
+	}
 
 	// Build timeout context
 	ctx, cancel := context.WithTimeout(ctx, input.Timeout)
@@ -146,12 +227,7 @@ func (r *Runner) Run(ctx context.Context, input Input) (*Result, error) {
 		defer r.tracker.TrackEnd(input.RunID)
 	}
 
-	// Build command args: configured args + composed prompt as final arg
-	args := make([]string, len(input.Args))
-	copy(args, input.Args)
-	args = append(args, composedPrompt)
-
-	cmd := exec.CommandContext(ctx, input.Command, args...)
+	cmd := exec.CommandContext(ctx, input.Command, commandArgs(input, composedPrompt)...)
 
 	// Set working directory for git-managed runs
 	if input.WorkDir != "" {
@@ -163,45 +239,63 @@ func (r *Runner) Run(ctx context.Context, input Input) (*Result, error) {
 
 	stdout := &limitedWriter{limit: maxOutputBytes}
 	stderr := &limitedWriter{limit: maxOutputBytes}
+	if input.LiveOutput != nil {
+		stdoutExtra = io.MultiWriter(stdoutExtra, input.LiveOutput)
+		stderrExtra = io.MultiWriter(stderrExtra, input.LiveOutput)
+	}
 	cmd.Stdout = io.MultiWriter(stdout, stdoutExtra)
 	cmd.Stderr = io.MultiWriter(stderr, stderrExtra)
 
 	// Optionally pipe JSON to stdin
 	if input.ContextMode == "stdin" || input.ContextMode == "both" {
-		stdinMap := map[string]any{
-			"issue_id":          input.IssueID,
-			"issue_identifier":  input.IssueIdentifier,
-			"issue_title":       input.IssueTitle,
-			"issue_description": input.IssueDescription,
-			"issue_url":         input.IssueURL,
-			"issue_state":       input.IssueState,
-			"issue_labels":      input.IssueLabels,
-			"stage_name":        input.StageName,
-			"next_state":        input.NextState,
-			"prompt":            input.Prompt,
-		}
-		if len(input.Comments) > 0 {
-			stdinMap["comments"] = input.Comments
-		}
-		stdinData, err := json.Marshal(stdinMap)
+		stdinData, err := json.Marshal(buildStdin(input))
 		if err != nil {
 			return nil, fmt.Errorf("marshaling stdin: %w", err)
 		}
 		cmd.Stdin = bytes.NewReader(stdinData)
 	}
 
-	err := cmd.Run()
+	// Or stream it one record per line, so the whole context is never held
+	// in one blob; stdin is closed after the last record
+	if input.ContextMode == "stdin-ndjson" {
+		pr, pw := io.Pipe()
+		written := make(chan struct{})
+		go func() {
+			defer close(written)
+			pw.CloseWithError(writeNDJSON(pw, input))
+		}()
+		cmd.Stdin = pr
+		// The command may exit without reading every record; closing the
+		// read side unblocks the writer so it doesn't outlive the run
+		defer func() {
+			pr.Close()
+			<-written
+		}()
+	}
+
+	if err := limitCommand(cmd, r.limits); err != nil {
+		slog.Warn("applying subprocess limits", "error", err, "issue", input.IssueIdentifier, "stage", input.StageName)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, &StartError{Command: input.Command, Err: err}
+	}
+	err := cmd.Wait()
 
 	result := &Result{
-		Stdout: stdout.String(),
-		Stderr: stderr.String(),
+		Stdout:          stdout.String(),
+		Stderr:          stderr.String(),
+		StdoutTruncated: stdout.dropped,
+		StderrTruncated: stderr.dropped,
 	}
 
 	if err != nil {
+		// Check the deadline first: a command killed on timeout also
+		// reports an ExitError
+		if ctx.Err() == context.DeadlineExceeded {
+			return result, fmt.Errorf("%w after %s", ErrTimeout, input.Timeout)
+		}
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			result.ExitCode = exitErr.ExitCode()
-		} else if ctx.Err() == context.DeadlineExceeded {
-			return result, fmt.Errorf("subprocess timed out after %s", input.Timeout)
 		} else {
 			return result, fmt.Errorf("executing subprocess: %w", err)
 		}
@@ -210,6 +304,47 @@ func (r *Runner) Run(ctx context.Context, input Input) (*Result, error) {
 	return result, nil
 }
 
+// ErrTimeout is returned (wrapped) by Run when the command is still running
+// when its timeout, or the caller's deadline, expires.
+var ErrTimeout = errors.New("subprocess timed out")
+
+// StartError is returned by Run when the command could not be started at all,
+// e.g. it is not installed or not executable. No Result is returned with it.
+type StartError struct {
+	Command string
+	Err     error
+}
+
+func (e *StartError) Error() string {
+	return fmt.Sprintf("starting %s: %v", e.Command, e.Err)
+}
+
+func (e *StartError) Unwrap() error { return e.Err }
+
+// InputHash fingerprints what a run would be given: the command, its args,
+// the composed prompt, and workspaceRev (the checked-out commit, if any).
+// Run-specific fields like RunID and WorkDir are left out, as are the stage's
+// own earlier comments: they are what a previous run produced, not new input.
+func InputHash(input Input, workspaceRev string) string {
+	own := "**ai-flow: stage `" + input.StageName + "`"
+	input.Comments = slices.DeleteFunc(slices.Clone(input.Comments), func(c Comment) bool {
+		return strings.HasPrefix(c.Body, own)
+	})
+
+	h := sha256.New()
+	for _, part := range append([]string{input.Command, input.ContextMode, input.Model, input.Provider, workspaceRev, composePrompt(input)}, input.Args...) {
+		fmt.Fprintf(h, "%d:%s\n", len(part), part)
+	}
+	if len(input.Extra) > 0 {
+		extra, _ := json.Marshal(input.Extra) // map keys marshal sorted
+		fmt.Fprintf(h, "%d:%s\n", len(extra), extra)
+	}
+	if input.CycleCount > 0 {
+		fmt.Fprintf(h, "cycle:%d\n", input.CycleCount)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 func composePrompt(input Input) string {
 	// Project pipeline mode: different prompt composition
 	if input.ProjectID != "" {
@@ -237,6 +372,11 @@ func composePrompt(input Input) string {
 		}
 	}
 
+	if input.ReviewOutput != "" {
+		b.WriteString("\n\n---\n\nOutput to review:\n")
+		b.WriteString(input.ReviewOutput)
+	}
+
 	return b.String()
 }
 
@@ -261,30 +401,127 @@ func composeProjectPrompt(input Input) string {
 	return b.String()
 }
 
+// buildStdin returns the JSON object piped to the command in the stdin and
+// both context modes.
+func buildStdin(input Input) map[string]any {
+	stdinMap := map[string]any{
+		"issue_id":          input.IssueID,
+		"issue_identifier":  input.IssueIdentifier,
+		"issue_title":       input.IssueTitle,
+		"issue_description": input.IssueDescription,
+		"issue_url":         input.IssueURL,
+		"issue_state":       input.IssueState,
+		"issue_labels":      input.IssueLabels,
+		"issue_priority":    input.IssuePriority,
+		"stage_name":        input.StageName,
+		"next_state":        input.NextState,
+		"prompt":            input.Prompt,
+		"cycle_count":       input.CycleCount,
+	}
+	if len(input.Comments) > 0 {
+		stdinMap["comments"] = input.Comments
+	}
+	if input.PRURL != "" {
+		stdinMap["pr_url"] = input.PRURL
+		if n := prNumber(input.PRURL); n != "" {
+			stdinMap["pr_number"] = n
+		}
+	}
+	if input.ReviewOutput != "" {
+		stdinMap["review_output"] = input.ReviewOutput
+	}
+	if input.CheckpointFile != "" {
+		stdinMap["checkpoint_file"] = input.CheckpointFile
+	}
+	if input.AttachmentsDir != "" {
+		stdinMap["attachments_dir"] = input.AttachmentsDir
+	}
+	if input.Model != "" {
+		stdinMap["model"] = input.Model
+	}
+	if input.Provider != "" {
+		stdinMap["provider"] = input.Provider
+	}
+	if len(input.Extra) > 0 {
+		stdinMap["extra"] = input.Extra
+	}
+	return stdinMap
+}
+
+// writeNDJSON writes input's stdin context as newline-delimited JSON: an
+// "issue" record with the fields of the stdin object except comments, then
+// one "comment" record per comment.
+func writeNDJSON(w io.Writer, input Input) error {
+	enc := json.NewEncoder(w)
+	issue := buildStdin(input)
+	delete(issue, "comments")
+	issue["type"] = "issue"
+	if err := enc.Encode(issue); err != nil {
+		return err
+	}
+	for _, c := range input.Comments {
+		record := struct {
+			Type string `json:"type"`
+			Comment
+		}{"comment", c}
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func buildEnv(input Input, composedPrompt string) []string {
-	// Inherit the parent process environment
-	env := os.Environ()
-
-	// Append AIFLOW-specific variables
-	env = append(env,
-		"AIFLOW_ISSUE_ID="+input.IssueID,
-		"AIFLOW_ISSUE_IDENTIFIER="+input.IssueIdentifier,
-		"AIFLOW_ISSUE_TITLE="+input.IssueTitle,
-		"AIFLOW_ISSUE_DESCRIPTION="+input.IssueDescription,
-		"AIFLOW_ISSUE_URL="+input.IssueURL,
-		"AIFLOW_ISSUE_STATE="+input.IssueState,
-		"AIFLOW_ISSUE_LABELS="+strings.Join(input.IssueLabels, ","),
-		"AIFLOW_STAGE_NAME="+input.StageName,
-		"AIFLOW_NEXT_STATE="+input.NextState,
-		"AIFLOW_PROMPT="+composedPrompt,
-	)
+	// Inherit the parent process environment, then add AIFLOW-specific variables
+	return append(os.Environ(), aiflowEnv(input, composedPrompt)...)
+}
+
+// aiflowEnv returns the AIFLOW_* variables set for a run.
+func aiflowEnv(input Input, composedPrompt string) []string {
+	env := []string{
+		"AIFLOW_ISSUE_ID=" + input.IssueID,
+		"AIFLOW_ISSUE_IDENTIFIER=" + input.IssueIdentifier,
+		"AIFLOW_ISSUE_TITLE=" + input.IssueTitle,
+		"AIFLOW_ISSUE_DESCRIPTION=" + input.IssueDescription,
+		"AIFLOW_ISSUE_URL=" + input.IssueURL,
+		"AIFLOW_ISSUE_STATE=" + input.IssueState,
+		"AIFLOW_ISSUE_LABELS=" + strings.Join(input.IssueLabels, ","),
+		"AIFLOW_ISSUE_PRIORITY=" + input.IssuePriority,
+		"AIFLOW_STAGE_NAME=" + input.StageName,
+		"AIFLOW_NEXT_STATE=" + input.NextState,
+		"AIFLOW_PROMPT=" + composedPrompt,
+		"AIFLOW_CYCLE_COUNT=" + strconv.Itoa(input.CycleCount),
+	}
 	if input.WorkDir != "" {
 		env = append(env, "AIFLOW_WORK_DIR="+input.WorkDir)
 	}
+	if input.CheckpointFile != "" {
+		env = append(env, "AIFLOW_CHECKPOINT_FILE="+input.CheckpointFile)
+	}
+	if input.AttachmentsDir != "" {
+		env = append(env, "AIFLOW_ATTACHMENTS_DIR="+input.AttachmentsDir)
+	}
 	if input.BranchName != "" {
 		env = append(env, "AIFLOW_BRANCH="+input.BranchName)
 	}
-	if len(input.Comments) > 0 {
+	if input.PRURL != "" {
+		env = append(env, "AIFLOW_PR_URL="+input.PRURL)
+		if n := prNumber(input.PRURL); n != "" {
+			env = append(env, "AIFLOW_PR_NUMBER="+n)
+		}
+	}
+	if input.ReviewOutput != "" {
+		env = append(env, "AIFLOW_REVIEW_OUTPUT="+input.ReviewOutput)
+	}
+	if input.Model != "" {
+		env = append(env, "AIFLOW_MODEL="+input.Model)
+	}
+	if input.Provider != "" {
+		env = append(env, "AIFLOW_PROVIDER="+input.Provider)
+	}
+	// stdin-ndjson streams comments on stdin instead, since long histories
+	// can exceed the environment size limit
+	if len(input.Comments) > 0 && input.ContextMode != "stdin-ndjson" {
 		if commentsJSON, err := json.Marshal(input.Comments); err == nil {
 			env = append(env, "AIFLOW_COMMENTS="+string(commentsJSON))
 		}
@@ -303,3 +540,23 @@ func buildEnv(input Input, composedPrompt string) []string {
 	}
 	return env
 }
+
+// prNumber extracts the PR number from a GitHub PR URL
+// (https://github.com/owner/repo/pull/123 → "123"), or returns "".
+func prNumber(prURL string) string {
+	_, after, ok := strings.Cut(prURL, "/pull/")
+	if !ok {
+		return ""
+	}
+	after, _, _ = strings.Cut(after, "/")
+	after, _, _ = strings.Cut(after, "#")
+	if after == "" {
+		return ""
+	}
+	for _, r := range after {
+		if r < '0' || r > '9' {
+			return ""
+		}
+	}
+	return after
+}
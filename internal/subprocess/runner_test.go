@@ -0,0 +1,258 @@
+package subprocess
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPRContextOnlyWhenKnown(t *testing.T) {
+	first := Input{IssueID: "issue-1", StageName: "implement", BranchName: "eng-1-fix"}
+	rerun := first
+	rerun.PRURL = "https://github.com/acme/app/pull/42"
+
+	if stdin := buildStdin(first); stdin["pr_url"] != nil || stdin["pr_number"] != nil {
+		t.Errorf("first run stdin has PR fields: %v", stdin)
+	}
+	env := buildEnv(first, "")
+	for _, v := range env {
+		if strings.HasPrefix(v, "AIFLOW_PR_") {
+			t.Errorf("first run env has %s", v)
+		}
+	}
+
+	stdin := buildStdin(rerun)
+	if stdin["pr_url"] != rerun.PRURL || stdin["pr_number"] != "42" {
+		t.Errorf("re-run stdin pr_url=%v pr_number=%v", stdin["pr_url"], stdin["pr_number"])
+	}
+	env = buildEnv(rerun, "")
+	for _, want := range []string{"AIFLOW_PR_URL=" + rerun.PRURL, "AIFLOW_PR_NUMBER=42"} {
+		if !slices.Contains(env, want) {
+			t.Errorf("re-run env is missing %s", want)
+		}
+	}
+}
+
+func TestPRNumber(t *testing.T) {
+	for url, want := range map[string]string{
+		"https://github.com/acme/app/pull/42":               "42",
+		"https://github.com/acme/app/pull/7/files":          "7",
+		"https://github.com/acme/app/pull/7#issuecomment-1": "7",
+		"https://github.com/acme/app/issues/3":              "",
+		"":                                                  "",
+	} {
+		if got := prNumber(url); got != want {
+			t.Errorf("prNumber(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+// shInput runs script with sh, with no prompt argument.
+func shInput(script string) Input {
+	return Input{
+		Command:   "sh",
+		Args:      []string{"-c", script},
+		PromptArg: "none",
+		Timeout:   time.Minute,
+	}
+}
+
+func TestRunReportsTruncatedOutput(t *testing.T) {
+	const extra = 4096
+	script := "head -c " + strconv.Itoa(maxOutputBytes+extra) + " /dev/zero | tr '\\0' x; echo short >&2"
+	result, err := NewRunner(1).Run(context.Background(), shInput(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.StdoutTruncated != extra || result.StderrTruncated != 0 {
+		t.Errorf("truncated stdout %d, stderr %d bytes; want %d and 0", result.StdoutTruncated, result.StderrTruncated, extra)
+	}
+	if !strings.HasSuffix(result.Stdout, "\n... (4096 bytes truncated)") {
+		t.Errorf("stdout does not end with the truncation note: ...%q", result.Stdout[len(result.Stdout)-40:])
+	}
+	if result.Stderr != "short\n" {
+		t.Errorf("stderr = %q", result.Stderr)
+	}
+}
+
+func TestLimitedWriterTruncatesWithoutShortWrite(t *testing.T) {
+	w := &limitedWriter{limit: 4}
+	if n, err := io.MultiWriter(w).Write([]byte("abcdef")); n != 6 || err != nil {
+		t.Fatalf("Write = %d, %v; want 6, nil", n, err)
+	}
+	if n, err := w.Write([]byte("gh")); n != 2 || err != nil {
+		t.Fatalf("Write past the limit = %d, %v; want 2, nil", n, err)
+	}
+	if got := w.String(); got != "abcd\n... (4 bytes truncated)" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestInputHashIgnoresStagesOwnComments(t *testing.T) {
+	input := shInput("echo planned")
+	input.StageName = "plan"
+	input.Prompt = "Plan it."
+	base := InputHash(input, "")
+
+	own := input
+	own.Comments = []Comment{{Author: "ai-flow", Body: "**ai-flow: stage `plan` completed**\n\nplanned"}}
+	if InputHash(own, "") != base {
+		t.Error("the stage's own output comment changed the hash")
+	}
+
+	for name, c := range map[string]Comment{
+		"human comment":       {Author: "Ada", Body: "Use OAuth."},
+		"other stage comment": {Author: "ai-flow", Body: "**ai-flow: stage `design` completed**\n\ndesigned"},
+	} {
+		changed := input
+		changed.Comments = []Comment{c}
+		if InputHash(changed, "") == base {
+			t.Errorf("%s did not change the hash", name)
+		}
+	}
+	if InputHash(input, "abc123") == base {
+		t.Error("a new workspace commit did not change the hash")
+	}
+}
+
+func TestRunDistinguishesStartFailuresFromTimeouts(t *testing.T) {
+	noExec := filepath.Join(t.TempDir(), "tool")
+	if err := os.WriteFile(noExec, []byte("#!/bin/sh\necho hi\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, command := range map[string]string{
+		"missing command":   "ai-flow-no-such-command",
+		"permission denied": noExec,
+	} {
+		result, err := NewRunner(1).Run(context.Background(), Input{Command: command, PromptArg: "none", Timeout: time.Minute})
+		var startErr *StartError
+		if !errors.As(err, &startErr) || startErr.Command != command {
+			t.Errorf("%s: err = %v, want a StartError for %s", name, err, command)
+		}
+		if errors.Is(err, ErrTimeout) || result != nil {
+			t.Errorf("%s: err = %v, result = %+v; want no timeout and no result", name, err, result)
+		}
+	}
+
+	input := shInput("exec sleep 5")
+	input.Timeout = 100 * time.Millisecond
+	_, err := NewRunner(1).Run(context.Background(), input)
+	var startErr *StartError
+	if !errors.Is(err, ErrTimeout) || errors.As(err, &startErr) {
+		t.Errorf("timeout: err = %v, want ErrTimeout", err)
+	}
+}
+
+func TestPromptArg(t *testing.T) {
+	// Print the argument count, the first argument, and whether
+	// AIFLOW_PROMPT is set; "sh" fills $0 so the prompt args start at $1
+	const script = `printf '%s\n' "$#" "$1" "${AIFLOW_PROMPT:+env}"`
+	tests := []struct {
+		promptArg string
+		argc      string
+		first     string // "" for the composed prompt
+	}{
+		{"positional", "1", ""},
+		{"", "1", ""},
+		{"--prompt", "2", "--prompt"},
+		{"none", "0", ""},
+	}
+	for _, tt := range tests {
+		input := shInput(script)
+		input.Args = append(input.Args, "sh")
+		input.Prompt = "Do the thing."
+		input.PromptArg = tt.promptArg
+		result, err := NewRunner(1).Run(context.Background(), input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(result.Stdout, "\n")
+		if len(lines) < 3 {
+			t.Fatalf("prompt_arg %q: output %q", tt.promptArg, result.Stdout)
+		}
+		first := tt.first
+		if first == "" && tt.argc != "0" {
+			first, _, _ = strings.Cut(composePrompt(input), "\n")
+		}
+		if lines[0] != tt.argc || lines[1] != first {
+			t.Errorf("prompt_arg %q: argc %s, first arg %q; want %s, %q", tt.promptArg, lines[0], lines[1], tt.argc, first)
+		}
+		if lines[len(lines)-2] != "env" {
+			t.Errorf("prompt_arg %q: AIFLOW_PROMPT not set", tt.promptArg)
+		}
+	}
+
+	args := commandArgs(Input{Args: []string{"run"}, PromptArg: "--prompt"}, "Do the thing.")
+	if !slices.Equal(args, []string{"run", "--prompt", "Do the thing."}) {
+		t.Errorf("commandArgs with --prompt = %q", args)
+	}
+}
+
+func TestStdinNDJSON(t *testing.T) {
+	input := shInput("cat")
+	input.ContextMode = "stdin-ndjson"
+	input.IssueIdentifier = "ENG-1"
+	input.Comments = []Comment{
+		{Author: "alice", Body: "first"},
+		{Author: "bob", Body: "second\nwith a newline"},
+	}
+	result, err := NewRunner(1).Run(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(result.Stdout, "\n"), "\n")
+	if len(lines) != 1+len(input.Comments) {
+		t.Fatalf("got %d lines, want one issue record and %d comment records:\n%s", len(lines), len(input.Comments), result.Stdout)
+	}
+	var issue map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &issue); err != nil {
+		t.Fatalf("issue record: %v", err)
+	}
+	if issue["type"] != "issue" || issue["issue_identifier"] != "ENG-1" || issue["comments"] != nil {
+		t.Errorf("issue record = %v, want type issue for ENG-1 without comments", issue)
+	}
+	for i, line := range lines[1:] {
+		var record struct {
+			Type string `json:"type"`
+			Comment
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("comment record %d: %v", i, err)
+		}
+		if record.Type != "comment" || record.Comment != input.Comments[i] {
+			t.Errorf("comment record %d = %+v, want %+v", i, record, input.Comments[i])
+		}
+	}
+}
+
+func TestStdinNDJSONIgnoredByCommand(t *testing.T) {
+	// More context than a pipe buffers, for a command that never reads it
+	input := shInput("echo done")
+	input.ContextMode = "stdin-ndjson"
+	for range 100 {
+		input.Comments = append(input.Comments, Comment{Author: "alice", Body: strings.Repeat("x", 1024)})
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := NewRunner(1).Run(context.Background(), input)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return for a command that ignores stdin")
+	}
+}
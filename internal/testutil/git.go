@@ -0,0 +1,158 @@
+package testutil
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Git gives a test its own git environment: a HOME whose global config sets
+// a commit identity and maps GitHub's SSH URLs onto local bare repos, so code
+// cloning git@github.com:owner/repo.git clones Remote(owner/repo) instead.
+type Git struct {
+	Home    string
+	remotes string
+}
+
+// NewGit sets up the environment for the rest of the test.
+func NewGit(t testing.TB) *Git {
+	t.Helper()
+	home := t.TempDir()
+	remotes := filepath.Join(home, "remotes")
+	config := "[user]\n\tname = Test\n\temail = test@example.com\n" +
+		"[init]\n\tdefaultBranch = main\n" +
+		"[protocol \"file\"]\n\tallow = always\n" +
+		"[url \"file://" + remotes + "/\"]\n\tinsteadOf = git@github.com:\n"
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	return &Git{Home: home, remotes: remotes}
+}
+
+// Remote creates the bare repo for "owner/name" with one commit on main and
+// returns its path.
+func (g *Git) Remote(t testing.TB, repo string) string {
+	t.Helper()
+	bare := filepath.Join(g.remotes, repo+".git")
+	RunGit(t, "", "init", "--quiet", "--bare", bare)
+	seed := t.TempDir()
+	RunGit(t, seed, "init", "--quiet")
+	if err := os.WriteFile(filepath.Join(seed, "README.md"), []byte("# "+repo+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	RunGit(t, seed, "add", "-A")
+	RunGit(t, seed, "commit", "--quiet", "-m", "initial commit")
+	RunGit(t, seed, "push", "--quiet", bare, "HEAD:main")
+	return bare
+}
+
+// Commit adds a commit writing content to file on branch of a bare repo.
+func (g *Git) Commit(t testing.TB, bare, branch, file, content string) {
+	t.Helper()
+	dir := t.TempDir()
+	RunGit(t, "", "clone", "--quiet", "--branch", branch, bare, dir)
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	RunGit(t, dir, "add", "-A")
+	RunGit(t, dir, "commit", "--quiet", "-m", "update "+file)
+	RunGit(t, dir, "push", "--quiet", "origin", branch)
+}
+
+// RunGit runs git in dir (the current directory if empty), failing the test
+// on error, and returns its trimmed output.
+func RunGit(t testing.TB, dir string, args ...string) string {
+	t.Helper()
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// GH is a fake gh CLI installed first on PATH. It records its arguments and
+// answers each "<command> <subcommand>" with a canned response: by default
+// "pr create" prints a PR URL, "pr view" fails as if there were no PR, and
+// anything else succeeds silently.
+type GH struct {
+	dir string
+}
+
+// DefaultPRURL is what the fake gh prints for "pr create" by default.
+const DefaultPRURL = "https://github.com/acme/app/pull/1"
+
+const ghScript = `#!/bin/sh
+d="$FAKE_GH_DIR"
+{ for a in "$@"; do printf '%s\037' "$a"; done; printf '\036'; } >> "$d/calls"
+f="$d/$1_$2"
+[ -f "$f.out" ] && cat "$f.out"
+[ -f "$f.err" ] && cat "$f.err" >&2
+[ -f "$f.exit" ] && exit "$(cat "$f.exit")"
+[ -f "$f.out" ] && exit 0
+case "$1 $2" in
+"pr create") echo "` + DefaultPRURL + `" ;;
+"pr view") echo "no pull requests found" >&2; exit 1 ;;
+esac
+exit 0
+`
+
+// NewGH installs the fake gh for the rest of the test.
+func NewGH(t testing.TB) *GH {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "gh"), []byte(ghScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FAKE_GH_DIR", dir)
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return &GH{dir: dir}
+}
+
+// Respond makes "gh <command> ..." (e.g. command "pr merge") print stdout
+// and stderr and exit with code.
+func (g *GH) Respond(t testing.TB, command, stdout, stderr string, code int) {
+	t.Helper()
+	base := filepath.Join(g.dir, strings.ReplaceAll(command, " ", "_"))
+	for ext, content := range map[string]string{".out": stdout, ".err": stderr, ".exit": strconv.Itoa(code)} {
+		if err := os.WriteFile(base+ext, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Calls returns the arguments of every gh invocation so far whose leading
+// arguments match prefix (all of them for an empty prefix).
+func (g *GH) Calls(prefix ...string) [][]string {
+	data, _ := os.ReadFile(filepath.Join(g.dir, "calls"))
+	var calls [][]string
+	for _, rec := range strings.Split(string(data), "\036") {
+		if rec == "" {
+			continue
+		}
+		args := strings.Split(strings.TrimSuffix(rec, "\037"), "\037")
+		if len(args) >= len(prefix) && slices.Equal(args[:len(prefix)], prefix) {
+			calls = append(calls, args)
+		}
+	}
+	return calls
+}
+
+// ArgValue returns the value following flag in args, or "".
+func ArgValue(args []string, flag string) string {
+	for i, a := range args {
+		if a == flag && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
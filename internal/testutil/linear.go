@@ -0,0 +1,358 @@
+// Package testutil provides fakes of the services ai-flow talks to (Linear,
+// GitHub's gh CLI, git remotes) for tests.
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mauza/ai-flow/internal/linear"
+)
+
+// TeamKey is the key of the team a fake Linear issue belongs to unless it
+// says otherwise.
+const TeamKey = "ENG"
+
+// Comment is a comment on a fake Linear issue.
+type Comment struct {
+	ID        string
+	IssueID   string
+	Body      string
+	User      string
+	CreatedAt time.Time
+	Edits     int // times commentUpdate replaced the body
+}
+
+// Linear is an in-memory fake of the parts of Linear's GraphQL API ai-flow
+// uses. Queries are told apart by the fields they select.
+type Linear struct {
+	URL string
+
+	// Handle, if set, is offered every request first; returning ok answers
+	// the request with data.
+	Handle func(req linear.GraphQLRequest) (data any, ok bool)
+
+	t         testing.TB
+	mu        sync.Mutex
+	states    []linear.WorkflowState
+	teams     map[string][]linear.WorkflowState // states of teams added with AddTeam
+	labels    []linear.IssueLabel
+	issues    map[string]*linear.IssueDetails
+	order     []string // issue IDs in creation order
+	comments  []*Comment
+	relatives map[string]linear.IssueRelatives
+	requests  []linear.GraphQLRequest
+	nextID    int
+}
+
+// NewLinear starts a fake Linear API with the given workflow states.
+func NewLinear(t testing.TB, states ...string) *Linear {
+	l := &Linear{
+		t:         t,
+		issues:    make(map[string]*linear.IssueDetails),
+		relatives: make(map[string]linear.IssueRelatives),
+		teams:     make(map[string][]linear.WorkflowState),
+	}
+	for _, name := range states {
+		l.AddState(name)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(l.serve))
+	t.Cleanup(srv.Close)
+	l.URL = srv.URL
+	return l
+}
+
+// Client returns a Linear client talking to the fake, with retries disabled.
+func (l *Linear) Client() *linear.Client {
+	c := linear.NewClient("test-key")
+	c.SetAPIURL(l.URL)
+	c.SetRetryPolicy(1, time.Millisecond)
+	return c
+}
+
+func (l *Linear) id(prefix string) string {
+	l.nextID++
+	return fmt.Sprintf("%s-%d", prefix, l.nextID)
+}
+
+// AddState adds a workflow state and returns its ID.
+func (l *Linear) AddState(name string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	id := l.id("state")
+	l.states = append(l.states, linear.WorkflowState{ID: id, Name: name, Type: "started"})
+	return id
+}
+
+// AddTeam adds a team with its own workflow states, whose IDs differ from
+// those of the same names in other teams. Teams not added share the states
+// given to NewLinear.
+func (l *Linear) AddTeam(key string, states ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, name := range states {
+		l.teams[key] = append(l.teams[key], linear.WorkflowState{ID: l.id("state"), Name: name, Type: "started"})
+	}
+}
+
+// StateID returns the ID of the named state, or "" if there is none.
+func (l *Linear) StateID(name string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stateID(TeamKey, name)
+}
+
+// teamStates returns the workflow states of a team.
+func (l *Linear) teamStates(team string) []linear.WorkflowState {
+	if states, ok := l.teams[team]; ok {
+		return states
+	}
+	return l.states
+}
+
+func (l *Linear) stateID(team, name string) string {
+	for _, s := range l.teamStates(team) {
+		if s.Name == name {
+			return s.ID
+		}
+	}
+	return ""
+}
+
+// LabelID returns the ID of the named label, creating the label if needed.
+func (l *Linear) LabelID(name string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.labelID(name)
+}
+
+func (l *Linear) labelID(name string) string {
+	for _, lb := range l.labels {
+		if lb.Name == name {
+			return lb.ID
+		}
+	}
+	id := l.id("label")
+	l.labels = append(l.labels, linear.IssueLabel{ID: id, Name: name})
+	return id
+}
+
+// AddIssue adds an issue. Only the names of its state and labels need to be
+// set; missing IDs, the team, and the identifier are filled in.
+func (l *Linear) AddIssue(issue linear.IssueDetails) *linear.IssueDetails {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if issue.ID == "" {
+		issue.ID = l.id("issue")
+	}
+	if issue.Identifier == "" {
+		issue.Identifier = fmt.Sprintf("%s-%d", TeamKey, len(l.order)+1)
+	}
+	if issue.Team.Key == "" {
+		issue.Team.Key = TeamKey
+	}
+	issue.Team.ID = "team-" + issue.Team.Key
+	if issue.URL == "" {
+		issue.URL = "https://linear.app/acme/issue/" + issue.Identifier
+	}
+	issue.State.ID = l.stateID(issue.Team.Key, issue.State.Name)
+	for i := range issue.Labels.Nodes {
+		issue.Labels.Nodes[i].ID = l.labelID(issue.Labels.Nodes[i].Name)
+	}
+	l.issues[issue.ID] = &issue
+	l.order = append(l.order, issue.ID)
+	copied := issue
+	return &copied
+}
+
+// Issue returns the current state of an issue.
+func (l *Linear) Issue(id string) linear.IssueDetails {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return *l.issues[id]
+}
+
+// MoveIssue puts an issue in the named state.
+func (l *Linear) MoveIssue(id, state string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.issues[id].State.ID = l.stateID(l.issues[id].Team.Key, state)
+	l.issues[id].State.Name = state
+}
+
+// SetRelatives sets the parent and sub-issues returned for an issue.
+func (l *Linear) SetRelatives(id string, rel linear.IssueRelatives) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.relatives[id] = rel
+}
+
+// AddComment adds a comment by user to an issue.
+func (l *Linear) AddComment(issueID, user, body string) *Comment {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.addComment(issueID, user, body)
+}
+
+func (l *Linear) addComment(issueID, user, body string) *Comment {
+	c := &Comment{ID: l.id("comment"), IssueID: issueID, Body: body, User: user, CreatedAt: time.Now()}
+	l.comments = append(l.comments, c)
+	return c
+}
+
+// Comments returns copies of the comments on an issue, oldest first.
+func (l *Linear) Comments(issueID string) []Comment {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []Comment
+	for _, c := range l.comments {
+		if c.IssueID == issueID {
+			out = append(out, *c)
+		}
+	}
+	return out
+}
+
+// Requests returns the requests whose query contains substr, oldest first.
+func (l *Linear) Requests(substr string) []linear.GraphQLRequest {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []linear.GraphQLRequest
+	for _, r := range l.requests {
+		if strings.Contains(r.Query, substr) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func (l *Linear) serve(w http.ResponseWriter, r *http.Request) {
+	var req linear.GraphQLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	l.mu.Lock()
+	l.requests = append(l.requests, req)
+	handle := l.Handle
+	l.mu.Unlock()
+
+	data, ok := any(nil), false
+	if handle != nil {
+		data, ok = handle(req)
+	}
+	if !ok {
+		l.mu.Lock()
+		data = l.answer(req)
+		l.mu.Unlock()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+// answer builds the response data for req. l.mu must be held.
+func (l *Linear) answer(req linear.GraphQLRequest) any {
+	q, vars := req.Query, req.Variables
+	str := func(name string) string { s, _ := vars[name].(string); return s }
+	switch {
+	case strings.Contains(q, "teams("):
+		return map[string]any{"teams": map[string]any{"nodes": []any{map[string]any{
+			"id":     "team-" + str("teamKey"),
+			"states": map[string]any{"nodes": l.teamStates(str("teamKey"))},
+			"labels": map[string]any{"nodes": l.labels},
+		}}}}
+	case strings.Contains(q, "commentCreate"):
+		c := l.addComment(str("issueId"), "ai-flow", str("body"))
+		return map[string]any{"commentCreate": map[string]any{"success": true, "comment": map[string]any{"id": c.ID}}}
+	case strings.Contains(q, "commentUpdate"):
+		for _, c := range l.comments {
+			if c.ID == str("id") {
+				c.Body = str("body")
+				c.Edits++
+			}
+		}
+		return map[string]any{"commentUpdate": map[string]any{"success": true}}
+	case strings.Contains(q, "attachmentLinkGitHubPR"):
+		return map[string]any{"attachmentLinkGitHubPR": map[string]any{"success": true}}
+	case strings.Contains(q, "issueUpdate"):
+		if issue, ok := l.issues[str("id")]; ok {
+			l.update(issue, q, vars)
+		}
+		return map[string]any{"issueUpdate": map[string]any{"success": true}}
+	case strings.Contains(q, "comments(orderBy"):
+		var nodes []any
+		for _, c := range l.comments {
+			if c.IssueID == str("id") {
+				nodes = append(nodes, map[string]any{
+					"id": c.ID, "body": c.Body, "createdAt": c.CreatedAt.Format(time.RFC3339Nano),
+					"user": map[string]any{"name": c.User},
+				})
+			}
+		}
+		return map[string]any{"issue": map[string]any{"comments": map[string]any{"nodes": nodes}}}
+	case strings.Contains(q, "children(first"):
+		rel := l.relatives[str("id")]
+		return map[string]any{"issue": map[string]any{"parent": rel.Parent, "children": map[string]any{"nodes": rel.Children}}}
+	case strings.Contains(q, ": issues("):
+		data := make(map[string]any)
+		for i := 0; ; i++ {
+			state, ok := vars[fmt.Sprintf("state%d", i)].(string)
+			if !ok {
+				break
+			}
+			var nodes []any
+			for _, id := range l.order {
+				issue := l.issues[id]
+				if issue.Team.Key == str("teamKey") && issue.State.Name == state {
+					nodes = append(nodes, issue)
+				}
+			}
+			data[fmt.Sprintf("s%d", i)] = map[string]any{"nodes": nodes}
+		}
+		return data
+	case strings.Contains(q, "issue(id:"):
+		if issue, ok := l.issues[str("id")]; ok {
+			return map[string]any{"issue": issue}
+		}
+		return map[string]any{"issue": nil}
+	}
+	l.t.Logf("fake Linear: unhandled query: %s", q)
+	return map[string]any{}
+}
+
+// update applies an issueUpdate mutation. l.mu must be held.
+func (l *Linear) update(issue *linear.IssueDetails, q string, vars map[string]any) {
+	if id, ok := vars["stateId"].(string); ok {
+		for _, s := range l.teamStates(issue.Team.Key) {
+			if s.ID == id {
+				issue.State.ID, issue.State.Name = s.ID, s.Name
+			}
+		}
+	}
+	if desc, ok := vars["description"].(string); ok {
+		issue.Description = desc
+	}
+	ids, _ := vars["labelIds"].([]any)
+	for _, v := range ids {
+		id, _ := v.(string)
+		switch {
+		case strings.Contains(q, "addedLabelIds"):
+			if !slices.ContainsFunc(issue.Labels.Nodes, func(lb linear.IssueLabel) bool { return lb.ID == id }) {
+				for _, lb := range l.labels {
+					if lb.ID == id {
+						issue.Labels.Nodes = append(issue.Labels.Nodes, lb)
+					}
+				}
+			}
+		case strings.Contains(q, "removedLabelIds"):
+			issue.Labels.Nodes = slices.DeleteFunc(issue.Labels.Nodes, func(lb linear.IssueLabel) bool { return lb.ID == id })
+		}
+	}
+}